@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+
+	"github.com/imranansari/hcl2-demo/datcfg"
+	"github.com/imranansari/hcl2-demo/internal/report"
+	"github.com/spf13/cobra"
+)
+
+// applyStateFile records the components applied by a run that failed
+// part-way, so that the next run resumes after them.
+const applyStateFile = ".datstate.json"
+
+type applyState struct {
+	Applied []string `json:"applied"`
+}
+
+func newApplyCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "apply",
+		Short: "Apply the components of the configuration",
+		Long: `Apply the components of the configuration, in the order of their
+dependencies. If a component fails, the components applied so far are
+recorded in ` + applyStateFile + `, and the next run resumes after them.`,
+		Args: cobra.NoArgs,
+	}
+	flags := cmd.Flags()
+	rollbackOnError := flags.Bool("rollback-on-error", false, "roll back the components applied in this run if one fails")
+	parallelism := flags.Int("parallelism", 10, "maximum number of components applied at the same time")
+	reportPath := addReportFlag(flags)
+	loaderFlags := addLoaderFlags(flags)
+	cmd.Run = statusRun(func(args []string) int {
+		return apply(*rollbackOnError, *parallelism, newRunReport(*reportPath, "apply", commandLine(cmd, args)), loaderFlags)
+	})
+	return cmd
+}
+
+// apply loads the configuration and applies its components, returning the
+// exit status.
+func apply(rollbackOnError bool, parallelism int, runReport *report.Report, loaderFlags *loaderFlags) int {
+	result, diags := newLoader(".", loaderFlags.options()...).Load()
+	runReport.AddDiags(diags)
+	if diags.HasErrors() {
+		printDiags(diags)
+		return runReport.Finish(1)
+	}
+	printDiags(diags)
+	runReport.AddConfig(result)
+
+	completed, err := readApplyState(applyStateFile, result)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return runReport.Finish(1)
+	}
+
+	ctx, cancel := interruptContext()
+	defer cancel()
+	outcomes, applyDiags := datcfg.ApplyComponents(ctx, result.Components, datcfg.ApplyOptions{
+		RollbackOnError: rollbackOnError,
+		Completed:       completed,
+		Parallelism:     parallelism,
+		Logger:          newApplyLogger(os.Stdout, result),
+	})
+	runReport.AddDiags(applyDiags)
+	if applyDiags.HasErrors() {
+		printDiags(applyDiags)
+		return runReport.Finish(1)
+	}
+
+	counts := map[datcfg.ApplyStatus]int{}
+	fmt.Printf("\nApply report:\n")
+	for _, outcome := range outcomes {
+		counts[outcome.Status]++
+		switch {
+		case outcome.Reason != "":
+			fmt.Printf("  %s: %s (%s)\n", outcome.Component, outcome.Status, outcome.Reason)
+		default:
+			fmt.Printf("  %s: %s after %d attempt(s)\n", outcome.Component, outcome.Status, outcome.Attempts)
+		}
+		printDiags(outcome.Diags)
+		runReport.Components = append(runReport.Components, report.Component{
+			Address:     outcome.Component,
+			Status:      string(outcome.Status),
+			DurationMS:  report.Milliseconds(outcome.Duration),
+			Attempts:    outcome.Attempts,
+			Reason:      outcome.Reason,
+			Diagnostics: report.Diagnostics(outcome.Diags),
+		})
+
+		switch outcome.Status {
+		case datcfg.ApplySucceeded:
+			completed[outcome.Component] = true
+		case datcfg.ApplyRolledBack:
+			delete(completed, outcome.Component)
+		}
+	}
+	fmt.Printf("%d succeeded, %d failed, %d rolled back, %d skipped.\n",
+		counts[datcfg.ApplySucceeded], counts[datcfg.ApplyFailed], counts[datcfg.ApplyRolledBack], counts[datcfg.ApplySkipped])
+
+	if ctx.Err() != nil {
+		fmt.Printf("Apply interrupted, %d of %d components applied. Run apply again to resume.\n", len(completed), len(outcomes))
+		if err := writeApplyState(applyStateFile, completed); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+		}
+		return runReport.Finish(report.ExitInterrupted)
+	}
+	if counts[datcfg.ApplyFailed] > 0 {
+		if err := writeApplyState(applyStateFile, completed); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+		}
+		return runReport.Finish(1)
+	}
+	if err := os.Remove(applyStateFile); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+	}
+	return runReport.Finish(0)
+}
+
+// interruptContext returns a context that is cancelled by the first
+// interrupt or termination signal, which lets the components being applied
+// finish or abort. Further signals have their default effect, so that a
+// second Ctrl-C exits right away.
+func interruptContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case sig := <-signals:
+			signal.Stop(signals)
+			fmt.Fprintf(os.Stderr, "\nReceived %s, waiting for the components being applied. Interrupt again to exit right away.\n", sig)
+			cancel()
+		case <-ctx.Done():
+			signal.Stop(signals)
+		}
+	}()
+	return ctx, cancel
+}
+
+// newApplyLogger returns the logger for the progress of an apply run. The
+// time is left out, as the records are read as the run goes. The attributes
+// redacted by the config are masked.
+func newApplyLogger(w io.Writer, result *datcfg.Config) *slog.Logger {
+	return slog.New(slog.NewTextHandler(w, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, attr slog.Attr) slog.Attr {
+			if len(groups) == 0 && attr.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			if result.Redacted(attr.Key) {
+				return slog.String(attr.Key, "(sensitive)")
+			}
+			return attr
+		},
+	}))
+}
+
+// readApplyState returns the addresses of the components recorded as
+// applied, with those recorded before being moved at their current address.
+func readApplyState(path string, result *datcfg.Config) (map[string]bool, error) {
+	completed := map[string]bool{}
+	src, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return completed, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var state applyState
+	if err := json.Unmarshal(src, &state); err != nil {
+		return nil, fmt.Errorf("invalid apply state in %s: %s", path, err)
+	}
+	for _, address := range state.Applied {
+		completed[result.CurrentAddress(address)] = true
+	}
+	return completed, nil
+}
+
+func writeApplyState(path string, completed map[string]bool) error {
+	state := applyState{Applied: []string{}}
+	for address := range completed {
+		state.Applied = append(state.Applied, address)
+	}
+	sort.Strings(state.Applied)
+
+	src, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(src, '\n'), 0644)
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"io"
+	"os"
+
+	"github.com/imranansari/hcl2-demo/internal/runcache"
+)
+
+// cachedRun runs a command whose output only depends on the files in the
+// working directory, the environment variables they refer to and its command
+// line, replaying the recorded output if it ran before on the same inputs,
+// see runcache. Recorded and replayed runs always evaluate, and so do runs
+// writing their report with --output-to or reading values files given with
+// --var-file, which may be outside the working directory, and runs on
+// configs runcache.Key can't tell the inputs of, like those with
+// post_decode hooks.
+func cachedRun(command string, inputs []string, noCache bool, run func(stdout, stderr io.Writer) int) int {
+	if noCache || recorder != nil || replayed != nil || len(outputSinks) > 0 || len(rootFlags.varFiles) > 0 {
+		return run(os.Stdout, os.Stderr)
+	}
+
+	key, err := runcache.Key(os.DirFS("."), command, inputs)
+	if err != nil {
+		// The cache is an optimization only, run uncached.
+		return run(os.Stdout, os.Stderr)
+	}
+	return runcache.Run(command, key, func(stdout, stderr io.Writer) int {
+		status := run(stdout, stderr)
+		// Diagnostics rendered as a single document are part of the output
+		// to replay.
+		flushDiags(stderr)
+		return status
+	})
+}
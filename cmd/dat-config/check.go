@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+func newCheckCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "check",
+		Short: "Print the variables still needing values",
+		Long: `Print, for the cluster and every component, the variables they refer to
+that have no value or no known one yet, so operators see which inputs are
+still needed before an apply. The exit status is 1 unless everything is
+ready, for gating pipelines on it.`,
+		Args: cobra.NoArgs,
+	}
+	loaderFlags := addLoaderFlags(cmd.Flags())
+	cmd.Run = statusRun(func(args []string) int {
+		return check(loaderFlags)
+	})
+	return cmd
+}
+
+// check prints the readiness of the loaded configuration, returning the exit
+// status.
+func check(loaderFlags *loaderFlags) int {
+	readiness, diags := newLoader(".", loaderFlags.options()...).Readiness()
+	fprintDiags(os.Stderr, diags)
+	if diags.HasErrors() {
+		return 1
+	}
+
+	status := 0
+	for _, r := range readiness {
+		if r.Ready() {
+			fmt.Printf("%s: ready\n", r.Address)
+			continue
+		}
+		status = 1
+		var waiting []string
+		if len(r.Missing) > 0 {
+			waiting = append(waiting, "missing "+varList(r.Missing))
+		}
+		if len(r.Unknown) > 0 {
+			waiting = append(waiting, "unknown "+varList(r.Unknown))
+		}
+		fmt.Printf("%s: %s\n", r.Address, strings.Join(waiting, "; "))
+	}
+	return status
+}
+
+func varList(names []string) string {
+	refs := make([]string, len(names))
+	for i, name := range names {
+		refs[i] = "var." + name
+	}
+	return strings.Join(refs, ", ")
+}
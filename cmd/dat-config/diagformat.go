@@ -0,0 +1,30 @@
+package main
+
+import (
+	"io"
+	"os"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/imranansari/hcl2-demo/internal/diagfmt"
+)
+
+// diagPrinter prints the diagnostics of all subcommands, in the format set
+// with the --diag-format flag.
+var diagPrinter = &diagfmt.Printer{Format: diagfmt.Text}
+
+// exit writes the pending diagnostics and exits with the given status.
+func exit(status int) {
+	flushDiags(os.Stderr)
+	finishRecording()
+	os.Exit(status)
+}
+
+func fprintDiags(w io.Writer, diags hcl.Diagnostics) {
+	diagPrinter.Print(w, diags)
+}
+
+// flushDiags writes the diagnostics collected for the formats that produce a
+// single document.
+func flushDiags(w io.Writer) {
+	diagPrinter.Flush(w)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/imranansari/hcl2-demo/datcfg"
+	"github.com/spf13/cobra"
+)
+
+func newDiffCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "diff OLD_DIR",
+		Short: "Print how the configuration differs from the one in another directory",
+		Long: `Load the configuration in the given directory and the one in the working
+directory, and print how they differ.`,
+		Args: cobra.ExactArgs(1),
+	}
+	loaderFlags := addLoaderFlags(cmd.Flags())
+	cmd.Run = statusRun(func(args []string) int {
+		return diff(args[0], loaderFlags)
+	})
+	return cmd
+}
+
+// diff prints how the configuration in the working directory differs from
+// the one in oldDir, returning the exit status.
+func diff(oldDir string, loaderFlags *loaderFlags) int {
+	old, diags := newLoader(oldDir, loaderFlags.options()...).Load()
+	printDiags(diags)
+	if diags.HasErrors() {
+		return 1
+	}
+	result, diags := newLoader(".", loaderFlags.options()...).Load()
+	printDiags(diags)
+	if diags.HasErrors() {
+		return 1
+	}
+
+	changes := datcfg.Diff(old, result)
+	if len(changes) == 0 {
+		fmt.Printf("No changes.\n")
+		return 0
+	}
+	printChanges(result, changes)
+	return 0
+}
+
+// printChanges prints the changes of the given result, one per line.
+func printChanges(result *datcfg.Config, changes []datcfg.Change) {
+	for _, change := range changes {
+		oldVal, newVal := redactedChange(result, change)
+		switch change.Action {
+		case datcfg.PlanCreate:
+			fmt.Printf("+ %s = %s\n", change.Path, newVal)
+		case datcfg.PlanDelete:
+			fmt.Printf("- %s = %s\n", change.Path, oldVal)
+		default:
+			fmt.Printf("~ %s: %s -> %s\n", change.Path, oldVal, newVal)
+		}
+	}
+}
+
+// redactedChange renders the old and new value of a change, masked if an
+// attribute on its path is redacted in the new config.
+func redactedChange(result *datcfg.Config, change datcfg.Change) (string, string) {
+	// The first two names are the kind of block and its address.
+	for _, name := range strings.Split(change.Path, ".")[2:] {
+		if result.Redacted(name) {
+			return "(sensitive)", "(sensitive)"
+		}
+	}
+	return datcfg.FormatValue(result.RedactValue(change.Old)), datcfg.FormatValue(result.RedactValue(change.New))
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/imranansari/hcl2-demo/datcfg"
+	"github.com/spf13/cobra"
+)
+
+func newExampleCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "example COMPONENT_KIND",
+		Short: "Print an example block of a component kind",
+		Long: `Print a component block of the given kind, generated from the schema of its
+config, to paste into a config file.`,
+		Args: cobra.ExactArgs(1),
+		Run: statusRun(func(args []string) int {
+			return example(args[0])
+		}),
+	}
+}
+
+// example prints the example block of the component kind, returning the exit
+// status.
+func example(kind string) int {
+	block, err := datcfg.ComponentExample(kind)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	fmt.Print(block)
+	return 0
+}
@@ -0,0 +1,15 @@
+package main
+
+import "github.com/spf13/cobra"
+
+// optionalCommands return the subcommands of optional features, registered
+// by the init functions of their files. Features are left out of a build
+// with these tags, for a smaller binary with fewer dependencies:
+//
+//   - nolsp leaves out the language server, the lsp command;
+//   - novault leaves out the vault variable source, also from datcfg.
+//
+// Like:
+//
+//	go build -tags nolsp,novault ./cmd/dat-config
+var optionalCommands []func() *cobra.Command
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/imranansari/hcl2-demo/datcfg"
+	"github.com/spf13/cobra"
+)
+
+func newFixCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "fix",
+		Short: "Rewrite deprecated constructs in the config files",
+		Long: `Rewrite deprecated attribute names in all config files to their replacement,
+leaving everything else in the files untouched. If the implicit_conversion
+lint rule is enabled, quoted numbers and bools are unquoted too, and if the
+constant_expression rule is, constant expressions are replaced with their
+value.`,
+		Args: cobra.NoArgs,
+		Run: statusRun(func(args []string) int {
+			return fix()
+		}),
+	}
+}
+
+// fix rewrites the config and values files in the working directory,
+// returning the exit status.
+func fix() int {
+	fsys := os.DirFS(".")
+
+	hclFiles, diags := datcfg.ParseConfigFiles(fsys)
+	if diags.HasErrors() {
+		printDiags(diags)
+		return 1
+	}
+
+	severities, cfgDiags := datcfg.LoadLintConfig(fsys, datcfg.LintConfigFile)
+	if cfgDiags.HasErrors() {
+		printDiags(cfgDiags)
+		return 1
+	}
+	severity, ok := severities[datcfg.ImplicitConversionRule]
+	fixConversions := ok && severity != "off"
+	severity, ok = severities[datcfg.ConstantExpressionRule]
+	fixConstants := ok && severity != "off"
+
+	for _, file := range hclFiles {
+		filename := file.Body.MissingItemRange().Filename
+		src, err := fs.ReadFile(fsys, filename)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 1
+		}
+		src, deprecations, diags := datcfg.FixDeprecatedAttributes(src, filename)
+		if diags.HasErrors() {
+			printDiags(diags)
+			return 1
+		}
+		if deprecations > 0 {
+			// The remaining fixes are edits of the renamed source.
+			if file, diags = datcfg.ParseConfig(src, filename); diags.HasErrors() {
+				printDiags(diags)
+				return 1
+			}
+		}
+
+		var edits []datcfg.SourceEdit
+		if fixConversions {
+			edits = append(edits, datcfg.ImplicitConversionFixes(file)...)
+		}
+		conversions := len(edits)
+		if fixConstants {
+			edits = append(edits, datcfg.ConstantExpressionFixes(file)...)
+		}
+		if deprecations == 0 && len(edits) == 0 {
+			continue
+		}
+
+		if err := os.WriteFile(filename, datcfg.ApplyEdits(src, edits), 0644); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 1
+		}
+		if deprecations > 0 {
+			fmt.Printf("%s: fixed %d deprecated attribute(s)\n", filename, deprecations)
+		}
+		if conversions > 0 {
+			fmt.Printf("%s: fixed %d implicit type conversion(s)\n", filename, conversions)
+		}
+		if constants := len(edits) - conversions; constants > 0 {
+			fmt.Printf("%s: folded %d constant expression(s)\n", filename, constants)
+		}
+	}
+
+	return fixRenamedVariables(fsys, datcfg.VariableRenames(hclFiles))
+}
+
+// fixRenamedVariables renames the values set under a former variable name in
+// the values files. Values files in the JSON syntax are left alone.
+func fixRenamedVariables(fsys fs.FS, renames map[string]string) int {
+	if len(renames) == 0 {
+		return 0
+	}
+	paths, diags := datcfg.ValuesFiles(fsys)
+	if diags.HasErrors() {
+		printDiags(diags)
+		return 1
+	}
+
+	for _, path := range paths {
+		if strings.HasSuffix(path, ".json") {
+			continue
+		}
+		src, err := fs.ReadFile(fsys, path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 1
+		}
+		file, diags := datcfg.ParseConfig(src, path)
+		if diags.HasErrors() {
+			printDiags(diags)
+			return 1
+		}
+
+		edits := datcfg.ValuesRenameFixes(file, renames)
+		if len(edits) == 0 {
+			continue
+		}
+		if err := writeEdits(fsys, path, edits); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 1
+		}
+		fmt.Printf("%s: renamed %d variable(s)\n", path, len(edits))
+	}
+	return 0
+}
+
+func writeEdits(fsys fs.FS, filename string, edits []datcfg.SourceEdit) error {
+	src, err := fs.ReadFile(fsys, filename)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, datcfg.ApplyEdits(src, edits), 0644)
+}
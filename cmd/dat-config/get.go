@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/imranansari/hcl2-demo/datcfg"
+	"github.com/spf13/cobra"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func newGetCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "get PATH",
+		Short: "Print the value at a path of the configuration",
+		Long: `Load the configuration and print the value at the given path, like
+cluster.worker_count, for scripts. Strings are printed as they are, other
+values as JSON, and with --json strings are too.`,
+		Args: cobra.ExactArgs(1),
+	}
+	flags := cmd.Flags()
+	asJSON := flags.Bool("json", false, "print strings as JSON too, quoted")
+	loaderFlags := addLoaderFlags(flags)
+	cmd.Run = statusRun(func(args []string) int {
+		return get(args[0], *asJSON, loaderFlags)
+	})
+	return cmd
+}
+
+// get prints the value at path, returning the exit status.
+func get(path string, asJSON bool, loaderFlags *loaderFlags) int {
+	result, diags := newLoader(".", loaderFlags.options()...).Load()
+	if diags.HasErrors() {
+		fprintDiags(os.Stderr, diags)
+		return 1
+	}
+	val, queryDiags := result.Query(path)
+	fprintDiags(os.Stderr, append(diags, queryDiags...))
+	if queryDiags.HasErrors() {
+		return 1
+	}
+
+	if !asJSON && val.Type() == cty.String && val.IsKnown() && !val.IsNull() {
+		fmt.Println(val.AsString())
+	} else {
+		fmt.Println(datcfg.FormatJSONValue(val))
+	}
+	return 0
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/imranansari/hcl2-demo/datcfg"
+	"github.com/spf13/cobra"
+)
+
+func newGraphCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "graph",
+		Short: "Print the reference graph of the configuration as DOT or Mermaid",
+		Args:  cobra.NoArgs,
+	}
+	flags := cmd.Flags()
+	format := flags.String("format", "dot", "output format, \"dot\" or \"mermaid\"")
+	focus := flags.String("focus", "", "only show the nodes reachable from the given node, like component.foo")
+	cmd.Run = statusRun(func(args []string) int {
+		return graph(*format, *focus)
+	})
+	return cmd
+}
+
+// graph prints the reference graph, returning the exit status.
+func graph(format, focus string) int {
+
+	hclFiles, diags := datcfg.ParseConfigFiles(os.DirFS("."))
+	if diags.HasErrors() {
+		printDiags(diags)
+		return 1
+	}
+
+	refs := datcfg.ReferenceGraph(hclFiles)
+	if focus != "" {
+		refs = refs.Reachable(focus)
+		if len(refs.Nodes) == 0 {
+			fmt.Fprintf(os.Stderr, "There is no node %q in the configuration.\n", focus)
+			return 1
+		}
+	}
+
+	switch format {
+	case "dot":
+		renderDOT(os.Stdout, refs)
+	case "mermaid":
+		renderMermaid(os.Stdout, refs)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown graph format %q, use \"dot\" or \"mermaid\".\n", format)
+		return 2
+	}
+	return 0
+}
+
+// nodeShapes distinguish the kinds of nodes in the rendered graphs.
+var nodeShapes = map[string]string{
+	"var":       "ellipse",
+	"local":     "note",
+	"cluster":   "box3d",
+	"component": "box",
+}
+
+func renderDOT(w io.Writer, graph *datcfg.Graph) {
+	fmt.Fprintf(w, "digraph config {\n")
+	fmt.Fprintf(w, "  rankdir = \"LR\";\n")
+	for _, node := range graph.Nodes {
+		fmt.Fprintf(w, "  %q [shape = %q];\n", node, nodeShapes[nodeKind(node)])
+	}
+	for _, node := range graph.Nodes {
+		for _, target := range graph.Edges[node] {
+			fmt.Fprintf(w, "  %q -> %q;\n", node, target)
+		}
+	}
+	fmt.Fprintf(w, "}\n")
+}
+
+func renderMermaid(w io.Writer, graph *datcfg.Graph) {
+	ids := map[string]string{}
+	for i, node := range graph.Nodes {
+		ids[node] = fmt.Sprintf("n%d", i)
+	}
+
+	fmt.Fprintf(w, "graph LR\n")
+	for _, node := range graph.Nodes {
+		open, close := "[", "]"
+		switch nodeKind(node) {
+		case "var":
+			open, close = "([", "])"
+		case "local":
+			open, close = "[/", "/]"
+		case "cluster":
+			open, close = "[[", "]]"
+		}
+		fmt.Fprintf(w, "  %s%s\"%s\"%s\n", ids[node], open, node, close)
+	}
+	for _, node := range graph.Nodes {
+		for _, target := range graph.Edges[node] {
+			fmt.Fprintf(w, "  %s --> %s\n", ids[node], ids[target])
+		}
+	}
+}
+
+func nodeKind(node string) string {
+	return strings.SplitN(node, ".", 2)[0]
+}
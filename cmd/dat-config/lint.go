@@ -0,0 +1,48 @@
+package main
+
+import (
+	"os"
+
+	"github.com/imranansari/hcl2-demo/datcfg"
+	"github.com/spf13/cobra"
+)
+
+func newLintCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "lint",
+		Short: "Check the config files against the lint rules",
+		Long: `Check the config files against the lint rules, with the severities set in
+` + datcfg.LintConfigFile + `.`,
+		Args: cobra.NoArgs,
+		Run: statusRun(func(args []string) int {
+			return lint()
+		}),
+	}
+}
+
+// lint prints the lint diagnostics of the config files, returning the exit
+// status.
+func lint() int {
+	fsys := os.DirFS(".")
+
+	hclFiles, diags := datcfg.ParseConfigFiles(fsys)
+	if diags.HasErrors() {
+		printDiags(diags)
+		return 1
+	}
+
+	severities, cfgDiags := datcfg.LoadLintConfig(fsys, datcfg.LintConfigFile)
+	diags = append(diags, cfgDiags...)
+	if cfgDiags.HasErrors() {
+		printDiags(diags)
+		return 1
+	}
+
+	diags = append(diags, datcfg.Lint(hclFiles, severities)...)
+
+	printDiags(diags)
+	if diags.HasErrors() {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,45 @@
+//go:build !nolsp
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/imranansari/hcl2-demo/datcfg"
+	"github.com/imranansari/hcl2-demo/internal/lsp"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	optionalCommands = append(optionalCommands, newLSPCommand)
+}
+
+func newLSPCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "lsp",
+		Short: lspShort,
+		Args:  cobra.NoArgs,
+	}
+	loaderFlags := addLoaderFlags(cmd.Flags())
+	cmd.Run = statusRun(func(args []string) int {
+		return serveLSP(loaderFlags)
+	})
+	return cmd
+}
+
+// serveLSP serves the language server protocol on stdin and stdout.
+func serveLSP(loaderFlags *loaderFlags) int {
+	root, err := os.Getwd()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+
+	server := lsp.NewServer(os.Stdin, os.Stdout, root, append(loaderFlags.options(), datcfg.WithoutHooks()))
+	if err := server.Serve(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/imranansari/hcl2-demo/datcfg"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+type FooComponentConfig struct {
+	Foo *string `hcl:"foo,attr"`
+}
+
+func (foo *FooComponentConfig) PrintAttrs() {
+	fmt.Printf("Foo: %s\n", *foo.Foo)
+}
+
+func (foo *FooComponentConfig) Apply(ctx context.Context) hcl.Diagnostics {
+	datcfg.ComponentLogger(ctx).Info("applying foo", "foo", *foo.Foo)
+	return nil
+}
+
+func (foo *FooComponentConfig) Check(ctx context.Context) (datcfg.Status, hcl.Diagnostics) {
+	if *foo.Foo == "" {
+		return datcfg.StatusDegraded, hcl.Diagnostics{
+			{
+				Severity: hcl.DiagWarning,
+				Summary:  "Empty foo",
+				Detail:   "The foo component has nothing to serve.",
+			},
+		}
+	}
+	return datcfg.StatusHealthy, nil
+}
+
+func (foo *FooComponentConfig) Plan(ctx context.Context) (datcfg.PlanSummary, hcl.Diagnostics) {
+	return datcfg.PlanSummary{
+		Actions: []datcfg.PlanAction{
+			{Type: datcfg.PlanCreate, Address: "foo", Detail: *foo.Foo},
+		},
+	}, nil
+}
+
+type BarComponentConfig struct {
+	Bar string `hcl:"bar,attr"`
+}
+
+func (bar *BarComponentConfig) PrintAttrs() {
+	fmt.Printf("Bar: %s\n", bar.Bar)
+}
+
+// attrsPrinter is implemented by component configs that can print their
+// attributes in a human readable form.
+type attrsPrinter interface {
+	PrintAttrs()
+}
+
+func init() {
+	for kind, config := range map[string]datcfg.ComponentConfig{
+		"foo": &FooComponentConfig{},
+		"bar": &BarComponentConfig{},
+	} {
+		datcfg.MustRegisterComponent(kind, config)
+	}
+}
+
+func main() {
+	exit(run(os.Args[1:]))
+}
+
+func newShowCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "show",
+		Short: "Load the configuration and print it",
+		Long: `Load the configuration and print its files, values and decoded blocks.
+This is also what dat-config does without a subcommand.`,
+		Args: cobra.NoArgs,
+	}
+	loaderFlags := addLoaderFlags(cmd.Flags())
+	cmd.Run = statusRun(func(args []string) int {
+		return show(loaderFlags)
+	})
+	return cmd
+}
+
+// show loads the configuration and prints it.
+func show(loaderFlags *loaderFlags) int {
+	result, diags := newLoader(".", loaderFlags.options()...).Load()
+
+	exitIfDiags(diags)
+	printConfig(result)
+	return 0
+}
+
+// printConfig prints the files, values and decoded blocks of the given
+// configuration.
+func printConfig(result *datcfg.Config) {
+	fmt.Printf("config files: %+v\n", result.Files)
+	fmt.Printf("user values: %s\n", datcfg.FormatValues(result.RedactValues(result.Values)))
+	fmt.Printf("variables: %s\n", datcfg.FormatValues(result.RedactValues(result.Variables)))
+	if len(result.Locals) > 0 {
+		fmt.Printf("locals: %s\n", datcfg.FormatValues(result.RedactValues(result.Locals)))
+	}
+	if len(result.Overrides) > 0 {
+		fmt.Printf("overrides: %+v\n", result.RedactOverrides(result.Overrides))
+	}
+
+	for _, cluster := range result.Clusters {
+		fmt.Printf("config cluster %s: %s\n", cluster.Name, result.FormatConfig(cluster.Config))
+	}
+
+	for i, component := range result.Components {
+		formatted := result.FormatConfig(component.Config)
+		fmt.Printf("component config for %q: %s\n", datcfg.ComponentAddress(result.Components, i), formatted)
+
+		// Printers don't know which attributes are redacted, so they only
+		// print configs without any.
+		if printer, ok := component.Config.(attrsPrinter); ok && formatted == datcfg.FormatConfig(component.Config) {
+			printer.PrintAttrs()
+		}
+	}
+}
+
+// loaderFlags are the command line flags shared by all subcommands that
+// load the configuration.
+type loaderFlags struct {
+	allowUnknownComponents bool
+	restricted             bool
+	hermetic               bool
+	strictVars             bool
+	environment            string
+	yaml                   bool
+	overrides              overrideFlag
+	seed                   seedFlag
+	functionTimeout        time.Duration
+}
+
+func addLoaderFlags(flags *pflag.FlagSet) *loaderFlags {
+	f := &loaderFlags{}
+	flags.BoolVar(&f.allowUnknownComponents, "allow-unknown-components", false, "decode components of unknown kinds generically instead of failing")
+	flags.BoolVar(&f.restricted, "restricted", false, "deny functions, variable sources and hooks that access the host, for untrusted configs")
+	flags.BoolVar(&f.hermetic, "hermetic", false, "fail on anything the evaluation could take from the host, so that the config evaluates identically anywhere")
+	flags.BoolVar(&f.strictVars, "strict-vars", false, "check the values files against the declared variables first, reporting all undeclared, mistyped and missing values at once")
+	flags.StringVar(&f.environment, "environment", "", "also load the values files under environments/NAME/, which take precedence over those in the root")
+	flags.BoolVar(&f.yaml, "yaml", false, "also load the .datcfg.yaml config files, which hold static values only")
+	flags.DurationVar(&f.functionTimeout, "function-timeout", 0, "fail calls of plugin functions that take longer than this, like 30s")
+	flags.Var(&f.overrides, "set", "override an attribute of the evaluated config, like cluster.worker_count=5; can be repeated")
+	flags.Var(&f.seed, "seed", "derive the values of uuid, random_integer and timestamp from this number, for reproducible runs")
+	flags.Var(recordFlag{}, "record", "record the inputs of the run to this archive, like run.tar.gz, to reproduce it with replay")
+	return f
+}
+
+func (f *loaderFlags) options() []datcfg.LoaderOption {
+	opts := append([]datcfg.LoaderOption(nil), rootFlags.options...)
+	if f.allowUnknownComponents {
+		opts = append(opts, datcfg.WithUnknownComponents())
+	}
+	if f.restricted {
+		opts = append(opts, datcfg.WithRestrictedMode())
+	}
+	if f.hermetic {
+		opts = append(opts, datcfg.WithHermeticMode())
+	}
+	if f.strictVars {
+		opts = append(opts, datcfg.WithStrictValues())
+	}
+	if f.environment != "" {
+		opts = append(opts, datcfg.WithValuesEnvironment(f.environment))
+	}
+	if f.yaml {
+		opts = append(opts, datcfg.WithYAMLConfigs())
+	}
+	if f.functionTimeout > 0 {
+		opts = append(opts, datcfg.WithFunctionTimeout(f.functionTimeout))
+	}
+	if f.seed.set {
+		opts = append(opts, datcfg.WithSeed(f.seed.seed))
+	}
+	for _, o := range f.overrides {
+		opts = append(opts, datcfg.WithOverride(o.path, o.value))
+	}
+	return opts
+}
+
+// cacheInputs returns the inputs of the run cache key the loader flags add
+// to the command line: the environment and the overrides one by one, since
+// the --set flag joins them ambiguously.
+func (f *loaderFlags) cacheInputs() []string {
+	inputs := []string{"environment=" + f.environment}
+	for _, o := range f.overrides {
+		inputs = append(inputs, fmt.Sprintf("set=%q=%q", o.path, o.value))
+	}
+	return inputs
+}
+
+// overrideFlag collects the values of the repeatable --set flag.
+type overrideFlag []struct{ path, value string }
+
+func (f *overrideFlag) String() string {
+	pairs := make([]string, len(*f))
+	for i, o := range *f {
+		pairs[i] = o.path + "=" + o.value
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (f *overrideFlag) Set(s string) error {
+	path, value, ok := strings.Cut(s, "=")
+	if !ok || path == "" {
+		return fmt.Errorf("expected path=value, like cluster.worker_count=5")
+	}
+	*f = append(*f, struct{ path, value string }{path, value})
+	return nil
+}
+
+func (f *overrideFlag) Type() string {
+	return "path=value"
+}
+
+// seedFlag is the --seed flag, which tells whether it was given.
+type seedFlag struct {
+	seed int64
+	set  bool
+}
+
+func (f *seedFlag) String() string {
+	if !f.set {
+		return ""
+	}
+	return strconv.FormatInt(f.seed, 10)
+}
+
+func (f *seedFlag) Set(s string) error {
+	seed, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return fmt.Errorf("expected an integer")
+	}
+	f.seed, f.set = seed, true
+	return nil
+}
+
+func (f *seedFlag) Type() string {
+	return "int"
+}
+
+// exitIfDiags prints the given diagnostics and exits if any of them is an
+// error.
+func exitIfDiags(diags hcl.Diagnostics) {
+	if len(diags) == 0 {
+		return
+	}
+	printDiags(diags)
+	if diags.HasErrors() {
+		exit(1)
+	}
+}
+
+func printDiags(diags hcl.Diagnostics) {
+	fprintDiags(os.Stderr, diags)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// captureStdout returns what f prints to the standard output.
+func captureStdout(t *testing.T, f func()) string {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	out := make(chan string)
+	go func() {
+		b, _ := io.ReadAll(r)
+		out <- string(b)
+	}()
+	f()
+	w.Close()
+	return <-out
+}
+
+func TestOverridesRedacted(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	config := `
+settings {
+  redact = ["bar"]
+}
+
+cluster "a" {
+  controller_count = 1
+  worker_count     = 1
+}
+
+component "bar" {
+  bar = "x"
+}
+`
+	if err := os.WriteFile("cluster.datcfg", []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	out := captureStdout(t, func() {
+		if status := run([]string{"show", "--set", "component.bar.bar=hunter2", "--set", "cluster.worker_count=3"}); status != 0 {
+			t.Errorf("show exited with %d", status)
+		}
+	})
+	if strings.Contains(out, "hunter2") || !strings.Contains(out, "component.bar.bar:(sensitive)") || !strings.Contains(out, "cluster.worker_count:3") {
+		t.Errorf("got the output\n%s\nwant the override of component.bar.bar masked", out)
+	}
+
+	reportPath := filepath.Join(dir, "report.json")
+	captureStdout(t, func() {
+		if status := run([]string{"validate", "--set", "component.bar.bar=hunter2", "--report", reportPath}); status != 0 {
+			t.Errorf("validate exited with %d", status)
+		}
+	})
+	report, err := os.ReadFile(reportPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(report), "hunter2") || !strings.Contains(string(report), `"component.bar.bar": "(sensitive)"`) {
+		t.Errorf("got the report\n%s\nwant the override of component.bar.bar masked", report)
+	}
+}
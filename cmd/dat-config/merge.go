@@ -0,0 +1,33 @@
+package main
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+func newMergeCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "merge",
+		Short: "Print the configuration merged into a single file",
+		Long: `Print the configuration as the loader sees it before evaluating it, merged
+into a single file.`,
+		Args: cobra.NoArgs,
+	}
+	loaderFlags := addLoaderFlags(cmd.Flags())
+	cmd.Run = statusRun(func(args []string) int {
+		return merge(loaderFlags)
+	})
+	return cmd
+}
+
+// merge prints the merged configuration, returning the exit status.
+func merge(loaderFlags *loaderFlags) int {
+	merged, diags := newLoader(".", loaderFlags.options()...).Merged()
+	printDiags(diags)
+	if diags.HasErrors() {
+		return 1
+	}
+	os.Stdout.Write(merged)
+	return 0
+}
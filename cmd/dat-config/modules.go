@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/imranansari/hcl2-demo/datcfg"
+	"github.com/spf13/cobra"
+)
+
+func newModulesCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "modules",
+		Short: "Install and verify the modules of the configuration",
+		Long: `Install the modules declared in the config files into ` + datcfg.ModulesDir + `, and check
+them against ` + datcfg.ModulesLockFile + `, which records where every module was
+installed from and the hash of its files.`,
+	}
+	cmd.AddCommand(&cobra.Command{
+		Use:   "update [MODULE...]",
+		Short: "Download the modules and record them in the lock file",
+		Long: `Download the given modules, or all of them, into ` + datcfg.ModulesDir + ` and record their
+sources and hashes in ` + datcfg.ModulesLockFile + `. Modules that are no longer declared
+are removed.`,
+		Run: statusRun(updateModules),
+	}, &cobra.Command{
+		Use:   "verify",
+		Short: "Check the installed modules against the lock file",
+		Args:  cobra.NoArgs,
+		Run: statusRun(func(args []string) int {
+			return verifyModules()
+		}),
+	})
+	return cmd
+}
+
+// declaredModules returns the modules declared in the config files in the
+// working directory, printing the diagnostics.
+func declaredModules() ([]datcfg.Module, bool) {
+	hclFiles, diags := datcfg.ParseConfigFiles(os.DirFS("."))
+	modules, moduleDiags := datcfg.DeclaredModules(hclFiles)
+	diags = append(diags, moduleDiags...)
+	printDiags(diags)
+	return modules, !diags.HasErrors()
+}
+
+// updateModules installs the named modules, or all of them without names,
+// and rewrites the lock file, returning the exit status.
+func updateModules(names []string) int {
+	modules, ok := declaredModules()
+	if !ok {
+		return 1
+	}
+	fsys := os.DirFS(".")
+	locks, diags := datcfg.LoadModulesLock(fsys)
+	printDiags(diags)
+	if diags.HasErrors() {
+		return 1
+	}
+
+	selected := map[string]bool{}
+	for _, name := range names {
+		selected[name] = true
+	}
+	declared := map[string]bool{}
+	for _, module := range modules {
+		declared[module.Name] = true
+	}
+	for name := range selected {
+		if !declared[name] {
+			fmt.Fprintf(os.Stderr, "There is no module %q in the configuration.\n", name)
+			return 1
+		}
+	}
+	for name := range locks {
+		if !declared[name] {
+			delete(locks, name)
+			if err := os.RemoveAll(filepath.Join(datcfg.ModulesDir, name)); err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				return 1
+			}
+			fmt.Printf("module.%s: removed\n", name)
+		}
+	}
+
+	for _, module := range modules {
+		if len(selected) > 0 && !selected[module.Name] {
+			continue
+		}
+		files, err := datcfg.FetchModule(context.Background(), nil, module.Source)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to download module %q: %v\n", module.Name, err)
+			return 1
+		}
+		if err := writeModule(module.Name, files); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 1
+		}
+		hash, err := datcfg.HashModule(fsys, module.Name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 1
+		}
+		locks[module.Name] = datcfg.ModuleLock{Name: module.Name, Source: module.Source, Hash: hash}
+		fmt.Printf("module.%s: installed from %s\n", module.Name, module.Source)
+	}
+
+	var entries []datcfg.ModuleLock
+	for _, lock := range locks {
+		entries = append(entries, lock)
+	}
+	if err := os.WriteFile(datcfg.ModulesLockFile, datcfg.FormatModulesLock(entries), 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// writeModule replaces the vendored files of the named module.
+func writeModule(name string, files map[string][]byte) error {
+	dir := filepath.Join(datcfg.ModulesDir, name)
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	for path, src := range files {
+		path = filepath.Join(dir, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, src, 0644); err != nil {
+			return err
+		}
+	}
+	// Modules without files still have their directory, to hash.
+	return os.MkdirAll(dir, 0755)
+}
+
+// verifyModules checks every declared module against the lock file,
+// returning the exit status.
+func verifyModules() int {
+	modules, ok := declaredModules()
+	if !ok {
+		return 1
+	}
+	status := 0
+	for _, module := range modules {
+		diags := datcfg.VerifyModules(os.DirFS("."), []datcfg.Module{module})
+		if diags.HasErrors() {
+			printDiags(diags)
+			status = 1
+			continue
+		}
+		fmt.Printf("module.%s: ok\n", module.Name)
+	}
+	return status
+}
@@ -0,0 +1,25 @@
+//go:build nolsp
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	optionalCommands = append(optionalCommands, func() *cobra.Command {
+		return &cobra.Command{
+			Use:   "lsp",
+			Short: lspShort,
+			// Whatever the flags, the command is not available.
+			DisableFlagParsing: true,
+			Run: statusRun(func(args []string) int {
+				fmt.Fprintf(os.Stderr, "The lsp command is not available, this binary was built with the nolsp tag.\n")
+				return 2
+			}),
+		}
+	})
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/imranansari/hcl2-demo/datcfg"
+	"github.com/imranansari/hcl2-demo/internal/report"
+	"github.com/imranansari/hcl2-demo/internal/runcache"
+	"github.com/spf13/cobra"
+)
+
+var planSymbols = map[datcfg.PlanActionType]string{
+	datcfg.PlanCreate: "+",
+	datcfg.PlanUpdate: "~",
+	datcfg.PlanDelete: "-",
+}
+
+var planColors = map[datcfg.PlanActionType]string{
+	datcfg.PlanCreate: "\x1b[32m",
+	datcfg.PlanUpdate: "\x1b[33m",
+	datcfg.PlanDelete: "\x1b[31m",
+}
+
+const colorReset = "\x1b[0m"
+
+func newPlanCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "plan",
+		Short: "Print what applying the configuration would change",
+		Long: `Print the actions applying the configuration would take, per component.
+The output is cached in ` + runcache.Dir + ` and replayed while the config files and
+the command line stay the same.`,
+		Args: cobra.NoArgs,
+	}
+	flags := cmd.Flags()
+	noColor := flags.Bool("no-color", false, "disable colorized output")
+	noCache := flags.Bool("no-cache", false, "always evaluate the configuration, ignoring cached results")
+	reportPath := addReportFlag(flags)
+	loaderFlags := addLoaderFlags(flags)
+	cmd.Run = statusRun(func(args []string) int {
+		return plan(commandLine(cmd, args), !*noColor, *noCache, *reportPath, loaderFlags)
+	})
+	return cmd
+}
+
+// plan loads the configuration and prints the plans of its components,
+// returning the exit status.
+func plan(commandLine []string, color, noCache bool, reportPath string, loaderFlags *loaderFlags) int {
+	// Cached results have no report to write, so --report always evaluates.
+	return cachedRun("plan", append(commandLine, loaderFlags.cacheInputs()...), noCache || reportPath != "", func(stdout, stderr io.Writer) int {
+		runReport := newRunReport(reportPath, "plan", commandLine)
+		result, diags := newLoader(".", loaderFlags.options()...).Load()
+		runReport.AddDiags(diags)
+		if diags.HasErrors() {
+			fprintDiags(stderr, diags)
+			return runReport.Finish(1)
+		}
+		runReport.AddConfig(result)
+
+		ctx := context.Background()
+		counts := map[datcfg.PlanActionType]int{}
+		for i, component := range result.Components {
+			entry := report.Component{Address: datcfg.ComponentAddress(result.Components, i)}
+			planner, ok := component.Config.(datcfg.Planner)
+			if !ok {
+				fmt.Fprintf(stdout, "component %q: planning not supported\n", component.Type)
+				entry.Status, entry.Reason = "skipped", "planning not supported"
+				runReport.Components = append(runReport.Components, entry)
+				continue
+			}
+
+			start := time.Now()
+			summary, planDiags := planner.Plan(datcfg.WithComponentMetadata(ctx, component.Metadata))
+			entry.DurationMS = report.Milliseconds(time.Since(start))
+			entry.Diagnostics = report.Diagnostics(planDiags)
+			diags = append(diags, planDiags...)
+			if planDiags.HasErrors() {
+				entry.Status = "failed"
+				runReport.Components = append(runReport.Components, entry)
+				continue
+			}
+			entry.Status, entry.Actions = "planned", report.Actions(summary)
+			entry.KnownAfterApply = component.KnownAfterApply
+			runReport.Components = append(runReport.Components, entry)
+
+			renderPlan(stdout, component, summary, color)
+			for _, action := range summary.Actions {
+				counts[action.Type]++
+			}
+		}
+
+		fmt.Fprintf(stdout, "\nPlan: %d to create, %d to update, %d to delete.\n",
+			counts[datcfg.PlanCreate], counts[datcfg.PlanUpdate], counts[datcfg.PlanDelete])
+
+		fprintDiags(stderr, diags)
+		if diags.HasErrors() {
+			return runReport.Finish(1)
+		}
+		return runReport.Finish(0)
+	})
+}
+
+func renderPlan(w io.Writer, component datcfg.Component, summary datcfg.PlanSummary, color bool) {
+	fmt.Fprintf(w, "component %q:\n", component.Type)
+	for _, attr := range component.KnownAfterApply {
+		fmt.Fprintf(w, "  %s = (known after apply)\n", attr)
+	}
+	if len(summary.Actions) == 0 {
+		fmt.Fprintf(w, "  no changes\n")
+		return
+	}
+
+	for _, action := range summary.Actions {
+		line := fmt.Sprintf("%s %s %s", planSymbols[action.Type], action.Type, action.Address)
+		if action.Detail != "" {
+			line += ": " + action.Detail
+		}
+		if color {
+			line = planColors[action.Type] + line + colorReset
+		}
+		fmt.Fprintf(w, "  %s\n", line)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/imranansari/hcl2-demo/configedit"
+	"github.com/imranansari/hcl2-demo/datcfg"
+	"github.com/spf13/cobra"
+)
+
+func newPruneVarsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "prune-vars",
+		Short: "Remove the values no variable is declared for",
+		Long: `Remove the values from the values files, those of the environments included,
+for which no variable is declared, since nothing reads them. With --dry-run,
+only list them. Values files in the JSON syntax are left alone.`,
+		Args: cobra.NoArgs,
+	}
+	dryRun := cmd.Flags().Bool("dry-run", false, "list the unused values without removing them")
+	cmd.Run = statusRun(func(args []string) int {
+		return pruneVars(*dryRun)
+	})
+	return cmd
+}
+
+// pruneVars removes or lists the unused values, returning the exit status.
+func pruneVars(dryRun bool) int {
+	fsys := os.DirFS(".")
+	configFiles, diags := datcfg.ParseConfigFiles(fsys)
+	if diags.HasErrors() {
+		printDiags(diags)
+		return 1
+	}
+
+	paths, diags := datcfg.ValuesFiles(fsys)
+	if diags.HasErrors() {
+		printDiags(diags)
+		return 1
+	}
+	environments, err := datcfg.Environments(fsys)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	for _, environment := range environments {
+		envPaths, diags := datcfg.EnvironmentValuesFiles(fsys, environment)
+		if diags.HasErrors() {
+			printDiags(diags)
+			return 1
+		}
+		paths = append(paths, envPaths...)
+	}
+
+	for _, path := range paths {
+		if strings.HasSuffix(path, ".json") {
+			continue
+		}
+		file, diags := configedit.Load(fsys, path)
+		if diags.HasErrors() {
+			printDiags(diags)
+			return 1
+		}
+		parsed, _ := datcfg.ParseConfig(file.Bytes(), path)
+		unused := datcfg.UnusedValues(parsed, configFiles)
+		for _, name := range unused {
+			if dryRun {
+				fmt.Printf("%s: %s is not used\n", path, name)
+				continue
+			}
+			if diags := file.RemoveValue(name); diags.HasErrors() {
+				printDiags(diags)
+				return 1
+			}
+			fmt.Printf("%s: removed %s\n", path, name)
+		}
+		if len(unused) > 0 && !dryRun {
+			if err := file.WriteFile(path); err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				return 1
+			}
+		}
+	}
+	return 0
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/imranansari/hcl2-demo/datcfg"
+	"github.com/imranansari/hcl2-demo/internal/replay"
+	"github.com/spf13/cobra"
+)
+
+// recorder is set by the --record flag.
+var recorder *replay.Recorder
+
+// replayed is the recorded run being reproduced by the replay subcommand.
+var replayed *replay.Archive
+
+// recordFlag is the --record flag, which starts recording the run.
+type recordFlag struct{}
+
+func (recordFlag) String() string {
+	if recorder == nil {
+		return ""
+	}
+	return recorder.Path
+}
+
+func (recordFlag) Set(path string) error {
+	recorder = replay.NewRecorder(path)
+	return nil
+}
+
+func (recordFlag) Type() string {
+	return "archive"
+}
+
+// newLoader returns a loader for the configuration in dir. It reads the
+// files of a replayed run, or records those it reads with --record.
+func newLoader(dir string, opts ...datcfg.LoaderOption) *datcfg.Loader {
+	if replayed != nil {
+		return datcfg.NewLoader(replayed.Dirs[dir], append(opts, datcfg.WithEnvironment(replayed.Manifest.Env), datcfg.WithRootDir(dir))...)
+	}
+	if recorder != nil {
+		opts = append(opts, datcfg.WithRecording(recorder.Recording(dir)))
+	}
+	return datcfg.NewLoader(datcfg.MmapFS(dir), opts...)
+}
+
+// finishRecording writes the archive of a recorded run, if any. It is called
+// on exit, so that failing runs are recorded too.
+func finishRecording() {
+	if recorder == nil {
+		return
+	}
+	if err := recorder.Write(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "Error recording the run: %v\n", err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Recorded the run to %s, reproduce it with: replay %s\n", recorder.Path, recorder.Path)
+}
+
+func newReplayCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "replay ARCHIVE",
+		Short: "Reproduce a run recorded with --record",
+		Long: `Reproduce a run recorded with --record: the recorded command is run again
+with the recorded arguments, reading the recorded files and environment
+variables instead of those of the host. Commands with side effects, like
+apply, have them again.`,
+		Args: cobra.ExactArgs(1),
+		Run:  statusRun(runReplay),
+	}
+}
+
+func runReplay(args []string) int {
+	archive, err := replay.ReadArchive(args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", args[0], err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "Replaying %q, recorded with version %s.\n", strings.Join(archive.Manifest.Args, " "), archive.Manifest.Version)
+	if archive.Manifest.Version != datcfg.Version {
+		fmt.Fprintf(os.Stderr, "Note: this is version %s, results may differ.\n", datcfg.Version)
+	}
+
+	replayed = archive
+	return run(archive.Manifest.Args)
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"github.com/imranansari/hcl2-demo/internal/report"
+	"github.com/spf13/pflag"
+)
+
+// outputSinks are the destinations given with --output-to that the run
+// reports are written to, in addition to the --report file.
+var outputSinks report.Sinks
+
+// addReportFlag adds the --report and --output-to flags of the given
+// subcommand, returning the path of the report.
+func addReportFlag(flags *pflag.FlagSet) *string {
+	flags.Var(&outputSinks, "output-to", "also write the report to this file://, s3:// or https:// destination, with its SHA-256 checksum; can be repeated")
+	return flags.String("report", "", "write a machine-readable report of the run to this file, like run-report.json")
+}
+
+// newRunReport starts the report of a run, which is only written if path is
+// not empty or --output-to is given.
+func newRunReport(path, command string, args []string) *report.Report {
+	return report.New(path, command, args, outputSinks)
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/imranansari/hcl2-demo/datcfg"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+const lspShort = "Serve the language server protocol on stdin and stdout"
+
+// builtinCommands returns the constructors of the subcommands of the binary
+// besides the optional ones.
+func builtinCommands() []func() *cobra.Command {
+	return []func() *cobra.Command{
+		newApplyCommand,
+		newCheckCommand,
+		newDiffCommand,
+		newExampleCommand,
+		newFixCommand,
+		newGetCommand,
+		newGraphCommand,
+		newLintCommand,
+		newMergeCommand,
+		newModulesCommand,
+		newPlanCommand,
+		newPruneVarsCommand,
+		newReplayCommand,
+		newServeCommand,
+		newShowCommand,
+		newStatusCommand,
+		newTestCommand,
+		newValidateCommand,
+		newVariablesCommand,
+		newWatchCommand,
+	}
+}
+
+// rootFlags are the flags of the root command, shared by all subcommands,
+// like:
+//
+//	dat-config --config-dir envs/prod --var-file prod.vars plan
+var rootFlags struct {
+	configDir string
+	varFiles  []string
+
+	// options are the loader options the flags result in, added to those
+	// of the loader flags of every subcommand.
+	options []datcfg.LoaderOption
+}
+
+// exitStatus is the exit status of the subcommand that ran.
+var exitStatus int
+
+// statusRun adapts the body of a subcommand, which returns the exit status,
+// to cobra.
+func statusRun(fn func(args []string) int) func(*cobra.Command, []string) {
+	return func(cmd *cobra.Command, args []string) {
+		exitStatus = fn(args)
+	}
+}
+
+// run runs the command tree with the given arguments and returns the exit
+// status. Invalid flags and arguments exit with 2.
+func run(args []string) int {
+	root := newRootCommand()
+	root.SetArgs(args)
+	exitStatus = 0
+	if err := root.Execute(); err != nil {
+		return 2
+	}
+	return exitStatus
+}
+
+// newRootCommand returns the root command, with the builtin and optional
+// subcommands. Without a subcommand, it runs show.
+func newRootCommand() *cobra.Command {
+	root := &cobra.Command{
+		Use:   "dat-config",
+		Short: "Load, check and apply dat configurations",
+		Long: `dat-config loads the configuration in the config directory, the .datcfg
+files and the values files, and runs the given subcommand on it. Without a
+subcommand, it prints the configuration like show does.`,
+		Args:              cobra.NoArgs,
+		PersistentPreRunE: applyRootFlags,
+	}
+	flags := root.PersistentFlags()
+	flags.StringVarP(&rootFlags.configDir, "config-dir", "C", "", "load the configuration in this directory instead of the working directory")
+	flags.StringArrayVar(&rootFlags.varFiles, "var-file", nil, "also read variable values from this file, taking precedence over the values files; can be repeated")
+	flags.Var(&diagPrinter.Format, "diag-format", "format of the diagnostics: \"text\", \"github\" for workflow commands, \"gitlab\" for a code quality report or \"sarif\"")
+
+	loaderFlags := addLoaderFlags(root.Flags())
+	root.Run = statusRun(func(args []string) int {
+		return show(loaderFlags)
+	})
+
+	var commands []*cobra.Command
+	for _, newCommand := range append(builtinCommands(), optionalCommands...) {
+		commands = append(commands, newCommand())
+	}
+	sort.Slice(commands, func(i, j int) bool { return commands[i].Name() < commands[j].Name() })
+	root.AddCommand(commands...)
+	return root
+}
+
+// applyRootFlags applies the flags of the root command before a subcommand
+// runs.
+func applyRootFlags(cmd *cobra.Command, args []string) error {
+	// The values files are read before changing into the config directory,
+	// so that their paths are relative to the working directory.
+	rootFlags.options = nil
+	for _, path := range rootFlags.varFiles {
+		vals, diags := datcfg.LoadValuesFile(os.DirFS(filepath.Dir(path)), filepath.Base(path))
+		exitIfDiags(diags)
+		rootFlags.options = append(rootFlags.options, datcfg.WithValues(vals))
+	}
+
+	// Replayed runs read the recorded directories instead.
+	if rootFlags.configDir != "" && replayed == nil {
+		return os.Chdir(rootFlags.configDir)
+	}
+	return nil
+}
+
+// commandLine returns the flags set on the command line of cmd, including
+// those of the root command, and its arguments, which the results of some
+// commands depend on.
+func commandLine(cmd *cobra.Command, args []string) []string {
+	var line []string
+	cmd.Flags().Visit(func(flag *pflag.Flag) {
+		line = append(line, "--"+flag.Name+"="+flag.Value.String())
+	})
+	return append(line, args...)
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/imranansari/hcl2-demo/datcfg"
+	"github.com/imranansari/hcl2-demo/internal/memfs"
+	"github.com/imranansari/hcl2-demo/internal/report"
+	"github.com/spf13/cobra"
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// maxRequestSize limits the size of the config bundles accepted by serve.
+const maxRequestSize = 10 << 20
+
+// The time limits of serve, so that slow clients and expensive bundles
+// can't hold a handler forever. A bundle is evaluated within
+// evaluateTimeout, the response must be written within writeTimeout.
+const (
+	readHeaderTimeout = 10 * time.Second
+	readTimeout       = 30 * time.Second
+	evaluateTimeout   = 30 * time.Second
+	writeTimeout      = evaluateTimeout + 10*time.Second
+	idleTimeout       = 2 * time.Minute
+)
+
+// evaluateRequest is the body of a POST to /v1/evaluate. Files maps file
+// names, like "cluster.datcfg" or "dat.vars", to their content. Variables
+// take precedence over the values files in the bundle.
+type evaluateRequest struct {
+	Files     map[string]string          `json:"files"`
+	Variables map[string]json.RawMessage `json:"variables"`
+}
+
+type evaluateResponse struct {
+	Version     string              `json:"version"`
+	Valid       bool                `json:"valid"`
+	Diagnostics []report.Diagnostic `json:"diagnostics"`
+	Result      json.RawMessage     `json:"result,omitempty"`
+}
+
+func newServeCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve an HTTP API evaluating config bundles",
+		Long: `Serve an HTTP/JSON API that evaluates config bundles, so that configs can be
+validated centrally instead of with a local binary. The bundles are
+untrusted, so they are always loaded in restricted mode.`,
+		Args: cobra.NoArgs,
+	}
+	flags := cmd.Flags()
+	addr := flags.String("addr", "localhost:8080", "address to listen on")
+	allowUnknownComponents := flags.Bool("allow-unknown-components", false, "decode components of unknown kinds generically instead of failing")
+	cmd.Run = statusRun(func(args []string) int {
+		return serve(*addr, *allowUnknownComponents)
+	})
+	return cmd
+}
+
+// serve serves the API on addr until it fails, returning the exit status.
+func serve(addr string, allowUnknownComponents bool) int {
+	opts := []datcfg.LoaderOption{datcfg.WithRestrictedMode(), datcfg.WithoutHooks()}
+	if allowUnknownComponents {
+		opts = append(opts, datcfg.WithUnknownComponents())
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "ok\n")
+	})
+	mux.HandleFunc("/v1/evaluate", func(w http.ResponseWriter, r *http.Request) {
+		serveEvaluate(w, r, opts)
+	})
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: readHeaderTimeout,
+		ReadTimeout:       readTimeout,
+		WriteTimeout:      writeTimeout,
+		IdleTimeout:       idleTimeout,
+	}
+	log.Printf("listening on %s", addr)
+	if err := server.ListenAndServe(); err != nil {
+		log.Print(err)
+		return 1
+	}
+	return 0
+}
+
+func serveEvaluate(w http.ResponseWriter, r *http.Request, opts []datcfg.LoaderOption) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "only POST is supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req evaluateRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize)).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	fsys := memfs.FS{}
+	for name, content := range req.Files {
+		// The loader only reads the root of the bundle.
+		if name == "" || strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+			http.Error(w, fmt.Sprintf("invalid file name %q", name), http.StatusBadRequest)
+			return
+		}
+		fsys[name] = []byte(content)
+	}
+
+	vals := map[string]cty.Value{}
+	for name, raw := range req.Variables {
+		var val ctyjson.SimpleJSONValue
+		if err := val.UnmarshalJSON(raw); err != nil {
+			http.Error(w, fmt.Sprintf("invalid value for variable %q: %s", name, err), http.StatusBadRequest)
+			return
+		}
+		vals[name] = val.Value
+	}
+
+	// The load stops at the deadline by itself, but not before the function
+	// call or component it is at returns, so it isn't waited for.
+	ctx, cancel := context.WithTimeout(r.Context(), evaluateTimeout)
+	defer cancel()
+	type loaded struct {
+		result *datcfg.Config
+		diags  hcl.Diagnostics
+	}
+	loaderOpts := append(append([]datcfg.LoaderOption(nil), opts...), datcfg.WithValues(vals), datcfg.WithContext(ctx))
+	done := make(chan loaded, 1)
+	go func() {
+		result, diags := datcfg.NewLoader(fsys, loaderOpts...).Load()
+		done <- loaded{result, diags}
+	}()
+	var result *datcfg.Config
+	var diags hcl.Diagnostics
+	select {
+	case l := <-done:
+		result, diags = l.result, l.diags
+	case <-ctx.Done():
+	}
+	if ctx.Err() != nil {
+		http.Error(w, fmt.Sprintf("the bundle was not evaluated within %s", evaluateTimeout), http.StatusServiceUnavailable)
+		return
+	}
+	resp := evaluateResponse{
+		Version:     datcfg.Version,
+		Valid:       !diags.HasErrors(),
+		Diagnostics: report.Diagnostics(diags),
+	}
+	if resp.Diagnostics == nil {
+		resp.Diagnostics = []report.Diagnostic{}
+	}
+	if resp.Valid {
+		rendered, err := datcfg.RenderJSON(result)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to render the result: %s", err), http.StatusInternalServerError)
+			return
+		}
+		resp.Result = rendered
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
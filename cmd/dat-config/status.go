@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/imranansari/hcl2-demo/datcfg"
+	"github.com/imranansari/hcl2-demo/internal/report"
+	"github.com/spf13/cobra"
+)
+
+func newStatusCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Check the health of every component",
+		Long: `Load the configuration and check the health of every component, printing a
+table of the results. It fails if a component is unhealthy.`,
+		Args: cobra.NoArgs,
+	}
+	flags := cmd.Flags()
+	reportPath := addReportFlag(flags)
+	loaderFlags := addLoaderFlags(flags)
+	cmd.Run = statusRun(func(args []string) int {
+		return checkStatus(newRunReport(*reportPath, "status", commandLine(cmd, args)), loaderFlags)
+	})
+	return cmd
+}
+
+// checkStatus checks the components of the loaded configuration, returning the
+// exit status.
+func checkStatus(runReport *report.Report, loaderFlags *loaderFlags) int {
+	result, diags := newLoader(".", loaderFlags.options()...).Load()
+	runReport.AddDiags(diags)
+	printDiags(diags)
+	if diags.HasErrors() {
+		return runReport.Finish(1)
+	}
+	runReport.AddConfig(result)
+
+	results, checkDiags := datcfg.CheckComponents(context.Background(), result.Components)
+	runReport.AddDiags(checkDiags)
+	printDiags(checkDiags)
+	if checkDiags.HasErrors() {
+		return runReport.Finish(1)
+	}
+
+	status := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "COMPONENT\tSTATUS\tDETAIL\n")
+	for _, check := range results {
+		detail := check.Reason
+		if detail == "" && len(check.Diags) > 0 {
+			detail = check.Diags[0].Summary
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", check.Component, check.Status, detail)
+		runReport.Components = append(runReport.Components, report.Component{
+			Address:     check.Component,
+			Status:      string(check.Status),
+			DurationMS:  report.Milliseconds(check.Duration),
+			Reason:      check.Reason,
+			Diagnostics: report.Diagnostics(check.Diags),
+		})
+		if check.Status == datcfg.StatusUnhealthy {
+			status = 1
+		}
+	}
+	w.Flush()
+
+	for _, check := range results {
+		printDiags(check.Diags)
+	}
+	return runReport.Finish(status)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/imranansari/hcl2-demo/datcfg"
+	"github.com/spf13/cobra"
+)
+
+func newTestCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "test",
+		Short: "Run the assertions of the test blocks",
+		Long: `Load the configuration and run the assertions of its test blocks, reporting
+the result of each of them.`,
+		Args: cobra.NoArgs,
+	}
+	loaderFlags := addLoaderFlags(cmd.Flags())
+	cmd.Run = statusRun(func(args []string) int {
+		return test(loaderFlags)
+	})
+	return cmd
+}
+
+// test runs the tests of the loaded configuration, returning the exit
+// status.
+func test(loaderFlags *loaderFlags) int {
+	result, diags := newLoader(".", loaderFlags.options()...).Load()
+	if diags.HasErrors() {
+		printDiags(diags)
+		return 1
+	}
+	printDiags(diags)
+
+	results, testDiags := datcfg.RunTests(result)
+	printDiags(testDiags)
+	if len(results) == 0 && !testDiags.HasErrors() {
+		fmt.Printf("No tests found.\n")
+		return 0
+	}
+
+	passed, failed := 0, 0
+	test := ""
+	for _, res := range results {
+		if res.Test != test {
+			test = res.Test
+			fmt.Printf("test %q:\n", test)
+		}
+		if res.Passed {
+			passed++
+			fmt.Printf("  assertion %d: pass\n", res.Assertion)
+			continue
+		}
+		failed++
+		fmt.Printf("  assertion %d (%s): FAIL", res.Assertion, res.Range)
+		if res.Message != "" {
+			fmt.Printf(": %s", res.Message)
+		}
+		fmt.Printf("\n")
+		printDiags(res.Diags)
+	}
+	fmt.Printf("%d passed, %d failed.\n", passed, failed)
+
+	if failed > 0 || testDiags.HasErrors() {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+
+	"github.com/imranansari/hcl2-demo/datcfg"
+	"github.com/imranansari/hcl2-demo/internal/report"
+	"github.com/spf13/cobra"
+)
+
+func newValidateCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Check that the configuration is valid",
+		Long: `Load the configuration and report whether it is valid, without planning or
+applying anything. Files with syntax errors don't stop the validation of the
+other files. With --var-file-matrix, the configuration is validated once per
+matching values file instead.`,
+		Args: cobra.NoArgs,
+	}
+	flags := cmd.Flags()
+	noCache := flags.Bool("no-cache", false, "always evaluate the configuration, ignoring cached results")
+	matrix := flags.String("var-file-matrix", "", "validate once per values file matching this pattern, like 'env/*.vars'")
+	reportPath := addReportFlag(flags)
+	loaderFlags := addLoaderFlags(flags)
+	cmd.Run = statusRun(func(args []string) int {
+		commandLine := commandLine(cmd, args)
+		opts := append(loaderFlags.options(), datcfg.WithIsolatedParseErrors())
+		if *matrix != "" {
+			return validateMatrix(*matrix, newRunReport(*reportPath, "validate", commandLine), opts)
+		}
+
+		// Cached results have no report to write, so --report always evaluates.
+		return cachedRun("validate", append(commandLine, loaderFlags.cacheInputs()...), *noCache || *reportPath != "", func(stdout, stderr io.Writer) int {
+			runReport := newRunReport(*reportPath, "validate", commandLine)
+			return runReport.Finish(validate(stdout, stderr, runReport, opts))
+		})
+	})
+	return cmd
+}
+
+// validate loads the configuration once, recording the result in the given
+// report, and returns the exit status.
+func validate(stdout, stderr io.Writer, runReport *report.Report, opts []datcfg.LoaderOption) int {
+	result, diags := newLoader(".", opts...).Load()
+	runReport.AddDiags(diags)
+	fprintDiags(stderr, diags)
+	if result != nil {
+		runReport.AddConfig(result)
+		for i := range result.Components {
+			runReport.Components = append(runReport.Components, report.Component{
+				Address: datcfg.ComponentAddress(result.Components, i),
+				Status:  "valid",
+			})
+		}
+	}
+	if diags.HasErrors() {
+		return 1
+	}
+	fmt.Fprintf(stdout, "The configuration is valid.\n")
+	return 0
+}
+
+// validateMatrix validates the configuration once per values file matching
+// the pattern, on top of the regular values files, so that a change can be
+// checked against all environments at once. The report has the result of
+// every run, keyed by values file. Values files outside the working
+// directory are not part of the cache key, so the results are never cached.
+func validateMatrix(pattern string, runReport *report.Report, opts []datcfg.LoaderOption) int {
+	fsys := os.DirFS(".")
+	paths, err := fs.Glob(fsys, pattern)
+	if err == nil && len(paths) == 0 {
+		err = fmt.Errorf("no values file matches %q", pattern)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return runReport.Finish(1)
+	}
+
+	status := 0
+	runReport.Matrix = map[string]*report.Report{}
+	for _, path := range paths {
+		fmt.Printf("%s:\n", path)
+		run := newRunReport("", "validate", runReport.Provenance.Args)
+		runReport.Matrix[path] = run
+
+		vals, diags := datcfg.LoadValuesFile(fsys, path)
+		runStatus := 0
+		if diags.HasErrors() {
+			run.AddDiags(diags)
+			printDiags(diags)
+			runStatus = 1
+		} else {
+			runStatus = validate(os.Stdout, os.Stderr, run, append(opts, datcfg.WithValues(vals)))
+		}
+		run.Complete(runStatus)
+		if runStatus != 0 {
+			status = 1
+		}
+	}
+	return runReport.Finish(status)
+}
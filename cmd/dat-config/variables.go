@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/spf13/cobra"
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// manifestVariable is a declared variable in the output of the variables
+// subcommand.
+type manifestVariable struct {
+	Name        string          `json:"name"`
+	Type        string          `json:"type"`
+	Default     json.RawMessage `json:"default,omitempty"`
+	Description string          `json:"description,omitempty"`
+	Sensitive   bool            `json:"sensitive"`
+	Provided    bool            `json:"provided"`
+	Origin      string          `json:"origin,omitempty"`
+	File        string          `json:"file,omitempty"`
+	DeclaredAt  string          `json:"declared_at"`
+}
+
+func newVariablesCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "variables",
+		Short: "Print a manifest of the declared variables",
+		Long: `Print a manifest of the declared variables, with their types, defaults and
+where their values come from, as JSON or CSV, for audits and for tools asking
+users for the values.`,
+		Args: cobra.NoArgs,
+	}
+	flags := cmd.Flags()
+	format := flags.String("format", "json", "output format, \"json\" or \"csv\"")
+	loaderFlags := addLoaderFlags(flags)
+	cmd.PreRunE = func(cmd *cobra.Command, args []string) error {
+		if *format != "json" && *format != "csv" {
+			return fmt.Errorf("unknown format %q, use \"json\" or \"csv\"", *format)
+		}
+		return nil
+	}
+	cmd.Run = statusRun(func(args []string) int {
+		return printVariables(*format == "csv", loaderFlags)
+	})
+	return cmd
+}
+
+// printVariables prints the manifest of the variables, returning the exit status.
+func printVariables(asCSV bool, loaderFlags *loaderFlags) int {
+	infos, diags := newLoader(".", loaderFlags.options()...).Variables()
+	fprintDiags(os.Stderr, diags)
+	if diags.HasErrors() {
+		return 1
+	}
+
+	variables := []manifestVariable{}
+	for _, info := range infos {
+		v := manifestVariable{
+			Name:        info.Name,
+			Type:        info.Type,
+			Description: info.Description,
+			Sensitive:   info.Sensitive,
+			Provided:    info.Provided,
+			Origin:      info.Origin,
+			File:        info.File,
+			DeclaredAt:  info.DeclRange.String(),
+		}
+		if info.Default != cty.NilVal {
+			v.Default, _ = ctyjson.SimpleJSONValue{Value: info.Default}.MarshalJSON()
+		}
+		variables = append(variables, v)
+	}
+
+	var err error
+	if asCSV {
+		err = writeVariablesCSV(os.Stdout, variables)
+	} else {
+		var src []byte
+		if src, err = json.MarshalIndent(variables, "", "  "); err == nil {
+			_, err = fmt.Printf("%s\n", src)
+		}
+	}
+	if err != nil {
+		fprintDiags(os.Stderr, hcl.Diagnostics{
+			{
+				Severity: hcl.DiagError,
+				Summary:  "Failed to write the variables",
+				Detail:   err.Error(),
+			},
+		})
+		return 1
+	}
+	return 0
+}
+
+// writeVariablesCSV writes the variables as CSV, with a header row. Defaults
+// are in JSON.
+func writeVariablesCSV(w io.Writer, variables []manifestVariable) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"name", "type", "default", "description", "sensitive", "provided", "origin", "file", "declared_at"})
+	for _, v := range variables {
+		cw.Write([]string{v.Name, v.Type, string(v.Default), v.Description, strconv.FormatBool(v.Sensitive), strconv.FormatBool(v.Provided), v.Origin, v.File, v.DeclaredAt})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,9 @@
+//go:build !novault
+
+package main
+
+import "github.com/imranansari/hcl2-demo/datcfg"
+
+func init() {
+	datcfg.MustRegisterValueSource("vault", datcfg.VaultSource{})
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/imranansari/hcl2-demo/datcfg"
+	"github.com/spf13/cobra"
+)
+
+func newWatchCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "watch",
+		Short: "Print the configuration again whenever a file changes",
+		Long: `Print the configuration like show, and whenever a file in the working
+directory changes, the attributes whose values changed since the last
+evaluation without errors. Only the files and components affected by a change
+are parsed and decoded again.`,
+		Args: cobra.NoArgs,
+	}
+	flags := cmd.Flags()
+	interval := flags.Duration("interval", 500*time.Millisecond, "how often to check the files for changes")
+	full := flags.Bool("full", false, "print the whole configuration on every change, not only the values that changed")
+	clear := flags.Bool("clear", false, "clear the screen before every evaluation and start it with a status line")
+	loaderFlags := addLoaderFlags(flags)
+	cmd.Run = statusRun(func(args []string) int {
+		return watch(*interval, *full, *clear, loaderFlags)
+	})
+	return cmd
+}
+
+// watch evaluates the configuration whenever the files change, until the
+// process is stopped.
+func watch(interval time.Duration, full, clear bool, loaderFlags *loaderFlags) int {
+	cache := datcfg.NewEvalCache()
+	opts := append(loaderFlags.options(), datcfg.WithoutHooks(), datcfg.WithEvalCache(cache))
+	fsys := os.DirFS(".")
+
+	var last map[string]fileStamp
+	var previous *datcfg.Config
+	for ; ; time.Sleep(interval) {
+		stamps, err := fileStamps(fsys)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 1
+		}
+		if last != nil && equalStamps(last, stamps) {
+			continue
+		}
+		first := last == nil
+		last = stamps
+
+		result, diags := datcfg.NewLoader(fsys, opts...).Load()
+		ok := result != nil && !diags.HasErrors()
+		var changes []datcfg.Change
+		if ok && previous != nil {
+			changes = datcfg.Diff(previous, result)
+		}
+		stats := cache.Stats()
+
+		switch {
+		case clear:
+			fmt.Print(clearScreen)
+			fmt.Println(watchStatus(result, diags, changes, stats))
+		case !first:
+			fmt.Printf("\n--- %s\n", time.Now().Format(time.TimeOnly))
+		}
+		printDiags(diags)
+		switch {
+		case !ok:
+		case previous == nil || full:
+			printConfig(result)
+		case len(changes) == 0:
+			fmt.Printf("No changes.\n")
+		default:
+			printChanges(result, changes)
+		}
+		if ok {
+			previous = result
+		}
+
+		if !clear {
+			fmt.Printf("(parsed %d of %d files, decoded %d of %d components)\n",
+				stats.FilesParsed, stats.FilesParsed+stats.FilesReused,
+				stats.ComponentsDecoded, stats.ComponentsDecoded+stats.ComponentsReused)
+		}
+	}
+}
+
+// clearScreen moves the cursor home and clears the terminal.
+const clearScreen = "\x1b[H\x1b[2J"
+
+// watchStatus is the status line of an evaluation with --clear, like
+// `12:04:05 ok, 2 change(s): 3 files, 2 components (parsed 1, decoded 1)`.
+func watchStatus(result *datcfg.Config, diags hcl.Diagnostics, changes []datcfg.Change, stats datcfg.EvalCacheStats) string {
+	status := fmt.Sprintf("ok, %d change(s)", len(changes))
+	if diags.HasErrors() {
+		status = fmt.Sprintf("%d error(s)", len(diags.Errs()))
+	}
+	line := time.Now().Format(time.TimeOnly) + " " + status
+	if result != nil {
+		line += fmt.Sprintf(": %d files, %d components", len(result.Files), len(result.Components))
+	}
+	return line + fmt.Sprintf(" (parsed %d, decoded %d)", stats.FilesParsed, stats.ComponentsDecoded)
+}
+
+// fileStamp is what tells whether a file changed between two checks.
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+func fileStamps(fsys fs.FS) (map[string]fileStamp, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	stamps := map[string]fileStamp{}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			// The file was removed since reading the directory.
+			continue
+		}
+		stamps[entry.Name()] = fileStamp{size: info.Size(), modTime: info.ModTime()}
+	}
+	return stamps, nil
+}
+
+func equalStamps(a, b map[string]fileStamp) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, stamp := range a {
+		if other, ok := b[name]; !ok || other != stamp {
+			return false
+		}
+	}
+	return true
+}
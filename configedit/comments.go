@@ -0,0 +1,123 @@
+package configedit
+
+import (
+	"bytes"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hcl/hclsyntax"
+)
+
+// removalRange returns the range to remove with the given item, like an
+// attribute, so that no comment or blank line is left behind that only made
+// sense with it. Like in hclwrite, the comments on the lines right above the
+// item and the comment after it on the same line belong to it. If removing
+// the item would join two blank lines, or leave a blank line next to a brace
+// or at the end of the file, one of them is removed too, so that the grouping
+// of the remaining items is kept. Items sharing a line with something else
+// are removed on their own.
+func (f *File) removalRange(rng hcl.Range) hcl.Range {
+	comments := f.comments()
+
+	start := lineStartPos(f.src, rng.Start).Byte
+	if !isBlank(f.src[start:rng.Start.Byte]) {
+		return rng
+	}
+	end := skipSpaces(f.src, rng.End.Byte)
+	if comment, ok := comments[end]; ok {
+		end = skipSpaces(f.src, comment.End.Byte)
+	}
+	switch {
+	case end == len(f.src):
+	case f.src[end] == '\n':
+		end++
+	default:
+		return rng
+	}
+
+	for {
+		comment, ok := f.commentEndingAt(comments, start)
+		if !ok {
+			break
+		}
+		commentStart := lineStartPos(f.src, comment.Start).Byte
+		if !isBlank(f.src[commentStart:comment.Start.Byte]) {
+			break
+		}
+		start = commentStart
+	}
+
+	prevStart := start
+	if start > 0 {
+		prevStart = bytes.LastIndexByte(f.src[:start-1], '\n') + 1
+	}
+	prev := bytes.TrimSpace(f.src[prevStart:start])
+	prevBlank := start > 0 && len(prev) == 0
+	next := f.src[end:lineEnd(f.src, end)]
+	nextBlank := end < len(f.src) && isBlank(next)
+	switch {
+	case nextBlank && (prevBlank || bytes.HasSuffix(prev, []byte("{"))):
+		end = lineEnd(f.src, end)
+		if end < len(f.src) {
+			end++
+		}
+	case prevBlank && (end == len(f.src) || bytes.HasPrefix(bytes.TrimSpace(next), []byte("}"))):
+		start = prevStart
+	}
+
+	return hcl.Range{
+		Filename: rng.Filename,
+		Start:    hcl.Pos{Byte: start},
+		End:      hcl.Pos{Byte: end},
+	}
+}
+
+// comments returns the ranges of all comments in the file, by start byte.
+func (f *File) comments() map[int]hcl.Range {
+	tokens, _ := hclsyntax.LexConfig(f.src, f.filename, hcl.Pos{Line: 1, Column: 1})
+	comments := map[int]hcl.Range{}
+	for _, token := range tokens {
+		if token.Type == hclsyntax.TokenComment {
+			comments[token.Range.Start.Byte] = token.Range
+		}
+	}
+	return comments
+}
+
+// commentEndingAt returns the comment whose line ends right before the given
+// byte. Line comments end with their newline, block comments may be followed
+// by spaces.
+func (f *File) commentEndingAt(comments map[int]hcl.Range, lineStart int) (hcl.Range, bool) {
+	for _, comment := range comments {
+		end := comment.End.Byte
+		if end > 0 && f.src[end-1] != '\n' {
+			end = skipSpaces(f.src, end)
+			if end < len(f.src) && f.src[end] == '\n' {
+				end++
+			}
+		}
+		if end == lineStart {
+			return comment, true
+		}
+	}
+	return hcl.Range{}, false
+}
+
+func skipSpaces(src []byte, i int) int {
+	for i < len(src) && (src[i] == ' ' || src[i] == '\t') {
+		i++
+	}
+	return i
+}
+
+// lineEnd returns the index of the newline ending the line i is on, or the
+// length of src if it is the last line.
+func lineEnd(src []byte, i int) int {
+	if nl := bytes.IndexByte(src[i:], '\n'); nl != -1 {
+		return i + nl
+	}
+	return len(src)
+}
+
+func isBlank(b []byte) bool {
+	return len(bytes.TrimLeft(b, " \t\r")) == 0
+}
@@ -0,0 +1,280 @@
+// Package configedit changes `.datcfg` files programmatically, for automation
+// that keeps configs up to date, like a bot bumping worker_count. Edits are
+// spliced into the original source, so comments and the layout of everything
+// that is not edited are preserved and the resulting diff stays minimal.
+package configedit
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/hashicorp/hcl2/gohcl"
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hcl/hclsyntax"
+	"github.com/hashicorp/hcl2/hclwrite"
+	"github.com/imranansari/hcl2-demo/datcfg"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// File is a config file being edited.
+type File struct {
+	filename string
+	src      []byte
+	body     *hclsyntax.Body
+}
+
+// Load reads and parses the file at the given path from fsys.
+func Load(fsys fs.FS, path string) (*File, hcl.Diagnostics) {
+	src, err := fs.ReadFile(fsys, path)
+	if err != nil {
+		return nil, hcl.Diagnostics{
+			{
+				Severity: hcl.DiagError,
+				Summary:  "Failed to read file",
+				Detail:   fmt.Sprintf("The file %q could not be read: %s.", path, err),
+			},
+		}
+	}
+	return Parse(src, path)
+}
+
+// Parse parses the given source of a config file.
+func Parse(src []byte, filename string) (*File, hcl.Diagnostics) {
+	f := &File{filename: filename}
+	diags := f.reset(src)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	return f, diags
+}
+
+// Bytes returns the current source of the file.
+func (f *File) Bytes() []byte {
+	return f.src
+}
+
+// WriteFile writes the current source of the file to the given path.
+func (f *File) WriteFile(path string) error {
+	return os.WriteFile(path, f.src, 0644)
+}
+
+// BlockRef identifies a top-level block of a config file.
+type BlockRef struct {
+	Type   string
+	Labels []string
+	// Index selects among several blocks with the same type and labels,
+	// like several components of the same kind.
+	Index int
+}
+
+// Cluster refers to the cluster block with the given name.
+func Cluster(name string) BlockRef {
+	return BlockRef{Type: "cluster", Labels: []string{name}}
+}
+
+// Component refers to the first component block of the given kind. Use
+// Nth to refer to a later one.
+func Component(kind string) BlockRef {
+	return BlockRef{Type: "component", Labels: []string{kind}}
+}
+
+// Nth returns the reference to the i-th matching block, counting from 0.
+func (r BlockRef) Nth(i int) BlockRef {
+	r.Index = i
+	return r
+}
+
+func (r BlockRef) String() string {
+	s := r.Type
+	for _, label := range r.Labels {
+		s += fmt.Sprintf(" %q", label)
+	}
+	if r.Index > 0 {
+		s += fmt.Sprintf(" (#%d)", r.Index+1)
+	}
+	return s
+}
+
+// SetAttribute sets the attribute of the given block to the given value,
+// replacing its expression if it is already set and adding it at the end of
+// the block otherwise.
+func (f *File) SetAttribute(ref BlockRef, name string, val cty.Value) hcl.Diagnostics {
+	return f.setAttribute(ref, name, hclwrite.TokensForValue(val).Bytes())
+}
+
+// SetAttributeTraversal is like SetAttribute, with a reference like
+// `var.worker_count` as the new expression.
+func (f *File) SetAttributeTraversal(ref BlockRef, name string, traversal hcl.Traversal) hcl.Diagnostics {
+	return f.setAttribute(ref, name, hclwrite.TokensForTraversal(traversal).Bytes())
+}
+
+func (f *File) setAttribute(ref BlockRef, name string, expr []byte) hcl.Diagnostics {
+	block, diags := f.find(ref)
+	if diags.HasErrors() {
+		return diags
+	}
+
+	if attr, ok := block.Body.Attributes[name]; ok {
+		return f.edit(datcfg.SourceEdit{Range: attr.Expr.Range(), Replacement: expr})
+	}
+
+	line := fmt.Sprintf("%s%s = %s\n", f.indent(block), name, expr)
+	closing := block.CloseBraceRange.Start
+	if closing.Line == block.OpenBraceRange.Start.Line {
+		// `{}` on a single line is split up around the new attribute.
+		return f.edit(datcfg.SourceEdit{
+			Range:       hcl.Range{Start: closing, End: closing},
+			Replacement: []byte("\n" + line),
+		})
+	}
+	lineStart := lineStartPos(f.src, closing)
+	return f.edit(datcfg.SourceEdit{
+		Range:       hcl.Range{Start: lineStart, End: lineStart},
+		Replacement: []byte(line),
+	})
+}
+
+// RemoveAttribute removes the attribute from the given block, including the
+// line it was on and the comments that belong to it, see removalRange.
+// Removing an attribute that is not set is not an error.
+func (f *File) RemoveAttribute(ref BlockRef, name string) hcl.Diagnostics {
+	block, diags := f.find(ref)
+	if diags.HasErrors() {
+		return diags
+	}
+
+	attr, ok := block.Body.Attributes[name]
+	if !ok {
+		return nil
+	}
+	return f.edit(datcfg.SourceEdit{Range: f.removalRange(attr.SrcRange), Replacement: nil})
+}
+
+// RemoveValue removes the value or section set under the given name from a
+// values file, like RemoveAttribute removes attributes. Removing a value that
+// is not set is not an error.
+func (f *File) RemoveValue(name string) hcl.Diagnostics {
+	if attr, ok := f.body.Attributes[name]; ok {
+		return f.edit(datcfg.SourceEdit{Range: f.removalRange(attr.SrcRange), Replacement: nil})
+	}
+	for _, block := range f.body.Blocks {
+		if block.Type == name && len(block.Labels) == 0 {
+			return f.edit(datcfg.SourceEdit{Range: f.removalRange(block.Range()), Replacement: nil})
+		}
+	}
+	return nil
+}
+
+// AddComponent appends a component block of the given kind to the file,
+// with the attributes encoded from the given config struct, which must have
+// `hcl` struct tags that gohcl can encode.
+func (f *File) AddComponent(kind string, config datcfg.ComponentConfig) hcl.Diagnostics {
+	block := hclwrite.NewBlock("component", []string{kind})
+	gohcl.EncodeIntoBody(config, block.Body())
+
+	file := hclwrite.NewEmptyFile()
+	file.Body().AppendBlock(block)
+
+	src := append([]byte{}, f.src...)
+	if len(src) > 0 {
+		if !bytes.HasSuffix(src, []byte("\n")) {
+			src = append(src, '\n')
+		}
+		src = append(src, '\n')
+	}
+	src = append(src, hclwrite.Format(file.Bytes())...)
+	return f.reset(src)
+}
+
+// find returns the referenced block.
+func (f *File) find(ref BlockRef) (*hclsyntax.Block, hcl.Diagnostics) {
+	var matches []*hclsyntax.Block
+	for _, block := range f.body.Blocks {
+		if block.Type == ref.Type && equalLabels(block.Labels, ref.Labels) {
+			matches = append(matches, block)
+		}
+	}
+	if ref.Index < 0 || ref.Index >= len(matches) {
+		return nil, hcl.Diagnostics{
+			{
+				Severity: hcl.DiagError,
+				Summary:  "Block not found",
+				Detail:   fmt.Sprintf("The file %q has no block %s.", f.filename, ref),
+			},
+		}
+	}
+	return matches[ref.Index], nil
+}
+
+func equalLabels(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// edit applies the edit and parses the result again, so that the ranges of
+// the next edit refer to the new source.
+func (f *File) edit(edit datcfg.SourceEdit) hcl.Diagnostics {
+	return f.reset(datcfg.ApplyEdits(f.src, []datcfg.SourceEdit{edit}))
+}
+
+func (f *File) reset(src []byte) hcl.Diagnostics {
+	file, diags := datcfg.ParseConfig(src, f.filename)
+	if diags.HasErrors() {
+		return diags
+	}
+	body, ok := file.Body.(*hclsyntax.Body)
+	if !ok {
+		return hcl.Diagnostics{
+			{
+				Severity: hcl.DiagError,
+				Summary:  "Unsupported file syntax",
+				Detail:   fmt.Sprintf("The file %q is not in the native syntax.", f.filename),
+			},
+		}
+	}
+
+	f.src, f.body = src, body
+	return diags
+}
+
+// indent returns the indentation of the attributes in the given block,
+// that of the first attribute or block if there are any and two spaces
+// otherwise.
+func (f *File) indent(block *hclsyntax.Block) string {
+	first := block.CloseBraceRange.Start
+	for _, attr := range block.Body.Attributes {
+		if attr.SrcRange.Start.Byte < first.Byte {
+			first = attr.SrcRange.Start
+		}
+	}
+	for _, nested := range block.Body.Blocks {
+		if nested.TypeRange.Start.Byte < first.Byte {
+			first = nested.TypeRange.Start
+		}
+	}
+	if first == block.CloseBraceRange.Start {
+		return strings.Repeat(" ", block.TypeRange.Start.Column-1) + "  "
+	}
+
+	prefix := f.src[lineStartPos(f.src, first).Byte:first.Byte]
+	if len(bytes.TrimLeft(prefix, " \t")) > 0 {
+		return "  "
+	}
+	return string(prefix)
+}
+
+// lineStartPos returns the position of the first byte of the line pos is on.
+func lineStartPos(src []byte, pos hcl.Pos) hcl.Pos {
+	start := bytes.LastIndexByte(src[:pos.Byte], '\n') + 1
+	return hcl.Pos{Line: pos.Line, Column: 1, Byte: start}
+}
@@ -0,0 +1,74 @@
+package configedit
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/zclconf/go-cty/cty"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+type exampleComponent struct {
+	Replicas int `hcl:"replicas"`
+}
+
+// TestCommentFidelity edits the files in testdata and compares the results
+// with the .golden files next to them. The comments that don't belong to an
+// edited item, and the blank lines grouping the items, must be kept.
+func TestCommentFidelity(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(f *File) hcl.Diagnostics
+	}{
+		{"set_attribute", func(f *File) hcl.Diagnostics {
+			diags := f.SetAttribute(Cluster("prod"), "controller_count", cty.NumberIntVal(5))
+			diags = append(diags, f.SetAttribute(Cluster("prod"), "worker_count", cty.NumberIntVal(20))...)
+			diags = append(diags, f.SetAttribute(Cluster("prod"), "zone", cty.StringVal("b"))...)
+			return append(diags, f.SetAttribute(Component("foo"), "replicas", cty.NumberIntVal(2))...)
+		}},
+		{"remove_attribute", func(f *File) hcl.Diagnostics {
+			return f.RemoveAttribute(Cluster("prod"), "worker_count")
+		}},
+		{"remove_value", func(f *File) hcl.Diagnostics {
+			return f.RemoveValue("sizing")
+		}},
+		{"add_component", func(f *File) hcl.Diagnostics {
+			return f.AddComponent("example", &exampleComponent{Replicas: 2})
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join("testdata", test.name+".datcfg")
+			src, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f, diags := Parse(src, path)
+			if diags.HasErrors() {
+				t.Fatal(diags)
+			}
+			if diags := test.edit(f); diags.HasErrors() {
+				t.Fatal(diags)
+			}
+
+			golden := filepath.Join("testdata", test.name+".golden")
+			if *update {
+				if err := os.WriteFile(golden, f.Bytes(), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(f.Bytes(), want) {
+				t.Errorf("got:\n%s\nwant:\n%s", f.Bytes(), want)
+			}
+		})
+	}
+}
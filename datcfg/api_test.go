@@ -0,0 +1,125 @@
+package datcfg_test
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"reflect"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/imranansari/hcl2-demo/datcfg"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+)
+
+// The stable API, as other modules use it. Changing any of these signatures
+// breaks them, so this fails to compile instead.
+var (
+	_ func(fs.FS, ...datcfg.LoaderOption) *datcfg.Loader            = datcfg.NewLoader
+	_ func(*datcfg.Loader) (*datcfg.Config, hcl.Diagnostics)        = (*datcfg.Loader).Load
+	_ func(fs.FS, ...datcfg.LoaderOption) (*datcfg.Config, error)   = datcfg.LoadConfig
+	_ func(fs.FS) ([]*hcl.File, hcl.Diagnostics)                    = datcfg.ParseConfigFiles
+	_ func(hcl.Body, *hcl.EvalContext, interface{}) hcl.Diagnostics = datcfg.DecodeBody
+	_ func(reflect.Type, datcfg.DecodeHook)                         = datcfg.RegisterDecodeHook
+	_ func(string, datcfg.ComponentConfig) error                    = datcfg.RegisterComponent
+	_ func(string, datcfg.ComponentConfig)                          = datcfg.MustRegisterComponent
+	_ func(string, string, ...datcfg.AliasOption) error             = datcfg.RegisterComponentAlias
+	_ func(string, interface{}) error                               = datcfg.RegisterBlockType
+
+	_ func(context.Context, []datcfg.Component, datcfg.ApplyOptions) ([]datcfg.ApplyOutcome, hcl.Diagnostics) = datcfg.ApplyComponents
+
+	_ error = &datcfg.DiagnosticsError{}
+
+	_ = []datcfg.LoaderOption{
+		datcfg.WithBlockContext(nil),
+		datcfg.WithCapsuleTypes(),
+		datcfg.WithContext(context.Background()),
+		datcfg.WithContextFunction("ns", "name", nil),
+		datcfg.WithEnvironment(map[string]string{}),
+		datcfg.WithEvalCache(nil),
+		datcfg.WithFunction("ns", "name", function.Function{}),
+		datcfg.WithFunctionTimeout(time.Second),
+		datcfg.WithHermeticMode(),
+		datcfg.WithIsolatedParseErrors(),
+		datcfg.WithOverride("path", "value"),
+		datcfg.WithRecording(nil),
+		datcfg.WithRestrictedMode(),
+		datcfg.WithRootDir("."),
+		datcfg.WithSeed(1),
+		datcfg.WithStrictValues(),
+		datcfg.WithUnknownComponents(),
+		datcfg.WithValueSource("name", nil),
+		datcfg.WithValues(map[string]cty.Value{}),
+		datcfg.WithValuesEnvironment("name"),
+		datcfg.WithYAMLConfigs(),
+		datcfg.WithoutHooks(),
+	}
+)
+
+// TestConfigFields checks the exported fields of the results, which callers
+// read and construct.
+func TestConfigFields(t *testing.T) {
+	config := datcfg.Config{
+		Files:       []string{"cluster.datcfg"},
+		Values:      map[string]cty.Value{},
+		Variables:   map[string]cty.Value{},
+		Sensitive:   map[string]bool{},
+		Locals:      map[string]cty.Value{},
+		EvalContext: &hcl.EvalContext{},
+		Clusters:    []datcfg.Cluster{{Name: "a", Key: cty.NilVal, Config: datcfg.ClusterConfig{ControllerCount: 1, WorkerCount: 2}}},
+		Components: []datcfg.Component{{
+			Type:      "foo",
+			Name:      "bar",
+			Index:     0,
+			Config:    nil,
+			Outputs:   map[string]cty.Value{},
+			Retries:   1,
+			OnFailure: "continue",
+			DependsOn: []string{"baz"},
+			Priority:  1,
+		}},
+		Blocks:    map[string][]interface{}{},
+		Moved:     map[string]string{},
+		Overrides: map[string]string{},
+		Redact:    []string{},
+	}
+	if config.Components[0].Name != "bar" {
+		t.Fatal("the component was not set")
+	}
+}
+
+func TestLoadConfigError(t *testing.T) {
+	fsys := fstest.MapFS{"cluster.datcfg": {Data: []byte(`cluster "a" {`)}}
+	_, err := datcfg.LoadConfig(fsys)
+
+	var diagsErr *datcfg.DiagnosticsError
+	if !errors.As(err, &diagsErr) {
+		t.Fatalf("got %v, want a *DiagnosticsError", err)
+	}
+	if !diagsErr.Diags.HasErrors() || diagsErr.Diags[0].Subject == nil {
+		t.Errorf("got %v, want the diagnostics with their source ranges", diagsErr.Diags)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	fsys := fstest.MapFS{"cluster.datcfg": {Data: []byte(`
+variable "workers" {
+  default = 2
+}
+
+cluster "a" {
+  controller_count = 1
+  worker_count     = var.workers
+}
+`)}}
+	config, err := datcfg.LoadConfig(fsys, datcfg.WithValues(map[string]cty.Value{"workers": cty.NumberIntVal(3)}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := config.Clusters[0].Config.WorkerCount; got != 3 {
+		t.Errorf("worker_count = %d, want the value passed with WithValues", got)
+	}
+}
@@ -0,0 +1,424 @@
+package datcfg
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/hcl2/hcl"
+)
+
+// Applier is implemented by components that can apply their configuration.
+type Applier interface {
+	Apply(ctx context.Context) hcl.Diagnostics
+}
+
+// ApplyStatus is the final state of a component after an apply run.
+type ApplyStatus string
+
+const (
+	ApplySucceeded ApplyStatus = "succeeded"
+	ApplyFailed    ApplyStatus = "failed"
+	ApplySkipped   ApplyStatus = "skipped"
+	// ApplyRolledBack components were applied and then undone, after a
+	// later component failed.
+	ApplyRolledBack ApplyStatus = "rolled back"
+)
+
+// ApplyOutcome records how applying a single component went.
+type ApplyOutcome struct {
+	// Component is the address of the component, see ComponentAddress.
+	Component string
+	Status    ApplyStatus
+	Attempts  int
+	// Duration is the time spent applying the component, over all of its
+	// attempts.
+	Duration time.Duration
+	// Reason explains why a component was skipped, or why an applied
+	// component was not rolled back.
+	Reason string
+	Diags  hcl.Diagnostics
+}
+
+const (
+	onFailureAbort    = "abort"
+	onFailureContinue = "continue"
+)
+
+// decodeFailurePolicy evaluates the `retries` and `on_failure`
+// meta-attributes of a component block into the given instance.
+func decodeFailurePolicy(component componentBlock, ctx *hcl.EvalContext, instance *Component) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+
+	instance.OnFailure = onFailureAbort
+	if isSet(component.OnFailure) {
+		diags = append(diags, decodeExpression(component.OnFailure, "on_failure", ctx, reflect.ValueOf(&instance.OnFailure).Elem())...)
+		if !diags.HasErrors() && instance.OnFailure != onFailureAbort && instance.OnFailure != onFailureContinue {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid on_failure value",
+				Detail:   fmt.Sprintf("The on_failure argument must be %q or %q.", onFailureAbort, onFailureContinue),
+				Subject:  component.OnFailure.Range().Ptr(),
+			})
+		}
+	}
+
+	if isSet(component.Retries) {
+		retriesDiags := decodeExpression(component.Retries, "retries", ctx, reflect.ValueOf(&instance.Retries).Elem())
+		diags = append(diags, retriesDiags...)
+		if !retriesDiags.HasErrors() && instance.Retries < 0 {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid retries value",
+				Detail:   "The retries argument must not be negative.",
+				Subject:  component.Retries.Range().Ptr(),
+			})
+		}
+	}
+
+	return diags
+}
+
+// isSet reports whether an optional expression field was set in the config.
+// DecodeBody leaves expression fields of absent attributes nil.
+func isSet(expr hcl.Expression) bool {
+	return expr != nil
+}
+
+// ApplyOptions configure an apply run.
+type ApplyOptions struct {
+	// RollbackOnError makes the first failure roll back the components
+	// already applied in this run, in reverse order, and skip the rest.
+	RollbackOnError bool
+	// Completed are the addresses of the components applied by a previous,
+	// partially failed run. They are skipped, so that the run resumes.
+	Completed map[string]bool
+	// Parallelism is the number of components applied at the same time.
+	// Components only start once all components they depend on are done.
+	// Values below 1 apply one component at a time.
+	Parallelism int
+	// Logger receives the progress of the run. Components get it, tagged
+	// with their address, from ComponentLogger. Nil discards the logs.
+	Logger *slog.Logger
+}
+
+type componentLoggerKey struct{}
+
+// ComponentLogger returns the logger of the component being applied with
+// the given context, which tags all records with the component address.
+func ComponentLogger(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(componentLoggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.New(slog.DiscardHandler)
+}
+
+// ApplyComponents applies the given components in dependency order, retrying
+// failed components and honoring their failure policies. Independent
+// components are applied concurrently, up to opts.Parallelism at a time.
+// The outcomes are in dependency order, however the applies interleave.
+// Components depending on a component that failed with on_failure
+// "continue" are skipped, and so are their own dependents.
+//
+// Once ctx is cancelled, like on an interrupt, no more components are
+// started and the remaining ones are skipped. The components being applied
+// get the cancelled context, and are not retried if they fail with it. The
+// applied components are not rolled back then, so that a later run can
+// resume after them.
+func ApplyComponents(ctx context.Context, components []Component, opts ApplyOptions) ([]ApplyOutcome, hcl.Diagnostics) {
+	ordered, diags := dependencyOrder(components)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+	parallelism := opts.Parallelism
+	if parallelism < 1 {
+		parallelism = 1
+	}
+
+	// deps are the positions in ordered of the dependencies of each
+	// position, which are all earlier positions.
+	position := make([]int, len(components))
+	for pos, idx := range ordered {
+		position[idx] = pos
+	}
+	deps := make([][]int, len(ordered))
+	for pos, idx := range ordered {
+		for _, kind := range components[idx].DependsOn {
+			for dep, component := range components {
+				if component.Type == kind {
+					deps[pos] = append(deps[pos], position[dep])
+				}
+			}
+		}
+	}
+
+	outcomes := make([]ApplyOutcome, len(ordered))
+	started := make([]bool, len(ordered))
+	finished := make([]bool, len(ordered))
+	// failedDeps are the addresses of the failed components each position
+	// depends on, directly or through skipped dependents of them.
+	failedDeps := make([][]string, len(ordered))
+	results := make(chan int)
+	aborted := ""
+	running, remaining := 0, len(ordered)
+	for remaining > 0 {
+		// Components that are skipped finish right away, which is seen by
+		// their dependents in the same pass, as they come later.
+		for pos, idx := range ordered {
+			if started[pos] || !allFinished(finished, deps[pos]) {
+				continue
+			}
+			component := components[idx]
+			outcome := &outcomes[pos]
+			outcome.Component = ComponentAddress(components, idx)
+
+			applier, ok := component.Config.(Applier)
+			failed := failedDependencies(failedDeps, deps[pos])
+			switch {
+			case ctx.Err() != nil:
+				outcome.Status = ApplySkipped
+				outcome.Reason = "apply cancelled"
+			case aborted != "":
+				outcome.Status = ApplySkipped
+				outcome.Reason = fmt.Sprintf("component %q failed", aborted)
+			case len(failed) > 0:
+				// The failed components continued the run, but what
+				// depends on them can't be applied.
+				failedDeps[pos] = failed
+				outcome.Status = ApplySkipped
+				outcome.Reason = fmt.Sprintf("depends on failed component %s", quoteAll(failed))
+			case opts.Completed[outcome.Component]:
+				outcome.Status = ApplySkipped
+				outcome.Reason = "already applied"
+			case !ok:
+				outcome.Status = ApplySkipped
+				outcome.Reason = "apply not supported"
+			case len(component.KnownAfterApply) > 0:
+				// The config was decoded before the outputs it depends on
+				// were known, so it is incomplete.
+				outcome.Status = ApplySkipped
+				outcome.Reason = fmt.Sprintf("%s known after apply", strings.Join(component.KnownAfterApply, ", "))
+			case running < parallelism:
+				running++
+				started[pos] = true
+				go func(pos int) {
+					applyComponent(ctx, component, applier, outcome, logger.With("component", outcome.Component))
+					results <- pos
+				}(pos)
+				continue
+			default:
+				continue
+			}
+			logger.Info("skipped", "component", outcome.Component, "reason", outcome.Reason)
+			started[pos], finished[pos] = true, true
+			remaining--
+		}
+		if remaining == 0 {
+			break
+		}
+
+		pos := <-results
+		running--
+		finished[pos] = true
+		remaining--
+		component := components[ordered[pos]]
+		if outcomes[pos].Status == ApplyFailed {
+			failedDeps[pos] = []string{outcomes[pos].Component}
+			if aborted == "" && (component.OnFailure == onFailureAbort || opts.RollbackOnError) {
+				aborted = outcomes[pos].Component
+			}
+		}
+	}
+
+	if aborted != "" && opts.RollbackOnError && ctx.Err() == nil {
+		// Dependents come later in ordered than what they depend on, so
+		// rolling back in reverse order undoes them first.
+		var applied []int
+		for pos, outcome := range outcomes {
+			if outcome.Status == ApplySucceeded {
+				applied = append(applied, pos)
+			}
+		}
+		rollback(ctx, components, ordered, outcomes, applied)
+	}
+	return outcomes, diags
+}
+
+// applyComponent applies a single component, retrying it as configured.
+func applyComponent(ctx context.Context, component Component, applier Applier, outcome *ApplyOutcome, logger *slog.Logger) {
+	ctx = context.WithValue(ctx, componentLoggerKey{}, logger)
+	ctx = WithComponentMetadata(ctx, component.Metadata)
+	start := time.Now()
+	defer func() {
+		outcome.Duration = time.Since(start)
+	}()
+
+	outcome.Status = ApplyFailed
+	for outcome.Attempts <= component.Retries {
+		outcome.Attempts++
+		logger.Info("applying", "attempt", outcome.Attempts)
+		outcome.Diags = applyWithin(ctx, component.Timeouts.Apply, outcome.Component, applier)
+		if !outcome.Diags.HasErrors() {
+			outcome.Status = ApplySucceeded
+			break
+		}
+		logger.Warn("apply failed", "attempt", outcome.Attempts, "error", outcome.Diags.Error())
+		if ctx.Err() != nil {
+			outcome.Reason = "apply cancelled"
+			break
+		}
+	}
+	logger.Info(string(outcome.Status), "attempts", outcome.Attempts)
+}
+
+func allFinished(finished []bool, positions []int) bool {
+	for _, pos := range positions {
+		if !finished[pos] {
+			return false
+		}
+	}
+	return true
+}
+
+// failedDependencies returns the failed components the given positions are,
+// or depend on, sorted and without duplicates.
+func failedDependencies(failedDeps [][]string, positions []int) []string {
+	var failed []string
+	for _, pos := range positions {
+		failed = append(failed, failedDeps[pos]...)
+	}
+	sort.Strings(failed)
+	return slices.Compact(failed)
+}
+
+// quoteAll quotes the given strings and joins them into a list.
+func quoteAll(values []string) string {
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = fmt.Sprintf("%q", value)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// Rollbacker is implemented by components that can undo their apply.
+type Rollbacker interface {
+	Rollback(ctx context.Context) hcl.Diagnostics
+}
+
+// rollback undoes the applied components, given by their index in
+// outcomes, in reverse order. Components that can't be rolled back keep
+// their status.
+func rollback(ctx context.Context, components []Component, ordered []int, outcomes []ApplyOutcome, applied []int) {
+	for i := len(applied) - 1; i >= 0; i-- {
+		outcome := &outcomes[applied[i]]
+		rollbacker, ok := components[ordered[applied[i]]].Config.(Rollbacker)
+		if !ok {
+			outcome.Reason = "rollback not supported"
+			continue
+		}
+		rollbackDiags := rollbacker.Rollback(WithComponentMetadata(ctx, components[ordered[applied[i]]].Metadata))
+		outcome.Diags = append(outcome.Diags, rollbackDiags...)
+		if rollbackDiags.HasErrors() {
+			outcome.Reason = "rollback failed"
+			continue
+		}
+		outcome.Status = ApplyRolledBack
+	}
+}
+
+// ComponentAddress returns the address of the component at the given index,
+// which is its name if it has one. Otherwise it is its kind, followed by its
+// position among the unnamed components of the same kind if there are
+// several.
+func ComponentAddress(components []Component, idx int) string {
+	if components[idx].Name != "" {
+		return components[idx].Name
+	}
+
+	pos, total := 0, 0
+	for i, component := range components {
+		if component.Type != components[idx].Type || component.Name != "" {
+			continue
+		}
+		if i < idx {
+			pos++
+		}
+		total++
+	}
+	if total == 1 {
+		return components[idx].Type
+	}
+	return fmt.Sprintf("%s[%d]", components[idx].Type, pos)
+}
+
+// dependencyOrder returns the indexes of the components, sorted such that
+// every component comes after the components it depends on. Otherwise they
+// are sorted by decreasing priority, then in declaration order. The
+// components a component depends on come right before it, if they are not
+// already earlier, so that they run as early as it does.
+//
+// Both sequential and parallel applies start the components in this order,
+// as soon as their dependencies are done.
+func dependencyOrder(components []Component) ([]int, hcl.Diagnostics) {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make([]int, len(components))
+	var ordered []int
+
+	var visit func(idx int) hcl.Diagnostics
+	visit = func(idx int) hcl.Diagnostics {
+		switch state[idx] {
+		case done:
+			return nil
+		case visiting:
+			return hcl.Diagnostics{
+				{
+					Severity: hcl.DiagError,
+					Summary:  "Dependency cycle",
+					Detail:   fmt.Sprintf("The component %q depends on itself through depends_on.", components[idx].Type),
+				},
+			}
+		}
+
+		state[idx] = visiting
+		for _, kind := range components[idx].DependsOn {
+			for dep, component := range components {
+				if component.Type != kind {
+					continue
+				}
+				if diags := visit(dep); diags.HasErrors() {
+					return diags
+				}
+			}
+		}
+		state[idx] = done
+		ordered = append(ordered, idx)
+		return nil
+	}
+
+	byPriority := make([]int, len(components))
+	for idx := range components {
+		byPriority[idx] = idx
+	}
+	sort.SliceStable(byPriority, func(i, j int) bool {
+		return components[byPriority[i]].Priority > components[byPriority[j]].Priority
+	})
+	for _, idx := range byPriority {
+		if diags := visit(idx); diags.HasErrors() {
+			return nil, diags
+		}
+	}
+	return ordered, nil
+}
@@ -0,0 +1,85 @@
+package datcfg
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/hashicorp/hcl2/hcl"
+)
+
+// fakeApplier fails its applies if failing is set.
+type fakeApplier struct {
+	failing bool
+}
+
+func (a *fakeApplier) Apply(ctx context.Context) hcl.Diagnostics {
+	if a.failing {
+		return hcl.Diagnostics{{Severity: hcl.DiagError, Summary: "Apply failed"}}
+	}
+	return nil
+}
+
+func TestApplySkipsDependentsOfFailed(t *testing.T) {
+	components := []Component{
+		{Type: "network", Config: &fakeApplier{failing: true}, OnFailure: onFailureContinue},
+		{Type: "storage", Config: &fakeApplier{}, OnFailure: onFailureContinue},
+		{Type: "database", Config: &fakeApplier{}, DependsOn: []string{"network", "storage"}},
+		{Type: "app", Config: &fakeApplier{}, DependsOn: []string{"database"}},
+	}
+	outcomes, diags := ApplyComponents(context.Background(), components, ApplyOptions{Parallelism: 2})
+	if diags.HasErrors() {
+		t.Fatal(diags)
+	}
+
+	want := map[string]ApplyOutcome{
+		"network":  {Status: ApplyFailed},
+		"storage":  {Status: ApplySucceeded},
+		"database": {Status: ApplySkipped, Reason: `depends on failed component "network"`},
+		"app":      {Status: ApplySkipped, Reason: `depends on failed component "network"`},
+	}
+	for _, outcome := range outcomes {
+		if outcome.Status != want[outcome.Component].Status || outcome.Reason != want[outcome.Component].Reason {
+			t.Errorf("%s: %s (%s), want %s (%s)", outcome.Component, outcome.Status, outcome.Reason, want[outcome.Component].Status, want[outcome.Component].Reason)
+		}
+	}
+}
+
+type volumeConfig struct {
+	Size int `hcl:"size,optional"`
+}
+
+func init() {
+	MustRegisterComponent("test_volume", &volumeConfig{})
+	MustRegisterComponent("test_mount", &volumeConfig{})
+	if err := RegisterComponentAlias("test_disk", "test_volume"); err != nil {
+		panic(err)
+	}
+}
+
+func TestDependsOnAlias(t *testing.T) {
+	fsys := fstest.MapFS{"cluster.datcfg": {Data: []byte(`
+cluster "a" {
+  controller_count = 1
+  worker_count     = 1
+}
+
+component "test_mount" {
+  depends_on = [component.test_disk]
+}
+
+component "test_disk" {}
+`)}}
+	result, diags := NewLoader(fsys).Load()
+	if diags.HasErrors() {
+		t.Fatal(diags)
+	}
+
+	ordered, diags := dependencyOrder(result.Components)
+	if diags.HasErrors() {
+		t.Fatal(diags)
+	}
+	if got := result.Components[ordered[0]].Type; got != "test_volume" {
+		t.Errorf("%s is applied first, want the component depended on through its alias", got)
+	}
+}
@@ -0,0 +1,95 @@
+package datcfg
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hcl/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+)
+
+// assertExpr is a call of the assert function, which guards the expression
+// of a single attribute:
+//
+//	port = assert(var.port > 1024, "The port must not be privileged.", var.port)
+//
+// assert returns its last argument, or true without one, if the condition
+// holds. Otherwise it fails with the message, pointing to the call, which
+// a function of the function table can't do. The message is only evaluated
+// then. The result is unknown while the condition is.
+type assertExpr struct {
+	*hclsyntax.FunctionCallExpr
+}
+
+func (e *assertExpr) Value(ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
+	if len(e.Args) != 2 && len(e.Args) != 3 {
+		return cty.DynamicVal, hcl.Diagnostics{
+			{
+				Severity: hcl.DiagError,
+				Summary:  "Error in function call",
+				Detail:   `Call to function "assert" failed. A condition, a message and optionally the value to return are required.`,
+				Subject:  e.Range().Ptr(),
+			},
+		}
+	}
+
+	result := cty.True
+	var diags hcl.Diagnostics
+	if len(e.Args) == 3 {
+		result, diags = e.Args[2].Value(ctx)
+		if diags.HasErrors() {
+			return cty.DynamicVal, diags
+		}
+	}
+
+	condition, condDiags := e.Args[0].Value(ctx)
+	diags = append(diags, condDiags...)
+	if condDiags.HasErrors() {
+		return cty.DynamicVal, diags
+	}
+	boolCondition, err := convert.Convert(condition, cty.Bool)
+	if err != nil || boolCondition.IsNull() {
+		detail := "The condition must not be null."
+		if err != nil {
+			detail = fmt.Sprintf("The condition must be a bool, not %s.", condition.Type().FriendlyName())
+		}
+		return cty.DynamicVal, append(diags, &hcl.Diagnostic{
+			Severity:    hcl.DiagError,
+			Summary:     "Invalid assert condition",
+			Detail:      detail,
+			Subject:     e.Args[0].Range().Ptr(),
+			Expression:  e.Args[0],
+			EvalContext: ctx,
+		})
+	}
+	condition = boolCondition
+	if !condition.IsKnown() {
+		return cty.UnknownVal(result.Type()), diags
+	}
+	if condition.True() {
+		return result, diags
+	}
+
+	message := "The condition is false."
+	msgVal, msgDiags := e.Args[1].Value(ctx)
+	diags = append(diags, msgDiags...)
+	if msgVal, err := convert.Convert(msgVal, cty.String); err == nil && msgVal.IsKnown() && !msgVal.IsNull() {
+		message = msgVal.AsString()
+	} else if !msgDiags.HasErrors() {
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid assert message",
+			Detail:   "The message must be a known string.",
+			Subject:  e.Args[1].Range().Ptr(),
+		})
+	}
+	return cty.DynamicVal, append(diags, &hcl.Diagnostic{
+		Severity:    hcl.DiagError,
+		Summary:     "Assertion failed",
+		Detail:      message,
+		Subject:     e.Range().Ptr(),
+		Expression:  e.Args[0],
+		EvalContext: ctx,
+	})
+}
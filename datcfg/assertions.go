@@ -0,0 +1,104 @@
+package datcfg
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+)
+
+// testBlock is a named group of assertions about the resolved config:
+//
+//	test "workers" {
+//	  assert {
+//	    condition     = var.worker_count >= 2
+//	    error_message = "At least two workers are needed for failover."
+//	  }
+//	}
+//
+// Tests are not evaluated when loading the config, only by RunTests.
+type testBlock struct {
+	Name    string        `hcl:"name,label"`
+	Asserts []assertBlock `hcl:"assert,block"`
+}
+
+type assertBlock struct {
+	Condition    hcl.Expression `hcl:"condition"`
+	ErrorMessage hcl.Expression `hcl:"error_message"`
+}
+
+// TestResult is the result of a single assertion of a test.
+type TestResult struct {
+	Test string
+	// Assertion is the position of the assertion in its test, counting
+	// from 1.
+	Assertion int
+	Range     hcl.Range
+	Passed    bool
+	// Message is the error message of a failed assertion.
+	Message string
+	// Diags are the problems evaluating the assertion, which then fails.
+	Diags hcl.Diagnostics
+}
+
+// RunTests evaluates the assertions of all test blocks against the fully
+// resolved config, in the order they are declared.
+func RunTests(result *Config) ([]TestResult, hcl.Diagnostics) {
+	var diags hcl.Diagnostics
+	var results []TestResult
+	seen := map[string]bool{}
+	for _, test := range result.root.Tests {
+		if seen[test.Name] {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Duplicate test",
+				Detail:   fmt.Sprintf("A test named %q was already declared.", test.Name),
+			})
+			continue
+		}
+		seen[test.Name] = true
+
+		for i, assert := range test.Asserts {
+			res := TestResult{Test: test.Name, Assertion: i + 1, Range: assert.Condition.Range()}
+			res.Passed, res.Message, res.Diags = evaluateAssertion(assert, result.EvalContext)
+			results = append(results, res)
+		}
+	}
+	return results, diags
+}
+
+func evaluateAssertion(assert assertBlock, ctx *hcl.EvalContext) (bool, string, hcl.Diagnostics) {
+	cond, diags := assert.Condition.Value(ctx)
+	if diags.HasErrors() {
+		return false, "", diags
+	}
+	cond, err := convert.Convert(cond, cty.Bool)
+	if err != nil || cond.IsNull() || !cond.IsKnown() {
+		return false, "", append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid assertion condition",
+			Detail:   "The condition of an assertion must be a known bool.",
+			Subject:  assert.Condition.Range().Ptr(),
+		})
+	}
+	if cond.True() {
+		return true, "", diags
+	}
+
+	msg, msgDiags := assert.ErrorMessage.Value(ctx)
+	diags = append(diags, msgDiags...)
+	if msgDiags.HasErrors() {
+		return false, "", diags
+	}
+	msg, err = convert.Convert(msg, cty.String)
+	if err != nil || msg.IsNull() || !msg.IsKnown() {
+		return false, "", append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid error message",
+			Detail:   "The error message of an assertion must be a known string.",
+			Subject:  assert.ErrorMessage.Range().Ptr(),
+		})
+	}
+	return false, msg.AsString(), diags
+}
@@ -0,0 +1,59 @@
+package datcfg
+
+import (
+	"github.com/hashicorp/hcl2/hcl"
+)
+
+// BlockMeta describes a top-level block about to be evaluated, see
+// WithBlockContext.
+type BlockMeta struct {
+	// Type is the type of the block, like "cluster", "component" or a type
+	// registered with RegisterBlockType.
+	Type string
+	// Labels are the labels of the block, like the kind of a component.
+	Labels []string
+	// Name is the value of the `name` meta-argument of a component, if set.
+	Name string
+	// Index is the `count.index` of a component instance, 0 otherwise.
+	Index int
+}
+
+// BlockContextFunc returns the evaluation context for the given block. The
+// returned context is usually a child of parent, created with
+// parent.NewChild(). Returning nil keeps parent.
+type BlockContextFunc func(block BlockMeta, parent *hcl.EvalContext) *hcl.EvalContext
+
+// WithBlockContext lets embedders inject variables and functions that are
+// only available to certain blocks, like the credentials or region scoped to
+// a component:
+//
+//	datcfg.WithBlockContext(func(block datcfg.BlockMeta, parent *hcl.EvalContext) *hcl.EvalContext {
+//		if block.Type != "component" || block.Labels[0] != "db" {
+//			return nil
+//		}
+//		ctx := parent.NewChild()
+//		ctx.Variables = map[string]cty.Value{"region": cty.StringVal("eu-west-1")}
+//		return ctx
+//	})
+//
+// The function is called for the cluster block, for every instance of a
+// component and for the blocks of registered types. The meta-arguments of
+// components, like `count` and `name`, are evaluated before and don't see
+// the injected context. Several functions are applied in the order given,
+// each getting the context returned by the previous one.
+func WithBlockContext(fn BlockContextFunc) LoaderOption {
+	return func(l *Loader) {
+		l.blockContexts = append(l.blockContexts, fn)
+	}
+}
+
+// blockContext returns the context the given block is evaluated in.
+func (l *Loader) blockContext(block BlockMeta, parent *hcl.EvalContext) *hcl.EvalContext {
+	ctx := parent
+	for _, fn := range l.blockContexts {
+		if child := fn(block, ctx); child != nil {
+			ctx = child
+		}
+	}
+	return ctx
+}
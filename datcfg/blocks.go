@@ -0,0 +1,116 @@
+package datcfg
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/hcl2/gohcl"
+	"github.com/hashicorp/hcl2/hcl"
+)
+
+var (
+	blockTypesMu sync.RWMutex
+	blockTypes   = map[string]reflect.Type{}
+)
+
+// RegisterBlockType makes an additional top-level block type, like `policy`
+// or `notification`, available to all loaders. The given target must be a
+// pointer to a struct, it is only used as a prototype: every block of the
+// type is decoded into a new value, including its labels, and returned in
+// Config.Blocks. The blocks are evaluated after the components, so they can
+// refer to everything else in the config.
+func RegisterBlockType(typeName string, target interface{}) error {
+	ty := reflect.TypeOf(target)
+	if ty == nil || ty.Kind() != reflect.Ptr || ty.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("target of block type %q must be a pointer to a struct, not %T", typeName, target)
+	}
+	rootSchema, _ := gohcl.ImpliedBodySchema(&configRoot{})
+	for _, blockS := range rootSchema.Blocks {
+		if blockS.Type == typeName {
+			return fmt.Errorf("block type %q is built in", typeName)
+		}
+	}
+
+	blockTypesMu.Lock()
+	defer blockTypesMu.Unlock()
+	if _, exists := blockTypes[typeName]; exists {
+		return fmt.Errorf("block type %q is already registered", typeName)
+	}
+	blockTypes[typeName] = ty.Elem()
+	return nil
+}
+
+// registeredBlockType returns the struct type blocks of the given type are
+// decoded into, if the type was registered.
+func registeredBlockType(typeName string) (reflect.Type, bool) {
+	blockTypesMu.RLock()
+	defer blockTypesMu.RUnlock()
+	ty, ok := blockTypes[typeName]
+	return ty, ok
+}
+
+// registeredBlocksSchema returns the schema of the registered block types,
+// sorted by type.
+func registeredBlocksSchema() *hcl.BodySchema {
+	blockTypesMu.RLock()
+	defer blockTypesMu.RUnlock()
+
+	schema := &hcl.BodySchema{}
+	for typeName, ty := range blockTypes {
+		var labelNames []string
+		for _, idx := range getDecodeFieldTags(ty).Labels {
+			name := ty.Field(idx).Tag.Get("hcl")
+			labelNames = append(labelNames, strings.TrimSuffix(name, ",label"))
+		}
+		schema.Blocks = append(schema.Blocks, hcl.BlockHeaderSchema{Type: typeName, LabelNames: labelNames})
+	}
+	sort.Slice(schema.Blocks, func(i, j int) bool {
+		return schema.Blocks[i].Type < schema.Blocks[j].Type
+	})
+	return schema
+}
+
+// registeredBlocks returns the blocks of the registered types in the part
+// of the root body that is not built in. Anything else in it is reported as
+// unsupported, with the registered types as valid names next to the built-in
+// ones.
+func registeredBlocks(body hcl.Body) (hcl.Blocks, hcl.Diagnostics) {
+	schema := registeredBlocksSchema()
+	content, diags := body.Content(schema)
+
+	hintSchema, _ := gohcl.ImpliedBodySchema(&configRoot{})
+	hintSchema.Blocks = append(hintSchema.Blocks, schema.Blocks...)
+	diags = enrichDiagnostics(diags, hintSchema, nil)
+	if content == nil {
+		return nil, diags
+	}
+	return content.Blocks, diags
+}
+
+// decodeRegisteredBlocks decodes the given blocks of the registered types,
+// by type, in declaration order. Each block is evaluated in the context
+// returned for it by ctxFor.
+func decodeRegisteredBlocks(blocks hcl.Blocks, ctxFor func(*hcl.Block) *hcl.EvalContext) (map[string][]interface{}, hcl.Diagnostics) {
+	var diags hcl.Diagnostics
+	decoded := map[string][]interface{}{}
+	byType := blocks.ByType()
+	typeNames := make([]string, 0, len(byType))
+	for typeName := range byType {
+		typeNames = append(typeNames, typeName)
+	}
+	sort.Strings(typeNames)
+	for _, typeName := range typeNames {
+		ty, _ := registeredBlockType(typeName)
+		for _, block := range byType[typeName] {
+			targets := reflect.New(reflect.SliceOf(reflect.PtrTo(ty))).Elem()
+			diags = append(diags, decodeBlocksToField(hcl.Blocks{block}, typeName, ctxFor(block), targets, block.DefRange)...)
+			for i := 0; i < targets.Len(); i++ {
+				decoded[typeName] = append(decoded[typeName], targets.Index(i).Interface())
+			}
+		}
+	}
+	return decoded, diags
+}
@@ -0,0 +1,90 @@
+package datcfg
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/hcl2/hcl"
+)
+
+// Checker is implemented by components that can report the health of what
+// they applied.
+type Checker interface {
+	Check(ctx context.Context) (Status, hcl.Diagnostics)
+}
+
+// Status is the health of a component, as reported by its Check.
+type Status string
+
+const (
+	StatusHealthy   Status = "healthy"
+	StatusDegraded  Status = "degraded"
+	StatusUnhealthy Status = "unhealthy"
+	// StatusUnknown is the status of components that can't be checked.
+	StatusUnknown Status = "unknown"
+)
+
+// CheckResult records how checking a single component went.
+type CheckResult struct {
+	// Component is the address of the component, see ComponentAddress.
+	Component string
+	Status    Status
+	Duration  time.Duration
+	// Reason explains why a component has an unknown status.
+	Reason string
+	Diags  hcl.Diagnostics
+}
+
+// CheckComponents checks the health of the given components in dependency
+// order, one at a time. A check that returns errors without a status makes
+// the component unhealthy. Components depending on an unhealthy component
+// are still checked, their own status is what they report.
+func CheckComponents(ctx context.Context, components []Component) ([]CheckResult, hcl.Diagnostics) {
+	ordered, diags := dependencyOrder(components)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	results := make([]CheckResult, 0, len(ordered))
+	for _, idx := range ordered {
+		result := CheckResult{Component: ComponentAddress(components, idx)}
+		checker, ok := components[idx].Config.(Checker)
+		switch {
+		case !ok:
+			result.Status = StatusUnknown
+			result.Reason = "check not supported"
+		case len(components[idx].KnownAfterApply) > 0:
+			result.Status = StatusUnknown
+			result.Reason = "config incomplete until apply"
+		default:
+			start := time.Now()
+			result.Status, result.Diags = checker.Check(WithComponentMetadata(ctx, components[idx].Metadata))
+			result.Duration = time.Since(start)
+			if result.Status == "" {
+				result.Status = StatusHealthy
+				if result.Diags.HasErrors() {
+					result.Status = StatusUnhealthy
+				}
+			}
+			if !validStatus(result.Status) {
+				result.Diags = append(result.Diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Invalid component status",
+					Detail:   fmt.Sprintf("The check of component %q returned the unknown status %q.", result.Component, result.Status),
+				})
+				result.Status = StatusUnknown
+			}
+		}
+		results = append(results, result)
+	}
+	return results, diags
+}
+
+func validStatus(status Status) bool {
+	switch status {
+	case StatusHealthy, StatusDegraded, StatusUnhealthy, StatusUnknown:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,231 @@
+package datcfg
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Cluster is a single cluster generated from a cluster block. A
+// block without `for_each` generates exactly one instance, named like the
+// block.
+type Cluster struct {
+	Name   string
+	Key    cty.Value
+	Config ClusterConfig
+}
+
+// clusterConfigBlock is a partial cluster body. The attributes of all
+// cluster_config blocks are merged into the cluster block, so that cluster
+// settings can be split across files.
+type clusterConfigBlock struct {
+	Config hcl.Body `hcl:",remain"`
+}
+
+// expandCluster decodes the given cluster block, merged with the partial
+// blocks, once per element of its `for_each` expression, with `each.key` and
+// `each.value` available in the cluster body.
+func expandCluster(cluster clusterBlock, partials []clusterConfigBlock, ctx *hcl.EvalContext) ([]Cluster, hcl.Diagnostics) {
+	decode := func(ctx *hcl.EvalContext) (ClusterConfig, hcl.Diagnostics) {
+		var config ClusterConfig
+		body, diags := mergeClusterConfig(cluster.ClusterConfig, partials, ctx)
+		if diags.HasErrors() {
+			return config, diags
+		}
+		return config, append(diags, DecodeBody(body, ctx, &config)...)
+	}
+
+	if cluster.ForEach == nil {
+		config, diags := decode(ctx)
+		return []Cluster{{Name: cluster.Name, Key: cty.NilVal, Config: config}}, diags
+	}
+
+	forEach, diags := cluster.ForEach.Value(ctx)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	ty := forEach.Type()
+	if forEach.IsNull() || !forEach.IsKnown() || !(ty.IsMapType() || ty.IsObjectType() || ty.IsSetType()) {
+		return nil, append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid for_each argument",
+			Detail:   "The for_each argument of a cluster block must be a known map or set of strings.",
+			Subject:  cluster.ForEach.Range().Ptr(),
+		})
+	}
+
+	// Sets are iterated in sorted order, maps and objects by key.
+	var keys, values []cty.Value
+	if ty.IsSetType() {
+		keys = sortedElements(forEach)
+		values = keys
+	} else {
+		for it := forEach.ElementIterator(); it.Next(); {
+			key, value := it.Element()
+			keys, values = append(keys, key), append(values, value)
+		}
+	}
+
+	var instances []Cluster
+	for i, key := range keys {
+		value := values[i]
+		if ty.IsSetType() {
+			if !key.Type().Equals(cty.String) {
+				return nil, append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Invalid for_each argument",
+					Detail:   "A set used in the for_each argument of a cluster block must only contain strings.",
+					Subject:  cluster.ForEach.Range().Ptr(),
+				})
+			}
+		}
+
+		eachCtx := ctx.NewChild()
+		eachCtx.Variables = map[string]cty.Value{
+			"each": cty.ObjectVal(map[string]cty.Value{
+				"key":   key,
+				"value": value,
+			}),
+		}
+
+		config, configDiags := decode(eachCtx)
+		diags = append(diags, configDiags...)
+
+		instances = append(instances, Cluster{
+			Name:   fmt.Sprintf("%s[%q]", cluster.Name, key.AsString()),
+			Key:    key,
+			Config: config,
+		})
+	}
+
+	return instances, diags
+}
+
+// mergeClusterConfig merges the attributes of the partial blocks into the
+// body of the cluster block. An attribute may be set more than once, as long
+// as it has the same value everywhere.
+func mergeClusterConfig(body hcl.Body, partials []clusterConfigBlock, ctx *hcl.EvalContext) (hcl.Body, hcl.Diagnostics) {
+	if len(partials) == 0 {
+		return body, nil
+	}
+
+	merged, diags := body.JustAttributes()
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	for _, partial := range partials {
+		attrs, attrsDiags := partial.Config.JustAttributes()
+		diags = append(diags, attrsDiags...)
+		for _, name := range sortedAttrNames(attrs) {
+			attr := attrs[name]
+			prev, exists := merged[name]
+			if !exists {
+				merged[name] = attr
+				continue
+			}
+
+			prevVal, prevDiags := prev.Expr.Value(ctx)
+			val, valDiags := attr.Expr.Value(ctx)
+			diags = append(diags, prevDiags...)
+			diags = append(diags, valDiags...)
+			if prevDiags.HasErrors() || valDiags.HasErrors() {
+				continue
+			}
+			if !Equal(prevVal, val) {
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Conflicting cluster argument",
+					Detail:   fmt.Sprintf("The argument %q was already set to a different value at %s.", name, prev.Range),
+					Subject:  &attr.NameRange,
+				})
+			}
+		}
+	}
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	return attrsBody{attrs: merged, missing: body.MissingItemRange()}, diags
+}
+
+// attrsBody is a body consisting only of the given attributes.
+type attrsBody struct {
+	attrs   hcl.Attributes
+	missing hcl.Range
+}
+
+func (b attrsBody) Content(schema *hcl.BodySchema) (*hcl.BodyContent, hcl.Diagnostics) {
+	content, remain, diags := b.PartialContent(schema)
+	for _, name := range sortedAttrNames(remain.(attrsBody).attrs) {
+		attr := b.attrs[name]
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Unsupported argument",
+			Detail:   fmt.Sprintf("An argument named %q is not expected here.", name),
+			Subject:  &attr.NameRange,
+		})
+	}
+	return content, diags
+}
+
+func (b attrsBody) PartialContent(schema *hcl.BodySchema) (*hcl.BodyContent, hcl.Body, hcl.Diagnostics) {
+	var diags hcl.Diagnostics
+	content := &hcl.BodyContent{Attributes: hcl.Attributes{}, MissingItemRange: b.missing}
+	remain := attrsBody{attrs: hcl.Attributes{}, missing: b.missing}
+	for name, attr := range b.attrs {
+		remain.attrs[name] = attr
+	}
+
+	for _, attrS := range schema.Attributes {
+		if attr, ok := b.attrs[attrS.Name]; ok {
+			content.Attributes[attrS.Name] = attr
+			delete(remain.attrs, attrS.Name)
+			continue
+		}
+		if attrS.Required {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Missing required argument",
+				Detail:   fmt.Sprintf("The argument %q is required, but no definition was found.", attrS.Name),
+				Subject:  b.missing.Ptr(),
+			})
+		}
+	}
+	return content, remain, diags
+}
+
+func (b attrsBody) JustAttributes() (hcl.Attributes, hcl.Diagnostics) {
+	return b.attrs, nil
+}
+
+func (b attrsBody) MissingItemRange() hcl.Range {
+	return b.missing
+}
+
+// clusterObject returns the `cluster` object of the evaluation context of
+// the blocks decoded after the cluster, with its name and its attributes,
+// including those derived by PostDecode, like `cluster.worker_count` or
+// `cluster.node_count`. A cluster block with `for_each` has an object like
+// that per instance, by key, like `cluster["eu"].worker_count`.
+func clusterObject(block clusterBlock, clusters []Cluster) cty.Value {
+	instance := func(cluster Cluster) cty.Value {
+		attrs := map[string]cty.Value{
+			"name":       cty.StringVal(cluster.Name),
+			"node_count": cty.NumberIntVal(int64(cluster.Config.NodeCount)),
+		}
+		for name, val := range configValue(cluster.Config).AsValueMap() {
+			attrs[name] = val
+		}
+		return cty.ObjectVal(attrs)
+	}
+
+	if block.ForEach == nil {
+		return instance(clusters[0])
+	}
+	instances := map[string]cty.Value{}
+	for _, cluster := range clusters {
+		instances[cluster.Key.AsString()] = instance(cluster)
+	}
+	return cty.ObjectVal(instances)
+}
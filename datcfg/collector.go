@@ -0,0 +1,69 @@
+package datcfg
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/hashicorp/hcl2/hcl"
+)
+
+// DiagnosticsCollector collects the diagnostics of work done concurrently,
+// like parsing several files at once. It is safe for concurrent use, and the
+// diagnostics come out in the same order whatever order they were added in,
+// so that every run reports them alike. The loader collects the diagnostics
+// of all its steps with it. The zero value is ready to use.
+type DiagnosticsCollector struct {
+	mu    sync.Mutex
+	diags hcl.Diagnostics
+}
+
+// Add adds the given diagnostics.
+func (c *DiagnosticsCollector) Add(diags hcl.Diagnostics) {
+	if len(diags) == 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.diags = append(c.diags, diags...)
+}
+
+// HasErrors reports whether any of the diagnostics added so far is an
+// error.
+func (c *DiagnosticsCollector) HasErrors() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.diags.HasErrors()
+}
+
+// Diagnostics returns the diagnostics added so far, ordered by the file and
+// the position they refer to. Those without a subject come first. Those at
+// the same position are ordered by their severity, errors first, summary and
+// detail.
+func (c *DiagnosticsCollector) Diagnostics() hcl.Diagnostics {
+	c.mu.Lock()
+	diags := append(hcl.Diagnostics(nil), c.diags...)
+	c.mu.Unlock()
+
+	sort.SliceStable(diags, func(i, j int) bool {
+		a, b := diags[i], diags[j]
+		var aRange, bRange hcl.Range
+		if a.Subject != nil {
+			aRange = *a.Subject
+		}
+		if b.Subject != nil {
+			bRange = *b.Subject
+		}
+		switch {
+		case aRange.Filename != bRange.Filename:
+			return aRange.Filename < bRange.Filename
+		case aRange.Start.Byte != bRange.Start.Byte:
+			return aRange.Start.Byte < bRange.Start.Byte
+		case a.Severity != b.Severity:
+			return a.Severity < b.Severity
+		case a.Summary != b.Summary:
+			return a.Summary < b.Summary
+		}
+		return a.Detail < b.Detail
+	})
+	return diags
+}
@@ -0,0 +1,84 @@
+package datcfg
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"github.com/hashicorp/hcl2/hcl"
+)
+
+func TestDiagnosticsCollectorConcurrent(t *testing.T) {
+	var want hcl.Diagnostics
+	for i := 0; i < 100; i++ {
+		want = append(want, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid value",
+			Subject:  &hcl.Range{Filename: fmt.Sprintf("%03d.datcfg", i), Start: hcl.Pos{Byte: i}},
+		})
+	}
+
+	var diags DiagnosticsCollector
+	var wg sync.WaitGroup
+	for i := len(want) - 1; i >= 0; i-- {
+		wg.Add(1)
+		go func(diag *hcl.Diagnostic) {
+			defer wg.Done()
+			diags.Add(hcl.Diagnostics{diag})
+			diags.HasErrors()
+		}(want[i])
+	}
+	wg.Wait()
+
+	if got := diags.Diagnostics(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want the diagnostics ordered by file", got)
+	}
+}
+
+func TestDiagnosticsCollectorOrder(t *testing.T) {
+	at := func(filename string, offset int) *hcl.Range {
+		return &hcl.Range{Filename: filename, Start: hcl.Pos{Byte: offset}}
+	}
+	want := hcl.Diagnostics{
+		{Severity: hcl.DiagError, Summary: "No subject"},
+		{Severity: hcl.DiagError, Summary: "B", Subject: at("a.datcfg", 3)},
+		{Severity: hcl.DiagWarning, Summary: "A", Subject: at("a.datcfg", 3)},
+		{Severity: hcl.DiagError, Summary: "A", Subject: at("a.datcfg", 10)},
+		{Severity: hcl.DiagError, Summary: "A", Subject: at("b.datcfg", 0)},
+	}
+
+	var diags DiagnosticsCollector
+	for _, i := range []int{4, 2, 0, 3, 1} {
+		diags.Add(hcl.Diagnostics{want[i]})
+	}
+	if got := diags.Diagnostics(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+// TestLoadConcurrent loads configs with errors in several files from
+// several goroutines, which must report the same diagnostics every time.
+func TestLoadConcurrent(t *testing.T) {
+	fsys := fstest.MapFS{}
+	for i := 0; i < 20; i++ {
+		fsys[fmt.Sprintf("%02d.datcfg", i)] = &fstest.MapFile{Data: []byte(fmt.Sprintf("variable \"v%d\" {\n  default = \n}\n", i))}
+	}
+	_, want := NewLoader(fsys).Load()
+	if len(want) != 20 {
+		t.Fatalf("got %d diagnostics, want one per file", len(want))
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, got := NewLoader(fsys).Load(); !reflect.DeepEqual(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		}()
+	}
+	wg.Wait()
+}
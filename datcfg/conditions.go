@@ -0,0 +1,71 @@
+package datcfg
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+)
+
+// fileConditionSchema extracts the top-level `applies_when` attribute, which
+// decides whether the blocks of a file are part of the configuration.
+var fileConditionSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{
+		{Name: "applies_when"},
+	},
+}
+
+// splitFileConditions separates the `applies_when` attribute of each file
+// from the rest of its body. Files without a condition get a nil expression.
+func splitFileConditions(files []*hcl.File) ([]hcl.Expression, []hcl.Body, hcl.Diagnostics) {
+	var diags hcl.Diagnostics
+	conditions := make([]hcl.Expression, len(files))
+	bodies := make([]hcl.Body, len(files))
+
+	for i, file := range files {
+		content, rest, contentDiags := file.Body.PartialContent(fileConditionSchema)
+		diags = append(diags, contentDiags...)
+		if attr, ok := content.Attributes["applies_when"]; ok {
+			conditions[i] = attr.Expr
+		}
+		bodies[i] = rest
+	}
+
+	return conditions, bodies, diags
+}
+
+// applyFileConditions evaluates the file conditions and returns only the
+// files, and their bodies, that apply.
+func applyFileConditions(files []*hcl.File, conditions []hcl.Expression, bodies []hcl.Body, ctx *hcl.EvalContext) ([]*hcl.File, []hcl.Body, hcl.Diagnostics) {
+	var diags hcl.Diagnostics
+	var includedFiles []*hcl.File
+	var includedBodies []hcl.Body
+
+	for i, cond := range conditions {
+		if cond != nil {
+			val, condDiags := cond.Value(ctx)
+			diags = append(diags, condDiags...)
+			if condDiags.HasErrors() {
+				continue
+			}
+			val, err := convert.Convert(val, cty.Bool)
+			if err != nil || val.IsNull() || !val.IsKnown() {
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Invalid applies_when condition",
+					Detail:   fmt.Sprintf("The applies_when condition of %s must be a known bool.", files[i].Body.MissingItemRange().Filename),
+					Subject:  cond.Range().Ptr(),
+				})
+				continue
+			}
+			if val.False() {
+				continue
+			}
+		}
+		includedFiles = append(includedFiles, files[i])
+		includedBodies = append(includedBodies, bodies[i])
+	}
+
+	return includedFiles, includedBodies, diags
+}
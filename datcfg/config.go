@@ -0,0 +1,227 @@
+package datcfg
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"sync"
+
+	"github.com/hashicorp/hcl2/hcl"
+)
+
+type variableBlock struct {
+	Name    string         `hcl:"name,label"`
+	Default hcl.Attributes `hcl:"default,remain"`
+}
+
+// ClusterConfig is the body of the cluster block.
+type ClusterConfig struct {
+	ControllerCount int `hcl:"controller_count,attr"`
+	WorkerCount     int `hcl:"worker_count,attr"`
+
+	// NodeCount is the number of controllers and workers, see PostDecode.
+	NodeCount int
+}
+
+type clusterBlock struct {
+	Name          string         `hcl:"name,label"`
+	ForEach       hcl.Expression `hcl:"for_each,optional"`
+	ClusterConfig hcl.Body       `hcl:",remain"`
+}
+
+type componentBlock struct {
+	Type      string          `hcl:"type,label"`
+	Name      hcl.Expression  `hcl:"name,optional"`
+	Retries   hcl.Expression  `hcl:"retries,optional"`
+	OnFailure hcl.Expression  `hcl:"on_failure,optional"`
+	Extends   hcl.Expression  `hcl:"extends,optional"`
+	Lifecycle *lifecycleBlock `hcl:"lifecycle,block"`
+	Timeouts  *timeoutsBlock  `hcl:"timeouts,block"`
+	Metadata  *metadataBlock  `hcl:"metadata,block"`
+	Config    hcl.Body        `hcl:",remain"`
+}
+
+// ComponentConfig is the decoded body of a component block. It is a pointer
+// to a struct with `hcl` tags, which can additionally implement Planner,
+// Applier or Outputter.
+type ComponentConfig interface{}
+
+var (
+	componentsMu     sync.RWMutex
+	components       = map[string]ComponentConfig{}
+	componentAliases = map[string]componentAlias{}
+	// registeredAt is where every kind and alias was registered.
+	registeredAt = map[string]string{}
+)
+
+type componentAlias struct {
+	kind   string
+	legacy bool
+}
+
+// AliasOption configures a component alias.
+type AliasOption func(*componentAlias)
+
+// LegacyAlias marks an alias as kept for compatibility only. Using it in a
+// config produces a deprecation warning pointing to the canonical name.
+func LegacyAlias() AliasOption {
+	return func(a *componentAlias) {
+		a.legacy = true
+	}
+}
+
+// RegisterComponent makes a component kind available to all loaders. The
+// given config must be a pointer to a struct, it is only used as a prototype
+// and is never decoded into. It is safe to register kinds concurrently, like
+// from goroutines discovering plugins. Registering a kind twice fails with
+// an error naming both callers.
+func RegisterComponent(kind string, config ComponentConfig) error {
+	return registerComponent(kind, config, callerLocation())
+}
+
+// MustRegisterComponent is like RegisterComponent, but panics if the kind
+// can't be registered, for use in init functions.
+func MustRegisterComponent(kind string, config ComponentConfig) {
+	if err := registerComponent(kind, config, callerLocation()); err != nil {
+		panic(err)
+	}
+}
+
+func registerComponent(kind string, config ComponentConfig, caller string) error {
+	ty := reflect.TypeOf(config)
+	if ty == nil || ty.Kind() != reflect.Ptr || ty.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("config of component kind %q must be a pointer to a struct, not %T", kind, config)
+	}
+
+	componentsMu.Lock()
+	defer componentsMu.Unlock()
+	if _, exists := components[kind]; exists {
+		return fmt.Errorf("component kind %q is registered at %s and again at %s", kind, registeredAt[kind], caller)
+	}
+	if _, exists := componentAliases[kind]; exists {
+		return fmt.Errorf("component kind %q is registered as an alias at %s and as a kind at %s", kind, registeredAt[kind], caller)
+	}
+	components[kind] = config
+	registeredAt[kind] = caller
+	return nil
+}
+
+// RegisterComponentAlias makes alias another name for the component kind,
+// for friendlier or legacy names in configs. Components declared with the
+// alias are reported with the canonical kind. The kind can be registered
+// after the alias, so that the order of the init functions registering them
+// doesn't matter.
+func RegisterComponentAlias(alias, kind string, opts ...AliasOption) error {
+	caller := callerLocation()
+	componentsMu.Lock()
+	defer componentsMu.Unlock()
+	if _, exists := components[alias]; exists {
+		return fmt.Errorf("component alias %q is registered as a kind at %s and as an alias at %s", alias, registeredAt[alias], caller)
+	}
+	if _, exists := componentAliases[alias]; exists {
+		return fmt.Errorf("component alias %q is registered at %s and again at %s", alias, registeredAt[alias], caller)
+	}
+
+	a := componentAlias{kind: kind}
+	for _, opt := range opts {
+		opt(&a)
+	}
+	componentAliases[alias] = a
+	registeredAt[alias] = caller
+	return nil
+}
+
+// callerLocation returns the file and line of the code calling the exported
+// function that calls callerLocation.
+func callerLocation() string {
+	_, file, line, ok := runtime.Caller(2)
+	if !ok {
+		return "an unknown location"
+	}
+	return fmt.Sprintf("%s:%d", file, line)
+}
+
+// canonicalKind returns the kind the given component type label refers to,
+// which differs from the label if it is an alias.
+func canonicalKind(label string) (componentAlias, bool) {
+	componentsMu.RLock()
+	defer componentsMu.RUnlock()
+	a, ok := componentAliases[label]
+	return a, ok
+}
+
+// resolveAlias replaces an alias in the type label of the given component
+// block with the canonical kind.
+func resolveAlias(component *componentBlock) hcl.Diagnostics {
+	a, ok := canonicalKind(component.Type)
+	if !ok {
+		return nil
+	}
+
+	alias := component.Type
+	component.Type = a.kind
+	if !a.legacy {
+		return nil
+	}
+	return hcl.Diagnostics{
+		{
+			Severity: hcl.DiagWarning,
+			Summary:  "Deprecated component kind",
+			Detail:   fmt.Sprintf("The component kind %q is deprecated: use %q.", alias, a.kind),
+			Subject:  component.Config.MissingItemRange().Ptr(),
+		},
+	}
+}
+
+// componentType returns the config struct type of the given component kind
+// or alias.
+func componentType(kind string) (reflect.Type, bool) {
+	if a, ok := canonicalKind(kind); ok {
+		kind = a.kind
+	}
+
+	componentsMu.RLock()
+	defer componentsMu.RUnlock()
+	proto, ok := components[kind]
+	if !ok {
+		return nil, false
+	}
+	return reflect.TypeOf(proto).Elem(), true
+}
+
+// newComponent returns a new, empty config struct for the given component
+// type, so that several components of the same type don't share state.
+func newComponent(kind string) (ComponentConfig, bool) {
+	ty, ok := componentType(kind)
+	if !ok {
+		return nil, false
+	}
+	return reflect.New(ty).Interface(), true
+}
+
+// Settings is the optional settings block.
+type Settings struct {
+	Hooks  *HooksSettings  `hcl:"hooks,block"`
+	Naming *NamingSettings `hcl:"naming,block"`
+	// Redact are glob patterns over attribute names whose values are
+	// masked in output, see Config.Redacted.
+	Redact []string `hcl:"redact,optional"`
+}
+
+type configRoot struct {
+	Cluster        clusterBlock         `hcl:"cluster,block"`
+	ClusterConfigs []clusterConfigBlock `hcl:"cluster_config,block"`
+	Components     []componentBlock     `hcl:"component,block"`
+	Variables      []variableBlock      `hcl:"variable,block"`
+	Locals         []localsBlock        `hcl:"locals,block"`
+	Sources        []valueSourceBlock   `hcl:"variable_source,block"`
+	Settings       *Settings            `hcl:"settings,block"`
+	Tests          []testBlock          `hcl:"test,block"`
+	Moved          []movedBlock         `hcl:"moved,block"`
+	Defaults       []defaultsBlock      `hcl:"defaults,block"`
+	Modules        []moduleBlock        `hcl:"module,block"`
+
+	// Registered holds the blocks of the types registered with
+	// RegisterBlockType, and anything unsupported.
+	Registered hcl.Body `hcl:",remain"`
+}
@@ -0,0 +1,136 @@
+package datcfg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+)
+
+// ContextFunctionSpec is like function.Spec, for functions doing I/O, like
+// reading files or looking values up in a service. Their implementation gets
+// the context of the load, see WithContext, with the deadline set by
+// WithFunctionTimeout, and should give up once it is done.
+type ContextFunctionSpec struct {
+	Params   []function.Parameter
+	VarParam *function.Parameter
+	Type     function.TypeFunc
+	Impl     func(ctx context.Context, args []cty.Value, retType cty.Type) (cty.Value, error)
+}
+
+// contextFunctions are the specs of the functions registered with
+// RegisterContextFunction, by their internal name. They are in
+// pluginFunctions too, called with a background context, and guarded by
+// functionsMu.
+var contextFunctions = map[string]*ContextFunctionSpec{}
+
+// RegisterContextFunction is like RegisterFunction, for a function whose
+// implementation gets a context.
+func RegisterContextFunction(namespace, name string, spec *ContextFunctionSpec) error {
+	return registerFunction(namespace, name, backgroundFunction(spec), spec)
+}
+
+// WithContextFunction is like WithFunction, for a function whose
+// implementation gets a context.
+func WithContextFunction(namespace, name string, spec *ContextFunctionSpec) LoaderOption {
+	return func(l *Loader) {
+		WithFunction(namespace, name, backgroundFunction(spec))(l)
+		key, _ := namespacedFunctionName(namespace, name)
+		l.contextFunctions[key] = spec
+	}
+}
+
+// WithContext sets the context of the load. Once it is done, the calls of
+// the registered functions in progress return an error, instead of holding
+// up the load, and so do all further function calls. The load stops before
+// the next component, so that callers can bound the time spent on expensive
+// configs.
+func WithContext(ctx context.Context) LoaderOption {
+	return func(l *Loader) {
+		l.ctx = ctx
+	}
+}
+
+// WithFunctionTimeout limits how long every call of a registered function
+// may take. A call that doesn't return in time is an error. Functions
+// registered with a ContextFunctionSpec get the deadline in their context,
+// the others are left to finish in the background and their result is
+// dropped.
+func WithFunctionTimeout(timeout time.Duration) LoaderOption {
+	return func(l *Loader) {
+		l.functionTimeout = timeout
+	}
+}
+
+// backgroundFunction returns the function of the given spec, called with a
+// background context, as it is outside of a loader.
+func backgroundFunction(spec *ContextFunctionSpec) function.Function {
+	return function.New(&function.Spec{
+		Params:   spec.Params,
+		VarParam: spec.VarParam,
+		Type:     spec.Type,
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			return spec.Impl(context.Background(), args, retType)
+		},
+	})
+}
+
+// guardedFunction returns the given registered function as called by the
+// loader, with the context and the timeout of the load. spec is nil for
+// functions that don't get a context.
+func (l *Loader) guardedFunction(fn function.Function, spec *ContextFunctionSpec) function.Function {
+	if l.ctx == nil && l.functionTimeout == 0 {
+		return fn
+	}
+
+	return function.New(&function.Spec{
+		Params:   fn.Params(),
+		VarParam: fn.VarParam(),
+		Type:     fn.ReturnTypeForValues,
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			return l.callWithin(func(ctx context.Context) (cty.Value, error) {
+				if spec != nil {
+					return spec.Impl(ctx, args, retType)
+				}
+				return fn.Call(args)
+			})
+		},
+	})
+}
+
+// callWithin runs the given call of a registered function, waiting for it
+// until the context of the load is done or the function timeout runs out.
+func (l *Loader) callWithin(call func(ctx context.Context) (cty.Value, error)) (cty.Value, error) {
+	parent := l.ctx
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx := parent
+	if l.functionTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(parent, l.functionTimeout)
+		defer cancel()
+	}
+
+	type result struct {
+		val cty.Value
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		val, err := call(ctx)
+		done <- result{val, err}
+	}()
+	select {
+	case r := <-done:
+		return r.val, r.err
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) && parent.Err() == nil {
+			return cty.DynamicVal, fmt.Errorf("the call did not return within the function timeout of %s", l.functionTimeout)
+		}
+		return cty.DynamicVal, fmt.Errorf("the load was stopped: %w", ctx.Err())
+	}
+}
@@ -0,0 +1,148 @@
+package datcfg
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hcl/hclsyntax"
+	"github.com/hashicorp/hcl2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+)
+
+// ImplicitConversionRule is the name of the lint rule flagging quoted
+// numbers and bools, which is off unless enabled in the lint config.
+const ImplicitConversionRule = "implicit_conversion"
+
+// implicitConversion is a string literal set for a number or bool argument,
+// like `worker_count = "3"`. Decoding converts it silently, which often
+// hides a mistake, like a value meant for another argument.
+type implicitConversion struct {
+	attr *hclsyntax.Attribute
+	lit  string
+	// val is the literal, converted to the type of the argument.
+	val cty.Value
+}
+
+// implicitConversions returns the implicit conversions in the top-level
+// blocks of the given file whose schema is known, and their nested blocks.
+func implicitConversions(file *hcl.File) []implicitConversion {
+	body, ok := file.Body.(*hclsyntax.Body)
+	if !ok {
+		return nil
+	}
+	var found []implicitConversion
+	for _, block := range body.Blocks {
+		if ty := blockConfigType(block); ty != nil {
+			found = append(found, bodyConversions(block.Body, ty)...)
+		}
+	}
+	return found
+}
+
+func bodyConversions(body *hclsyntax.Body, ty reflect.Type) []implicitConversion {
+	tags := getDecodeFieldTags(ty)
+
+	var found []implicitConversion
+	for _, attr := range sortedAttributes(body) {
+		fieldIdx, ok := tags.Attributes[attr.Name]
+		if !ok {
+			continue
+		}
+		want, ok := conversionTarget(ty.Field(fieldIdx).Type)
+		if !ok {
+			continue
+		}
+		lit, ok := stringLiteral(attr.Expr)
+		if !ok {
+			continue
+		}
+		if val, err := convert.Convert(cty.StringVal(lit), want); err == nil {
+			found = append(found, implicitConversion{attr: attr, lit: lit, val: val})
+		}
+	}
+
+	for _, block := range body.Blocks {
+		fieldIdx, ok := tags.Blocks[block.Type]
+		if !ok {
+			continue
+		}
+		blockTy := ty.Field(fieldIdx).Type
+		for blockTy.Kind() == reflect.Slice || blockTy.Kind() == reflect.Ptr {
+			blockTy = blockTy.Elem()
+		}
+		if blockTy.Kind() == reflect.Struct && blockTy != blockType {
+			found = append(found, bodyConversions(block.Body, blockTy)...)
+		}
+	}
+	return found
+}
+
+// conversionTarget returns the cty type string literals are converted to for
+// fields of the given type, if it is a number or a bool. Fields decoded by a
+// hook take strings on purpose.
+func conversionTarget(ty reflect.Type) (cty.Type, bool) {
+	if hook, _ := decodeHookFor(ty); hook != nil {
+		return cty.NilType, false
+	}
+	for ty.Kind() == reflect.Ptr {
+		ty = ty.Elem()
+	}
+	switch {
+	case isIntegerKind(ty), ty.Kind() == reflect.Float32, ty.Kind() == reflect.Float64:
+		return cty.Number, true
+	case ty.Kind() == reflect.Bool:
+		return cty.Bool, true
+	}
+	return cty.NilType, false
+}
+
+// stringLiteral returns the content of a quoted string without any
+// interpolation.
+func stringLiteral(expr hclsyntax.Expression) (string, bool) {
+	tmpl, ok := expr.(*hclsyntax.TemplateExpr)
+	if !ok || len(tmpl.Parts) != 1 {
+		return "", false
+	}
+	lit, ok := tmpl.Parts[0].(*hclsyntax.LiteralValueExpr)
+	if !ok || !lit.Val.Type().Equals(cty.String) {
+		return "", false
+	}
+	return lit.Val.AsString(), true
+}
+
+func lintImplicitConversions(files []*hcl.File) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+	for _, file := range files {
+		for _, conv := range implicitConversions(file) {
+			diags = append(diags, &hcl.Diagnostic{
+				Summary: "Implicit type conversion",
+				Detail: fmt.Sprintf(
+					"The string %q is converted to a %s for the argument %q. Remove the quotes if that is intended: %s = %s",
+					conv.lit, conv.val.Type().FriendlyName(), conv.attr.Name, conv.attr.Name, valueTokens(conv.val),
+				),
+				Subject: conv.attr.Expr.Range().Ptr(),
+			})
+		}
+	}
+	return diags
+}
+
+// ImplicitConversionFixes returns the edits replacing the quoted numbers and
+// bools flagged by the implicit_conversion lint rule in the given file with
+// the values they are converted to.
+func ImplicitConversionFixes(file *hcl.File) []SourceEdit {
+	var edits []SourceEdit
+	for _, conv := range implicitConversions(file) {
+		edits = append(edits, SourceEdit{
+			Range:       conv.attr.Expr.Range(),
+			Replacement: valueTokens(conv.val),
+		})
+	}
+	return edits
+}
+
+func valueTokens(val cty.Value) []byte {
+	return hclwrite.TokensForValue(val).Bytes()
+}
@@ -0,0 +1,126 @@
+package datcfg
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl2/hcl"
+)
+
+// checkReferenceCycles reports the cycles of references among the variables,
+// locals, clusters and components of the given files, like two components
+// using each other's outputs. Evaluating them would fail on whichever comes
+// first with an unknown reference, so the whole cycle is reported instead,
+// with the range of every reference on it.
+func checkReferenceCycles(files []*hcl.File) hcl.Diagnostics {
+	refs := referenceTraversals(files)
+
+	// The range of the first reference from a node to another.
+	edges := map[string]map[string]hcl.Range{}
+	kinds := componentNodes(refs)
+	for node, traversals := range refs {
+		edges[node] = map[string]hcl.Range{}
+		for _, traversal := range traversals {
+			var targets []string
+			if target, ok := traversalAddress(traversal); ok {
+				targets = []string{target}
+			}
+			if traversal.RootName() == "component" {
+				targets = componentTargets(traversal, refs, kinds)
+			}
+			for _, target := range targets {
+				if _, exists := refs[target]; !exists {
+					continue
+				}
+				// A local referring to itself is a cycle, but the blocks
+				// of a component kind may refer to each other.
+				if target == node && !strings.HasPrefix(node, "local.") || sameComponentKind(node, target) {
+					continue
+				}
+				if _, seen := edges[node][target]; !seen {
+					edges[node][target] = traversal.SourceRange()
+				}
+			}
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := map[string]int{}
+	var path []string
+	var diags hcl.Diagnostics
+
+	var visit func(node string)
+	visit = func(node string) {
+		state[node] = visiting
+		path = append(path, node)
+		for _, target := range sortedTargets(edges[node]) {
+			switch state[target] {
+			case unvisited:
+				visit(target)
+			case visiting:
+				for i := range path {
+					if path[i] == target {
+						diags = append(diags, referenceCycle(path[i:], edges))
+						break
+					}
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[node] = done
+	}
+
+	for _, node := range sortedNodes(edges) {
+		if state[node] == unvisited {
+			visit(node)
+		}
+	}
+	return diags
+}
+
+// referenceCycle describes the cycle through the given nodes, each of which
+// refers to the next one and the last one to the first.
+func referenceCycle(cycle []string, edges map[string]map[string]hcl.Range) *hcl.Diagnostic {
+	steps := make([]string, len(cycle))
+	for i, node := range cycle {
+		next := cycle[(i+1)%len(cycle)]
+		steps[i] = fmt.Sprintf("%s refers to %s at %s", node, next, edges[node][next])
+	}
+	first := edges[cycle[0]][cycle[1%len(cycle)]]
+	return &hcl.Diagnostic{
+		Severity: hcl.DiagError,
+		Summary:  "Reference cycle",
+		Detail:   fmt.Sprintf("The references %s -> %s form a cycle, so none of them can be evaluated: %s.", strings.Join(cycle, " -> "), cycle[0], strings.Join(steps, ", ")),
+		Subject:  first.Ptr(),
+	}
+}
+
+func sortedNodes(nodes map[string]map[string]hcl.Range) []string {
+	names := make([]string, 0, len(nodes))
+	for name := range nodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sortedTargets(targets map[string]hcl.Range) []string {
+	names := make([]string, 0, len(targets))
+	for name := range targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// sameComponentKind reports whether the given graph nodes are components of
+// the same kind.
+func sameComponentKind(node, target string) bool {
+	nodeParts, targetParts := strings.Split(node, "."), strings.Split(target, ".")
+	return nodeParts[0] == "component" && targetParts[0] == "component" && nodeParts[1] == targetParts[1]
+}
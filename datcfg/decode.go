@@ -0,0 +1,464 @@
+package datcfg
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/hcl2/gohcl"
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+	"github.com/zclconf/go-cty/cty/gocty"
+)
+
+// DecodeHook converts a cty value into a Go value of the type it was
+// registered for. It is used for types gocty cannot decode into on its own,
+// like `time.Duration`, `ByteSize` or `net.IP`, which are usually written as
+// strings in config files.
+type DecodeHook func(val cty.Value) (interface{}, error)
+
+var (
+	decodeHooksMu sync.RWMutex
+	decodeHooks   = map[reflect.Type]DecodeHook{}
+)
+
+// RegisterDecodeHook registers a hook that DecodeBody and the loader use
+// whenever an attribute is decoded into a field of the given type (or a
+// pointer to it). A hook registered for a type replaces the previous one.
+func RegisterDecodeHook(ty reflect.Type, hook DecodeHook) {
+	decodeHooksMu.Lock()
+	defer decodeHooksMu.Unlock()
+	decodeHooks[ty] = hook
+}
+
+func init() {
+	RegisterDecodeHook(reflect.TypeOf(time.Duration(0)), func(val cty.Value) (interface{}, error) {
+		s, err := stringFromValue(val)
+		if err != nil {
+			return nil, err
+		}
+		return parseDuration(s)
+	})
+	RegisterDecodeHook(reflect.TypeOf(ByteSize(0)), func(val cty.Value) (interface{}, error) {
+		s, err := stringFromValue(val)
+		if err != nil {
+			return nil, err
+		}
+		return ParseByteSize(s)
+	})
+	RegisterDecodeHook(reflect.TypeOf(net.IP{}), func(val cty.Value) (interface{}, error) {
+		s, err := stringFromValue(val)
+		if err != nil {
+			return nil, err
+		}
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil, fmt.Errorf("%q is not a valid IP address", s)
+		}
+		return ip, nil
+	})
+	RegisterDecodeHook(reflect.TypeOf(url.URL{}), func(val cty.Value) (interface{}, error) {
+		s, err := stringFromValue(val)
+		if err != nil {
+			return nil, err
+		}
+		u, err := url.Parse(s)
+		if err != nil {
+			return nil, err
+		}
+		return *u, nil
+	})
+}
+
+func stringFromValue(val cty.Value) (string, error) {
+	val, err := convert.Convert(val, cty.String)
+	if err != nil {
+		return "", err
+	}
+	if val.IsNull() {
+		return "", fmt.Errorf("a non-null string is required")
+	}
+	if !val.IsKnown() {
+		return "", fmt.Errorf("the value is not known yet")
+	}
+	return val.AsString(), nil
+}
+
+// decodeFieldTags is the subset of the `hcl` struct tags DecodeBody needs to
+// know about.
+type decodeFieldTags struct {
+	Attributes map[string]int
+	Blocks     map[string]int
+	Labels     []int
+	Remain     *int
+}
+
+func getDecodeFieldTags(ty reflect.Type) *decodeFieldTags {
+	ret := &decodeFieldTags{
+		Attributes: map[string]int{},
+		Blocks:     map[string]int{},
+	}
+
+	for i := 0; i < ty.NumField(); i++ {
+		tag := ty.Field(i).Tag.Get("hcl")
+		if tag == "" {
+			continue
+		}
+
+		name, kind := tag, "attr"
+		if comma := strings.Index(tag, ","); comma != -1 {
+			name, kind = tag[:comma], tag[comma+1:]
+		}
+
+		switch kind {
+		case "attr", "optional":
+			ret.Attributes[name] = i
+		case "block":
+			ret.Blocks[name] = i
+		case "label":
+			ret.Labels = append(ret.Labels, i)
+		case "remain":
+			idx := i
+			ret.Remain = &idx
+		}
+	}
+
+	return ret
+}
+
+// sortedNames returns the keys of the given field index map in
+// lexicographical order, so that diagnostics are reported deterministically.
+func sortedNames(fields map[string]int) []string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DecodeBody decodes the given body into val, which must be a pointer, like
+// `gohcl.DecodeBody`. For struct targets, it also consults the decode hooks
+// registered with RegisterDecodeHook for attributes and nested blocks.
+func DecodeBody(body hcl.Body, ctx *hcl.EvalContext, val interface{}) hcl.Diagnostics {
+	rv := reflect.ValueOf(val)
+	if rv.Kind() != reflect.Ptr {
+		panic(fmt.Sprintf("target value must be a pointer, not %s", rv.Type().String()))
+	}
+	if rv.Elem().Kind() != reflect.Struct {
+		return gohcl.DecodeBody(body, ctx, val)
+	}
+
+	return decodeBodyToStruct(body, ctx, rv.Elem())
+}
+
+var (
+	blockType = reflect.TypeOf(hcl.Block{})
+	bodyType  = reflect.TypeOf((*hcl.Body)(nil)).Elem()
+	attrType  = reflect.TypeOf((*hcl.Attribute)(nil))
+	attrsType = reflect.TypeOf(hcl.Attributes(nil))
+	exprType  = reflect.TypeOf((*hcl.Expression)(nil)).Elem()
+)
+
+func decodeBodyToStruct(body hcl.Body, ctx *hcl.EvalContext, val reflect.Value) hcl.Diagnostics {
+	schema, partial := gohcl.ImpliedBodySchema(val.Addr().Interface())
+
+	var content *hcl.BodyContent
+	var leftovers hcl.Body
+	var diags hcl.Diagnostics
+	if partial {
+		content, leftovers, diags = body.PartialContent(schema)
+	} else {
+		content, diags = body.Content(schema)
+	}
+	diags = enrichDiagnostics(diags, schema, val.Type())
+	if content == nil {
+		return diags
+	}
+
+	tags := getDecodeFieldTags(val.Type())
+
+	if tags.Remain != nil {
+		diags = append(diags, decodeBodyToField(leftovers, ctx, val.Field(*tags.Remain))...)
+	}
+
+	for _, name := range sortedNames(tags.Attributes) {
+		fieldIdx := tags.Attributes[name]
+		attr := content.Attributes[name]
+		fieldV := val.Field(fieldIdx)
+		if attr == nil {
+			continue
+		}
+
+		if msg, ok := val.Type().Field(fieldIdx).Tag.Lookup("deprecated"); ok {
+			diags = append(diags, deprecationWarning(attr, msg))
+		}
+
+		if kinds, ok := variantKinds(fieldV.Type()); ok {
+			diags = append(diags, decodeVariant(attr, ctx, fieldV, kinds)...)
+			continue
+		}
+		if hook, isPtr := decodeHookFor(fieldV.Type()); hook != nil {
+			diags = append(diags, decodeAttrWithHook(attr, ctx, fieldV, hook, isPtr)...)
+			continue
+		}
+
+		switch {
+		case attrType.AssignableTo(fieldV.Type()):
+			fieldV.Set(reflect.ValueOf(attr))
+		case exprType.AssignableTo(fieldV.Type()):
+			fieldV.Set(reflect.ValueOf(attr.Expr))
+		default:
+			diags = append(diags, decodeExpression(attr.Expr, attr.Name, ctx, fieldV)...)
+		}
+	}
+
+	blocksByType := content.Blocks.ByType()
+	for _, typeName := range sortedNames(tags.Blocks) {
+		fieldIdx := tags.Blocks[typeName]
+		diags = append(diags, decodeBlocksToField(blocksByType[typeName], typeName, ctx, val.Field(fieldIdx), body.MissingItemRange())...)
+	}
+
+	return diags
+}
+
+// decodeBodyToField decodes a whole body into a field, which can either be
+// one of the hcl "raw" types or a struct.
+func decodeBodyToField(body hcl.Body, ctx *hcl.EvalContext, fieldV reflect.Value) hcl.Diagnostics {
+	switch {
+	case bodyType.AssignableTo(fieldV.Type()):
+		fieldV.Set(reflect.ValueOf(body))
+		return nil
+	case attrsType.AssignableTo(fieldV.Type()):
+		attrs, diags := body.JustAttributes()
+		fieldV.Set(reflect.ValueOf(attrs))
+		return diags
+	case fieldV.Kind() == reflect.Struct:
+		return decodeBodyToStruct(body, ctx, fieldV)
+	default:
+		return gohcl.DecodeBody(body, ctx, fieldV.Addr().Interface())
+	}
+}
+
+// largeCollectionSize is the number of elements above which a list, set or
+// tuple value is decoded into a Go slice one element at a time. Converting
+// the whole collection up front would materialize a second copy of it.
+const largeCollectionSize = 1024
+
+// decodeExpression works like `gohcl.DecodeExpression`, but streams large
+// collections into slice targets element by element. Values that are not
+// known yet, like outputs of components that are only known after apply, are
+// type-checked and leave the target unset. The name of the argument the
+// expression belongs to is used in diagnostics.
+func decodeExpression(expr hcl.Expression, name string, ctx *hcl.EvalContext, fieldV reflect.Value) hcl.Diagnostics {
+	srcVal, diags := expr.Value(ctx)
+	if fieldV.Kind() == reflect.Slice {
+		srcVal = sortedSet(srcVal)
+	}
+
+	if isLargeCollection(srcVal) && fieldV.Kind() == reflect.Slice && fieldV.Type().Elem().Kind() != reflect.Uint8 {
+		return append(diags, decodeLargeCollection(srcVal, expr, fieldV)...)
+	}
+
+	convTy, err := gocty.ImpliedType(fieldV.Addr().Interface())
+	if err != nil {
+		panic(fmt.Sprintf("unsuitable DecodeExpression target: %s", err))
+	}
+
+	convVal, err := convert.Convert(srcVal, convTy)
+	if err != nil {
+		return append(diags, typeMismatch(expr, name, fieldV.Type(), convTy, srcVal))
+	}
+	if !convVal.IsWhollyKnown() {
+		return diags
+	}
+	if isIntegerKind(fieldV.Type()) && !convVal.IsNull() && !isWholeNumber(convVal, isUnsignedKind(fieldV.Type())) {
+		return append(diags, typeMismatch(expr, name, fieldV.Type(), convTy, srcVal))
+	}
+	if err := gocty.FromCtyValue(convVal, fieldV.Addr().Interface()); err != nil {
+		diags = append(diags, unsuitableValue(expr, err))
+	}
+
+	return diags
+}
+
+func isLargeCollection(val cty.Value) bool {
+	ty := val.Type()
+	if !(ty.IsListType() || ty.IsSetType() || ty.IsTupleType()) {
+		return false
+	}
+	return val.IsWhollyKnown() && !val.IsNull() && val.LengthInt() > largeCollectionSize
+}
+
+func decodeLargeCollection(val cty.Value, expr hcl.Expression, fieldV reflect.Value) hcl.Diagnostics {
+	elemV := reflect.New(fieldV.Type().Elem())
+	elemTy, err := gocty.ImpliedType(elemV.Interface())
+	if err != nil {
+		panic(fmt.Sprintf("unsuitable DecodeExpression target: %s", err))
+	}
+
+	sli := reflect.MakeSlice(fieldV.Type(), 0, val.LengthInt())
+	for it := val.ElementIterator(); it.Next(); {
+		_, elem := it.Element()
+		elem, err := convert.Convert(elem, elemTy)
+		if err == nil {
+			elemV.Elem().Set(reflect.Zero(elemV.Elem().Type()))
+			err = gocty.FromCtyValue(elem, elemV.Interface())
+		}
+		if err != nil {
+			return hcl.Diagnostics{unsuitableValue(expr, fmt.Errorf("element %d: %s", sli.Len(), err))}
+		}
+		sli = reflect.Append(sli, elemV.Elem())
+	}
+	fieldV.Set(sli)
+
+	return nil
+}
+
+func unsuitableValue(expr hcl.Expression, err error) *hcl.Diagnostic {
+	return &hcl.Diagnostic{
+		Severity: hcl.DiagError,
+		Summary:  "Unsuitable value type",
+		Detail:   fmt.Sprintf("Unsuitable value: %s", err.Error()),
+		Subject:  expr.StartRange().Ptr(),
+		Context:  expr.Range().Ptr(),
+	}
+}
+
+func decodeHookFor(ty reflect.Type) (DecodeHook, bool) {
+	decodeHooksMu.RLock()
+	defer decodeHooksMu.RUnlock()
+	if hook, ok := decodeHooks[ty]; ok {
+		return hook, false
+	}
+	if ty.Kind() == reflect.Ptr {
+		if hook, ok := decodeHooks[ty.Elem()]; ok {
+			return hook, true
+		}
+	}
+	return nil, false
+}
+
+func decodeAttrWithHook(attr *hcl.Attribute, ctx *hcl.EvalContext, fieldV reflect.Value, hook DecodeHook, isPtr bool) hcl.Diagnostics {
+	val, diags := attr.Expr.Value(ctx)
+	if diags.HasErrors() {
+		return diags
+	}
+	if val.IsNull() && isPtr {
+		fieldV.Set(reflect.Zero(fieldV.Type()))
+		return diags
+	}
+	if !val.IsWhollyKnown() {
+		return diags
+	}
+
+	result, err := hook(val)
+	if err != nil {
+		return append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Unsuitable value type",
+			Detail:   fmt.Sprintf("Unsuitable value for %q: %s.", attr.Name, err),
+			Subject:  attr.Expr.StartRange().Ptr(),
+			Context:  attr.Expr.Range().Ptr(),
+		})
+	}
+
+	rv := reflect.ValueOf(result)
+	if isPtr {
+		ptr := reflect.New(fieldV.Type().Elem())
+		ptr.Elem().Set(rv)
+		rv = ptr
+	}
+	fieldV.Set(rv)
+
+	return diags
+}
+
+func decodeBlocksToField(blocks hcl.Blocks, typeName string, ctx *hcl.EvalContext, fieldV reflect.Value, missing hcl.Range) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+
+	ty := fieldV.Type()
+	isSlice, isPtr := false, false
+	if ty.Kind() == reflect.Slice {
+		isSlice = true
+		ty = ty.Elem()
+	}
+	if ty.Kind() == reflect.Ptr {
+		isPtr = true
+		ty = ty.Elem()
+	}
+
+	if len(blocks) > 1 && !isSlice {
+		return append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  fmt.Sprintf("Duplicate %s block", typeName),
+			Detail: fmt.Sprintf(
+				"Only one %s block is allowed. Another was defined at %s.",
+				typeName, blocks[0].DefRange.String(),
+			),
+			Subject: &blocks[1].DefRange,
+		})
+	}
+
+	if len(blocks) == 0 {
+		if isSlice || isPtr {
+			fieldV.Set(reflect.Zero(fieldV.Type()))
+			return diags
+		}
+		return append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  fmt.Sprintf("Missing %s block", typeName),
+			Detail:   fmt.Sprintf("A %s block is required.", typeName),
+			Subject:  missing.Ptr(),
+		})
+	}
+
+	decodeOne := func(block *hcl.Block) (reflect.Value, hcl.Diagnostics) {
+		v := reflect.New(ty)
+		if ty == blockType {
+			v.Elem().Set(reflect.ValueOf(*block))
+			return v, nil
+		}
+		diags := decodeBodyToField(block.Body, ctx, v.Elem())
+		if ty.Kind() == reflect.Struct {
+			labelIdxs := getDecodeFieldTags(ty).Labels
+			for li, lv := range block.Labels {
+				if li < len(labelIdxs) {
+					v.Elem().Field(labelIdxs[li]).Set(reflect.ValueOf(lv))
+				}
+			}
+		}
+		return v, diags
+	}
+
+	if !isSlice {
+		v, blockDiags := decodeOne(blocks[0])
+		diags = append(diags, blockDiags...)
+		if isPtr {
+			fieldV.Set(v)
+		} else {
+			fieldV.Set(v.Elem())
+		}
+		return diags
+	}
+
+	sli := reflect.MakeSlice(fieldV.Type(), len(blocks), len(blocks))
+	for i, block := range blocks {
+		v, blockDiags := decodeOne(block)
+		diags = append(diags, blockDiags...)
+		if isPtr {
+			sli.Index(i).Set(v)
+		} else {
+			sli.Index(i).Set(v.Elem())
+		}
+	}
+	fieldV.Set(sli)
+
+	return diags
+}
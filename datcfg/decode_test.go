@@ -0,0 +1,60 @@
+package datcfg
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/hcl2/gohcl"
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// subnetsExpr returns a tuple of n CIDR strings, like a list of subnets in
+// a generated values file.
+func subnetsExpr(n int) hcl.Expression {
+	subnets := make([]cty.Value, n)
+	for i := range subnets {
+		subnets[i] = cty.StringVal(fmt.Sprintf("10.%d.%d.0/24", i/256%256, i%256))
+	}
+	return hcl.StaticExpr(cty.TupleVal(subnets), hcl.Range{})
+}
+
+func TestDecodeLargeCollection(t *testing.T) {
+	expr := subnetsExpr(largeCollectionSize + 1)
+	var streamed, converted []string
+	if diags := decodeExpression(expr, "subnets", nil, reflect.ValueOf(&streamed).Elem()); diags.HasErrors() {
+		t.Fatal(diags)
+	}
+	if diags := gohcl.DecodeExpression(expr, nil, &converted); diags.HasErrors() {
+		t.Fatal(diags)
+	}
+	if !reflect.DeepEqual(streamed, converted) {
+		t.Error("the streamed collection differs from the converted one")
+	}
+}
+
+// BenchmarkDecodeLargeCollection compares decoding a large collection into a
+// slice element by element, as decodeExpression does, to converting it as a
+// whole, as gohcl.DecodeExpression does.
+func BenchmarkDecodeLargeCollection(b *testing.B) {
+	expr := subnetsExpr(100000)
+	b.Run("streamed", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var subnets []string
+			if diags := decodeExpression(expr, "subnets", nil, reflect.ValueOf(&subnets).Elem()); diags.HasErrors() {
+				b.Fatal(diags)
+			}
+		}
+	})
+	b.Run("converted", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var subnets []string
+			if diags := gohcl.DecodeExpression(expr, nil, &subnets); diags.HasErrors() {
+				b.Fatal(diags)
+			}
+		}
+	})
+}
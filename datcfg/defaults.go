@@ -0,0 +1,128 @@
+package datcfg
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/hcl2/gohcl"
+	"github.com/hashicorp/hcl2/hcl"
+)
+
+// DefaultsFile is the config file declaring the defaults of component
+// kinds, shared by all configs of an organization:
+//
+//	defaults "foo" {
+//	  foo = "hello"
+//	}
+//
+// Every component block of the kind inherits the attributes and nested
+// blocks of its defaults block that it doesn't set itself, or through the
+// component it extends, like with extends.
+const DefaultsFile = "defaults.datcfg"
+
+type defaultsBlock struct {
+	Type   string   `hcl:"type,label"`
+	Config hcl.Body `hcl:",remain"`
+}
+
+// applyDefaults merges the defaults blocks underneath the bodies of the
+// component blocks of their kind. Defaults blocks are only allowed in
+// DefaultsFile, one per kind, and must not set meta-arguments, which are
+// given per component.
+func applyDefaults(root *configRoot) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+	defaults := map[string]defaultsBlock{}
+	for _, block := range root.Defaults {
+		rng := block.Config.MissingItemRange()
+		if rng.Filename != DefaultsFile {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Misplaced defaults block",
+				Detail:   fmt.Sprintf("The defaults of component kinds are declared in %s, so that they are found in one place.", DefaultsFile),
+				Subject:  rng.Ptr(),
+			})
+			continue
+		}
+
+		kind := canonicalKindName(block.Type)
+		if prev, ok := defaults[kind]; ok {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Duplicate defaults block",
+				Detail:   fmt.Sprintf("The defaults of component kind %q were already declared at %s.", kind, prev.Config.MissingItemRange()),
+				Subject:  rng.Ptr(),
+			})
+			continue
+		}
+		if _, ok := componentType(kind); !ok && !hasComponentOfKind(root.Components, kind) {
+			detail := fmt.Sprintf("There is no component kind %q.", block.Type)
+			if suggestion, ok := nearestName(block.Type, componentKinds()); ok {
+				detail += fmt.Sprintf(" Did you mean %q?", suggestion)
+			}
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Defaults of unknown component kind",
+				Detail:   detail,
+				Subject:  rng.Ptr(),
+			})
+			continue
+		}
+
+		metaDiags := defaultsMetaArguments(block.Config)
+		diags = append(diags, metaDiags...)
+		if metaDiags.HasErrors() {
+			continue
+		}
+		defaults[kind] = block
+	}
+
+	for i, component := range root.Components {
+		if block, ok := defaults[canonicalKindName(component.Type)]; ok {
+			root.Components[i].Config = extendedBody{body: component.Config, base: block.Config}
+		}
+	}
+	return diags
+}
+
+// defaultsMetaArguments reports the meta-arguments and meta blocks set in
+// the body of a defaults block.
+func defaultsMetaArguments(body hcl.Body) hcl.Diagnostics {
+	schema, _ := gohcl.ImpliedBodySchema(componentBlock{})
+	schema.Attributes = append(schema.Attributes, metaSchema.Attributes...)
+	content, _, _ := body.PartialContent(optionalSchema(schema))
+
+	names := make([]string, 0, len(content.Attributes))
+	for name := range content.Attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var diags hcl.Diagnostics
+	for _, name := range names {
+		attr := content.Attributes[name]
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Meta-argument in defaults",
+			Detail:   fmt.Sprintf("The %s argument is given per component, it can't have a default.", attr.Name),
+			Subject:  attr.NameRange.Ptr(),
+		})
+	}
+	for _, block := range content.Blocks {
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Meta-argument in defaults",
+			Detail:   fmt.Sprintf("The %s block is given per component, it can't have a default.", block.Type),
+			Subject:  block.TypeRange.Ptr(),
+		})
+	}
+	return diags
+}
+
+func hasComponentOfKind(components []componentBlock, kind string) bool {
+	for _, component := range components {
+		if canonicalKindName(component.Type) == kind {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,161 @@
+package datcfg
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hcl/hclsyntax"
+	"github.com/hashicorp/hcl2/hclwrite"
+)
+
+// deprecationWarning returns the warning emitted when a deprecated attribute
+// is set. By convention msg is of the form "use new_name", which also tells
+// the `fix` subcommand how to rewrite the attribute.
+func deprecationWarning(attr *hcl.Attribute, msg string) *hcl.Diagnostic {
+	return &hcl.Diagnostic{
+		Severity: hcl.DiagWarning,
+		Summary:  "Deprecated attribute",
+		Detail:   fmt.Sprintf("The attribute %q is deprecated: %s.", attr.Name, msg),
+		Subject:  attr.NameRange.Ptr(),
+	}
+}
+
+// deprecatedAttributes returns the messages of all attributes of the given
+// config struct type that carry a `deprecated` tag, keyed by attribute name.
+func deprecatedAttributes(ty reflect.Type) map[string]string {
+	deprecated := map[string]string{}
+	if ty == nil {
+		return deprecated
+	}
+
+	tags := getDecodeFieldTags(ty)
+	for name, fieldIdx := range tags.Attributes {
+		if msg, ok := ty.Field(fieldIdx).Tag.Lookup("deprecated"); ok {
+			deprecated[name] = msg
+		}
+	}
+	return deprecated
+}
+
+// blockConfigType returns the Go struct type the body of the given top-level
+// block is decoded into, or nil if it is not known.
+func blockConfigType(block *hclsyntax.Block) reflect.Type {
+	switch block.Type {
+	case "cluster":
+		return reflect.TypeOf(ClusterConfig{})
+	case "component":
+		if len(block.Labels) == 0 {
+			return nil
+		}
+		if ty, ok := componentType(block.Labels[0]); ok {
+			return ty
+		}
+	default:
+		if ty, ok := registeredBlockType(block.Type); ok {
+			return ty
+		}
+	}
+	return nil
+}
+
+// replacementName returns the attribute name a deprecation message of the
+// form "use new_name" points to.
+func replacementName(msg string) (string, bool) {
+	if !strings.HasPrefix(msg, "use ") {
+		return "", false
+	}
+	name := strings.TrimPrefix(msg, "use ")
+	return name, hclsyntax.ValidIdentifier(name)
+}
+
+// FixDeprecatedAttributes renames the deprecated attributes set in the
+// given config file to their replacement, returning the new source and the
+// number of renamed attributes. Attributes whose replacement is set too are
+// left alone. The attributes are renamed in the hclwrite syntax tree of the
+// file, which is written back with the original spacing, since formatting it
+// would touch the rest of the file too.
+func FixDeprecatedAttributes(src []byte, filename string) ([]byte, int, hcl.Diagnostics) {
+	file, diags := ParseConfig(src, filename)
+	if diags.HasErrors() {
+		return nil, 0, diags
+	}
+	body, ok := file.Body.(*hclsyntax.Body)
+	if !ok {
+		return src, 0, nil
+	}
+	rewritten := rewriteNamespacedCalls(src, filename)
+	writeFile, diags := hclwrite.ParseConfig(rewritten, filename, hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		return nil, 0, diags
+	}
+
+	renamed := map[*hclwrite.Token]bool{}
+	writeBlocks := orderedBlocks(writeFile)
+	for i, block := range body.Blocks {
+		for name, msg := range deprecatedAttributes(blockConfigType(block)) {
+			if _, ok := block.Body.Attributes[name]; !ok {
+				continue
+			}
+			newName, ok := replacementName(msg)
+			if !ok {
+				continue
+			}
+			if _, exists := block.Body.Attributes[newName]; exists {
+				// Both are set, a human has to decide which one wins.
+				continue
+			}
+			nameToken := attributeNameToken(writeBlocks[i].Body().GetAttribute(name))
+			nameToken.Bytes = []byte(newName)
+			renamed[nameToken] = true
+		}
+	}
+	if len(renamed) == 0 {
+		return src, 0, nil
+	}
+
+	// The writer tokens correspond to the tokens of the source one to one.
+	// Everything but the renamed tokens is copied from the source, which
+	// also undoes rewriteNamespacedCalls.
+	sourceTokens, _ := hclsyntax.LexConfig(rewritten, filename, hcl.Pos{Line: 1, Column: 1})
+	var out []byte
+	last := 0
+	for i, token := range writeFile.BuildTokens(nil) {
+		rng := sourceTokens[i].Range
+		out = append(out, src[last:rng.Start.Byte]...)
+		if renamed[token] {
+			out = append(out, token.Bytes...)
+		} else {
+			out = append(out, src[rng.Start.Byte:rng.End.Byte]...)
+		}
+		last = rng.End.Byte
+	}
+	return append(out, src[last:]...), len(renamed), nil
+}
+
+// orderedBlocks returns the top-level blocks of file in source order, which
+// Body.Blocks doesn't keep.
+func orderedBlocks(file *hclwrite.File) []*hclwrite.Block {
+	positions := map[*hclwrite.Token]int{}
+	for i, token := range file.BuildTokens(nil) {
+		positions[token] = i
+	}
+	blocks := file.Body().Blocks()
+	sort.Slice(blocks, func(i, j int) bool {
+		return positions[blocks[i].BuildTokens(nil)[0]] < positions[blocks[j].BuildTokens(nil)[0]]
+	})
+	return blocks
+}
+
+// attributeNameToken returns the token of the name of attr, which follows
+// its leading comments.
+func attributeNameToken(attr *hclwrite.Attribute) *hclwrite.Token {
+	for _, token := range attr.BuildTokens(nil) {
+		if token.Type == hclsyntax.TokenIdent {
+			return token
+		}
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package datcfg
+
+import "testing"
+
+type deprecatingConfig struct {
+	Size     int `hcl:"size,optional" deprecated:"use capacity"`
+	Capacity int `hcl:"capacity,optional"`
+}
+
+func init() {
+	MustRegisterComponent("test_deprecating", &deprecatingConfig{})
+}
+
+func TestFixDeprecatedAttributes(t *testing.T) {
+	tests := []struct {
+		name  string
+		src   string
+		want  string
+		fixed int
+	}{
+		{
+			"layout",
+			"\ufeff# keep\r\ncomponent \"test_deprecating\" {\r\n  # the size\r\n  size =\t2   # GiB\r\n  other  = ns::fn(1,2)\r\n}\r\n",
+			"\ufeff# keep\r\ncomponent \"test_deprecating\" {\r\n  # the size\r\n  capacity =\t2   # GiB\r\n  other  = ns::fn(1,2)\r\n}\r\n",
+			1,
+		},
+		{
+			"named",
+			"cluster \"a\" {}\n\ncomponent \"test_deprecating\" \"b\" { size = 1 }\n",
+			"cluster \"a\" {}\n\ncomponent \"test_deprecating\" \"b\" { capacity = 1 }\n",
+			1,
+		},
+		{
+			"both set",
+			"component \"test_deprecating\" {\n  size     = 1\n  capacity = 2\n}\n",
+			"component \"test_deprecating\" {\n  size     = 1\n  capacity = 2\n}\n",
+			0,
+		},
+	}
+	for _, test := range tests {
+		got, fixed, diags := FixDeprecatedAttributes([]byte(test.src), "test.datcfg")
+		if diags.HasErrors() {
+			t.Errorf("%s: %s", test.name, diags.Error())
+			continue
+		}
+		if string(got) != test.want || fixed != test.fixed {
+			t.Errorf("%s: got %d fixes of\n%q\nwant %d of\n%q", test.name, fixed, got, test.fixed, test.want)
+		}
+	}
+}
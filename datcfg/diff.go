@@ -0,0 +1,162 @@
+package datcfg
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// Change is a difference between two evaluated configs, as returned by Diff.
+type Change struct {
+	// Path is the address of what changed, like `cluster.main.worker_count`,
+	// `component.foo.settings.port`, or `component.foo` for a component that
+	// was added or removed as a whole.
+	Path   string
+	Action PlanActionType
+	// Old is the value in the old config, null for created paths.
+	Old cty.Value
+	// New is the value in the new config, null for deleted paths.
+	New cty.Value
+}
+
+// Diff returns the differences between the clusters and components of two
+// configs, sorted by path. Components are matched by address, following the
+// `moved` blocks of the new config. Attributes of nested blocks are compared
+// one by one, collections as a whole. The attributes a component of the new
+// config ignores with `lifecycle { ignore_changes }` are skipped.
+func Diff(old, new *Config) []Change {
+	var changes []Change
+
+	oldClusters := map[string]cty.Value{}
+	for _, cluster := range old.Clusters {
+		oldClusters[cluster.Name] = configValue(cluster.Config)
+	}
+	newClusters := map[string]cty.Value{}
+	for _, cluster := range new.Clusters {
+		newClusters[cluster.Name] = configValue(cluster.Config)
+	}
+	changes = append(changes, diffBlocks("cluster.", oldClusters, newClusters, nil)...)
+
+	oldComponents := map[string]cty.Value{}
+	for i, component := range old.Components {
+		oldComponents[new.CurrentAddress(ComponentAddress(old.Components, i))] = configValue(component.Config)
+	}
+	newComponents := map[string]cty.Value{}
+	ignored := map[string]Component{}
+	for i, component := range new.Components {
+		address := ComponentAddress(new.Components, i)
+		newComponents[address] = configValue(component.Config)
+		ignored[address] = component
+	}
+	changes = append(changes, diffBlocks("component.", oldComponents, newComponents, func(address, attr string) bool {
+		return ignored[address].IgnoresChanges(attr)
+	})...)
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes
+}
+
+// diffBlocks compares the configs of the blocks with the same address,
+// skipping the top-level attributes ignore reports.
+func diffBlocks(prefix string, old, new map[string]cty.Value, ignore func(address, attr string) bool) []Change {
+	var changes []Change
+	for address, newVal := range new {
+		oldVal, ok := old[address]
+		if !ok {
+			changes = append(changes, Change{Path: prefix + address, Action: PlanCreate, Old: cty.NullVal(newVal.Type()), New: newVal})
+			continue
+		}
+		if !isMapping(oldVal.Type()) || !isMapping(newVal.Type()) || oldVal.IsNull() || newVal.IsNull() {
+			changes = append(changes, diffValues(prefix+address, oldVal, newVal)...)
+			continue
+		}
+		oldAttrs, newAttrs := oldVal.AsValueMap(), newVal.AsValueMap()
+		for name := range mergedKeys(oldAttrs, newAttrs) {
+			if ignore != nil && ignore(address, name) {
+				continue
+			}
+			changes = append(changes, diffAttr(prefix+address+"."+name, oldAttrs, newAttrs, name)...)
+		}
+	}
+	for address, oldVal := range old {
+		if _, ok := new[address]; !ok {
+			changes = append(changes, Change{Path: prefix + address, Action: PlanDelete, Old: oldVal, New: cty.NullVal(oldVal.Type())})
+		}
+	}
+	return changes
+}
+
+// diffAttr compares the attribute with the given name of two objects.
+func diffAttr(path string, old, new map[string]cty.Value, name string) []Change {
+	oldVal, inOld := old[name]
+	newVal, inNew := new[name]
+	switch {
+	case inOld && inNew:
+		return diffValues(path, oldVal, newVal)
+	case inNew:
+		oldVal = cty.NullVal(newVal.Type())
+	case inOld:
+		newVal = cty.NullVal(oldVal.Type())
+	}
+	return diffValues(path, oldVal, newVal)
+}
+
+// diffValues compares two values, recursing into objects. Attributes that
+// are set to null or removed are deletions, attributes set where there was
+// null are creations.
+func diffValues(path string, old, new cty.Value) []Change {
+	if isMapping(old.Type()) && isMapping(new.Type()) && old.IsKnown() && new.IsKnown() && !old.IsNull() && !new.IsNull() {
+		oldAttrs, newAttrs := old.AsValueMap(), new.AsValueMap()
+		var changes []Change
+		for name := range mergedKeys(oldAttrs, newAttrs) {
+			changes = append(changes, diffAttr(path+"."+name, oldAttrs, newAttrs, name)...)
+		}
+		return changes
+	}
+
+	if Equal(old, new) {
+		return nil
+	}
+	action := PlanUpdate
+	switch {
+	case old.IsNull():
+		action = PlanCreate
+	case new.IsNull():
+		action = PlanDelete
+	}
+	return []Change{{Path: path, Action: action, Old: old, New: new}}
+}
+
+func mergedKeys(a, b map[string]cty.Value) map[string]bool {
+	keys := map[string]bool{}
+	for key := range a {
+		keys[key] = true
+	}
+	for key := range b {
+		keys[key] = true
+	}
+	return keys
+}
+
+// configValue converts a decoded config struct into a cty value, with the
+// attribute names from its `hcl` struct tags, like RenderJSON renders it.
+func configValue(config interface{}) cty.Value {
+	src, err := json.Marshal(jsonValue(reflect.ValueOf(config)))
+	if err != nil {
+		return cty.DynamicVal
+	}
+	ty, err := ctyjson.ImpliedType(src)
+	if err != nil {
+		return cty.DynamicVal
+	}
+	val, err := ctyjson.Unmarshal(src, ty)
+	if err != nil {
+		return cty.DynamicVal
+	}
+	return val
+}
@@ -0,0 +1,24 @@
+// Package datcfg loads configurations made of `.datcfg` files, declaring a
+// cluster and the components deployed on it, and `dat.vars` values files.
+//
+// Component kinds are registered with RegisterComponent or, in init functions,
+// MustRegisterComponent. The configuration is then loaded with a Loader:
+//
+//	config, diags := datcfg.NewLoader(os.DirFS("."), datcfg.WithUnknownComponents()).Load()
+//
+// The language of the config files, beyond HCL itself, is described in the
+// README of the repository. The loader options and the interfaces component
+// configs can implement document the features they enable.
+//
+// The exported identifiers of this package follow semantic versioning, see
+// Version. Everything else may change between releases.
+package datcfg
+
+// Version is the release of this package. Its exported API only changes
+// incompatibly with a new major version. Builds of modified sources should
+// tell themselves apart by setting it at link time, like:
+//
+//	go build -ldflags "-X github.com/imranansari/hcl2-demo/datcfg.Version=0.1.0+abc123"
+//
+// Configs can refer to it as `datcfg.version`.
+var Version = "0.1.0"
@@ -0,0 +1,80 @@
+package datcfg
+
+import (
+	"bytes"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/apparentlymart/go-textseg/textseg"
+	"github.com/hashicorp/hcl2/hcl"
+)
+
+var utf8BOM = []byte{0xef, 0xbb, 0xbf}
+
+// checkEncoding reports the first byte of src that is not part of a valid
+// UTF-8 character, with its offset, since the parsers either accept such
+// bytes silently or report them without saying where they are. Files can
+// start with a UTF-8 byte order mark and use CRLF line endings.
+func checkEncoding(src []byte, filename string) hcl.Diagnostics {
+	if utf8.Valid(src) {
+		return nil
+	}
+
+	pos := hcl.Pos{Line: 1, Column: 1}
+	start := 0
+	if bytes.HasPrefix(src, utf8BOM) {
+		start = len(utf8BOM)
+	}
+	for offset := start; offset < len(src); {
+		if r, size := utf8.DecodeRune(src[offset:]); r == utf8.RuneError && size <= 1 {
+			pos.Byte = offset
+			end := hcl.Pos{Line: pos.Line, Column: pos.Column + 1, Byte: offset + 1}
+			return hcl.Diagnostics{
+				{
+					Severity: hcl.DiagError,
+					Summary:  "Invalid UTF-8",
+					Detail: fmt.Sprintf(
+						"The byte 0x%02x at offset %d of %q is not part of a valid UTF-8 character. Config files must be saved as UTF-8.",
+						src[offset], offset, filename,
+					),
+					Subject: &hcl.Range{Filename: filename, Start: pos, End: end},
+				},
+			}
+		}
+
+		// Columns count grapheme clusters, like in the ranges of the
+		// parsers. A cluster ends before an invalid byte, and a CRLF is
+		// a single one.
+		advance, _, _ := textseg.ScanGraphemeClusters(src[offset:], true)
+		if valid := validPrefix(src[offset : offset+advance]); valid > 0 {
+			advance = valid
+		}
+		offset += advance
+		if src[offset-1] == '\n' {
+			pos.Line, pos.Column = pos.Line+1, 1
+			continue
+		}
+		pos.Column++
+	}
+	return nil
+}
+
+// validPrefix returns the length of the longest prefix of b that is valid
+// UTF-8.
+func validPrefix(b []byte) int {
+	n := 0
+	for n < len(b) {
+		r, size := utf8.DecodeRune(b[n:])
+		if r == utf8.RuneError && size <= 1 {
+			break
+		}
+		n += size
+	}
+	return n
+}
+
+// stripBOM returns src without its UTF-8 byte order mark, if it has one. The
+// JSON parser doesn't skip it, unlike the native syntax parser.
+func stripBOM(src []byte) []byte {
+	return bytes.TrimPrefix(src, utf8BOM)
+}
@@ -0,0 +1,112 @@
+package datcfg
+
+import (
+	"runtime"
+	"sort"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hcl/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// envObject returns the read-only `env` object available to expressions,
+// which holds the given environment variables, usually those of the
+// process, as strings:
+//
+//	region = env.AWS_REGION
+//
+// Referring to a variable that is not set is an error.
+func envObject(env map[string]string) cty.Value {
+	vals := map[string]cty.Value{}
+	for name, val := range env {
+		vals[name] = cty.StringVal(val)
+	}
+	return cty.ObjectVal(vals)
+}
+
+// EnvReferences returns the names of the environment variables the given
+// files refer to, like `env.AWS_REGION` or `env["AWS_REGION"]`, sorted. The
+// result is false if they also refer to the env object as a whole, like
+// when indexing it with an expression, so that any variable may be read.
+func EnvReferences(files []*hcl.File) ([]string, bool) {
+	referenced := map[string]bool{}
+	complete := true
+	for _, file := range files {
+		body, ok := file.Body.(*hclsyntax.Body)
+		if !ok {
+			continue
+		}
+		// The native syntax parses env["NAME"] as an index expression on the
+		// traversal env, which is a reference to the variable NAME only.
+		indexed := map[hclsyntax.Expression]bool{}
+		hclsyntax.VisitAll(body, func(node hclsyntax.Node) hcl.Diagnostics {
+			index, ok := node.(*hclsyntax.IndexExpr)
+			if !ok {
+				return nil
+			}
+			traversal, ok := index.Collection.(*hclsyntax.ScopeTraversalExpr)
+			if !ok || len(traversal.Traversal) != 1 || traversal.Traversal.RootName() != "env" {
+				return nil
+			}
+			if key, diags := index.Key.Value(nil); !diags.HasErrors() && key.Type() == cty.String && key.IsKnown() && !key.IsNull() {
+				referenced[key.AsString()] = true
+				indexed[index.Collection] = true
+			}
+			return nil
+		})
+		hclsyntax.VisitAll(body, func(node hclsyntax.Node) hcl.Diagnostics {
+			expr, ok := node.(*hclsyntax.ScopeTraversalExpr)
+			if !ok || expr.Traversal.RootName() != "env" || indexed[expr] {
+				return nil
+			}
+			if name, ok := envVariableName(expr.Traversal); ok {
+				referenced[name] = true
+			} else {
+				complete = false
+			}
+			return nil
+		})
+	}
+
+	names := make([]string, 0, len(referenced))
+	for name := range referenced {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, complete
+}
+
+// envVariableName returns the name of the environment variable a traversal
+// of env refers to, if it refers to a single one.
+func envVariableName(traversal hcl.Traversal) (string, bool) {
+	if len(traversal) < 2 {
+		return "", false
+	}
+	switch step := traversal[1].(type) {
+	case hcl.TraverseAttr:
+		return step.Name, true
+	case hcl.TraverseIndex:
+		if step.Key.Type() == cty.String && step.Key.IsKnown() && !step.Key.IsNull() {
+			return step.Key.AsString(), true
+		}
+	}
+	return "", false
+}
+
+// toolObject returns the `datcfg` object available to expressions, which
+// describes the running tool, so that configs can branch on its
+// capabilities:
+//
+//	applies_when = datcfg.os == "linux"
+//
+// In hermetic mode only the version is set, see hostAttributes.
+func toolObject(hermetic bool) cty.Value {
+	attrs := map[string]cty.Value{
+		"version": cty.StringVal(Version),
+	}
+	if !hermetic {
+		attrs["os"] = cty.StringVal(runtime.GOOS)
+		attrs["arch"] = cty.StringVal(runtime.GOARCH)
+	}
+	return cty.ObjectVal(attrs)
+}
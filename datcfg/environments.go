@@ -0,0 +1,105 @@
+package datcfg
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl2/hcl"
+)
+
+// EnvironmentsDir is the directory of a config holding the values files of
+// its environments, one directory per environment:
+//
+//	environments/
+//	  prod/
+//	    cluster.vars
+//	    network.vars
+//	  staging/
+//	    cluster.vars
+const EnvironmentsDir = "environments"
+
+// WithValuesEnvironment makes the loader also read all values files under
+// the directory of the given environment, like environments/prod/, which
+// are the files ending in `.vars` or `.vars.json`, including those in
+// subdirectories. They are read in lexical order of their paths, after the
+// values files in the root, so that they take precedence over them.
+func WithValuesEnvironment(name string) LoaderOption {
+	return func(l *Loader) {
+		l.valuesEnvironment = name
+	}
+}
+
+// Environments returns the names of the environments of the config in fsys,
+// in lexical order.
+func Environments(fsys fs.FS) ([]string, error) {
+	entries, err := fs.ReadDir(fsys, EnvironmentsDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
+
+// EnvironmentValuesFiles returns the paths of the values files of the given
+// environment, in lexical order.
+func EnvironmentValuesFiles(fsys fs.FS, name string) ([]string, hcl.Diagnostics) {
+	return environmentValuesFiles(fsys, name)
+}
+
+func environmentValuesFiles(fsys fs.FS, name string) ([]string, hcl.Diagnostics) {
+	if name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return nil, hcl.Diagnostics{{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid environment name",
+			Detail:   fmt.Sprintf("The environment %q must be the name of a directory in %s/.", name, EnvironmentsDir),
+		}}
+	}
+
+	dir := path.Join(EnvironmentsDir, name)
+	if info, err := fs.Stat(fsys, dir); err != nil || !info.IsDir() {
+		detail := fmt.Sprintf("There is no directory %s/.", dir)
+		names, _ := Environments(fsys)
+		if suggestion, ok := nearestName(name, names); ok {
+			detail += fmt.Sprintf(" Did you mean %q?", suggestion)
+		} else if len(names) > 0 {
+			detail += fmt.Sprintf(" The environments are: %s.", strings.Join(names, ", "))
+		}
+		return nil, hcl.Diagnostics{{
+			Severity: hcl.DiagError,
+			Summary:  "Unknown environment",
+			Detail:   detail,
+		}}
+	}
+
+	var paths []string
+	err := fs.WalkDir(fsys, dir, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() && (strings.HasSuffix(p, ".vars") || strings.HasSuffix(p, ".vars.json")) {
+			paths = append(paths, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, hcl.Diagnostics{{
+			Severity: hcl.DiagError,
+			Summary:  "Failed to find values files",
+			Detail:   err.Error(),
+		}}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
@@ -0,0 +1,205 @@
+package datcfg
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+// EqualOption relaxes the comparison done by Equal and AssertEqual.
+type EqualOption func(*equalOptions)
+
+type equalOptions struct {
+	nullAsAbsent bool
+	ignoreOrder  bool
+}
+
+// NullAsAbsent makes object attributes and map elements that are null equal
+// to ones that are not there at all, since configs commonly leave optional
+// attributes out instead of setting them to null.
+func NullAsAbsent() EqualOption {
+	return func(o *equalOptions) {
+		o.nullAsAbsent = true
+	}
+}
+
+// IgnoreOrder compares lists and tuples like sets, so that only the
+// elements matter and not their order. Sets are always compared that way.
+func IgnoreOrder() EqualOption {
+	return func(o *equalOptions) {
+		o.ignoreOrder = true
+	}
+}
+
+// Equal reports whether the given values are deeply equal. Unlike
+// cty.Value.Equals, it compares values of different but compatible types,
+// like an object and a map or a list and a tuple, and always returns a bool,
+// even for unknown values, which are only equal to unknown values of the
+// same type.
+func Equal(a, b cty.Value, opts ...EqualOption) bool {
+	return AssertEqual(a, b, opts...) == nil
+}
+
+// AssertEqual returns an error describing the first difference between the
+// wanted and the actual value, or nil if they are equal as defined by Equal.
+// It is meant for validation code of components, like:
+//
+//	if err := datcfg.AssertEqual(want, got, datcfg.NullAsAbsent()); err != nil {
+//		return fmt.Errorf("unexpected settings: %s", err)
+//	}
+func AssertEqual(want, got cty.Value, opts ...EqualOption) error {
+	var o equalOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	path, reason := difference(want, got, nil, &o)
+	if reason == "" {
+		return nil
+	}
+	if len(path) == 0 {
+		return fmt.Errorf("%s", reason)
+	}
+	return fmt.Errorf("at %s: %s", formatPath(path), reason)
+}
+
+// difference returns the path to and a description of the first difference
+// between the two values, with an empty description if they are equal.
+func difference(want, got cty.Value, path cty.Path, o *equalOptions) (cty.Path, string) {
+	mismatch := func() (cty.Path, string) {
+		return path, fmt.Sprintf("want %s, got %s", FormatValue(want), FormatValue(got))
+	}
+
+	switch {
+	case !want.IsKnown() || !got.IsKnown():
+		if want.IsKnown() != got.IsKnown() || !want.Type().Equals(got.Type()) {
+			return mismatch()
+		}
+		return nil, ""
+	case want.IsNull() || got.IsNull():
+		if want.IsNull() != got.IsNull() {
+			return mismatch()
+		}
+		return nil, ""
+	}
+
+	wantTy, gotTy := want.Type(), got.Type()
+	switch {
+	case isMapping(wantTy) && isMapping(gotTy):
+		return mappingDifference(want, got, path, o)
+	case isSequence(wantTy) && isSequence(gotTy) && !o.ignoreOrder:
+		if want.LengthInt() != got.LengthInt() {
+			return path, fmt.Sprintf("want %d elements, got %d", want.LengthInt(), got.LengthInt())
+		}
+		for i := 0; i < want.LengthInt(); i++ {
+			idx := cty.NumberIntVal(int64(i))
+			elemPath := append(path.Copy(), cty.IndexStep{Key: idx})
+			if p, reason := difference(want.Index(idx), got.Index(idx), elemPath, o); reason != "" {
+				return p, reason
+			}
+		}
+		return nil, ""
+	case (isSequence(wantTy) || wantTy.IsSetType()) && (isSequence(gotTy) || gotTy.IsSetType()):
+		return unorderedDifference(want, got, path, o)
+	case wantTy.IsPrimitiveType() && gotTy.IsPrimitiveType():
+		if !wantTy.Equals(gotTy) || want.Equals(got).False() {
+			return mismatch()
+		}
+		return nil, ""
+	case wantTy.IsCapsuleType() && gotTy.IsCapsuleType():
+		if !want.RawEquals(got) {
+			return mismatch()
+		}
+		return nil, ""
+	}
+	return path, fmt.Sprintf("want %s, got %s", wantTy.FriendlyName(), gotTy.FriendlyName())
+}
+
+func mappingDifference(want, got cty.Value, path cty.Path, o *equalOptions) (cty.Path, string) {
+	wantElems, gotElems := want.AsValueMap(), got.AsValueMap()
+	keys := map[string]bool{}
+	for key := range wantElems {
+		keys[key] = true
+	}
+	for key := range gotElems {
+		keys[key] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+
+	for _, key := range sorted {
+		elemPath := append(path.Copy(), cty.GetAttrStep{Name: key})
+		wantElem, inWant := wantElems[key]
+		gotElem, inGot := gotElems[key]
+		switch {
+		case inWant && inGot:
+			if p, reason := difference(wantElem, gotElem, elemPath, o); reason != "" {
+				return p, reason
+			}
+		case o.nullAsAbsent && ((inWant && wantElem.IsNull()) || (inGot && gotElem.IsNull())):
+		case inWant:
+			return elemPath, "missing"
+		default:
+			return elemPath, fmt.Sprintf("unexpected %s", FormatValue(gotElem))
+		}
+	}
+	return nil, ""
+}
+
+// unorderedDifference compares the elements of two collections regardless
+// of their order, matching every wanted element with a distinct actual one.
+func unorderedDifference(want, got cty.Value, path cty.Path, o *equalOptions) (cty.Path, string) {
+	if want.LengthInt() != got.LengthInt() {
+		return path, fmt.Sprintf("want %d elements, got %d", want.LengthInt(), got.LengthInt())
+	}
+
+	var gotElems []cty.Value
+	for it := got.ElementIterator(); it.Next(); {
+		_, elem := it.Element()
+		gotElems = append(gotElems, elem)
+	}
+	matched := make([]bool, len(gotElems))
+	for it := want.ElementIterator(); it.Next(); {
+		_, wantElem := it.Element()
+		found := false
+		for i, gotElem := range gotElems {
+			if matched[i] {
+				continue
+			}
+			if _, reason := difference(wantElem, gotElem, nil, o); reason == "" {
+				matched[i], found = true, true
+				break
+			}
+		}
+		if !found {
+			return path, fmt.Sprintf("no element matches %s", FormatValue(wantElem))
+		}
+	}
+	return nil, ""
+}
+
+func isMapping(ty cty.Type) bool {
+	return ty.IsObjectType() || ty.IsMapType()
+}
+
+func isSequence(ty cty.Type) bool {
+	return ty.IsListType() || ty.IsTupleType()
+}
+
+// formatPath renders a path like `.settings.ports[0]`.
+func formatPath(path cty.Path) string {
+	var b strings.Builder
+	for _, step := range path {
+		switch step := step.(type) {
+		case cty.GetAttrStep:
+			fmt.Fprintf(&b, ".%s", step.Name)
+		case cty.IndexStep:
+			fmt.Fprintf(&b, "[%s]", FormatValue(step.Key))
+		}
+	}
+	return b.String()
+}
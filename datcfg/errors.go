@@ -0,0 +1,24 @@
+package datcfg
+
+import (
+	"strings"
+
+	"github.com/hashicorp/hcl2/hcl"
+)
+
+// DiagnosticsError is returned by the functions reporting failures as a plain
+// error. The full diagnostics, including their source ranges, are kept in
+// Diags.
+type DiagnosticsError struct {
+	Diags hcl.Diagnostics
+}
+
+func (e *DiagnosticsError) Error() string {
+	var errs []string
+	for _, diag := range e.Diags {
+		if diag.Severity == hcl.DiagError {
+			errs = append(errs, diag.Error())
+		}
+	}
+	return strings.Join(errs, "; ")
+}
@@ -0,0 +1,152 @@
+package datcfg
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl2/gohcl"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/gocty"
+)
+
+// ComponentExample returns a component block of the given kind to paste into
+// a config file, generated from the schema of its config. Required
+// attributes are set to a placeholder of their type, optional attributes and
+// blocks are commented out, and every attribute is followed by its type:
+//
+//	component "web" {
+//	  image = "..." # string
+//	  # replicas = 0 # number, optional
+//	}
+func ComponentExample(kind string) (string, error) {
+	ty, ok := componentType(kind)
+	if !ok {
+		msg := fmt.Sprintf("there is no component kind %q", kind)
+		if suggestion, ok := nearestName(kind, componentKinds()); ok {
+			msg += fmt.Sprintf(", did you mean %q?", suggestion)
+		}
+		return "", fmt.Errorf("%s", msg)
+	}
+
+	lines := []string{fmt.Sprintf("component %q {", kind)}
+	lines = append(lines, exampleBody(ty, "  ")...)
+	lines = append(lines, "}")
+	return strings.Join(lines, "\n") + "\n", nil
+}
+
+// componentKinds returns the sorted names of the registered component kinds.
+func componentKinds() []string {
+	componentsMu.RLock()
+	defer componentsMu.RUnlock()
+	kinds := make([]string, 0, len(components))
+	for kind := range components {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// exampleBody renders the attributes and nested blocks of the given config
+// struct type, each line starting with prefix. Deprecated attributes are
+// left out, and so are the contents of blocks decoded into bodies.
+func exampleBody(ty reflect.Type, prefix string) []string {
+	schema, _ := gohcl.ImpliedBodySchema(reflect.New(ty).Interface())
+	tags := getDecodeFieldTags(ty)
+	deprecated := deprecatedAttributes(ty)
+
+	var lines []string
+	width := 0
+	for _, attr := range schema.Attributes {
+		if _, ok := deprecated[attr.Name]; !ok && len(attr.Name) > width {
+			width = len(attr.Name)
+		}
+	}
+	for _, attr := range schema.Attributes {
+		if _, ok := deprecated[attr.Name]; ok {
+			continue
+		}
+		fieldTy := ty.Field(tags.Attributes[attr.Name]).Type
+		line := fmt.Sprintf("%-*s = %s # %s", width, attr.Name, exampleValue(fieldTy), exampleType(fieldTy))
+		if !attr.Required {
+			line = "# " + line + ", optional"
+		}
+		lines = append(lines, prefix+line)
+	}
+
+	for _, block := range schema.Blocks {
+		fieldTy := ty.Field(tags.Blocks[block.Type]).Type
+		optional := fieldTy.Kind() == reflect.Ptr || fieldTy.Kind() == reflect.Slice
+		for fieldTy.Kind() == reflect.Ptr || fieldTy.Kind() == reflect.Slice {
+			fieldTy = fieldTy.Elem()
+		}
+
+		header := block.Type
+		for _, label := range block.LabelNames {
+			header += fmt.Sprintf(" %q", label)
+		}
+		blockLines := []string{header + " {"}
+		if fieldTy.Kind() == reflect.Struct {
+			blockLines = append(blockLines, exampleBody(fieldTy, "  ")...)
+		}
+		blockLines = append(blockLines, "}")
+
+		lines = append(lines, "")
+		for _, line := range blockLines {
+			if optional {
+				line = "# " + line
+			}
+			lines = append(lines, prefix+line)
+		}
+	}
+	return lines
+}
+
+// exampleType returns the type of a field as written in a type constraint,
+// like `list(string)`. Fields decoded by a hook take strings, like "30s"
+// for durations.
+func exampleType(fieldTy reflect.Type) string {
+	if hook, _ := decodeHookFor(fieldTy); hook != nil {
+		for fieldTy.Kind() == reflect.Ptr {
+			fieldTy = fieldTy.Elem()
+		}
+		return "string, as " + fieldTy.String()
+	}
+	if exprType.AssignableTo(fieldTy) {
+		return "any"
+	}
+	ty, err := gocty.ImpliedType(reflect.New(fieldTy).Interface())
+	if err != nil {
+		return "any"
+	}
+	return typeExpression(ty)
+}
+
+// typeExpression renders a type like a type constraint.
+func typeExpression(ty cty.Type) string {
+	switch {
+	case ty == cty.DynamicPseudoType:
+		return "any"
+	case ty.IsListType():
+		return "list(" + typeExpression(ty.ElementType()) + ")"
+	case ty.IsSetType():
+		return "set(" + typeExpression(ty.ElementType()) + ")"
+	case ty.IsMapType():
+		return "map(" + typeExpression(ty.ElementType()) + ")"
+	case ty.IsObjectType():
+		var attrs []string
+		for name, attrTy := range ty.AttributeTypes() {
+			attrs = append(attrs, name+" = "+typeExpression(attrTy))
+		}
+		sort.Strings(attrs)
+		return "object({ " + strings.Join(attrs, ", ") + " })"
+	case ty.IsTupleType():
+		var elems []string
+		for _, elem := range ty.TupleElementTypes() {
+			elems = append(elems, typeExpression(elem))
+		}
+		return "tuple([" + strings.Join(elems, ", ") + "])"
+	}
+	return ty.FriendlyName()
+}
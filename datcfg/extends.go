@@ -0,0 +1,293 @@
+package datcfg
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hcl/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// resolveExtends merges the body of every component block declaring
+// `extends` onto the body of the component block it extends:
+//
+//	component "db" {
+//	  name    = "replica"
+//	  extends = component.db.primary
+//	  role    = "replica"
+//	}
+//
+// The block inherits the attributes and nested blocks of the other one,
+// which must be of the same kind, and overrides those it sets itself. Nested
+// blocks are overridden by type. The meta-arguments, like `name` or `count`,
+// are not inherited. Components are referred to by their kind and static
+// name, or only by their kind without a name. The index of the component
+// each one extends is returned, -1 for those that don't.
+func resolveExtends(components []componentBlock) ([]int, hcl.Diagnostics) {
+	names := map[string]int{}
+	kinds := map[string][]int{}
+	for i, component := range components {
+		if name, ok := staticComponentName(component); ok {
+			names[name] = i
+			continue
+		}
+		kinds[canonicalKindName(component.Type)] = append(kinds[canonicalKindName(component.Type)], i)
+	}
+
+	var diags hcl.Diagnostics
+	const (
+		resolving = 1
+		resolved  = 2
+	)
+	state := make([]int, len(components))
+	bases := make([]int, len(components))
+	for i := range bases {
+		bases[i] = -1
+	}
+	var resolve func(i int) bool
+	resolve = func(i int) bool {
+		switch state[i] {
+		case resolving:
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Cyclic component inheritance",
+				Detail:   "The component extends itself, directly or through the components it extends.",
+				Subject:  components[i].Extends.Range().Ptr(),
+			})
+			return false
+		case resolved:
+			return true
+		}
+		state[i] = resolving
+		defer func() { state[i] = resolved }()
+
+		component := components[i]
+		if !isSet(component.Extends) {
+			return true
+		}
+		base, ok := extendedComponent(components, names, kinds, component.Extends, &diags)
+		if !ok {
+			return false
+		}
+		if canonicalKindName(components[base].Type) != canonicalKindName(component.Type) {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Conflicting component kinds",
+				Detail:   fmt.Sprintf("A component of kind %q can't extend a component of kind %q.", component.Type, components[base].Type),
+				Subject:  component.Extends.Range().Ptr(),
+			})
+			return false
+		}
+		if !resolve(base) {
+			return false
+		}
+
+		_, inherited, _ := components[base].Config.PartialContent(metaSchema)
+		components[i].Config = extendedBody{body: component.Config, base: inherited}
+		bases[i] = base
+		return true
+	}
+	for i := range components {
+		resolve(i)
+	}
+	return bases, diags
+}
+
+// extendedComponent returns the index of the component the given `extends`
+// expression refers to. Components are addressed like in moved blocks, as
+// component.<kind>.<name> if they are named and as component.<kind>
+// otherwise.
+func extendedComponent(components []componentBlock, names map[string]int, kinds map[string][]int, expr hcl.Expression, diags *hcl.Diagnostics) (int, bool) {
+	traversal, travDiags := hcl.AbsTraversalForExpr(expr)
+	var steps []string
+	if !travDiags.HasErrors() && (len(traversal) == 2 || len(traversal) == 3) && traversal.RootName() == "component" {
+		for _, step := range traversal[1:] {
+			if attr, ok := step.(hcl.TraverseAttr); ok {
+				steps = append(steps, attr.Name)
+			}
+		}
+	}
+	if len(steps) != len(traversal)-1 || len(steps) == 0 {
+		*diags = append(*diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid extends value",
+			Detail:   "The extends argument must be a reference to a component, like component.<kind>.<name> or component.<kind> for a component without a name.",
+			Subject:  expr.Range().Ptr(),
+		})
+		return 0, false
+	}
+
+	kind := canonicalKindName(steps[0])
+	if len(steps) == 2 {
+		if i, ok := names[steps[1]]; ok && canonicalKindName(components[i].Type) == kind {
+			return i, true
+		}
+		*diags = append(*diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Reference to undeclared component",
+			Detail:   fmt.Sprintf("There is no component of kind %q named %q to extend.", steps[0], steps[1]),
+			Subject:  expr.Range().Ptr(),
+		})
+		return 0, false
+	}
+
+	switch candidates := kinds[kind]; len(candidates) {
+	case 1:
+		return candidates[0], true
+	case 0:
+		detail := fmt.Sprintf("There is no component of kind %q without a name to extend.", steps[0])
+		if i, ok := names[steps[0]]; ok {
+			detail = fmt.Sprintf("Named components are referred to with their kind, like component.%s.%s.", components[i].Type, steps[0])
+		}
+		*diags = append(*diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Reference to undeclared component",
+			Detail:   detail,
+			Subject:  expr.Range().Ptr(),
+		})
+	default:
+		*diags = append(*diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Ambiguous component reference",
+			Detail:   fmt.Sprintf("There are %d components of kind %q without a name. Give the one to extend a name and refer to it by that.", len(candidates), steps[0]),
+			Subject:  expr.Range().Ptr(),
+		})
+	}
+	return 0, false
+}
+
+// staticComponentName returns the name of the given component block, if it
+// is set to a value that doesn't depend on the evaluation context.
+func staticComponentName(component componentBlock) (string, bool) {
+	if !isSet(component.Name) {
+		return "", false
+	}
+	val, diags := component.Name.Value(nil)
+	if diags.HasErrors() || !val.IsKnown() || val.IsNull() || !val.Type().Equals(cty.String) {
+		return "", false
+	}
+	return val.AsString(), true
+}
+
+// blockComponentName is like staticComponentName, for component blocks that
+// were not decoded, like in the lint rules.
+func blockComponentName(block *hclsyntax.Block) (string, bool) {
+	attr, ok := block.Body.Attributes["name"]
+	if !ok {
+		return "", false
+	}
+	val, diags := attr.Expr.Value(nil)
+	if diags.HasErrors() || !val.IsKnown() || val.IsNull() || !val.Type().Equals(cty.String) {
+		return "", false
+	}
+	return val.AsString(), true
+}
+
+func canonicalKindName(kind string) string {
+	if a, ok := canonicalKind(kind); ok {
+		return a.kind
+	}
+	return kind
+}
+
+// extendedBody is the body of a component block that extends base. The
+// attributes and nested block types set in body override those of base.
+type extendedBody struct {
+	body, base hcl.Body
+}
+
+func (b extendedBody) Content(schema *hcl.BodySchema) (*hcl.BodyContent, hcl.Diagnostics) {
+	optional := optionalSchema(schema)
+	content, diags := b.body.Content(optional)
+	baseContent, baseDiags := b.base.Content(optional)
+	diags = append(diags, baseDiags...)
+	merged := mergeContent(content, baseContent)
+	return merged, append(diags, b.missingAttributes(schema, merged)...)
+}
+
+func (b extendedBody) PartialContent(schema *hcl.BodySchema) (*hcl.BodyContent, hcl.Body, hcl.Diagnostics) {
+	optional := optionalSchema(schema)
+	content, remain, diags := b.body.PartialContent(optional)
+	baseContent, baseRemain, baseDiags := b.base.PartialContent(optional)
+	diags = append(diags, baseDiags...)
+	merged := mergeContent(content, baseContent)
+	return merged, extendedBody{body: remain, base: baseRemain}, append(diags, b.missingAttributes(schema, merged)...)
+}
+
+func (b extendedBody) JustAttributes() (hcl.Attributes, hcl.Diagnostics) {
+	attrs, diags := b.body.JustAttributes()
+	baseAttrs, baseDiags := b.base.JustAttributes()
+	diags = append(diags, baseDiags...)
+	if attrs == nil {
+		attrs = hcl.Attributes{}
+	}
+	for name, attr := range baseAttrs {
+		if _, exists := attrs[name]; !exists {
+			attrs[name] = attr
+		}
+	}
+	return attrs, diags
+}
+
+func (b extendedBody) MissingItemRange() hcl.Range {
+	return b.body.MissingItemRange()
+}
+
+// missingAttributes reports the required attributes that neither of the
+// bodies sets.
+func (b extendedBody) missingAttributes(schema *hcl.BodySchema, content *hcl.BodyContent) hcl.Diagnostics {
+	if content == nil {
+		return nil
+	}
+	var diags hcl.Diagnostics
+	for _, attrS := range schema.Attributes {
+		if _, ok := content.Attributes[attrS.Name]; attrS.Required && !ok {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Missing required argument",
+				Detail:   fmt.Sprintf("The argument %q is required, but no definition was found in the block or the component it extends.", attrS.Name),
+				Subject:  b.body.MissingItemRange().Ptr(),
+			})
+		}
+	}
+	return diags
+}
+
+// optionalSchema returns a copy of schema with no required attributes, since
+// each of them only has to be set in one of the bodies.
+func optionalSchema(schema *hcl.BodySchema) *hcl.BodySchema {
+	optional := &hcl.BodySchema{Blocks: schema.Blocks}
+	for _, attrS := range schema.Attributes {
+		attrS.Required = false
+		optional.Attributes = append(optional.Attributes, attrS)
+	}
+	return optional
+}
+
+func mergeContent(content, base *hcl.BodyContent) *hcl.BodyContent {
+	if content == nil || base == nil {
+		return content
+	}
+	merged := &hcl.BodyContent{
+		Attributes:       hcl.Attributes{},
+		Blocks:           append(hcl.Blocks(nil), content.Blocks...),
+		MissingItemRange: content.MissingItemRange,
+	}
+	for name, attr := range base.Attributes {
+		merged.Attributes[name] = attr
+	}
+	for name, attr := range content.Attributes {
+		merged.Attributes[name] = attr
+	}
+
+	overridden := map[string]bool{}
+	for _, block := range content.Blocks {
+		overridden[block.Type] = true
+	}
+	for _, block := range base.Blocks {
+		if !overridden[block.Type] {
+			merged.Blocks = append(merged.Blocks, block)
+		}
+	}
+	return merged
+}
@@ -0,0 +1,87 @@
+package datcfg
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hcl/hclsyntax"
+)
+
+const extendsConfig = `
+cluster "a" {
+  controller_count = 1
+  worker_count     = 1
+}
+
+component "test_volume" {
+  name = "base"
+  size = 10
+}
+
+component "test_volume" {
+  name    = "child"
+  extends = %s
+}
+`
+
+func TestExtendsNamed(t *testing.T) {
+	src := strings.Replace(extendsConfig, "%s", "component.test_volume.base", 1)
+	result, diags := NewLoader(fstest.MapFS{"cluster.datcfg": {Data: []byte(src)}}).Load()
+	if diags.HasErrors() {
+		t.Fatal(diags)
+	}
+	if got := result.Components[1].Config.(*volumeConfig).Size; got != 10 {
+		t.Errorf("size = %d, want the size of the extended component", got)
+	}
+}
+
+func TestExtendsAlias(t *testing.T) {
+	src := strings.Replace(extendsConfig, "%s", "component.test_disk.base", 1)
+	_, diags := NewLoader(fstest.MapFS{"cluster.datcfg": {Data: []byte(src)}}).Load()
+	if diags.HasErrors() {
+		t.Fatal(diags)
+	}
+}
+
+func TestExtendsByName(t *testing.T) {
+	src := strings.Replace(extendsConfig, "%s", "component.base", 1)
+	_, diags := NewLoader(fstest.MapFS{"cluster.datcfg": {Data: []byte(src)}}).Load()
+	if !diags.HasErrors() || !strings.Contains(diags[0].Detail, "component.test_volume.base") {
+		t.Fatalf("got %v, want an error pointing to the address with the kind", diags)
+	}
+}
+
+func parseTestFile(t *testing.T, src string) []*hcl.File {
+	t.Helper()
+	file, diags := hclsyntax.ParseConfig([]byte(src), "cluster.datcfg", hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		t.Fatal(diags)
+	}
+	return []*hcl.File{file}
+}
+
+func TestLintUndeclaredComponents(t *testing.T) {
+	tests := []struct {
+		extends string
+		detail  string
+	}{
+		{"component.test_volume.base", ""},
+		{"component.test_disk.base", ""},
+		{"component.test_volume.other", `There is no component of kind "test_volume" named "other".`},
+		{"component.base", `There is no component of kind "base". Named components are referred to with their kind, like component.test_volume.base.`},
+		{"component.test_mount", `There is no component "test_mount" declared in the configuration.`},
+	}
+	for _, test := range tests {
+		files := parseTestFile(t, strings.Replace(extendsConfig, "%s", test.extends, 1))
+		diags := lintUndeclaredComponents(files)
+		var detail string
+		if len(diags) > 0 {
+			detail = diags[0].Detail
+		}
+		if len(diags) > 1 || detail != test.detail {
+			t.Errorf("%s: got %v, want %q", test.extends, diags, test.detail)
+		}
+	}
+}
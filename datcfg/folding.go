@@ -0,0 +1,153 @@
+package datcfg
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hcl/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// ConstantExpressionRule is the name of the lint rule flagging expressions
+// that always evaluate to the same value, which is off unless enabled in the
+// lint config, since configs written by hand often spell out values like
+// `60 * 60` on purpose.
+const ConstantExpressionRule = "constant_expression"
+
+// constantExpression is an expression without references that is more than
+// a literal, like `3 * 1024` or `"${"eu"}-west"`, as generated configs are
+// full of. It can be replaced with its value.
+type constantExpression struct {
+	expr hclsyntax.Expression
+	val  cty.Value
+}
+
+// constantExpressions returns the constant expressions in the attributes of
+// the given file, and of its nested blocks. Only the outermost constant
+// expression is returned where they nest.
+func constantExpressions(file *hcl.File) []constantExpression {
+	body, ok := file.Body.(*hclsyntax.Body)
+	if !ok {
+		return nil
+	}
+	var found []constantExpression
+	var inBody func(body *hclsyntax.Body)
+	inBody = func(body *hclsyntax.Body) {
+		for _, attr := range sortedAttributes(body) {
+			var folded *hcl.Range
+			hclsyntax.VisitAll(attr.Expr, func(node hclsyntax.Node) hcl.Diagnostics {
+				expr, ok := node.(hclsyntax.Expression)
+				if !ok || folded != nil && rangeWithin(expr.Range(), *folded) {
+					return nil
+				}
+				if val, ok := constantValue(expr); ok {
+					found = append(found, constantExpression{expr: expr, val: val})
+					rng := expr.Range()
+					folded = &rng
+				}
+				return nil
+			})
+		}
+		for _, block := range body.Blocks {
+			inBody(block.Body)
+		}
+	}
+	inBody(body)
+	return found
+}
+
+// constantValue returns the value of the given expression if it is
+// constant but not a literal. Expressions calling functions are never
+// constant, since functions like timestamp or file return something else
+// every time or everywhere.
+func constantValue(expr hclsyntax.Expression) (cty.Value, bool) {
+	if isLiteral(expr) || len(hclsyntax.Variables(expr)) > 0 {
+		return cty.NilVal, false
+	}
+	calls := false
+	hclsyntax.VisitAll(expr, func(node hclsyntax.Node) hcl.Diagnostics {
+		switch node.(type) {
+		case *hclsyntax.FunctionCallExpr, *tryExpr, *assertExpr, *runValueExpr:
+			calls = true
+		}
+		return nil
+	})
+	if calls {
+		return cty.NilVal, false
+	}
+	val, diags := expr.Value(nil)
+	if diags.HasErrors() || !val.IsWhollyKnown() {
+		return cty.NilVal, false
+	}
+	return val, true
+}
+
+// isLiteral reports whether the given expression is written as a value,
+// like `-1`, `"eu-west"` or `{ port = 80 }`, which folding wouldn't change.
+func isLiteral(expr hclsyntax.Expression) bool {
+	switch e := expr.(type) {
+	case *hclsyntax.LiteralValueExpr:
+		return true
+	case *hclsyntax.UnaryOpExpr:
+		_, ok := e.Val.(*hclsyntax.LiteralValueExpr)
+		return ok && e.Op == hclsyntax.OpNegate
+	case *hclsyntax.TemplateExpr:
+		for _, part := range e.Parts {
+			lit, ok := part.(*hclsyntax.LiteralValueExpr)
+			if !ok || !lit.Val.Type().Equals(cty.String) {
+				return false
+			}
+		}
+		return true
+	case *hclsyntax.TupleConsExpr:
+		for _, item := range e.Exprs {
+			if !isLiteral(item) {
+				return false
+			}
+		}
+		return true
+	case *hclsyntax.ObjectConsExpr:
+		for _, item := range e.Items {
+			if !isLiteral(item.KeyExpr) || !isLiteral(item.ValueExpr) {
+				return false
+			}
+		}
+		return true
+	case *hclsyntax.ObjectConsKeyExpr:
+		// Keys are literal if they are names, like `port` in `{ port = 80 }`.
+		return hcl.ExprAsKeyword(e.Wrapped) != "" || isLiteral(e.Wrapped)
+	}
+	return false
+}
+
+// rangeWithin reports whether inner is part of outer.
+func rangeWithin(inner, outer hcl.Range) bool {
+	return inner.Filename == outer.Filename && inner.Start.Byte >= outer.Start.Byte && inner.End.Byte <= outer.End.Byte
+}
+
+func lintConstantExpressions(files []*hcl.File) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+	for _, file := range files {
+		for _, constant := range constantExpressions(file) {
+			diags = append(diags, &hcl.Diagnostic{
+				Summary: "Constant expression",
+				Detail:  fmt.Sprintf("The expression always evaluates to %s, which can be written instead.", valueTokens(constant.val)),
+				Subject: constant.expr.Range().Ptr(),
+			})
+		}
+	}
+	return diags
+}
+
+// ConstantExpressionFixes returns the edits replacing the expressions flagged
+// by the constant_expression lint rule in the given file with their values.
+func ConstantExpressionFixes(file *hcl.File) []SourceEdit {
+	var edits []SourceEdit
+	for _, constant := range constantExpressions(file) {
+		edits = append(edits, SourceEdit{
+			Range:       constant.expr.Range(),
+			Replacement: valueTokens(constant.val),
+		})
+	}
+	return edits
+}
@@ -0,0 +1,92 @@
+package datcfg
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+// FormatValues renders the given values in HCL-like syntax, sorted by name,
+// so that the output is stable across runs.
+func FormatValues(vals map[string]cty.Value) string {
+	names := make([]string, 0, len(vals))
+	for name := range vals {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = name + " = " + FormatValue(vals[name])
+	}
+	return "{" + strings.Join(parts, ", ") + "}"
+}
+
+// FormatValue renders a single value in HCL-like syntax. Values whose type
+// can't be told from the rendered value alone, like nulls and empty
+// collections, are annotated with their type.
+func FormatValue(val cty.Value) string {
+	ty := val.Type()
+	switch {
+	case !val.IsKnown():
+		return "(unknown " + ty.FriendlyName() + ")"
+	case val.IsNull():
+		return "null (" + ty.FriendlyName() + ")"
+	case ty.IsCapsuleType():
+		return capsulePlaceholder(ty)
+	case ty == cty.String:
+		return strconv.Quote(val.AsString())
+	case ty == cty.Number:
+		return val.AsBigFloat().Text('f', -1)
+	case ty == cty.Bool:
+		return strconv.FormatBool(val.True())
+	}
+
+	if val.LengthInt() == 0 {
+		if ty.IsObjectType() || ty.IsMapType() {
+			return "{} (" + ty.FriendlyName() + ")"
+		}
+		return "[] (" + ty.FriendlyName() + ")"
+	}
+
+	if ty.IsObjectType() || ty.IsMapType() {
+		// Object attributes and map keys are already iterated in
+		// lexicographical order.
+		var parts []string
+		for it := val.ElementIterator(); it.Next(); {
+			k, v := it.Element()
+			parts = append(parts, k.AsString()+" = "+FormatValue(v))
+		}
+		return "{" + strings.Join(parts, ", ") + "}"
+	}
+
+	var parts []string
+	for _, v := range sortedElements(val) {
+		parts = append(parts, FormatValue(v))
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
+
+// FormatConfig renders a decoded config struct as compact JSON with sorted
+// keys.
+func FormatConfig(config interface{}) string {
+	return formatJSON(jsonValue(reflect.ValueOf(config)))
+}
+
+// FormatJSONValue renders a single value as compact JSON, like RenderJSON
+// renders values, with unknown values as null.
+func FormatJSONValue(val cty.Value) string {
+	return formatJSON(ctyJSONValue(val))
+}
+
+func formatJSON(v interface{}) string {
+	rendered, err := json.Marshal(v)
+	if err != nil {
+		return err.Error()
+	}
+	return string(rendered)
+}
@@ -0,0 +1,213 @@
+package datcfg
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hcl/hclsyntax"
+	"github.com/zclconf/go-cty/cty/function"
+	"github.com/zclconf/go-cty/cty/function/stdlib"
+)
+
+// builtinFunctions are available in every expression.
+var builtinFunctions = map[string]function.Function{
+	"ceil":       ceilFunc,
+	"coalesce":   stdlib.CoalesceFunc,
+	"concat":     stdlib.ConcatFunc,
+	"csvdecode":  stdlib.CSVDecodeFunc,
+	"format":     stdlib.FormatFunc,
+	"formatdate": stdlib.FormatDateFunc,
+	"floor":      floorFunc,
+	"formatlist": stdlib.FormatListFunc,
+	"jsondecode": stdlib.JSONDecodeFunc,
+	"jsonencode": stdlib.JSONEncodeFunc,
+	"length":     lengthFunc,
+	"lower":      stdlib.LowerFunc,
+	"max":        stdlib.MaxFunc,
+	"min":        stdlib.MinFunc,
+	"percent":    percentFunc,
+	"reverse":    stdlib.ReverseFunc,
+	"strlen":     stdlib.StrlenFunc,
+	"substr":     stdlib.SubstrFunc,
+	"upper":      stdlib.UpperFunc,
+}
+
+var (
+	// functionsMu guards pluginFunctions and contextFunctions.
+	functionsMu sync.RWMutex
+	// pluginFunctions are the functions registered with RegisterFunction,
+	// keyed by their internal name.
+	pluginFunctions = map[string]function.Function{}
+)
+
+// namespaceSeparator replaces the `::` of namespaced function calls in the
+// source before parsing, since the native syntax parser only accepts plain
+// identifiers as function names. It has the same length as `::` so that
+// source ranges stay intact.
+const namespaceSeparator = "__"
+
+// RegisterFunction makes fn available in expressions as `namespace::name(...)`.
+// Functions are always namespaced to avoid collisions with built-in
+// functions, and registering the same name twice is an error.
+func RegisterFunction(namespace, name string, fn function.Function) error {
+	return registerFunction(namespace, name, fn, nil)
+}
+
+// registerFunction registers fn, and its context spec if it has one, at
+// once.
+func registerFunction(namespace, name string, fn function.Function, spec *ContextFunctionSpec) error {
+	key, err := namespacedFunctionName(namespace, name)
+	if err != nil {
+		return err
+	}
+	functionsMu.Lock()
+	defer functionsMu.Unlock()
+	if _, exists := pluginFunctions[key]; exists {
+		return fmt.Errorf("function %s::%s is already registered", namespace, name)
+	}
+	pluginFunctions[key] = fn
+	if spec != nil {
+		contextFunctions[key] = spec
+	}
+	return nil
+}
+
+func namespacedFunctionName(namespace, name string) (string, error) {
+	for _, part := range []string{namespace, name} {
+		if !hclsyntax.ValidIdentifier(part) || strings.Contains(part, namespaceSeparator) {
+			return "", fmt.Errorf("invalid function name %s::%s", namespace, name)
+		}
+	}
+	return namespace + namespaceSeparator + name, nil
+}
+
+// WithFunction makes fn available as `namespace::name(...)` in expressions
+// evaluated by the loader.
+func WithFunction(namespace, name string, fn function.Function) LoaderOption {
+	return func(l *Loader) {
+		key, err := namespacedFunctionName(namespace, name)
+		if err != nil {
+			panic(err)
+		}
+		l.functions[key] = fn
+	}
+}
+
+// functionTable returns all functions available to expressions evaluated by
+// the given loader, with new values for uuid, random_integer and timestamp.
+// The registered functions are called with the context and the function
+// timeout of the loader, and all functions fail once the context of the
+// loader is done. The registered plugin functions are left out in
+// restricted mode, hermetic mode leaves just the built-in functions.
+func functionTable(l *Loader) map[string]function.Function {
+	table := map[string]function.Function{}
+	for name, fn := range builtinFunctions {
+		table[name] = fn
+	}
+	for name, fn := range newRunValues(l).functions() {
+		table[name] = fn
+	}
+	if l.denyingMode() == "" {
+		functionsMu.RLock()
+		for name, fn := range pluginFunctions {
+			table[name] = l.guardedFunction(fn, contextFunctions[name])
+		}
+		functionsMu.RUnlock()
+	}
+	for name, fn := range l.functions {
+		if !l.hermetic {
+			table[name] = l.guardedFunction(fn, l.contextFunctions[name])
+		}
+	}
+	if l.ctx != nil {
+		for name, fn := range table {
+			table[name] = withDeadline(l.ctx, fn, errLoadStopped)
+		}
+	}
+	return table
+}
+
+// errLoadStopped is the error of the function calls of a load whose context
+// is done.
+var errLoadStopped = errors.New("the load was stopped")
+
+// rewriteNamespacedCalls replaces the `::` in calls like `ns::fn(...)` with
+// namespaceSeparator. Only tokens outside of string literals are touched.
+func rewriteNamespacedCalls(src []byte, filename string) []byte {
+	if !strings.Contains(string(src), "::") {
+		return src
+	}
+
+	tokens, _ := hclsyntax.LexConfig(src, filename, hcl.Pos{Line: 1, Column: 1})
+
+	var out []byte
+	for i := 0; i+4 < len(tokens); i++ {
+		if tokens[i].Type != hclsyntax.TokenIdent ||
+			tokens[i+1].Type != hclsyntax.TokenColon ||
+			tokens[i+2].Type != hclsyntax.TokenColon ||
+			tokens[i+3].Type != hclsyntax.TokenIdent ||
+			tokens[i+4].Type != hclsyntax.TokenOParen {
+			continue
+		}
+		if tokens[i+1].Range.End.Byte != tokens[i+2].Range.Start.Byte {
+			continue
+		}
+		if out == nil {
+			out = append([]byte(nil), src...)
+		}
+		copy(out[tokens[i+1].Range.Start.Byte:], namespaceSeparator)
+	}
+
+	if out == nil {
+		return src
+	}
+	return out
+}
+
+// separatorCalls reports the calls in src whose function name contains
+// namespaceSeparator as written, like `ns__fn(...)`, which would otherwise
+// call the namespaced function `ns::fn` without the namespace syntax.
+func separatorCalls(src []byte, filename string) hcl.Diagnostics {
+	if !strings.Contains(string(src), namespaceSeparator) {
+		return nil
+	}
+
+	tokens, _ := hclsyntax.LexConfig(src, filename, hcl.Pos{Line: 1, Column: 1})
+	var diags hcl.Diagnostics
+	for i := 0; i+1 < len(tokens); i++ {
+		if tokens[i].Type != hclsyntax.TokenIdent || tokens[i+1].Type != hclsyntax.TokenOParen {
+			continue
+		}
+		if name := string(tokens[i].Bytes); strings.Contains(name, namespaceSeparator) {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid function name",
+				Detail:   fmt.Sprintf("The function name %q contains %q, which is reserved for namespaced functions. Call them as namespace::name(...).", name, namespaceSeparator),
+				Subject:  tokens[i].Range.Ptr(),
+			})
+		}
+	}
+	return diags
+}
+
+// restoreNamespacedNames undoes rewriteNamespacedCalls in the details of
+// diagnostics about function calls, so that users see the name they wrote.
+func restoreNamespacedNames(diag *hcl.Diagnostic) {
+	switch diag.Summary {
+	case "Call to unknown function":
+		diag.Detail = strings.Replace(diag.Detail, namespaceSeparator, "::", -1)
+	case "Error in function call":
+		// Only the name is rewritten, the error may hold the separator.
+		const prefix = `Call to function "`
+		if !strings.HasPrefix(diag.Detail, prefix) {
+			return
+		}
+		name, rest, ok := strings.Cut(diag.Detail[len(prefix):], `"`)
+		if ok {
+			diag.Detail = prefix + strings.Replace(name, namespaceSeparator, "::", 1) + `"` + rest
+		}
+	}
+}
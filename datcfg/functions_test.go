@@ -0,0 +1,38 @@
+package datcfg
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function/stdlib"
+)
+
+func TestNamespacedFunctionCalls(t *testing.T) {
+	fsys := fstest.MapFS{"cluster.datcfg": {Data: []byte(workersConfig + `
+locals {
+  zone = test::upper("a")
+  # Not a call, just a string.
+  note = "test__upper(a)"
+}
+`)}}
+	result, diags := NewLoader(fsys, WithFunction("test", "upper", stdlib.UpperFunc)).Load()
+	if diags.HasErrors() {
+		t.Fatal(diags)
+	}
+	if got := result.Locals["zone"]; !got.RawEquals(cty.StringVal("A")) {
+		t.Errorf("zone = %#v, want \"A\"", got)
+	}
+
+	// The internal name of the function can't be called, which would skip
+	// the namespace syntax.
+	fsys["cluster.datcfg"] = &fstest.MapFile{Data: []byte(workersConfig + `
+locals {
+  zone = test__upper("a")
+}
+`)}
+	_, diags = NewLoader(fsys, WithFunction("test", "upper", stdlib.UpperFunc)).Load()
+	if !diags.HasErrors() || diags[0].Summary != "Invalid function name" {
+		t.Errorf("got %v, want the call of test__upper rejected", diags)
+	}
+}
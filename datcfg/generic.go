@@ -0,0 +1,157 @@
+package datcfg
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hcl/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// GenericComponent holds the attributes of a component of an unknown kind,
+// decoded without a schema. Nested blocks are attributes named after their
+// type, holding a list of their bodies, nested in objects by their labels
+// like in the JSON syntax. It is only used when the loader was created with
+// WithUnknownComponents.
+type GenericComponent struct {
+	Attrs cty.Value
+}
+
+// PrintAttrs prints the attributes to stdout.
+func (c *GenericComponent) PrintAttrs() {
+	fmt.Printf("Attrs: %s\n", FormatValue(c.Attrs))
+}
+
+// MarshalJSON renders the attributes as a plain JSON object.
+func (c *GenericComponent) MarshalJSON() ([]byte, error) {
+	return json.Marshal(ctyJSONValue(c.Attrs))
+}
+
+// WithUnknownComponents makes the loader decode components of unknown
+// kinds generically, with a warning, instead of failing.
+func WithUnknownComponents() LoaderOption {
+	return func(l *Loader) {
+		l.unknownComponents = true
+	}
+}
+
+// decodeGenericComponent evaluates all attributes and nested blocks of the
+// given component body into a single object value.
+func decodeGenericComponent(component componentBlock, ctx *hcl.EvalContext) (*GenericComponent, hcl.Diagnostics) {
+	diags := hcl.Diagnostics{
+		{
+			Severity: hcl.DiagWarning,
+			Summary:  "Unknown component kind",
+			Detail:   fmt.Sprintf("There is no component kind %q, its attributes are decoded without a schema.", component.Type),
+			Subject:  component.Config.MissingItemRange().Ptr(),
+		},
+	}
+
+	attrs, bodyDiags := decodeGenericBody(component.Config, ctx)
+	return &GenericComponent{Attrs: attrs}, append(diags, bodyDiags...)
+}
+
+// decodeGenericBody evaluates the attributes and the nested blocks of body
+// into an object value.
+func decodeGenericBody(body hcl.Body, ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
+	schema := genericSchema(body)
+	if schema == nil {
+		// Bodies not written in the native syntax, like those of YAML
+		// configs, only have attributes.
+		attrs, diags := body.JustAttributes()
+		return attributeValues(attrs, nil, ctx, diags)
+	}
+	content, diags := body.Content(schema)
+	return attributeValues(content.Attributes, content.Blocks, ctx, diags)
+}
+
+// attributeValues evaluates the given attributes and blocks into an object
+// value, see GenericComponent.
+func attributeValues(attrs hcl.Attributes, blocks hcl.Blocks, ctx *hcl.EvalContext, diags hcl.Diagnostics) (cty.Value, hcl.Diagnostics) {
+	vals := map[string]cty.Value{}
+	for name, attr := range attrs {
+		val, valDiags := attr.Expr.Value(ctx)
+		diags = append(diags, valDiags...)
+		vals[name] = val
+	}
+
+	nested := map[string][]cty.Value{}
+	for _, block := range blocks {
+		val, blockDiags := decodeGenericBody(block.Body, ctx)
+		diags = append(diags, blockDiags...)
+		for i := len(block.Labels) - 1; i >= 0; i-- {
+			val = cty.ObjectVal(map[string]cty.Value{block.Labels[i]: val})
+		}
+		nested[block.Type] = append(nested[block.Type], val)
+	}
+	for blockType, list := range nested {
+		if attr, ok := attrs[blockType]; ok {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Duplicate name",
+				Detail:   fmt.Sprintf("The block type %q has the name of an attribute, which components of unknown kinds can't tell apart.", blockType),
+				Subject:  attr.NameRange.Ptr(),
+			})
+			continue
+		}
+		vals[blockType] = cty.TupleVal(list)
+	}
+	return cty.ObjectVal(vals), diags
+}
+
+// genericSchema returns the schema of everything set in body, or nil if the
+// body is not written in the native syntax. Items that were already decoded,
+// like the meta-arguments of a component, are hidden by the body and left
+// out of its content.
+func genericSchema(body hcl.Body) *hcl.BodySchema {
+	switch body := body.(type) {
+	case *hclsyntax.Body:
+		schema := &hcl.BodySchema{}
+		for name := range body.Attributes {
+			schema.Attributes = append(schema.Attributes, hcl.AttributeSchema{Name: name})
+		}
+		seen := map[string]bool{}
+		for _, block := range body.Blocks {
+			if seen[block.Type] {
+				continue
+			}
+			seen[block.Type] = true
+			schema.Blocks = append(schema.Blocks, hcl.BlockHeaderSchema{
+				Type:       block.Type,
+				LabelNames: make([]string, len(block.Labels)),
+			})
+		}
+		return schema
+	case extendedBody:
+		schema, base := genericSchema(body.body), genericSchema(body.base)
+		if schema == nil || base == nil {
+			return nil
+		}
+		return mergeSchemas(schema, base)
+	}
+	return nil
+}
+
+// mergeSchemas returns a schema with the attributes and the block types of
+// both schemas.
+func mergeSchemas(a, b *hcl.BodySchema) *hcl.BodySchema {
+	merged := &hcl.BodySchema{}
+	attrs := map[string]bool{}
+	blocks := map[string]bool{}
+	for _, schema := range []*hcl.BodySchema{a, b} {
+		for _, attrS := range schema.Attributes {
+			if !attrs[attrS.Name] {
+				attrs[attrS.Name] = true
+				merged.Attributes = append(merged.Attributes, attrS)
+			}
+		}
+		for _, blockS := range schema.Blocks {
+			if !blocks[blockS.Type] {
+				blocks[blockS.Type] = true
+				merged.Blocks = append(merged.Blocks, blockS)
+			}
+		}
+	}
+	return merged
+}
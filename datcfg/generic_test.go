@@ -0,0 +1,61 @@
+package datcfg
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestDecodeGenericComponent(t *testing.T) {
+	fsys := fstest.MapFS{"cluster.datcfg": {Data: []byte(workersConfig + `
+component "unknown" {
+  retries = 2
+  size    = var.workers + 1
+
+  rule "allow" "tcp" {
+    port = 80
+  }
+  rule "deny" "tcp" {
+    port = 22
+  }
+  probe {
+    path = "/healthz"
+    header {
+      name = "Accept"
+    }
+  }
+}
+`)}}
+	result, diags := NewLoader(fsys, WithUnknownComponents()).Load()
+	if diags.HasErrors() {
+		t.Fatal(diags)
+	}
+
+	want := cty.ObjectVal(map[string]cty.Value{
+		"size": cty.NumberIntVal(2),
+		"rule": cty.TupleVal([]cty.Value{
+			cty.ObjectVal(map[string]cty.Value{"allow": cty.ObjectVal(map[string]cty.Value{
+				"tcp": cty.ObjectVal(map[string]cty.Value{"port": cty.NumberIntVal(80)}),
+			})}),
+			cty.ObjectVal(map[string]cty.Value{"deny": cty.ObjectVal(map[string]cty.Value{
+				"tcp": cty.ObjectVal(map[string]cty.Value{"port": cty.NumberIntVal(22)}),
+			})}),
+		}),
+		"probe": cty.TupleVal([]cty.Value{
+			cty.ObjectVal(map[string]cty.Value{
+				"path": cty.StringVal("/healthz"),
+				"header": cty.TupleVal([]cty.Value{
+					cty.ObjectVal(map[string]cty.Value{"name": cty.StringVal("Accept")}),
+				}),
+			}),
+		}),
+	})
+	got := result.Components[0].Config.(*GenericComponent).Attrs
+	if !got.RawEquals(want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+	if retries := result.Components[0].Retries; retries != 2 {
+		t.Errorf("retries = %d, want the meta-argument decoded as 2", retries)
+	}
+}
@@ -0,0 +1,170 @@
+package datcfg
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hcl/hclsyntax"
+)
+
+// Graph is the reference graph of a configuration. Nodes are addressed like
+// in expressions, e.g. `var.foo`, `local.bar`, `component.foo` or
+// `cluster.name`, and an edge from a node points to a node it refers to.
+// Named components are addressed with their kind and name, like in extends,
+// e.g. `component.foo.primary`.
+type Graph struct {
+	Nodes []string
+	Edges map[string][]string
+}
+
+// ReferenceGraph builds the reference graph of the given files from their
+// syntax alone, so it also works for configurations that fail to evaluate.
+func ReferenceGraph(files []*hcl.File) *Graph {
+	refs := referenceTraversals(files)
+
+	g := &Graph{Edges: map[string][]string{}}
+	var cluster string
+	kinds := componentNodes(refs)
+	for node := range refs {
+		g.Nodes = append(g.Nodes, node)
+		if strings.HasPrefix(node, "cluster.") {
+			cluster = node
+		}
+	}
+	sort.Strings(g.Nodes)
+
+	for _, node := range g.Nodes {
+		seen := map[string]bool{}
+		for _, traversal := range refs[node] {
+			var targets []string
+			if target, ok := traversalAddress(traversal); ok {
+				targets = []string{target}
+			}
+			switch traversal.RootName() {
+			case "cluster":
+				// The attributes of the cluster, like
+				// `cluster.worker_count`, belong to its block, which is
+				// named by its label.
+				if cluster != "" {
+					targets = []string{cluster}
+				}
+			case "component":
+				targets = componentTargets(traversal, refs, kinds)
+			}
+			for _, target := range targets {
+				if _, exists := refs[target]; !exists || seen[target] || target == node {
+					continue
+				}
+				seen[target] = true
+				g.Edges[node] = append(g.Edges[node], target)
+			}
+		}
+		sort.Strings(g.Edges[node])
+	}
+	return g
+}
+
+// componentNodes returns the graph nodes of the components of every kind.
+func componentNodes(refs map[string][]hcl.Traversal) map[string][]string {
+	kinds := map[string][]string{}
+	for node := range refs {
+		if parts := strings.Split(node, "."); parts[0] == "component" {
+			kinds[parts[1]] = append(kinds[parts[1]], node)
+		}
+	}
+	return kinds
+}
+
+// componentTargets returns the nodes of the components a `component`
+// traversal refers to. Those naming a component, like in extends, refer to
+// it alone. Those only naming a kind, like in depends_on or to get the
+// outputs, refer to all components of the kind.
+func componentTargets(traversal hcl.Traversal, refs map[string][]hcl.Traversal, kinds map[string][]string) []string {
+	target, ok := traversalAddress(traversal)
+	if !ok {
+		return nil
+	}
+	kind := canonicalKindName(strings.TrimPrefix(target, "component."))
+	if len(traversal) > 2 {
+		if attr, ok := traversal[2].(hcl.TraverseAttr); ok {
+			if _, exists := refs["component."+kind+"."+attr.Name]; exists {
+				return []string{"component." + kind + "." + attr.Name}
+			}
+		}
+	}
+	return kinds[kind]
+}
+
+// Reachable returns the subgraph of the nodes reachable from the given node,
+// including itself.
+func (g *Graph) Reachable(from string) *Graph {
+	sub := &Graph{Edges: map[string][]string{}}
+	visited := map[string]bool{}
+	var visit func(node string)
+	visit = func(node string) {
+		if visited[node] {
+			return
+		}
+		visited[node] = true
+		sub.Nodes = append(sub.Nodes, node)
+		for _, target := range g.Edges[node] {
+			sub.Edges[node] = append(sub.Edges[node], target)
+			visit(target)
+		}
+	}
+
+	for _, node := range g.Nodes {
+		if node == from {
+			visit(node)
+		}
+	}
+	sort.Strings(sub.Nodes)
+	return sub
+}
+
+// referenceTraversals returns the traversals in the top-level blocks of the
+// given files, by the graph node of the block.
+func referenceTraversals(files []*hcl.File) map[string][]hcl.Traversal {
+	refs := map[string][]hcl.Traversal{}
+	for _, block := range topLevelBlocks(files) {
+		switch {
+		case block.Type == "locals":
+			for name, attr := range block.Body.Attributes {
+				refs["local."+name] = attr.Expr.Variables()
+			}
+		case block.Type == "variable" && len(block.Labels) > 0:
+			addTraversals(refs, "var."+block.Labels[0], block.Body)
+		case block.Type == "cluster" && len(block.Labels) > 0:
+			addTraversals(refs, "cluster."+block.Labels[0], block.Body)
+		case block.Type == "component" && len(block.Labels) > 0:
+			node := "component." + canonicalKindName(block.Labels[0])
+			if name, ok := blockComponentName(block); ok {
+				node += "." + name
+			}
+			addTraversals(refs, node, block.Body)
+		}
+	}
+	return refs
+}
+
+func addTraversals(refs map[string][]hcl.Traversal, node string, body *hclsyntax.Body) {
+	refs[node] = append(refs[node], bodyTraversals(body)...)
+}
+
+// traversalAddress returns the graph node a traversal refers to.
+func traversalAddress(traversal hcl.Traversal) (string, bool) {
+	switch traversal.RootName() {
+	case "var", "local", "component", "cluster":
+	default:
+		return "", false
+	}
+	if len(traversal) < 2 {
+		return "", false
+	}
+	attr, ok := traversal[1].(hcl.TraverseAttr)
+	if !ok {
+		return "", false
+	}
+	return traversal.RootName() + "." + attr.Name, true
+}
@@ -0,0 +1,58 @@
+package datcfg
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReferenceGraphNamedComponents(t *testing.T) {
+	files := parseTestFile(t, `
+variable "size" {}
+
+component "test_volume" {
+  name = "base"
+  size = var.size
+}
+
+component "test_volume" {
+  name    = "child"
+  extends = component.test_disk.base
+}
+
+component "test_mount" {
+  depends_on = [component.test_volume]
+}
+`)
+	g := ReferenceGraph(files)
+
+	wantNodes := []string{"component.test_mount", "component.test_volume.base", "component.test_volume.child", "var.size"}
+	if !reflect.DeepEqual(g.Nodes, wantNodes) {
+		t.Errorf("nodes = %q, want %q", g.Nodes, wantNodes)
+	}
+	wantEdges := map[string][]string{
+		"component.test_volume.base":  {"var.size"},
+		"component.test_volume.child": {"component.test_volume.base"},
+		"component.test_mount":        {"component.test_volume.base", "component.test_volume.child"},
+	}
+	if !reflect.DeepEqual(g.Edges, wantEdges) {
+		t.Errorf("edges = %q, want %q", g.Edges, wantEdges)
+	}
+}
+
+func TestReferenceCyclesNamedComponents(t *testing.T) {
+	files := parseTestFile(t, `
+component "test_volume" {
+  name = "a"
+  size = component.test_mount.size
+}
+
+component "test_mount" {
+  name = "b"
+  size = component.test_volume.size
+}
+`)
+	diags := checkReferenceCycles(files)
+	if len(diags) != 1 || diags[0].Summary != "Reference cycle" {
+		t.Fatalf("got %v, want a cycle between the named components", diags)
+	}
+}
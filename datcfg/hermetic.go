@@ -0,0 +1,74 @@
+package datcfg
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hcl/hclsyntax"
+)
+
+// WithHermeticMode makes the loader fail on everything through which the
+// result could depend on the host it runs on, so that a config bundle
+// evaluates identically anywhere, as needed for signed or reproducible
+// config artifacts. On top of what restricted mode denies:
+//
+//   - functions passed to the loader with WithFunction are not available
+//     either, only the built-in functions are;
+//   - the `datcfg.os` and `datcfg.arch` attributes are an error, since they
+//     differ between hosts;
+//   - so are calls of uuid, random_integer and timestamp, unless there is a
+//     seed, see WithSeed.
+//
+// The loader only ever reads the config and values files from its
+// filesystem, which keeps the evaluation within the config directory.
+func WithHermeticMode() LoaderOption {
+	return func(l *Loader) {
+		l.hermetic = true
+	}
+}
+
+// hostAttributes reports the references to the attributes of the `datcfg`
+// object that describe the host, which are not set in hermetic mode.
+func hostAttributes(files []*hcl.File) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+	for _, traversal := range allTraversals(files) {
+		if traversal.RootName() != "datcfg" || len(traversal) < 2 {
+			continue
+		}
+		attr, ok := traversal[1].(hcl.TraverseAttr)
+		if !ok || (attr.Name != "os" && attr.Name != "arch") {
+			continue
+		}
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Host not available",
+			Detail:   fmt.Sprintf("The attribute datcfg.%s is not available in hermetic mode, since it differs between hosts.", attr.Name),
+			Subject:  traversal.SourceRange().Ptr(),
+		})
+	}
+	return diags
+}
+
+// unseededCalls reports the calls of uuid, random_integer and timestamp in
+// the given files, whose values differ between runs without a seed.
+func unseededCalls(files []*hcl.File) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+	for _, file := range files {
+		body, ok := file.Body.(*hclsyntax.Body)
+		if !ok {
+			continue
+		}
+		hclsyntax.VisitAll(body, func(node hclsyntax.Node) hcl.Diagnostics {
+			if call, ok := node.(*runValueExpr); ok {
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Seed required",
+					Detail:   fmt.Sprintf("The function %q needs a seed in hermetic mode, since its values differ between runs otherwise.", call.Name),
+					Subject:  call.NameRange.Ptr(),
+				})
+			}
+			return nil
+		})
+	}
+	return diags
+}
@@ -0,0 +1,254 @@
+package datcfg
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/gocty"
+)
+
+var (
+	unsupportedArgumentDetail  = regexp.MustCompile(`^An argument named "(.*?)" is not expected here\.`)
+	unsupportedBlockTypeDetail = regexp.MustCompile(`^Blocks of type "(.*?)" are not expected here\.`)
+)
+
+// enrichDiagnostics adds actionable hints to the diagnostics hcl produces
+// when the content of a body does not match the schema of the struct type
+// it is decoded into:
+//
+//   - unsupported arguments and blocks get the nearest valid name, or the
+//     list of valid names if none is close
+//   - missing required arguments get an example of the required arguments
+func enrichDiagnostics(diags hcl.Diagnostics, schema *hcl.BodySchema, ty reflect.Type) hcl.Diagnostics {
+	for _, diag := range diags {
+		switch diag.Summary {
+		case "Unsupported argument":
+			var names []string
+			for _, attrS := range schema.Attributes {
+				names = append(names, attrS.Name)
+			}
+			diag.Detail += nameHint(diag.Detail, unsupportedArgumentDetail, "arguments", names)
+		case "Unsupported block type":
+			var names []string
+			for _, blockS := range schema.Blocks {
+				names = append(names, blockS.Type)
+			}
+			diag.Detail += nameHint(diag.Detail, unsupportedBlockTypeDetail, "block types", names)
+		case "Missing required argument":
+			diag.Detail += "\n\nFor example:\n\n" + requiredExample(schema, ty)
+		}
+	}
+	return diags
+}
+
+// nameHint returns the hint for an unsupported name, extracted from the
+// detail using the given pattern. hcl already suggests names close to the
+// given one, which is left as is.
+func nameHint(detail string, pattern *regexp.Regexp, kind string, valid []string) string {
+	match := pattern.FindStringSubmatch(detail)
+	if match == nil || strings.Contains(detail, "Did you mean") || len(valid) == 0 {
+		return ""
+	}
+
+	if suggestion, ok := nearestName(match[1], valid); ok {
+		return fmt.Sprintf(" Did you mean %q?", suggestion)
+	}
+
+	sort.Strings(valid)
+	quoted := make([]string, len(valid))
+	for i, name := range valid {
+		quoted[i] = fmt.Sprintf("%q", name)
+	}
+	return fmt.Sprintf(" Valid %s are %s.", kind, strings.Join(quoted, ", "))
+}
+
+// nearestName returns the valid name closest to the given one. Names that
+// only differ in case and underscores, like "WorkerCount" and "worker_count",
+// always match.
+func nearestName(name string, valid []string) (string, bool) {
+	normalize := func(s string) string {
+		return strings.ToLower(strings.Replace(s, "_", "", -1))
+	}
+
+	best, bestDist := "", len(name)/3+1
+	for _, candidate := range valid {
+		if normalize(candidate) == normalize(name) {
+			return candidate, true
+		}
+		if dist := levenshtein(name, candidate); dist <= bestDist {
+			best, bestDist = candidate, dist
+		}
+	}
+	return best, best != ""
+}
+
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// requiredExample renders all required arguments of the schema with an
+// example value of their type.
+func requiredExample(schema *hcl.BodySchema, ty reflect.Type) string {
+	tags := getDecodeFieldTags(ty)
+
+	var names []string
+	width := 0
+	for _, attrS := range schema.Attributes {
+		if attrS.Required {
+			names = append(names, attrS.Name)
+			if len(attrS.Name) > width {
+				width = len(attrS.Name)
+			}
+		}
+	}
+	sort.Strings(names)
+
+	var lines []string
+	for _, name := range names {
+		example := `"..."`
+		if fieldIdx, ok := tags.Attributes[name]; ok {
+			example = exampleValue(ty.Field(fieldIdx).Type)
+		}
+		lines = append(lines, fmt.Sprintf("  %-*s = %s", width, name, example))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// exampleValue returns a placeholder value in HCL syntax for a field of the
+// given type.
+func exampleValue(fieldTy reflect.Type) string {
+	if hook, _ := decodeHookFor(fieldTy); hook != nil {
+		return `"..."`
+	}
+	ty, err := gocty.ImpliedType(reflect.New(fieldTy).Interface())
+	if err != nil {
+		return `"..."`
+	}
+
+	switch {
+	case ty == cty.Number:
+		return "0"
+	case ty == cty.Bool:
+		return "false"
+	case ty.IsListType() || ty.IsSetType() || ty.IsTupleType():
+		return "[]"
+	case ty.IsMapType() || ty.IsObjectType():
+		return "{}"
+	default:
+		return `"..."`
+	}
+}
+
+// typeMismatch is the diagnostic for a value that cannot be decoded into a
+// field of the given type, like `1.5` into an int or `true` into a string.
+// It tells what the argument expects and what it got instead, since the
+// conversion error alone often does not.
+func typeMismatch(expr hcl.Expression, name string, fieldTy reflect.Type, want cty.Type, got cty.Value) *hcl.Diagnostic {
+	detail := fmt.Sprintf("Expected %s, got %s.", expectedValue(fieldTy, want), describeValue(got))
+	if name != "" {
+		detail = fmt.Sprintf("The argument %q expects %s, got %s.", name, expectedValue(fieldTy, want), describeValue(got))
+	}
+	if isIntegerKind(fieldTy) && got.Type() == cty.Number && got.IsKnown() && !got.IsNull() && !got.AsBigFloat().IsInt() {
+		detail += " Round it with ceil or floor."
+	}
+	return &hcl.Diagnostic{
+		Severity: hcl.DiagError,
+		Summary:  "Unsuitable value type",
+		Detail:   detail,
+		Subject:  expr.StartRange().Ptr(),
+		Context:  expr.Range().Ptr(),
+	}
+}
+
+// expectedValue describes the values a field of the given type accepts.
+func expectedValue(fieldTy reflect.Type, ty cty.Type) string {
+	switch {
+	case isUnsignedKind(fieldTy):
+		return "a non-negative whole number"
+	case isIntegerKind(fieldTy):
+		return "a whole number"
+	case ty == cty.Number:
+		return "a number"
+	case ty == cty.Bool:
+		return "a bool"
+	case ty == cty.String:
+		return "a string"
+	}
+	return "a value of type " + ty.FriendlyName()
+}
+
+// describeValue describes a value that was given for an argument. Simple
+// values are shown as written, others by their type.
+func describeValue(val cty.Value) string {
+	ty := val.Type()
+	switch {
+	case val.IsNull():
+		return "null"
+	case ty.IsPrimitiveType() && val.IsKnown():
+		return FormatValue(val)
+	}
+	return "a value of type " + ty.FriendlyName()
+}
+
+func isIntegerKind(ty reflect.Type) bool {
+	for ty.Kind() == reflect.Ptr {
+		ty = ty.Elem()
+	}
+	switch ty.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return true
+	}
+	return isUnsignedKind(ty)
+}
+
+func isUnsignedKind(ty reflect.Type) bool {
+	for ty.Kind() == reflect.Ptr {
+		ty = ty.Elem()
+	}
+	switch ty.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	}
+	return false
+}
+
+// isWholeNumber reports whether the given known number value is an integer,
+// and not negative if unsigned is set.
+func isWholeNumber(val cty.Value, unsigned bool) bool {
+	bf := val.AsBigFloat()
+	if !bf.IsInt() {
+		return false
+	}
+	return !unsigned || bf.Sign() >= 0
+}
@@ -0,0 +1,96 @@
+package datcfg
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/hcl2/hcl"
+)
+
+// HooksSettings configures external commands that are run at certain points
+// of the evaluation.
+type HooksSettings struct {
+	// PostDecode commands are run after the config has been fully decoded,
+	// with the JSON-rendered result on stdin. They run in the config
+	// directory, and relative paths like ./hooks/check are relative to it,
+	// not to the working directory.
+	PostDecode []string `hcl:"post_decode,optional"`
+}
+
+// WithoutHooks disables the post_decode hooks, for callers that load the
+// config repeatedly, like the language server.
+func WithoutHooks() LoaderOption {
+	return func(l *Loader) {
+		l.noHooks = true
+	}
+}
+
+// HasPostDecodeHooks reports whether the settings in the given files declare
+// post_decode hooks, which run external commands on every load.
+func HasPostDecodeHooks(files []*hcl.File) bool {
+	for _, block := range topLevelBlocks(files) {
+		if block.Type != "settings" {
+			continue
+		}
+		for _, hooks := range block.Body.Blocks {
+			if _, ok := hooks.Body.Attributes["post_decode"]; ok && hooks.Type == "hooks" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// runPostDecodeHooks runs the post_decode hooks in dir, the config
+// directory.
+func runPostDecodeHooks(hooks *HooksSettings, dir string, result *Config) hcl.Diagnostics {
+	if len(hooks.PostDecode) == 0 {
+		return nil
+	}
+
+	rendered, err := RenderJSON(result)
+	if err != nil {
+		return hcl.Diagnostics{
+			{
+				Severity: hcl.DiagError,
+				Summary:  "Failed to render result",
+				Detail:   err.Error(),
+			},
+		}
+	}
+
+	var diags hcl.Diagnostics
+	for _, command := range hooks.PostDecode {
+		cmd := exec.Command(hookPath(dir, command))
+		cmd.Dir = dir
+		cmd.Stdin = bytes.NewReader(rendered)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Post-decode hook failed",
+				Detail:   fmt.Sprintf("The post_decode hook %q failed: %s.", command, err),
+			})
+		}
+	}
+	return diags
+}
+
+// hookPath returns the path of the given hook command. Commands with a
+// relative path are resolved against dir and made absolute, since the
+// command runs in dir. Bare names are looked up in $PATH.
+func hookPath(dir, command string) string {
+	if filepath.IsAbs(command) || !strings.ContainsRune(filepath.ToSlash(command), '/') {
+		return command
+	}
+	path, err := filepath.Abs(filepath.Join(dir, command))
+	if err != nil {
+		return filepath.Join(dir, command)
+	}
+	return path
+}
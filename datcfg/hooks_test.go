@@ -0,0 +1,45 @@
+package datcfg
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPostDecodeHookRelativeToConfigDir(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"cluster.datcfg": `
+cluster "a" {
+  controller_count = 1
+  worker_count     = 2
+}
+
+settings {
+  hooks {
+    post_decode = ["./hooks/record"]
+  }
+}
+`,
+		"hooks/record": "#!/bin/sh\ncat > decoded.json\n",
+	}
+	for name, src := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The hook is found, and run, in the config directory, wherever the
+	// loader runs.
+	t.Chdir(t.TempDir())
+	if _, diags := NewLoader(os.DirFS(dir), WithRootDir(dir)).Load(); diags.HasErrors() {
+		t.Fatal(diags)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "decoded.json")); err != nil {
+		t.Errorf("the hook did not run in the config directory: %s", err)
+	}
+}
@@ -0,0 +1,181 @@
+package datcfg
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hcl/hclsyntax"
+)
+
+// EvalCache keeps the parsed files and the decoded components of a load
+// around, so that loading again after a change, like in watch mode, only
+// re-parses the files that changed and only re-decodes the components whose
+// source or referenced values changed. Everything else, like variables and
+// locals, is evaluated again on every load.
+//
+// A cache must not be used by several loaders at the same time.
+type EvalCache struct {
+	// mu guards the files while they are parsed concurrently.
+	mu         sync.Mutex
+	files      map[string]cachedFile
+	components map[string]ComponentConfig
+
+	// nextFiles and nextComponents are the entries used by the current
+	// load. They replace the previous ones when it is done, so that the
+	// entries of deleted files and blocks are dropped.
+	nextFiles      map[string]cachedFile
+	nextComponents map[string]ComponentConfig
+	stats          EvalCacheStats
+}
+
+type cachedFile struct {
+	src   []byte
+	file  *hcl.File
+	diags hcl.Diagnostics
+}
+
+// EvalCacheStats tell how much work the last load with a cache did.
+type EvalCacheStats struct {
+	FilesParsed       int
+	FilesReused       int
+	ComponentsDecoded int
+	ComponentsReused  int
+}
+
+// NewEvalCache returns an empty cache.
+func NewEvalCache() *EvalCache {
+	return &EvalCache{
+		files:      map[string]cachedFile{},
+		components: map[string]ComponentConfig{},
+	}
+}
+
+// WithEvalCache makes the loader reuse the results of previous loads from
+// the given cache, and store its own results in it.
+func WithEvalCache(cache *EvalCache) LoaderOption {
+	return func(l *Loader) {
+		l.cache = cache
+	}
+}
+
+// Stats returns the statistics of the last load.
+func (c *EvalCache) Stats() EvalCacheStats {
+	return c.stats
+}
+
+func (c *EvalCache) begin() {
+	if c == nil {
+		return
+	}
+	c.nextFiles = map[string]cachedFile{}
+	c.nextComponents = map[string]ComponentConfig{}
+	c.stats = EvalCacheStats{}
+}
+
+// finish replaces the entries of the previous load with those used by the
+// one that just finished.
+func (c *EvalCache) finish() {
+	if c == nil {
+		return
+	}
+	c.files, c.components = c.nextFiles, c.nextComponents
+	c.nextFiles, c.nextComponents = nil, nil
+}
+
+// parseSource is ParseConfig, reusing the result for unchanged files. It is
+// safe for concurrent use.
+func (c *EvalCache) parseSource(src []byte, path string) (*hcl.File, hcl.Diagnostics) {
+	if c == nil {
+		return ParseConfig(src, path)
+	}
+
+	c.mu.Lock()
+	cached, ok := c.files[path]
+	if ok && bytes.Equal(cached.src, src) {
+		c.nextFiles[path] = cached
+		c.stats.FilesReused++
+		c.mu.Unlock()
+		return cached.file, cached.diags
+	}
+	c.mu.Unlock()
+
+	file, diags := ParseConfig(src, path)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.nextFiles[path] = cachedFile{src: src, file: file, diags: diags}
+	c.stats.FilesParsed++
+	return file, diags
+}
+
+// decodeComponent decodes the body of the given component instance into a
+// new config of its kind, unless a previous load decoded the same source with
+// the same referenced values already. Decoding must finish within the given
+// timeout, if it is not zero, otherwise the returned bool is false.
+func (c *EvalCache) decodeComponent(block componentBlock, index int, ctx *hcl.EvalContext, timeout time.Duration) (ComponentConfig, hcl.Diagnostics, bool) {
+	key, cacheable := c.componentKey(block, index, ctx)
+	if cacheable {
+		if config, ok := c.components[key]; ok {
+			c.nextComponents[key] = config
+			c.stats.ComponentsReused++
+			return config, nil, true
+		}
+	}
+
+	config, _ := newComponent(block.Type)
+	diags, inTime := decodeWithin(timeout, ctx, func(ctx *hcl.EvalContext) hcl.Diagnostics {
+		return DecodeBody(block.Config, ctx, config)
+	})
+	if !inTime {
+		return nil, nil, false
+	}
+	if c != nil {
+		c.stats.ComponentsDecoded++
+	}
+	// Warnings are not cached, so results with diagnostics are decoded
+	// again to report them.
+	if cacheable && len(diags) == 0 {
+		c.nextComponents[key] = config
+	}
+	return config, diags, true
+}
+
+// componentKey identifies the result of decoding a component instance by
+// its source and the values of everything its expressions refer to, as far
+// as the evaluation context goes, like `var.name` or `component.kind`.
+// Bodies calling functions whose values differ between loads, see
+// NondeterministicCalls, are not cached.
+func (c *EvalCache) componentKey(block componentBlock, index int, ctx *hcl.EvalContext) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	body, ok := block.Config.(*hclsyntax.Body)
+	if !ok || len(NondeterministicCalls([]*hcl.File{{Body: body}})) > 0 {
+		return "", false
+	}
+	cached, ok := c.nextFiles[body.SrcRange.Filename]
+	if !ok || body.SrcRange.End.Byte > len(cached.src) {
+		return "", false
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%d\x00", body.SrcRange.Filename, block.Type, index)
+	h.Write(cached.src[body.SrcRange.Start.Byte:body.SrcRange.End.Byte])
+	for _, traversal := range bodyTraversals(body) {
+		if len(traversal) > 2 {
+			traversal = traversal[:2]
+		}
+		val, diags := traversal.TraverseAbs(ctx)
+		// Capsule values all render the same, so they can't be told
+		// apart by the key.
+		if diags.HasErrors() || containsCapsule(val) {
+			return "", false
+		}
+		fmt.Fprintf(h, "\x00%s\x00%s", val.Type().GoString(), FormatValue(val))
+	}
+	return hex.EncodeToString(h.Sum(nil)), true
+}
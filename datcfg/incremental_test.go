@@ -0,0 +1,43 @@
+package datcfg
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+type cachedValueConfig struct {
+	Value string `hcl:"value"`
+}
+
+func init() {
+	MustRegisterComponent("test_cached_value", &cachedValueConfig{})
+}
+
+func TestEvalCacheNondeterministicCalls(t *testing.T) {
+	fsys := fstest.MapFS{"cluster.datcfg": {Data: []byte(workersConfig + `
+component "test_volume" {
+  size = var.workers
+}
+
+component "test_cached_value" {
+  value = uuid()
+}
+`)}}
+	cache := NewEvalCache()
+	load := func() string {
+		result, diags := NewLoader(fsys, WithEvalCache(cache)).Load()
+		if diags.HasErrors() {
+			t.Fatal(diags)
+		}
+		return result.Components[1].Config.(*cachedValueConfig).Value
+	}
+
+	first := load()
+	second := load()
+	if first == second {
+		t.Errorf("uuid() returned %s again, want the component calling it decoded again", first)
+	}
+	if stats := cache.Stats(); stats.ComponentsReused != 1 || stats.ComponentsDecoded != 1 {
+		t.Errorf("got %+v, want only the component without nondeterministic calls reused", stats)
+	}
+}
@@ -0,0 +1,88 @@
+package datcfg
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// lifecycleBlock is the `lifecycle` meta block accepted by every component,
+// whatever its kind.
+type lifecycleBlock struct {
+	// IgnoreChanges lists the attributes excluded from change detection,
+	// either as bare names or as strings:
+	//
+	//	lifecycle {
+	//	  ignore_changes = [token, "last_seen"]
+	//	}
+	IgnoreChanges hcl.Expression `hcl:"ignore_changes,optional"`
+}
+
+// decodeLifecycle evaluates the `lifecycle` meta block of a component block
+// into the given instance. Listed attributes must exist in the schema of
+// the component, unless its kind is unknown.
+func decodeLifecycle(component componentBlock, instance *Component) hcl.Diagnostics {
+	if component.Lifecycle == nil || !isSet(component.Lifecycle.IgnoreChanges) {
+		return nil
+	}
+
+	exprs, diags := hcl.ExprList(component.Lifecycle.IgnoreChanges)
+	if diags.HasErrors() {
+		return diags
+	}
+
+	var known map[string]int
+	if ty, ok := componentType(component.Type); ok {
+		known = getDecodeFieldTags(ty).Attributes
+	}
+
+	for _, expr := range exprs {
+		name, ok := attributeName(expr)
+		if !ok {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid ignore_changes entry",
+				Detail:   "The entries of ignore_changes must be attribute names.",
+				Subject:  expr.Range().Ptr(),
+			})
+			continue
+		}
+		if _, exists := known[name]; known != nil && !exists {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Unknown attribute in ignore_changes",
+				Detail:   fmt.Sprintf("Components of kind %q have no attribute named %q.", component.Type, name),
+				Subject:  expr.Range().Ptr(),
+			})
+			continue
+		}
+		instance.IgnoreChanges = append(instance.IgnoreChanges, name)
+	}
+	return diags
+}
+
+// attributeName returns the name an ignore_changes entry refers to, which is
+// either a bare name or a string literal.
+func attributeName(expr hcl.Expression) (string, bool) {
+	if traversal, diags := hcl.AbsTraversalForExpr(expr); !diags.HasErrors() && len(traversal) == 1 {
+		return traversal.RootName(), true
+	}
+
+	val, diags := expr.Value(nil)
+	if diags.HasErrors() || !val.Type().Equals(cty.String) || val.IsNull() {
+		return "", false
+	}
+	return val.AsString(), true
+}
+
+// IgnoresChanges reports whether change detection skips the given attribute
+// of the component, as requested by its `lifecycle` block.
+func (c Component) IgnoresChanges(attr string) bool {
+	for _, name := range c.IgnoreChanges {
+		if name == attr {
+			return true
+		}
+	}
+	return false
+}
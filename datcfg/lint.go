@@ -0,0 +1,350 @@
+package datcfg
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hcl/hclsyntax"
+	"github.com/hashicorp/hcl2/hclparse"
+)
+
+// LintConfigFile is the name of the file configuring the lint rules.
+const LintConfigFile = ".datlint.hcl"
+
+// LintRule is a single check run by Lint over the parsed config files.
+type LintRule struct {
+	Name            string
+	DefaultSeverity string
+	Check           func(files []*hcl.File) hcl.Diagnostics
+}
+
+// LintRules are the rules run by Lint.
+var LintRules = []LintRule{
+	{
+		Name:            "deprecated_attribute",
+		DefaultSeverity: "warning",
+		Check:           lintDeprecatedAttributes,
+	},
+	{
+		Name:            "interpolation_only",
+		DefaultSeverity: "warning",
+		Check:           lintInterpolationOnly,
+	},
+	{
+		Name:            "unused_variable",
+		DefaultSeverity: "warning",
+		Check:           lintUnusedVariables,
+	},
+	{
+		Name:            "shadowed_local",
+		DefaultSeverity: "error",
+		Check:           lintShadowedLocals,
+	},
+	{
+		Name:            "undeclared_component",
+		DefaultSeverity: "error",
+		Check:           lintUndeclaredComponents,
+	},
+	{
+		Name:            ImplicitConversionRule,
+		DefaultSeverity: "off",
+		Check:           lintImplicitConversions,
+	},
+	{
+		Name:            ConstantExpressionRule,
+		DefaultSeverity: "off",
+		Check:           lintConstantExpressions,
+	},
+}
+
+// LintConfig is the content of a `.datlint.hcl` file.
+type LintConfig struct {
+	Rules []LintRuleConfig `hcl:"rule,block"`
+}
+
+// LintRuleConfig overrides the severity of a single rule. Severity is one of
+// "error", "warning" or "off".
+type LintRuleConfig struct {
+	Name     string `hcl:"name,label"`
+	Severity string `hcl:"severity,attr"`
+}
+
+// Lint runs all lint rules over the given files. The severities override the
+// default severity of the rules they name, as returned by LoadLintConfig.
+func Lint(files []*hcl.File, severities map[string]string) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+	for _, rule := range LintRules {
+		severity := rule.DefaultSeverity
+		if s, ok := severities[rule.Name]; ok {
+			severity = s
+		}
+		if severity == "off" {
+			continue
+		}
+
+		for _, diag := range rule.Check(files) {
+			diag.Severity = hcl.DiagWarning
+			if severity == "error" {
+				diag.Severity = hcl.DiagError
+			}
+			diag.Summary = fmt.Sprintf("[%s] %s", rule.Name, diag.Summary)
+			diags = append(diags, diag)
+		}
+	}
+	return diags
+}
+
+// LoadLintConfig reads the rule severities from the given file. A missing
+// file is not an error, all rules then use their default severity.
+func LoadLintConfig(fsys fs.FS, path string) (map[string]string, hcl.Diagnostics) {
+	severities := map[string]string{}
+	if _, err := fs.Stat(fsys, path); errors.Is(err, fs.ErrNotExist) {
+		return severities, nil
+	}
+
+	hclParser := hclparse.NewParser()
+	file, diags := parseHCLFile(hclParser, fsys, path)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	var config LintConfig
+	diags = append(diags, DecodeBody(file.Body, nil, &config)...)
+
+	known := map[string]bool{}
+	for _, rule := range LintRules {
+		known[rule.Name] = true
+	}
+
+	for _, rule := range config.Rules {
+		switch {
+		case !known[rule.Name]:
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Unknown lint rule",
+				Detail:   fmt.Sprintf("There is no lint rule named %q.", rule.Name),
+			})
+		case rule.Severity != "error" && rule.Severity != "warning" && rule.Severity != "off":
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid lint severity",
+				Detail:   fmt.Sprintf("The severity of rule %q must be \"error\", \"warning\" or \"off\", not %q.", rule.Name, rule.Severity),
+			})
+		default:
+			severities[rule.Name] = rule.Severity
+		}
+	}
+
+	return severities, diags
+}
+
+func lintDeprecatedAttributes(files []*hcl.File) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+	for _, block := range topLevelBlocks(files) {
+		deprecated := deprecatedAttributes(blockConfigType(block))
+		for _, attr := range sortedAttributes(block.Body) {
+			if msg, ok := deprecated[attr.Name]; ok {
+				diags = append(diags, deprecationWarning(attr.AsHCLAttribute(), msg))
+			}
+		}
+	}
+	return diags
+}
+
+func lintInterpolationOnly(files []*hcl.File) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+	for _, file := range files {
+		body, ok := file.Body.(*hclsyntax.Body)
+		if !ok {
+			continue
+		}
+		hclsyntax.VisitAll(body, func(node hclsyntax.Node) hcl.Diagnostics {
+			wrap, ok := node.(*hclsyntax.TemplateWrapExpr)
+			if !ok {
+				return nil
+			}
+			inner := wrap.Wrapped.Range()
+			diags = append(diags, &hcl.Diagnostic{
+				Summary: "Interpolation-only expression",
+				Detail: fmt.Sprintf(
+					"Strings consisting of a single interpolation sequence are redundant, use the expression directly: %s",
+					inner.SliceBytes(file.Bytes),
+				),
+				Subject: wrap.SrcRange.Ptr(),
+			})
+			return nil
+		})
+	}
+	return diags
+}
+
+func lintUnusedVariables(files []*hcl.File) hcl.Diagnostics {
+	used := map[string]bool{}
+	for _, traversal := range allTraversals(files) {
+		if traversal.RootName() != "var" || len(traversal) < 2 {
+			continue
+		}
+		if attr, ok := traversal[1].(hcl.TraverseAttr); ok {
+			used[attr.Name] = true
+		}
+	}
+
+	var diags hcl.Diagnostics
+	for _, block := range topLevelBlocks(files) {
+		if block.Type != "variable" || len(block.Labels) == 0 || used[block.Labels[0]] {
+			continue
+		}
+		diags = append(diags, &hcl.Diagnostic{
+			Summary: "Unused variable",
+			Detail:  fmt.Sprintf("The variable %q is declared but never referenced.", block.Labels[0]),
+			Subject: block.LabelRanges[0].Ptr(),
+		})
+	}
+	return diags
+}
+
+func lintShadowedLocals(files []*hcl.File) hcl.Diagnostics {
+	declared := map[string]bool{}
+	for _, block := range topLevelBlocks(files) {
+		if block.Type == "variable" && len(block.Labels) > 0 {
+			declared[block.Labels[0]] = true
+		}
+	}
+
+	var diags hcl.Diagnostics
+	for _, block := range topLevelBlocks(files) {
+		if block.Type != "locals" {
+			continue
+		}
+		for _, attr := range sortedAttributes(block.Body) {
+			if declared[attr.Name] {
+				diags = append(diags, shadowedLocal(attr.AsHCLAttribute()))
+			}
+		}
+	}
+	return diags
+}
+
+// lintUndeclaredComponents checks that component references name declared
+// kinds, and that extends names a declared component of the kind.
+func lintUndeclaredComponents(files []*hcl.File) hcl.Diagnostics {
+	declared := map[string]bool{}
+	// names are the static names of the components of every kind.
+	names := map[string]map[string]bool{}
+	// kinds are the kinds of the named components, by name.
+	kinds := map[string]string{}
+	for _, block := range topLevelBlocks(files) {
+		if block.Type != "component" || len(block.Labels) == 0 {
+			continue
+		}
+		// References are resolved through the canonical kind, so that
+		// aliases and the canonical kind refer to the same components.
+		kind := canonicalKindName(block.Labels[0])
+		declared[kind] = true
+		if names[kind] == nil {
+			names[kind] = map[string]bool{}
+		}
+		if name, ok := blockComponentName(block); ok {
+			names[kind][name] = true
+			kinds[name] = block.Labels[0]
+		}
+	}
+
+	var diags hcl.Diagnostics
+	for _, traversal := range allTraversals(files) {
+		if traversal.RootName() != "component" || len(traversal) < 2 {
+			continue
+		}
+		attr, ok := traversal[1].(hcl.TraverseAttr)
+		if !ok || declared[canonicalKindName(attr.Name)] {
+			continue
+		}
+		detail := fmt.Sprintf("There is no component %q declared in the configuration.", attr.Name)
+		if kind, ok := kinds[attr.Name]; ok {
+			detail = fmt.Sprintf("There is no component of kind %q. Named components are referred to with their kind, like component.%s.%s.", attr.Name, kind, attr.Name)
+		}
+		diags = append(diags, &hcl.Diagnostic{
+			Summary: "Reference to undeclared component",
+			Detail:  detail,
+			Subject: traversal.SourceRange().Ptr(),
+		})
+	}
+
+	for _, block := range topLevelBlocks(files) {
+		if block.Type != "component" {
+			continue
+		}
+		attr, ok := block.Body.Attributes["extends"]
+		if !ok {
+			continue
+		}
+		traversal, travDiags := hcl.AbsTraversalForExpr(attr.Expr)
+		if travDiags.HasErrors() || len(traversal) != 3 || traversal.RootName() != "component" {
+			continue
+		}
+		kind, ok := traversal[1].(hcl.TraverseAttr)
+		name, nameOK := traversal[2].(hcl.TraverseAttr)
+		if !ok || !nameOK || !declared[canonicalKindName(kind.Name)] || names[canonicalKindName(kind.Name)][name.Name] {
+			continue
+		}
+		diags = append(diags, &hcl.Diagnostic{
+			Summary: "Reference to undeclared component",
+			Detail:  fmt.Sprintf("There is no component of kind %q named %q.", kind.Name, name.Name),
+			Subject: traversal.SourceRange().Ptr(),
+		})
+	}
+	return diags
+}
+
+// topLevelBlocks returns the top-level blocks of all given files, in file
+// order.
+func topLevelBlocks(files []*hcl.File) []*hclsyntax.Block {
+	var blocks []*hclsyntax.Block
+	for _, file := range files {
+		if body, ok := file.Body.(*hclsyntax.Body); ok {
+			blocks = append(blocks, body.Blocks...)
+		}
+	}
+	return blocks
+}
+
+// allTraversals returns the variable traversals of all expressions in the
+// given files.
+func allTraversals(files []*hcl.File) []hcl.Traversal {
+	var traversals []hcl.Traversal
+	for _, file := range files {
+		body, ok := file.Body.(*hclsyntax.Body)
+		if !ok {
+			continue
+		}
+		traversals = append(traversals, bodyTraversals(body)...)
+	}
+	return traversals
+}
+
+// bodyTraversals returns the variable traversals of all expressions in the
+// given body, including nested blocks.
+func bodyTraversals(body *hclsyntax.Body) []hcl.Traversal {
+	var traversals []hcl.Traversal
+	hclsyntax.VisitAll(body, func(node hclsyntax.Node) hcl.Diagnostics {
+		if attr, ok := node.(*hclsyntax.Attribute); ok {
+			traversals = append(traversals, attr.Expr.Variables()...)
+		}
+		return nil
+	})
+	return traversals
+}
+
+func sortedAttributes(body *hclsyntax.Body) []*hclsyntax.Attribute {
+	attrs := make([]*hclsyntax.Attribute, 0, len(body.Attributes))
+	for _, attr := range body.Attributes {
+		attrs = append(attrs, attr)
+	}
+	sort.Slice(attrs, func(i, j int) bool {
+		return attrs[i].SrcRange.Start.Byte < attrs[j].SrcRange.Start.Byte
+	})
+	return attrs
+}
@@ -0,0 +1,621 @@
+package datcfg
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"sort"
+	"time"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+)
+
+// Loader loads and evaluates the config files and the values files found in
+// the root of a filesystem.
+type Loader struct {
+	fsys         fs.FS
+	valuesFile   string
+	capsuleTypes bool
+	functions    map[string]function.Function
+	// contextFunctions are the specs of the functions in functions that
+	// get a context.
+	contextFunctions map[string]*ContextFunctionSpec
+	functionTimeout  time.Duration
+	valueSources     map[string]ValueSource
+	values           map[string]cty.Value
+	overrides        []override
+
+	valuesEnvironment string
+
+	blockContexts []BlockContextFunc
+
+	unknownComponents bool
+	noHooks           bool
+	isolateParseErrs  bool
+	restricted        bool
+	hermetic          bool
+	strictValues      bool
+	yamlConfigs       bool
+
+	cache  *EvalCache
+	ranges sourceMap
+
+	env       map[string]string
+	recording *Recording
+	rootDir   string
+	seed      *int64
+	ctx       context.Context
+}
+
+// LoaderOption configures optional behavior of a Loader.
+type LoaderOption func(*Loader)
+
+// WithCapsuleTypes allows components to export capsule-typed values, like
+// connection handles, to the components declared after them.
+func WithCapsuleTypes() LoaderOption {
+	return func(l *Loader) {
+		l.capsuleTypes = true
+	}
+}
+
+// WithIsolatedParseErrors makes the loader continue with the config files
+// that parsed cleanly when others contain syntax errors, instead of stopping.
+// The parse errors are still returned, together with the result of loading
+// the remaining files, so that all problems are reported in one pass.
+func WithIsolatedParseErrors() LoaderOption {
+	return func(l *Loader) {
+		l.isolateParseErrs = true
+	}
+}
+
+// NewLoader returns a loader reading from the given filesystem.
+func NewLoader(fsys fs.FS, opts ...LoaderOption) *Loader {
+	l := &Loader{
+		fsys:             fsys,
+		valuesFile:       "dat.vars",
+		functions:        map[string]function.Function{},
+		contextFunctions: map[string]*ContextFunctionSpec{},
+		valueSources:     registeredValueSources(),
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	if l.rootDir == "" {
+		l.rootDir = rootDirOf(fsys)
+	}
+	if l.recording != nil {
+		l.fsys = recordingFS{FS: l.fsys, rec: l.recording}
+	}
+	return l
+}
+
+// Load parses, decodes and evaluates the configuration. It stops at the
+// first step that produces errors.
+func (l *Loader) Load() (*Config, hcl.Diagnostics) {
+	l.cache.begin()
+	l.ranges = sourceMap{}
+	result, diags := l.load()
+	l.cache.finish()
+	for _, diag := range diags {
+		if l.denyingMode() != "" {
+			l.explainDenied(diag)
+		}
+		restoreNamespacedNames(diag)
+	}
+	return result, diags
+}
+
+// LoadConfig loads the configuration found in the root of fsys. Warnings are
+// discarded, errors are returned as a *DiagnosticsError.
+func LoadConfig(fsys fs.FS, opts ...LoaderOption) (*Config, error) {
+	result, diags := NewLoader(fsys, opts...).Load()
+	if diags.HasErrors() {
+		return nil, &DiagnosticsError{Diags: diags}
+	}
+	return result, nil
+}
+
+// loadFiles parses the config files and resolves the variables, returning
+// the files that apply with their bodies, without their applies_when
+// conditions. The config has the values, the variables and the evaluation
+// context set, it is nil if there are errors.
+func (l *Loader) loadFiles() (*Config, []*hcl.File, []hcl.Body, hcl.Diagnostics) {
+	var diags DiagnosticsCollector
+	hclFiles, parseDiags := parseConfigFiles(l.fsys, l.cache)
+	diags.Add(parseDiags)
+	if l.yamlConfigs {
+		yamlFiles, yamlDiags := parseYAMLConfigFiles(l.fsys)
+		diags.Add(yamlDiags)
+		hclFiles = append(hclFiles, yamlFiles...)
+		sort.SliceStable(hclFiles, func(i, j int) bool {
+			return hclFiles[i].Body.MissingItemRange().Filename < hclFiles[j].Body.MissingItemRange().Filename
+		})
+	}
+	if diags.HasErrors() && !l.isolateParseErrs {
+		return nil, nil, nil, diags.Diagnostics()
+	}
+	moduleFiles, moduleDiags := l.loadModules(hclFiles)
+	diags.Add(moduleDiags)
+	if moduleDiags.HasErrors() {
+		return nil, nil, nil, diags.Diagnostics()
+	}
+	hclFiles = append(hclFiles, moduleFiles...)
+
+	if l.hermetic {
+		hostDiags := hostAttributes(hclFiles)
+		if l.seed == nil {
+			hostDiags = append(hostDiags, unseededCalls(hclFiles)...)
+		}
+		diags.Add(hostDiags)
+		if hostDiags.HasErrors() {
+			return nil, nil, nil, diags.Diagnostics()
+		}
+	}
+	if mode := l.denyingMode(); mode != "" {
+		cwdDiags := cwdReferences(hclFiles, mode)
+		diags.Add(cwdDiags)
+		if cwdDiags.HasErrors() {
+			return nil, nil, nil, diags.Diagnostics()
+		}
+	}
+
+	policyDiags := l.checkPolicy(hclFiles)
+	diags.Add(policyDiags)
+	if policyDiags.HasErrors() {
+		return nil, nil, nil, diags.Diagnostics()
+	}
+
+	result := &Config{Files: fileNames(hclFiles)}
+
+	userVals, valueRanges, valDiags := loadValuesFiles(l.fsys, l.valuesFile, l.valuesEnvironment)
+	diags.Add(valDiags)
+	if valDiags.HasErrors() {
+		return nil, nil, nil, diags.Diagnostics()
+	}
+	for name, val := range l.values {
+		userVals[name] = val
+		delete(valueRanges, name)
+	}
+	result.valueRanges = valueRanges
+	if !l.strictValues {
+		valueRanges = nil
+	}
+	result.Values = userVals
+
+	conditions, bodies, condDiags := splitFileConditions(hclFiles)
+	diags.Add(condDiags)
+	if condDiags.HasErrors() {
+		return nil, nil, nil, diags.Diagnostics()
+	}
+	result.bodies = bodies
+
+	// Variables are resolved from all files, including those excluded by
+	// their applies_when condition, since the conditions refer to them.
+	variables, sensitive, varDiags := resolveVariables(hcl.MergeBodies(bodies), userVals, valueRanges, restrictedSources(l))
+	diags.Add(varDiags)
+	if varDiags.HasErrors() {
+		return nil, nil, nil, diags.Diagnostics()
+	}
+	result.Variables = variables
+	result.Sensitive = sensitive
+
+	evalContext := &hcl.EvalContext{
+		Variables: map[string]cty.Value{
+			"var":    cty.ObjectVal(variables),
+			"datcfg": toolObject(l.hermetic),
+			"path":   l.pathObject(),
+		},
+		Functions: functionTable(l),
+	}
+	if l.denyingMode() == "" {
+		env := l.environment()
+		evalContext.Variables["env"] = envObject(env)
+		if l.recording != nil {
+			l.recording.recordEnv(hclFiles, env)
+		}
+	}
+	result.EvalContext = evalContext
+
+	included, includedBodies, inclDiags := applyFileConditions(hclFiles, conditions, bodies, evalContext)
+	diags.Add(inclDiags)
+	if inclDiags.HasErrors() {
+		return nil, nil, nil, diags.Diagnostics()
+	}
+	result.Files = fileNames(included)
+	return result, included, includedBodies, diags.Diagnostics()
+}
+
+func (l *Loader) load() (*Config, hcl.Diagnostics) {
+	result, included, includedBodies, fileDiags := l.loadFiles()
+	var diags DiagnosticsCollector
+	diags.Add(fileDiags)
+	if result == nil {
+		return nil, diags.Diagnostics()
+	}
+	evalContext := result.EvalContext
+
+	var configRoot configRoot
+	rootDiags := DecodeBody(hcl.MergeBodies(includedBodies), nil, &configRoot)
+	registered, registeredDiags := registeredBlocks(configRoot.Registered)
+	rootDiags = append(rootDiags, registeredDiags...)
+	diags.Add(rootDiags)
+	if rootDiags.HasErrors() {
+		return nil, diags.Diagnostics()
+	}
+	result.root = &configRoot
+
+	_, extendsDiags := resolveExtends(configRoot.Components)
+	diags.Add(extendsDiags)
+	if extendsDiags.HasErrors() {
+		return nil, diags.Diagnostics()
+	}
+	defaultsDiags := applyDefaults(&configRoot)
+	diags.Add(defaultsDiags)
+	if defaultsDiags.HasErrors() {
+		return nil, diags.Diagnostics()
+	}
+
+	if configRoot.Settings != nil {
+		redactDiags := checkRedactPatterns(configRoot.Settings.Redact)
+		diags.Add(redactDiags)
+		if redactDiags.HasErrors() {
+			return nil, diags.Diagnostics()
+		}
+		result.Redact = configRoot.Settings.Redact
+	}
+
+	if configRoot.Settings != nil && configRoot.Settings.Naming != nil {
+		namingDiags := checkNaming(configRoot.Settings.Naming, included)
+		diags.Add(namingDiags)
+		if namingDiags.HasErrors() {
+			return nil, diags.Diagnostics()
+		}
+	}
+
+	cycleDiags := checkReferenceCycles(included)
+	diags.Add(cycleDiags)
+	if cycleDiags.HasErrors() {
+		return nil, diags.Diagnostics()
+	}
+
+	locals, localDiags := resolveLocals(configRoot.Locals, configRoot.Variables, evalContext)
+	diags.Add(localDiags)
+	if localDiags.HasErrors() {
+		return nil, diags.Diagnostics()
+	}
+	result.Locals = locals
+	evalContext.Variables["local"] = cty.ObjectVal(locals)
+
+	clusterContext := l.blockContext(BlockMeta{Type: "cluster", Labels: []string{configRoot.Cluster.Name}}, evalContext)
+	clusters, clusterDiags := expandCluster(configRoot.Cluster, configRoot.ClusterConfigs, clusterContext)
+	diags.Add(clusterDiags)
+	if clusterDiags.HasErrors() {
+		return nil, diags.Diagnostics()
+	}
+	for i := range clusters {
+		postDiags := postDecode(&clusters[i].Config, fmt.Sprintf("cluster %q", clusters[i].Name), evalContext, configRoot.Cluster.ClusterConfig.MissingItemRange())
+		diags.Add(postDiags)
+		if postDiags.HasErrors() {
+			return nil, diags.Diagnostics()
+		}
+	}
+	result.Clusters = clusters
+	overrideDiags := l.applyOverrides(result, true)
+	diags.Add(overrideDiags)
+	if overrideDiags.HasErrors() {
+		return nil, diags.Diagnostics()
+	}
+	evalContext.Variables["cluster"] = clusterObject(configRoot.Cluster, result.Clusters)
+
+	declaredKinds := map[string]bool{}
+	for _, componentConfig := range configRoot.Components {
+		kind := componentConfig.Type
+		if a, ok := canonicalKind(kind); ok {
+			kind = a.kind
+		}
+		declaredKinds[kind] = true
+	}
+
+	componentOutputs := map[string]cty.Value{}
+	nameRanges := map[int]hcl.Range{}
+	for _, componentConfig := range configRoot.Components {
+		aliasDiags := resolveAlias(&componentConfig)
+		meta, remain, metaDiags := DecodeMeta(componentConfig.Config, evalContext)
+		metaDiags = append(aliasDiags, metaDiags...)
+		diags.Add(metaDiags)
+		if metaDiags.HasErrors() {
+			return nil, diags.Diagnostics()
+		}
+		if !meta.Enabled {
+			continue
+		}
+		for _, kind := range meta.DependsOn {
+			if !declaredKinds[kind] {
+				diags.Add(hcl.Diagnostics{{
+					Severity: hcl.DiagError,
+					Summary:  "Reference to undeclared component",
+					Detail:   fmt.Sprintf("The component %q depends on the undeclared component %q.", componentConfig.Type, kind),
+					Subject:  remain.MissingItemRange().Ptr(),
+				}})
+				return nil, diags.Diagnostics()
+			}
+		}
+		componentConfig.Config = remain
+
+		count := 1
+		if meta.Count != nil {
+			count = *meta.Count
+		}
+		for index := 0; index < count; index++ {
+			if l.ctx != nil && l.ctx.Err() != nil {
+				diags.Add(hcl.Diagnostics{{
+					Severity: hcl.DiagError,
+					Summary:  "Load stopped",
+					Detail:   fmt.Sprintf("The load was stopped before the component %q: %s.", componentConfig.Type, l.ctx.Err()),
+					Subject:  componentConfig.Config.MissingItemRange().Ptr(),
+				}})
+				return nil, diags.Diagnostics()
+			}
+			ctx := evalContext
+			if meta.Count != nil {
+				ctx = countContext(evalContext, index)
+			}
+
+			var named Component
+			nameDiags := decodeComponentName(componentConfig, ctx, &named)
+			diags.Add(nameDiags)
+			if nameDiags.HasErrors() {
+				return nil, diags.Diagnostics()
+			}
+			ctx = l.blockContext(BlockMeta{
+				Type:   "component",
+				Labels: []string{componentConfig.Type},
+				Name:   named.Name,
+				Index:  index,
+			}, ctx)
+
+			timeouts, timeoutDiags := decodeTimeouts(componentConfig, ctx)
+			diags.Add(timeoutDiags)
+			if timeoutDiags.HasErrors() {
+				return nil, diags.Diagnostics()
+			}
+
+			address := componentConfig.Type
+			if meta.Count != nil {
+				address = fmt.Sprintf("%s[%d]", address, index)
+			}
+
+			var componentDiags hcl.Diagnostics
+			inTime := true
+			component, ok := newComponent(componentConfig.Type)
+			if ok {
+				inputDiags := checkInputTypes(address, component, componentOutputs, componentConfig.Config.MissingItemRange())
+				diags.Add(inputDiags)
+				if inputDiags.HasErrors() {
+					return nil, diags.Diagnostics()
+				}
+			}
+			switch {
+			case ok:
+				component, componentDiags, inTime = l.cache.decodeComponent(componentConfig, index, ctx, timeouts.Decode)
+			case l.unknownComponents:
+				var generic ComponentConfig
+				componentDiags, inTime = decodeWithin(timeouts.Decode, ctx, func(ctx *hcl.EvalContext) hcl.Diagnostics {
+					var genericDiags hcl.Diagnostics
+					generic, genericDiags = decodeGenericComponent(componentConfig, ctx)
+					return genericDiags
+				})
+				if inTime {
+					component = generic
+				}
+			default:
+				diags.Add(hcl.Diagnostics{{
+					Severity: hcl.DiagError,
+					Summary:  "Unknown component kind",
+					Detail:   fmt.Sprintf("There is no component kind %q.", componentConfig.Type),
+				}})
+				return nil, diags.Diagnostics()
+			}
+			if !inTime {
+				diags.Add(hcl.Diagnostics{timeoutExceeded(address, "decode", timeouts.Decode, componentConfig.Timeouts.Decode.Range().Ptr())})
+				return nil, diags.Diagnostics()
+			}
+			diags.Add(componentDiags)
+			if componentDiags.HasErrors() {
+				return nil, diags.Diagnostics()
+			}
+
+			instance := Component{
+				Type:      componentConfig.Type,
+				Name:      named.Name,
+				Index:     index,
+				Config:    component,
+				DependsOn: meta.DependsOn,
+				Priority:  meta.Priority,
+				Timeouts:  timeouts,
+			}
+			if ok {
+				l.ranges.record(componentConfig.Config, component)
+				instance.KnownAfterApply = knownAfterApply(componentConfig.Config, ctx, component)
+			}
+
+			policyDiags := decodeFailurePolicy(componentConfig, ctx, &instance)
+			if isSet(componentConfig.Name) {
+				nameRanges[len(result.Components)] = componentConfig.Name.Range()
+			}
+			policyDiags = append(policyDiags, decodeLifecycle(componentConfig, &instance)...)
+			metadata, metadataDiags := decodeMetadata(componentConfig, ctx)
+			instance.Metadata = metadata
+			policyDiags = append(policyDiags, metadataDiags...)
+			diags.Add(policyDiags)
+			if policyDiags.HasErrors() {
+				return nil, diags.Diagnostics()
+			}
+
+			postDiags := postDecode(component, fmt.Sprintf("component %q", address), evalContext, componentConfig.Config.MissingItemRange())
+			diags.Add(postDiags)
+			if postDiags.HasErrors() {
+				return nil, diags.Diagnostics()
+			}
+
+			if outputter, ok := component.(Outputter); ok {
+				instance.Outputs = outputter.Outputs()
+
+				outputDiags := checkOutputs(componentConfig.Type, instance.Outputs, l.capsuleTypes)
+				outputDiags = append(outputDiags, checkOutputTypes(address, component, instance.Outputs, componentConfig.Config.MissingItemRange())...)
+				diags.Add(outputDiags)
+				if outputDiags.HasErrors() {
+					return nil, diags.Diagnostics()
+				}
+
+				if _, exists := componentOutputs[componentConfig.Type]; exists {
+					diags.Add(hcl.Diagnostics{{
+						Severity: hcl.DiagError,
+						Summary:  "Ambiguous component outputs",
+						Detail:   fmt.Sprintf("More than one component of kind %q exports outputs.", componentConfig.Type),
+					}})
+					return nil, diags.Diagnostics()
+				}
+				componentOutputs[componentConfig.Type] = cty.ObjectVal(instance.Outputs)
+				evalContext.Variables["component"] = cty.ObjectVal(componentOutputs)
+			}
+
+			result.Components = append(result.Components, instance)
+		}
+	}
+
+	nameDiags := checkComponentNames(result.Components, nameRanges)
+	diags.Add(nameDiags)
+	if nameDiags.HasErrors() {
+		return nil, diags.Diagnostics()
+	}
+
+	moved, movedDiags := resolveMoves(configRoot.Moved, result.Components)
+	diags.Add(movedDiags)
+	if movedDiags.HasErrors() {
+		return nil, diags.Diagnostics()
+	}
+	result.Moved = moved
+
+	blocks, blockDiags := decodeRegisteredBlocks(registered, func(block *hcl.Block) *hcl.EvalContext {
+		return l.blockContext(BlockMeta{Type: block.Type, Labels: block.Labels}, evalContext)
+	})
+	diags.Add(blockDiags)
+	if blockDiags.HasErrors() {
+		return nil, diags.Diagnostics()
+	}
+	result.Blocks = blocks
+
+	overrideDiags = l.applyOverrides(result, false)
+	diags.Add(overrideDiags)
+	if overrideDiags.HasErrors() {
+		return nil, diags.Diagnostics()
+	}
+
+	if configRoot.Settings != nil && configRoot.Settings.Hooks != nil {
+		switch {
+		case l.denyingMode() != "":
+			diags.Add(deniedHooks(configRoot.Settings.Hooks, l.denyingMode()))
+		case !l.noHooks:
+			diags.Add(runPostDecodeHooks(configRoot.Settings.Hooks, l.rootDir, result))
+		}
+	}
+
+	return result, diags.Diagnostics()
+}
+
+// variablesRoot decodes just the variable blocks of a config body, and the
+// sources of their values.
+type variablesRoot struct {
+	Variables []variableBlock    `hcl:"variable,block"`
+	Sources   []valueSourceBlock `hcl:"variable_source,block"`
+	Remain    hcl.Body           `hcl:",remain"`
+}
+
+// resolveVariables returns the value of every declared variable, taking it
+// from the user values if present, from the variable sources next and from
+// its default otherwise. The names of the variables whose value came from a
+// source are returned as sensitive. If the ranges of the user values are
+// given, they are checked strictly first, see WithStrictValues.
+func resolveVariables(body hcl.Body, userVals map[string]cty.Value, strictRanges map[string]hcl.Range, sources map[string]ValueSource) (map[string]cty.Value, map[string]bool, hcl.Diagnostics) {
+	var root variablesRoot
+	diags := DecodeBody(body, nil, &root)
+	if diags.HasErrors() {
+		return nil, nil, diags
+	}
+
+	sourceVals, sourceDiags := fetchSourceValues(root.Sources, sources)
+	diags = append(diags, sourceDiags...)
+	if sourceDiags.HasErrors() {
+		return nil, nil, diags
+	}
+
+	if strictRanges != nil {
+		strictDiags := checkStrictValues(body, root.Variables, userVals, sourceVals, strictRanges)
+		diags = append(diags, strictDiags...)
+		if strictDiags.HasErrors() {
+			return nil, nil, diags
+		}
+	}
+
+	variables := map[string]cty.Value{}
+	sensitive := map[string]bool{}
+	for _, v := range root.Variables {
+		ty, typeRange := cty.DynamicPseudoType, hcl.Range{}
+		if typeAttr, ok := v.Default["type"]; ok {
+			var typeDiags hcl.Diagnostics
+			ty, typeDiags = typeConstraint(typeAttr.Expr)
+			diags = append(diags, typeDiags...)
+			if typeDiags.HasErrors() {
+				return nil, nil, diags
+			}
+			typeRange = typeAttr.Expr.Range()
+		}
+
+		renamedVal, renamed, renameDiags := renamedValue(v, userVals)
+		diags = append(diags, renameDiags...)
+		if renameDiags.HasErrors() {
+			return nil, nil, diags
+		}
+
+		val, valRange := cty.NilVal, typeRange
+		if userVal, ok := userVals[v.Name]; ok {
+			val = userVal
+		} else if renamed {
+			val = renamedVal
+		} else if sourceVal, ok := sourceVals[v.Name]; ok {
+			val = sourceVal
+			sensitive[v.Name] = true
+		} else if def, ok := v.Default["default"]; ok {
+			// Defaults are only evaluated when needed, since they can be
+			// large collections.
+			defaultVal, defaultDiags := def.Expr.Value(nil)
+			diags = append(diags, defaultDiags...)
+			if defaultDiags.HasErrors() {
+				return nil, nil, diags
+			}
+			val, valRange = defaultVal, def.Expr.Range()
+		} else {
+			continue
+		}
+
+		if ty != cty.DynamicPseudoType {
+			var convDiags hcl.Diagnostics
+			val, convDiags = convertVariable(v.Name, val, ty, valRange)
+			diags = append(diags, convDiags...)
+			if convDiags.HasErrors() {
+				return nil, nil, diags
+			}
+		}
+		nullDiags := checkNullable(v, val, valRange)
+		diags = append(diags, nullDiags...)
+		if nullDiags.HasErrors() {
+			return nil, nil, diags
+		}
+		variables[v.Name] = val
+	}
+
+	return variables, sensitive, diags
+}
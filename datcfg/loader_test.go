@@ -0,0 +1,150 @@
+package datcfg
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+const workersConfig = `
+variable "workers" {
+  default = 1
+}
+
+cluster "a" {
+  controller_count = 1
+  worker_count     = var.workers
+}
+`
+
+func TestLoadFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"cluster.datcfg":        {Data: []byte(workersConfig)},
+		"locals.datcfg":         {Data: []byte(`locals { zone = "a" }`)},
+		"nested/ignored.datcfg": {Data: []byte(`cluster "b" {}`)},
+		"README.md":             {Data: []byte("not a config file")},
+	}
+	result, diags := NewLoader(fsys).Load()
+	if diags.HasErrors() {
+		t.Fatal(diags)
+	}
+	if want := []string{"cluster.datcfg", "locals.datcfg"}; !reflect.DeepEqual(result.Files, want) {
+		t.Errorf("Files = %q, want only the config files in the root, %q", result.Files, want)
+	}
+	if got := result.Locals["zone"].AsString(); got != "a" {
+		t.Errorf("zone = %q, want the local from the second file", got)
+	}
+}
+
+func TestLoadFSSub(t *testing.T) {
+	// Like a directory of an embed.FS.
+	fsys, err := fs.Sub(fstest.MapFS{"config/cluster.datcfg": {Data: []byte(workersConfig)}}, "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, diags := NewLoader(fsys).Load()
+	if diags.HasErrors() {
+		t.Fatal(diags)
+	}
+	if want := []string{"cluster.datcfg"}; !reflect.DeepEqual(result.Files, want) {
+		t.Errorf("Files = %q, want %q", result.Files, want)
+	}
+}
+
+func TestLoadFSDiagnostics(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.datcfg": {Data: []byte(workersConfig)},
+		"b.datcfg": {Data: []byte("locals {\n  zone = \n}\n")},
+	}
+	_, diags := NewLoader(fsys).Load()
+	if !diags.HasErrors() {
+		t.Fatal("the invalid file was loaded")
+	}
+	if subject := diags[0].Subject; subject == nil || subject.Filename != "b.datcfg" || subject.Start.Line != 2 {
+		t.Errorf("got %v, want the error at line 2 of b.datcfg", diags)
+	}
+}
+
+func TestLoadFSValuesFiles(t *testing.T) {
+	fsys := fstest.MapFS{
+		"cluster.datcfg":         {Data: []byte(workersConfig)},
+		"dat.vars":               {Data: []byte(`workers = 2`)},
+		"dat.vars.json":          {Data: []byte(`{"workers": 3}`)},
+		"a.auto.dat.vars":        {Data: []byte(`workers = 4`)},
+		"b.auto.dat.vars.json":   {Data: []byte(`{"workers": 5}`)},
+		"nested/c.auto.dat.vars": {Data: []byte(`workers = 6`)},
+	}
+	paths, diags := ValuesFiles(fsys)
+	if diags.HasErrors() {
+		t.Fatal(diags)
+	}
+	want := []string{"dat.vars", "dat.vars.json", "a.auto.dat.vars", "b.auto.dat.vars.json"}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("values files = %q, want %q", paths, want)
+	}
+
+	result, diags := NewLoader(fsys).Load()
+	if diags.HasErrors() {
+		t.Fatal(diags)
+	}
+	if got := result.Clusters[0].Config.WorkerCount; got != 5 {
+		t.Errorf("worker_count = %d, want the value of the last values file", got)
+	}
+}
+
+// largeConfig returns a generated config with n locals, like those written
+// by config generators, which is over a megabyte for n above ten
+// thousand.
+func largeConfig(n int) string {
+	var b strings.Builder
+	b.WriteString(`
+cluster "large" {
+  controller_count = 3
+  worker_count     = 100
+}
+
+locals {
+`)
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "  subnet_%d = { cidr = \"10.%d.%d.0/24\", zone = \"zone-%d\", tags = [\"generated\", \"subnet\"] }\n", i, i/256%256, i%256, i%3)
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// BenchmarkLoadLargeConfig tracks the throughput of reading and parsing a
+// multi-megabyte config file, read onto the heap and memory-mapped. The
+// files are not evaluated, which costs what their contents do.
+func BenchmarkLoadLargeConfig(b *testing.B) {
+	dir := b.TempDir()
+	src := largeConfig(12000)
+	if len(src) < mmapThreshold {
+		b.Fatalf("the config is %d bytes, which is not memory-mapped", len(src))
+	}
+	if err := os.WriteFile(filepath.Join(dir, "large.datcfg"), []byte(src), 0644); err != nil {
+		b.Fatal(err)
+	}
+
+	for _, fsys := range []struct {
+		name string
+		fs   fs.FS
+	}{
+		{"read", os.DirFS(dir)},
+		{"mmap", MmapFS(dir)},
+	} {
+		b.Run(fsys.name, func(b *testing.B) {
+			b.SetBytes(int64(len(src)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, diags := ParseConfigFiles(fsys.fs); diags.HasErrors() {
+					b.Fatal(diags)
+				}
+			}
+		})
+	}
+}
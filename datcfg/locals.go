@@ -0,0 +1,117 @@
+package datcfg
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// localsBlock is a `locals` block, naming expressions for reuse as
+// `local.<name>`. A config can have any number of them.
+type localsBlock struct {
+	Values hcl.Attributes `hcl:"values,remain"`
+}
+
+// resolveLocals evaluates all local values in the given context, in an order
+// that satisfies their references to each other. Locals named like a
+// variable are rejected, since `local.x` and `var.x` side by side are easily
+// confused.
+func resolveLocals(blocks []localsBlock, variables []variableBlock, ctx *hcl.EvalContext) (map[string]cty.Value, hcl.Diagnostics) {
+	var diags hcl.Diagnostics
+
+	declared := map[string]bool{}
+	for _, v := range variables {
+		declared[v.Name] = true
+	}
+
+	pending := map[string]*hcl.Attribute{}
+	for _, block := range blocks {
+		for name, attr := range block.Values {
+			switch {
+			case pending[name] != nil:
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Duplicate local value",
+					Detail:   fmt.Sprintf("A local value named %q was already defined at %s.", name, pending[name].NameRange),
+					Subject:  attr.NameRange.Ptr(),
+				})
+			case declared[name]:
+				diags = append(diags, shadowedLocal(attr))
+			default:
+				pending[name] = attr
+			}
+		}
+	}
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	locals := map[string]cty.Value{}
+	for len(pending) > 0 {
+		progress := false
+		for _, name := range sortedAttrNames(pending) {
+			attr := pending[name]
+			if refersToAny(attr.Expr, "local", pending) {
+				continue
+			}
+
+			localCtx := ctx.NewChild()
+			localCtx.Variables = map[string]cty.Value{"local": cty.ObjectVal(locals)}
+			val, valDiags := attr.Expr.Value(localCtx)
+			diags = append(diags, valDiags...)
+			if valDiags.HasErrors() {
+				return nil, diags
+			}
+
+			locals[name] = val
+			delete(pending, name)
+			progress = true
+		}
+
+		if !progress {
+			first := pending[sortedAttrNames(pending)[0]]
+			return nil, append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Cycle in local values",
+				Detail:   fmt.Sprintf("The local values %v refer to each other, so none of them can be evaluated.", sortedAttrNames(pending)),
+				Subject:  first.NameRange.Ptr(),
+			})
+		}
+	}
+
+	return locals, diags
+}
+
+func shadowedLocal(attr *hcl.Attribute) *hcl.Diagnostic {
+	return &hcl.Diagnostic{
+		Severity: hcl.DiagError,
+		Summary:  "Local value shadows variable",
+		Detail:   fmt.Sprintf("The local value %q has the same name as a variable, rename one of them.", attr.Name),
+		Subject:  attr.NameRange.Ptr(),
+	}
+}
+
+// refersToAny reports whether the expression refers to any of the given
+// names below the given root, like `local.<name>`.
+func refersToAny(expr hcl.Expression, root string, names map[string]*hcl.Attribute) bool {
+	for _, traversal := range expr.Variables() {
+		if traversal.RootName() != root || len(traversal) < 2 {
+			continue
+		}
+		if attr, ok := traversal[1].(hcl.TraverseAttr); ok && names[attr.Name] != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedAttrNames(attrs map[string]*hcl.Attribute) []string {
+	names := make([]string, 0, len(attrs))
+	for name := range attrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
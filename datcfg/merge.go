@@ -0,0 +1,344 @@
+package datcfg
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl2/gohcl"
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hcl/hclsyntax"
+	"github.com/hashicorp/hcl2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Merged returns the configuration as the loader sees it before evaluating
+// it, as a single file in the canonical format. It has the blocks of the
+// config files that apply, per file and in order, without their applies_when
+// conditions. Components that extend another one have the attributes and
+// blocks they inherit, components have those of the defaults of their kind
+// in place of the defaults blocks, and the overrides given with WithOverride
+// replace the expressions of the attributes they set. Overrides of components that are
+// only addressed after evaluation, like those with `count`, are left out
+// with a warning. Comments are not kept.
+func (l *Loader) Merged() ([]byte, hcl.Diagnostics) {
+	result, included, includedBodies, diags := l.loadFiles()
+	if result == nil {
+		return nil, diags
+	}
+
+	var configRoot configRoot
+	rootDiags := DecodeBody(hcl.MergeBodies(includedBodies), nil, &configRoot)
+	diags = append(diags, rootDiags...)
+	if rootDiags.HasErrors() {
+		return nil, diags
+	}
+	// The component blocks are decoded in the order of the files, so they
+	// line up with topLevelBlocks.
+	bases, extendsDiags := resolveExtends(configRoot.Components)
+	diags = append(diags, extendsDiags...)
+	if extendsDiags.HasErrors() {
+		return nil, diags
+	}
+	defaultsDiags := applyDefaults(&configRoot)
+	diags = append(diags, defaultsDiags...)
+	if defaultsDiags.HasErrors() {
+		return nil, diags
+	}
+
+	m := &merger{srcs: map[string][]byte{}}
+	for _, file := range included {
+		filename := file.Body.MissingItemRange().Filename
+		// The parsed sources have namespaced calls rewritten, the original
+		// ones are printed.
+		src, err := fs.ReadFile(l.fsys, filename)
+		if err != nil {
+			return nil, append(diags, readFileDiags(filename, err)...)
+		}
+		m.srcs[filename] = src
+	}
+
+	var components []*hclsyntax.Block
+	defaults := map[string]*hclsyntax.Block{}
+	for _, block := range topLevelBlocks(included) {
+		switch block.Type {
+		case "component":
+			components = append(components, block)
+		case "defaults":
+			defaults[canonicalKindName(block.Labels[0])] = block
+		}
+	}
+	overrides, overrideDiags := mergedOverrides(l.overrides, components)
+	diags = append(diags, overrideDiags...)
+
+	var buf bytes.Buffer
+	componentIdx := 0
+	for _, file := range included {
+		body, ok := file.Body.(*hclsyntax.Body)
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(&buf, "# %s\n\n", file.Body.MissingItemRange().Filename)
+
+		var attrs []*hclsyntax.Attribute
+		for _, attr := range body.Attributes {
+			if attr.Name != "applies_when" {
+				attrs = append(attrs, attr)
+			}
+		}
+		m.writeAttributes(&buf, sortedBySource(attrs), nil)
+		if len(attrs) > 0 {
+			buf.WriteString("\n")
+		}
+
+		for _, block := range body.Blocks {
+			var blockOverrides map[string]string
+			attrs, blocks := sortedBySource(attributeList(block.Body)), block.Body.Blocks
+			switch block.Type {
+			case "cluster":
+				blockOverrides = overrides.cluster
+			case "component":
+				blockOverrides = overrides.components[componentIdx]
+				attrs, blocks = m.inheritedBody(components, bases, componentIdx)
+				if defaultsBlock, ok := defaults[canonicalKindName(block.Labels[0])]; ok {
+					attrs, blocks = withDefaults(attrs, blocks, defaultsBlock)
+				}
+				componentIdx++
+			case "defaults":
+				// The components have the defaults of their kind.
+				continue
+			}
+			m.writeBlock(&buf, block, attrs, blocks, blockOverrides)
+			buf.WriteString("\n")
+		}
+	}
+	return append(bytes.TrimRight(hclwrite.Format(buf.Bytes()), "\n"), '\n'), diags
+}
+
+// merger renders the blocks of the config files.
+type merger struct {
+	// srcs are the sources of the files, by their name.
+	srcs map[string][]byte
+}
+
+// source returns the source text of the given range.
+func (m *merger) source(rng hcl.Range) []byte {
+	return m.srcs[rng.Filename][rng.Start.Byte:rng.End.Byte]
+}
+
+// writeBlock renders a block with the given attributes and nested blocks.
+// The overrides are expressions by the path of the attribute they replace,
+// relative to the block.
+func (m *merger) writeBlock(buf *bytes.Buffer, block *hclsyntax.Block, attrs []*hclsyntax.Attribute, blocks []*hclsyntax.Block, overrides map[string]string) {
+	buf.WriteString(block.Type)
+	for _, label := range block.Labels {
+		buf.WriteString(" ")
+		buf.Write(valueTokens(cty.StringVal(label)))
+	}
+	buf.WriteString(" {\n")
+
+	m.writeAttributes(buf, attrs, overrides)
+	for _, nested := range blocks {
+		nestedOverrides := map[string]string{}
+		for path, expr := range overrides {
+			if rest := strings.TrimPrefix(path, nested.Type+"."); rest != path {
+				nestedOverrides[rest] = expr
+			}
+		}
+		m.writeBlock(buf, nested, sortedBySource(attributeList(nested.Body)), nested.Body.Blocks, nestedOverrides)
+	}
+	buf.WriteString("}\n")
+}
+
+// writeAttributes renders the given attributes, replacing the expressions of
+// those with an override and adding the overridden attributes not set.
+func (m *merger) writeAttributes(buf *bytes.Buffer, attrs []*hclsyntax.Attribute, overrides map[string]string) {
+	written := map[string]bool{}
+	for _, attr := range attrs {
+		expr, ok := overrides[attr.Name]
+		if !ok {
+			expr = string(m.source(attr.Expr.Range()))
+		}
+		fmt.Fprintf(buf, "%s = %s\n", attr.Name, expr)
+		written[attr.Name] = true
+	}
+
+	var added []string
+	for path := range overrides {
+		if !strings.Contains(path, ".") && !written[path] {
+			added = append(added, path)
+		}
+	}
+	sort.Strings(added)
+	for _, name := range added {
+		fmt.Fprintf(buf, "%s = %s\n", name, overrides[name])
+	}
+}
+
+// inheritedBody returns the attributes and nested blocks of the component
+// block with the given index, including those it inherits through extends.
+// The extends argument itself is left out.
+func (m *merger) inheritedBody(components []*hclsyntax.Block, bases []int, idx int) ([]*hclsyntax.Attribute, []*hclsyntax.Block) {
+	var attrs []*hclsyntax.Attribute
+	set := map[string]bool{}
+	for _, attr := range sortedBySource(attributeList(components[idx].Body)) {
+		if attr.Name != "extends" {
+			attrs = append(attrs, attr)
+			set[attr.Name] = true
+		}
+	}
+	blocks := components[idx].Body.Blocks
+	blockTypes := map[string]bool{}
+	for _, block := range blocks {
+		blockTypes[block.Type] = true
+	}
+	if bases[idx] < 0 {
+		return attrs, blocks
+	}
+
+	notInherited := map[string]bool{}
+	schema, _ := gohcl.ImpliedBodySchema(componentBlock{})
+	for _, attr := range append(schema.Attributes, metaSchema.Attributes...) {
+		notInherited[attr.Name] = true
+	}
+	for _, block := range schema.Blocks {
+		notInherited[block.Type] = true
+	}
+
+	baseAttrs, baseBlocks := m.inheritedBody(components, bases, bases[idx])
+	for _, attr := range baseAttrs {
+		if !set[attr.Name] && !notInherited[attr.Name] {
+			attrs = append(attrs, attr)
+		}
+	}
+	for _, block := range baseBlocks {
+		if !blockTypes[block.Type] && !notInherited[block.Type] {
+			blocks = append(blocks, block)
+		}
+	}
+	return attrs, blocks
+}
+
+// withDefaults adds the attributes and nested blocks of the given defaults
+// block to those of a component block that it doesn't set.
+func withDefaults(attrs []*hclsyntax.Attribute, blocks []*hclsyntax.Block, defaults *hclsyntax.Block) ([]*hclsyntax.Attribute, []*hclsyntax.Block) {
+	set := map[string]bool{}
+	for _, attr := range attrs {
+		set[attr.Name] = true
+	}
+	for _, block := range blocks {
+		set[block.Type] = true
+	}
+	for _, attr := range sortedBySource(attributeList(defaults.Body)) {
+		if !set[attr.Name] {
+			attrs = append(attrs, attr)
+		}
+	}
+	for _, block := range defaults.Body.Blocks {
+		if !set[block.Type] {
+			blocks = append(blocks, block)
+		}
+	}
+	return attrs, blocks
+}
+
+// staticOverrides are the overrides, as expressions by attribute path, of
+// the cluster blocks and of the component blocks by their index.
+type staticOverrides struct {
+	cluster    map[string]string
+	components map[int]map[string]string
+}
+
+// mergedOverrides resolves the given overrides to the blocks they apply to,
+// from the static names and kinds of the component blocks.
+func mergedOverrides(overrides []override, components []*hclsyntax.Block) (staticOverrides, hcl.Diagnostics) {
+	set := staticOverrides{cluster: map[string]string{}, components: map[int]map[string]string{}}
+	if len(overrides) == 0 {
+		return set, nil
+	}
+
+	// The address of every component block that has one before
+	// evaluation, like ComponentAddress.
+	addresses := map[string]int{}
+	unnamed := map[string][]int{}
+	counted := map[string]bool{}
+	for i, block := range components {
+		if attr, ok := block.Body.Attributes["name"]; ok {
+			if val, diags := attr.Expr.Value(nil); !diags.HasErrors() && val.IsKnown() && !val.IsNull() && val.Type() == cty.String {
+				addresses[val.AsString()] = i
+			}
+			continue
+		}
+		kind := canonicalKindName(block.Labels[0])
+		unnamed[kind] = append(unnamed[kind], i)
+		if _, ok := block.Body.Attributes["count"]; ok {
+			counted[kind] = true
+		}
+	}
+	for kind, indexes := range unnamed {
+		if counted[kind] {
+			continue
+		}
+		if len(indexes) == 1 {
+			addresses[kind] = indexes[0]
+			continue
+		}
+		for pos, i := range indexes {
+			addresses[fmt.Sprintf("%s[%d]", kind, pos)] = i
+		}
+	}
+
+	var diags hcl.Diagnostics
+	for _, o := range overrides {
+		parts := strings.Split(o.path, ".")
+		switch {
+		case parts[0] == "cluster" && len(parts) > 1:
+			set.cluster[strings.Join(parts[1:], ".")] = overrideExpression(o.value)
+		case parts[0] == "component" && len(parts) > 2:
+			i, ok := addresses[parts[1]]
+			if !ok {
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagWarning,
+					Summary:  "Override not shown",
+					Detail:   fmt.Sprintf("The override of %q is left out, since the component %q only has an address after evaluation.", o.path, parts[1]),
+				})
+				continue
+			}
+			if set.components[i] == nil {
+				set.components[i] = map[string]string{}
+			}
+			set.components[i][strings.Join(parts[2:], ".")] = overrideExpression(o.value)
+		default:
+			diags = append(diags, overrideError(o.path, "The path must be like cluster.<attribute> or component.<address>.<attribute>.")...)
+		}
+	}
+	return set, diags
+}
+
+// overrideExpression returns the native syntax of an override value. Values
+// that are not a constant expression, like `foo`, are strings.
+func overrideExpression(value string) string {
+	expr, diags := hclsyntax.ParseExpression([]byte(value), "", hcl.Pos{Line: 1, Column: 1})
+	if !diags.HasErrors() && len(expr.Variables()) == 0 {
+		return value
+	}
+	return string(valueTokens(cty.StringVal(value)))
+}
+
+func attributeList(body *hclsyntax.Body) []*hclsyntax.Attribute {
+	attrs := make([]*hclsyntax.Attribute, 0, len(body.Attributes))
+	for _, attr := range body.Attributes {
+		attrs = append(attrs, attr)
+	}
+	return attrs
+}
+
+// sortedBySource sorts attributes in the order they appear in their file.
+func sortedBySource(attrs []*hclsyntax.Attribute) []*hclsyntax.Attribute {
+	sort.Slice(attrs, func(i, j int) bool {
+		return attrs[i].SrcRange.Start.Byte < attrs[j].SrcRange.Start.Byte
+	})
+	return attrs
+}
@@ -0,0 +1,122 @@
+package datcfg
+
+import (
+	"reflect"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// MetaArguments are the meta-arguments every component block accepts in
+// addition to the attributes of its kind. Component config structs don't
+// need to declare them, and can't use their names for own attributes.
+type MetaArguments struct {
+	// Enabled is false if the component was switched off with
+	// `enabled = false`.
+	Enabled bool
+	// Count is the number of instances requested with `count`, or nil.
+	Count *int
+	// DependsOn are the component kinds listed in `depends_on`, as in
+	// `depends_on = [component.foo]`.
+	DependsOn []string
+	// Priority orders the components that don't depend on each other, see
+	// Component.Priority.
+	Priority int
+}
+
+var metaSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{
+		{Name: "enabled"},
+		{Name: "count"},
+		{Name: "depends_on"},
+		{Name: "priority"},
+	},
+}
+
+// DecodeMeta extracts the meta-arguments from the given component body and
+// returns them with the remaining body, which is decoded into the config of
+// the component.
+func DecodeMeta(body hcl.Body, ctx *hcl.EvalContext) (MetaArguments, hcl.Body, hcl.Diagnostics) {
+	meta := MetaArguments{Enabled: true}
+	content, remain, diags := body.PartialContent(metaSchema)
+	if diags.HasErrors() {
+		return meta, remain, diags
+	}
+
+	if attr, ok := content.Attributes["enabled"]; ok {
+		diags = append(diags, decodeExpression(attr.Expr, attr.Name, ctx, reflect.ValueOf(&meta.Enabled).Elem())...)
+	}
+
+	if attr, ok := content.Attributes["count"]; ok {
+		var count int
+		countDiags := decodeExpression(attr.Expr, attr.Name, ctx, reflect.ValueOf(&count).Elem())
+		diags = append(diags, countDiags...)
+		if !countDiags.HasErrors() && count < 0 {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid count value",
+				Detail:   "The count argument must not be negative.",
+				Subject:  attr.Expr.Range().Ptr(),
+			})
+		}
+		meta.Count = &count
+	}
+
+	if attr, ok := content.Attributes["depends_on"]; ok {
+		exprs, listDiags := hcl.ExprList(attr.Expr)
+		diags = append(diags, listDiags...)
+		for _, expr := range exprs {
+			kind, ok := componentReference(expr)
+			if !ok {
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Invalid depends_on entry",
+					Detail:   "The entries of depends_on must be references to components, like component.foo.",
+					Subject:  expr.Range().Ptr(),
+				})
+				continue
+			}
+			// Components are reported with their canonical kind, so
+			// aliases refer to them too.
+			if a, ok := canonicalKind(kind); ok {
+				kind = a.kind
+			}
+			meta.DependsOn = append(meta.DependsOn, kind)
+		}
+	}
+
+	if attr, ok := content.Attributes["priority"]; ok {
+		diags = append(diags, decodeExpression(attr.Expr, attr.Name, ctx, reflect.ValueOf(&meta.Priority).Elem())...)
+	}
+
+	return meta, remain, diags
+}
+
+// componentReference returns the kind a `component.<kind>` reference refers
+// to.
+func componentReference(expr hcl.Expression) (string, bool) {
+	traversal, diags := hcl.AbsTraversalForExpr(expr)
+	if diags.HasErrors() || len(traversal) != 2 || traversal.RootName() != "component" {
+		return "", false
+	}
+	attr, ok := traversal[1].(hcl.TraverseAttr)
+	if !ok {
+		return "", false
+	}
+	if a, ok := canonicalKind(attr.Name); ok {
+		return a.kind, true
+	}
+	return attr.Name, true
+}
+
+// countContext returns the context an instance of a counted component is
+// evaluated in, with `count.index` set.
+func countContext(ctx *hcl.EvalContext, index int) *hcl.EvalContext {
+	child := ctx.NewChild()
+	child.Variables = map[string]cty.Value{
+		"count": cty.ObjectVal(map[string]cty.Value{
+			"index": cty.NumberIntVal(int64(index)),
+		}),
+	}
+	return child
+}
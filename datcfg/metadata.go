@@ -0,0 +1,68 @@
+package datcfg
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/hashicorp/hcl2/hcl"
+)
+
+// metadataBlock is the `metadata` meta block accepted by every component,
+// attaching information for operators, like ownership, that the component
+// itself doesn't use:
+//
+//	metadata {
+//	  labels      = { team = "storage", cost_center = "cc-42" }
+//	  annotations = { runbook = "https://wiki.example.com/db" }
+//	}
+type metadataBlock struct {
+	Labels      hcl.Expression `hcl:"labels,optional"`
+	Annotations hcl.Expression `hcl:"annotations,optional"`
+}
+
+// ComponentMetadata is the content of the `metadata` block of a component.
+// Labels are short values to select components by, annotations are free
+// form.
+type ComponentMetadata struct {
+	Labels      map[string]string
+	Annotations map[string]string
+}
+
+// decodeMetadata evaluates the `metadata` meta block of a component block.
+func decodeMetadata(component componentBlock, ctx *hcl.EvalContext) (ComponentMetadata, hcl.Diagnostics) {
+	var metadata ComponentMetadata
+	if component.Metadata == nil {
+		return metadata, nil
+	}
+
+	var diags hcl.Diagnostics
+	for _, attr := range []struct {
+		name string
+		expr hcl.Expression
+		dst  *map[string]string
+	}{
+		{"labels", component.Metadata.Labels, &metadata.Labels},
+		{"annotations", component.Metadata.Annotations, &metadata.Annotations},
+	} {
+		if isSet(attr.expr) {
+			diags = append(diags, decodeExpression(attr.expr, attr.name, ctx, reflect.ValueOf(attr.dst).Elem())...)
+		}
+	}
+	return metadata, diags
+}
+
+type componentMetadataKey struct{}
+
+// WithComponentMetadata returns a context carrying the metadata of the
+// component it is passed to, see ContextMetadata. ApplyComponents and
+// CheckComponents do this for every component.
+func WithComponentMetadata(ctx context.Context, metadata ComponentMetadata) context.Context {
+	return context.WithValue(ctx, componentMetadataKey{}, metadata)
+}
+
+// ContextMetadata returns the metadata of the component being applied,
+// rolled back, planned or checked with the given context.
+func ContextMetadata(ctx context.Context) ComponentMetadata {
+	metadata, _ := ctx.Value(componentMetadataKey{}).(ComponentMetadata)
+	return metadata
+}
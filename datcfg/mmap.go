@@ -0,0 +1,46 @@
+package datcfg
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// mmapThreshold is the size from which MmapFS memory-maps files. Mapping
+// small files costs more than reading them.
+const mmapThreshold = 1 << 20
+
+// MmapFS returns a filesystem for the directory dir, like os.DirFS, which
+// memory-maps large files instead of reading them onto the heap. This saves
+// copying multi-megabyte generated config files, whose parse results refer
+// to their source anyway. Where files can't be mapped, they are read.
+//
+// Mapped files are never unmapped, since the parsed files refer to them, and
+// must not be truncated while in use. MmapFS is therefore meant for commands
+// that load the configuration once and exit, not for long-running processes.
+func MmapFS(dir string) fs.FS {
+	return mmapFS{FS: os.DirFS(dir), dir: dir}
+}
+
+type mmapFS struct {
+	fs.FS
+	dir string
+}
+
+func (m mmapFS) ReadFile(name string) ([]byte, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: fs.ErrInvalid}
+	}
+	f, err := os.Open(filepath.Join(m.dir, filepath.FromSlash(name)))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if info, err := f.Stat(); err == nil && info.Mode().IsRegular() && info.Size() >= mmapThreshold {
+		if data, err := mmapFile(f, info.Size()); err == nil {
+			return data, nil
+		}
+	}
+	return fs.ReadFile(m.FS, name)
+}
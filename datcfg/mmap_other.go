@@ -0,0 +1,13 @@
+//go:build !unix
+
+package datcfg
+
+import (
+	"errors"
+	"os"
+)
+
+// mmapFile is not supported on this platform, files are read instead.
+func mmapFile(f *os.File, size int64) ([]byte, error) {
+	return nil, errors.ErrUnsupported
+}
@@ -0,0 +1,14 @@
+//go:build unix
+
+package datcfg
+
+import (
+	"os"
+	"syscall"
+)
+
+// mmapFile maps the given file read-only. The mapping stays valid after the
+// file is closed.
+func mmapFile(f *os.File, size int64) ([]byte, error) {
+	return syscall.Mmap(int(f.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+}
@@ -0,0 +1,326 @@
+package datcfg
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hcl/hclsyntax"
+	"github.com/hashicorp/hcl2/hclparse"
+	"github.com/hashicorp/hcl2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// ModulesDir is the directory, relative to the config directory, the
+// modules are vendored into, one subdirectory per module.
+const ModulesDir = ".datmodules"
+
+// ModulesLockFile records the source and the hash of the files of every
+// vendored module, so that the loader notices when they drift from what was
+// installed.
+const ModulesLockFile = "modules.lock.hcl"
+
+// maxModuleSize limits the size of the unpacked files of a module.
+const maxModuleSize = 50 << 20
+
+// moduleBlock declares a module, config files maintained elsewhere that are
+// loaded together with those in the root:
+//
+//	module "network" {
+//	  source = "https://example.com/modules/network-1.2.tar.gz"
+//	}
+//
+// The module is not fetched while loading. `modules update` downloads the
+// archive into ModulesDir and records it in ModulesLockFile, and the loader
+// only reads the vendored copy, after checking it against the lock file.
+type moduleBlock struct {
+	Name   string `hcl:"name,label"`
+	Source string `hcl:"source,attr"`
+}
+
+var moduleBlockSchema = &hcl.BodySchema{
+	Blocks: []hcl.BlockHeaderSchema{{Type: "module", LabelNames: []string{"name"}}},
+}
+
+// Module is a module declared in the config files.
+type Module struct {
+	Name string
+	// Source is the URL of the .tar.gz archive holding the files of the
+	// module.
+	Source    string
+	DeclRange hcl.Range
+}
+
+// ModuleLock is the entry of a module in the lock file.
+type ModuleLock struct {
+	Name   string `hcl:"name,label"`
+	Source string `hcl:"source,attr"`
+	// Hash is the hash of the vendored files, see HashModule.
+	Hash string `hcl:"hash,attr"`
+}
+
+// DeclaredModules returns the modules declared in the given files, sorted by
+// name. The sources must be literal strings, since the modules are
+// resolved before anything is evaluated.
+func DeclaredModules(files []*hcl.File) ([]Module, hcl.Diagnostics) {
+	var modules []Module
+	var diags hcl.Diagnostics
+	declared := map[string]hcl.Range{}
+	for _, file := range files {
+		content, _, _ := file.Body.PartialContent(moduleBlockSchema)
+		for _, block := range content.Blocks {
+			name := block.Labels[0]
+			if !hclsyntax.ValidIdentifier(name) {
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Invalid module name",
+					Detail:   fmt.Sprintf("The module name %q must be a valid identifier, since it names the directory the module is vendored into.", name),
+					Subject:  block.LabelRanges[0].Ptr(),
+				})
+				continue
+			}
+			if prev, ok := declared[name]; ok {
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Duplicate module",
+					Detail:   fmt.Sprintf("The module %q was already declared at %s.", name, prev),
+					Subject:  block.DefRange.Ptr(),
+				})
+				continue
+			}
+			declared[name] = block.DefRange
+
+			var module moduleBlock
+			moduleDiags := DecodeBody(block.Body, nil, &module)
+			diags = append(diags, moduleDiags...)
+			if moduleDiags.HasErrors() {
+				continue
+			}
+			if !strings.HasPrefix(module.Source, "https://") && !strings.HasPrefix(module.Source, "http://") {
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Unsupported module source",
+					Detail:   fmt.Sprintf("The source of module %q must be the http:// or https:// URL of a .tar.gz archive, not %q.", name, module.Source),
+					Subject:  block.DefRange.Ptr(),
+				})
+				continue
+			}
+			modules = append(modules, Module{Name: name, Source: module.Source, DeclRange: block.DefRange})
+		}
+	}
+	sort.Slice(modules, func(i, j int) bool { return modules[i].Name < modules[j].Name })
+	return modules, diags
+}
+
+// LoadModulesLock reads the lock file in the root of fsys, returning its
+// entries by module name. Without a lock file, there are none.
+func LoadModulesLock(fsys fs.FS) (map[string]ModuleLock, hcl.Diagnostics) {
+	locks := map[string]ModuleLock{}
+	if _, err := fs.Stat(fsys, ModulesLockFile); errors.Is(err, fs.ErrNotExist) {
+		return locks, nil
+	}
+
+	file, diags := parseHCLFile(hclparse.NewParser(), fsys, ModulesLockFile)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	var lockFile struct {
+		Modules []ModuleLock `hcl:"module,block"`
+	}
+	diags = append(diags, DecodeBody(file.Body, nil, &lockFile)...)
+	for _, lock := range lockFile.Modules {
+		locks[lock.Name] = lock
+	}
+	return locks, diags
+}
+
+// FormatModulesLock renders the lock file with the given entries, sorted by
+// module name.
+func FormatModulesLock(locks []ModuleLock) []byte {
+	locks = append([]ModuleLock(nil), locks...)
+	sort.Slice(locks, func(i, j int) bool { return locks[i].Name < locks[j].Name })
+
+	file := hclwrite.NewEmptyFile()
+	body := file.Body()
+	body.AppendUnstructuredTokens(hclwrite.Tokens{{
+		Type:  hclsyntax.TokenComment,
+		Bytes: []byte("# This file is maintained by `dat-config modules update`.\n"),
+	}})
+	for _, lock := range locks {
+		body.AppendNewline()
+		block := body.AppendNewBlock("module", []string{lock.Name})
+		block.Body().SetAttributeValue("source", cty.StringVal(lock.Source))
+		block.Body().SetAttributeValue("hash", cty.StringVal(lock.Hash))
+	}
+	return hclwrite.Format(file.Bytes())
+}
+
+// HashModule returns the hash of the files of the named module vendored in
+// fsys, over their paths and contents.
+func HashModule(fsys fs.FS, name string) (string, error) {
+	dir := path.Join(ModulesDir, name)
+	h := sha256.New()
+	err := fs.WalkDir(fsys, dir, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.Type().IsRegular() {
+			return err
+		}
+		src, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00", strings.TrimPrefix(name, dir+"/"), len(src))
+		h.Write(src)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// VerifyModules checks that every module is recorded in the lock file with
+// its current source, and that its vendored files still have the recorded
+// hash.
+func VerifyModules(fsys fs.FS, modules []Module) hcl.Diagnostics {
+	locks, diags := LoadModulesLock(fsys)
+	if diags.HasErrors() {
+		return diags
+	}
+
+	for _, module := range modules {
+		lock, ok := locks[module.Name]
+		switch {
+		case !ok:
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Module not installed",
+				Detail:   fmt.Sprintf("The module %q is not recorded in %s, run \"modules update\" to install it.", module.Name, ModulesLockFile),
+				Subject:  module.DeclRange.Ptr(),
+			})
+			continue
+		case lock.Source != module.Source:
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Module source changed",
+				Detail:   fmt.Sprintf("The module %q was installed from %q, run \"modules update\" to install it from %q.", module.Name, lock.Source, module.Source),
+				Subject:  module.DeclRange.Ptr(),
+			})
+			continue
+		}
+
+		hash, err := HashModule(fsys, module.Name)
+		if err != nil {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Failed to read module",
+				Detail:   fmt.Sprintf("The files of module %q could not be read: %s. Run \"modules update\" to install it again.", module.Name, err),
+				Subject:  module.DeclRange.Ptr(),
+			})
+		} else if hash != lock.Hash {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Module changed",
+				Detail:   fmt.Sprintf("The files of module %q in %s don't have the hash recorded in %s, they changed since it was installed. Run \"modules update\" to install it again.", module.Name, path.Join(ModulesDir, module.Name), ModulesLockFile),
+				Subject:  module.DeclRange.Ptr(),
+			})
+		}
+	}
+	return diags
+}
+
+// FetchModule downloads the .tar.gz archive at source, returning the
+// regular files in it by their slash-separated path. The client is
+// http.DefaultClient if nil.
+func FetchModule(ctx context.Context, client *http.Client, source string) (map[string][]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", source, nil)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("downloading %s returned %s", source, resp.Status)
+	}
+	return unpackModule(resp.Body)
+}
+
+// unpackModule reads the regular files of a .tar.gz archive, rejecting
+// paths outside of it.
+func unpackModule(r io.Reader) (map[string][]byte, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	files := map[string][]byte{}
+	var size int64
+	archive := tar.NewReader(gz)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := strings.TrimPrefix(header.Name, "./")
+		if !fs.ValidPath(name) {
+			return nil, fmt.Errorf("the archive has a file outside of it, %q", header.Name)
+		}
+		if size += header.Size; size > maxModuleSize {
+			return nil, fmt.Errorf("the files in the archive are larger than %d bytes", maxModuleSize)
+		}
+		if files[name], err = io.ReadAll(archive); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// loadModules verifies the modules declared in the given files and parses
+// the config files of their vendored copies.
+func (l *Loader) loadModules(files []*hcl.File) ([]*hcl.File, hcl.Diagnostics) {
+	modules, diags := DeclaredModules(files)
+	if len(modules) == 0 || diags.HasErrors() {
+		return nil, diags
+	}
+	diags = append(diags, VerifyModules(l.fsys, modules)...)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	var moduleFiles []*hcl.File
+	for _, module := range modules {
+		parsed, parseDiags := parseConfigGlob(l.fsys, path.Join(ModulesDir, module.Name, "*.datcfg"), l.cache)
+		diags = append(diags, parseDiags...)
+		moduleFiles = append(moduleFiles, parsed...)
+	}
+	nested, _ := DeclaredModules(moduleFiles)
+	for _, module := range nested {
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Nested module",
+			Detail:   fmt.Sprintf("Modules can only be declared in the config files of the root, not in those of other modules like %q.", module.Name),
+			Subject:  module.DeclRange.Ptr(),
+		})
+	}
+	return moduleFiles, diags
+}
@@ -0,0 +1,155 @@
+package datcfg
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+const moduleRoot = `
+cluster "a" {
+  controller_count = 1
+  worker_count     = 2
+}
+
+module "network" {
+  source = "https://example.com/network.tar.gz"
+}
+`
+
+// moduleFS returns a config declaring the network module, vendored with the
+// given files and recorded in the lock file with the hash of the files.
+func moduleFS(t *testing.T, files map[string]string) fstest.MapFS {
+	fsys := fstest.MapFS{"cluster.datcfg": {Data: []byte(moduleRoot)}}
+	for name, src := range files {
+		fsys[ModulesDir+"/network/"+name] = &fstest.MapFile{Data: []byte(src)}
+	}
+	hash, err := HashModule(fsys, "network")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsys[ModulesLockFile] = &fstest.MapFile{Data: FormatModulesLock([]ModuleLock{
+		{Name: "network", Source: "https://example.com/network.tar.gz", Hash: hash},
+	})}
+	return fsys
+}
+
+func TestLoadModules(t *testing.T) {
+	fsys := moduleFS(t, map[string]string{
+		"network.datcfg": `variable "subnet" { default = "10.0.0.0/16" }`,
+	})
+	result, diags := NewLoader(fsys).Load()
+	if diags.HasErrors() {
+		t.Fatal(diags)
+	}
+	if got := result.Variables["subnet"].AsString(); got != "10.0.0.0/16" {
+		t.Errorf("subnet = %q, want the default of the module", got)
+	}
+	if !reflect.DeepEqual(result.Files, []string{"cluster.datcfg", ".datmodules/network/network.datcfg"}) {
+		t.Errorf("Files = %q", result.Files)
+	}
+}
+
+func TestLoadModulesChanged(t *testing.T) {
+	fsys := moduleFS(t, map[string]string{
+		"network.datcfg": `variable "subnet" { default = "10.0.0.0/16" }`,
+	})
+	fsys[ModulesDir+"/network/network.datcfg"].Data = []byte(`variable "subnet" { default = "0.0.0.0/0" }`)
+
+	_, diags := NewLoader(fsys).Load()
+	if !diags.HasErrors() || diags[0].Summary != "Module changed" {
+		t.Fatalf("got %v, want a changed module", diags)
+	}
+}
+
+func TestLoadModulesNotInstalled(t *testing.T) {
+	fsys := fstest.MapFS{"cluster.datcfg": {Data: []byte(moduleRoot)}}
+	_, diags := NewLoader(fsys).Load()
+	if !diags.HasErrors() || diags[0].Summary != "Module not installed" {
+		t.Fatalf("got %v, want a module that is not installed", diags)
+	}
+}
+
+func TestLoadModulesSourceChanged(t *testing.T) {
+	fsys := moduleFS(t, map[string]string{"network.datcfg": ``})
+	fsys["cluster.datcfg"].Data = []byte(strings.Replace(moduleRoot, "network.tar.gz", "network-2.tar.gz", 1))
+
+	_, diags := NewLoader(fsys).Load()
+	if !diags.HasErrors() || diags[0].Summary != "Module source changed" {
+		t.Fatalf("got %v, want a changed source", diags)
+	}
+}
+
+func TestModulesLockRoundTrip(t *testing.T) {
+	locks := []ModuleLock{
+		{Name: "storage", Source: "https://example.com/storage.tar.gz", Hash: "sha256:02"},
+		{Name: "network", Source: "https://example.com/network.tar.gz", Hash: "sha256:01"},
+	}
+	fsys := fstest.MapFS{ModulesLockFile: {Data: FormatModulesLock(locks)}}
+	got, diags := LoadModulesLock(fsys)
+	if diags.HasErrors() {
+		t.Fatal(diags)
+	}
+	want := map[string]ModuleLock{"network": locks[1], "storage": locks[0]}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestFetchModule(t *testing.T) {
+	archive := tarGz(t, map[string]string{"network.datcfg": `variable "subnet" {}`, "./docs/README": "network"})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(archive)
+	}))
+	defer server.Close()
+
+	files, err := FetchModule(context.Background(), server.Client(), server.URL+"/network.tar.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]byte{"network.datcfg": []byte(`variable "subnet" {}`), "docs/README": []byte("network")}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("got %q, want %q", files, want)
+	}
+}
+
+func TestFetchModuleOutside(t *testing.T) {
+	archive := tarGz(t, map[string]string{"../cluster.datcfg": `cluster "evil" {}`})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(archive)
+	}))
+	defer server.Close()
+
+	if _, err := FetchModule(context.Background(), server.Client(), server.URL); err == nil {
+		t.Fatal("a file outside of the archive was accepted")
+	}
+}
+
+func tarGz(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	archive := tar.NewWriter(gz)
+	for name, src := range files {
+		err := archive.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(src)), Typeflag: tar.TypeReg})
+		if err == nil {
+			_, err = archive.Write([]byte(src))
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
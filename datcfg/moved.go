@@ -0,0 +1,162 @@
+package datcfg
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// movedBlock records that a component was renamed, so that what is stored
+// under its former address, like the apply state, carries over:
+//
+//	moved {
+//	  from = component.foo.old
+//	  to   = component.foo.new
+//	}
+//
+// Components are given as component.<kind>.<name> for named components, and
+// as component.<kind> or component.<kind>[<index>] otherwise.
+type movedBlock struct {
+	From hcl.Expression `hcl:"from"`
+	To   hcl.Expression `hcl:"to"`
+}
+
+// CurrentAddress returns the address a component has now, given the address
+// it had before being moved with a `moved` block. Other addresses are
+// returned as is.
+func (c *Config) CurrentAddress(address string) string {
+	if to, ok := c.Moved[address]; ok {
+		return to
+	}
+	return address
+}
+
+// resolveMoves returns the current address of every former component address
+// given in the moved blocks. Chains of moves are followed to their end.
+func resolveMoves(blocks []movedBlock, components []Component) (map[string]string, hcl.Diagnostics) {
+	var diags hcl.Diagnostics
+	moves := map[string]string{}
+	ranges := map[string]hcl.Range{}
+	for _, block := range blocks {
+		fromKind, from, fromDiags := movedAddress(block.From)
+		toKind, to, toDiags := movedAddress(block.To)
+		diags = append(diags, fromDiags...)
+		diags = append(diags, toDiags...)
+		if fromDiags.HasErrors() || toDiags.HasErrors() {
+			continue
+		}
+
+		switch prev, exists := ranges[from]; {
+		case fromKind != toKind:
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid move",
+				Detail:   fmt.Sprintf("The component %q can't be moved to a component of kind %q.", from, toKind),
+				Subject:  block.To.Range().Ptr(),
+			})
+		case exists:
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Ambiguous move",
+				Detail:   fmt.Sprintf("The component %q was already moved at %s.", from, prev),
+				Subject:  block.From.Range().Ptr(),
+			})
+		default:
+			moves[from] = to
+			ranges[from] = block.From.Range()
+		}
+	}
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	declared := map[string]bool{}
+	for idx := range components {
+		declared[ComponentAddress(components, idx)] = true
+	}
+
+	froms := make([]string, 0, len(moves))
+	for from := range moves {
+		froms = append(froms, from)
+	}
+	sort.Strings(froms)
+
+	resolved := map[string]string{}
+	for _, from := range froms {
+		to, seen := moves[from], map[string]bool{from: true}
+		for !seen[to] {
+			next, ok := moves[to]
+			if !ok {
+				break
+			}
+			seen[to] = true
+			to = next
+		}
+
+		switch {
+		case seen[to]:
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Cyclic move",
+				Detail:   fmt.Sprintf("The moves of the component %q lead back to an address it was moved from.", from),
+				Subject:  ranges[from].Ptr(),
+			})
+		case declared[from] && declared[to]:
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Moved component still exists",
+				Detail:   fmt.Sprintf("The component %q was moved to %q, but both are declared. Remove one of them, or the moved block.", from, to),
+				Subject:  ranges[from].Ptr(),
+			})
+		case declared[from]:
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagWarning,
+				Summary:  "Move not done",
+				Detail:   fmt.Sprintf("The component %q is still declared under the address it was moved from, so the move to %q has no effect.", from, to),
+				Subject:  ranges[from].Ptr(),
+			})
+		default:
+			resolved[from] = to
+		}
+	}
+	return resolved, diags
+}
+
+// movedAddress returns the kind and the address of the component a `from` or
+// `to` expression refers to.
+func movedAddress(expr hcl.Expression) (string, string, hcl.Diagnostics) {
+	invalid := hcl.Diagnostics{
+		{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid component address",
+			Detail:   "A moved component must be given like component.<kind>.<name>, component.<kind>[<index>] or component.<kind>.",
+			Subject:  expr.Range().Ptr(),
+		},
+	}
+	traversal, diags := hcl.AbsTraversalForExpr(expr)
+	if diags.HasErrors() || len(traversal) < 2 || len(traversal) > 3 || traversal.RootName() != "component" {
+		return "", "", invalid
+	}
+	attr, ok := traversal[1].(hcl.TraverseAttr)
+	if !ok {
+		return "", "", invalid
+	}
+	kind := canonicalKindName(attr.Name)
+	if len(traversal) == 2 {
+		return kind, kind, nil
+	}
+
+	switch step := traversal[2].(type) {
+	case hcl.TraverseAttr:
+		return kind, step.Name, nil
+	case hcl.TraverseIndex:
+		if step.Key.Type() == cty.Number {
+			if index, accuracy := step.Key.AsBigFloat().Int64(); accuracy == 0 && index >= 0 {
+				return kind, fmt.Sprintf("%s[%d]", kind, index), nil
+			}
+		}
+	}
+	return "", "", invalid
+}
@@ -0,0 +1,124 @@
+package datcfg
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+
+	"github.com/hashicorp/hcl2/hcl"
+)
+
+// NamingSettings is a naming policy applied to cluster names, component
+// labels and variable names.
+type NamingSettings struct {
+	Pattern   *string  `hcl:"pattern,optional"`
+	MaxLength *int     `hcl:"max_length,optional"`
+	Reserved  []string `hcl:"reserved,optional"`
+}
+
+// namedBlockTypes are the top-level block types whose first label is a name
+// subject to the naming policy.
+var namedBlockTypes = map[string]string{
+	"cluster":   "cluster name",
+	"component": "component label",
+	"variable":  "variable name",
+}
+
+// checkNaming validates the labels of all named top-level blocks in the given
+// files against the policy.
+func checkNaming(policy *NamingSettings, files []*hcl.File) hcl.Diagnostics {
+	var pattern *regexp.Regexp
+	if policy.Pattern != nil {
+		var err error
+		pattern, err = regexp.Compile(*policy.Pattern)
+		if err != nil {
+			return hcl.Diagnostics{
+				{
+					Severity: hcl.DiagError,
+					Summary:  "Invalid naming pattern",
+					Detail:   fmt.Sprintf("The naming pattern %q is not a valid regular expression: %s.", *policy.Pattern, err),
+				},
+			}
+		}
+	}
+
+	reserved := map[string]bool{}
+	for _, word := range policy.Reserved {
+		reserved[word] = true
+	}
+
+	var diags hcl.Diagnostics
+	for _, block := range topLevelBlocks(files) {
+		what, ok := namedBlockTypes[block.Type]
+		if !ok || len(block.Labels) == 0 {
+			continue
+		}
+		name, rng := block.Labels[0], block.LabelRanges[0]
+
+		var problem string
+		switch {
+		case reserved[name]:
+			problem = fmt.Sprintf("%q is a reserved word", name)
+		case policy.MaxLength != nil && len(name) > *policy.MaxLength:
+			problem = fmt.Sprintf("%q is longer than %d characters", name, *policy.MaxLength)
+		case pattern != nil && !pattern.MatchString(name):
+			problem = fmt.Sprintf("%q does not match the pattern %q", name, pattern.String())
+		default:
+			continue
+		}
+
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid " + what,
+			Detail:   fmt.Sprintf("The %s violates the naming policy: %s.", what, problem),
+			Subject:  rng.Ptr(),
+		})
+	}
+	return diags
+}
+
+// decodeComponentName evaluates the optional `name` meta-attribute of a
+// component block, which gives the instance a name computed from an
+// expression, like `name = "${var.prefix}-db"`, instead of its kind.
+func decodeComponentName(component componentBlock, ctx *hcl.EvalContext, instance *Component) hcl.Diagnostics {
+	if !isSet(component.Name) {
+		return nil
+	}
+
+	diags := decodeExpression(component.Name, "name", ctx, reflect.ValueOf(&instance.Name).Elem())
+	if !diags.HasErrors() && instance.Name == "" {
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid component name",
+			Detail:   "The name of a component must be a known, non-empty string.",
+			Subject:  component.Name.Range().Ptr(),
+		})
+	}
+	return diags
+}
+
+// checkComponentNames validates that the addresses of all components are
+// unique once their names are evaluated. The ranges are those of the name
+// expressions, by component index.
+func checkComponentNames(components []Component, ranges map[int]hcl.Range) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+	seen := map[string]bool{}
+	for idx := range components {
+		address := ComponentAddress(components, idx)
+		if !seen[address] {
+			seen[address] = true
+			continue
+		}
+
+		diag := &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Duplicate component name",
+			Detail:   fmt.Sprintf("The name %q is used by more than one component.", address),
+		}
+		if rng, ok := ranges[idx]; ok {
+			diag.Subject = rng.Ptr()
+		}
+		diags = append(diags, diag)
+	}
+	return diags
+}
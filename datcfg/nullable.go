@@ -0,0 +1,41 @@
+package datcfg
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// checkNullable reports a null value for a variable declared with
+// `nullable = false`:
+//
+//	variable "worker_count" {
+//	  nullable = false
+//	}
+//
+// Variables are nullable by default. The subject is the range of the value
+// if known, like its default, and the nullable argument otherwise.
+func checkNullable(v variableBlock, val cty.Value, valRange hcl.Range) hcl.Diagnostics {
+	attr, ok := v.Default["nullable"]
+	if !ok {
+		return nil
+	}
+	nullable := true
+	diags := decodeExpression(attr.Expr, attr.Name, nil, reflect.ValueOf(&nullable).Elem())
+	if diags.HasErrors() || nullable || !val.IsNull() {
+		return diags
+	}
+
+	subject := valRange
+	if subject == (hcl.Range{}) {
+		subject = attr.Expr.Range()
+	}
+	return append(diags, &hcl.Diagnostic{
+		Severity: hcl.DiagError,
+		Summary:  "Null value for non-nullable variable",
+		Detail:   fmt.Sprintf("The variable %q is declared with nullable = false, so it must not be set to null.", v.Name),
+		Subject:  subject.Ptr(),
+	})
+}
@@ -0,0 +1,56 @@
+package datcfg
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+)
+
+// percentFunc returns the given percentage of a total, like `percent(5,
+// var.node_count)`. The result is exact and may have a fraction; round it
+// with ceil or floor where a whole number is expected.
+var percentFunc = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{Name: "percentage", Type: cty.Number},
+		{Name: "total", Type: cty.Number},
+	},
+	Type: function.StaticReturnType(cty.Number),
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		result := new(big.Float).Mul(args[0].AsBigFloat(), args[1].AsBigFloat())
+		return cty.NumberVal(result.Quo(result, big.NewFloat(100))), nil
+	},
+})
+
+// ceilFunc rounds a number up to the nearest whole number.
+var ceilFunc = roundingFunc(func(n *big.Float) bool { return n.Sign() > 0 })
+
+// floorFunc rounds a number down to the nearest whole number.
+var floorFunc = roundingFunc(func(n *big.Float) bool { return n.Sign() < 0 })
+
+// roundingFunc returns a function rounding a number to a whole one. Numbers
+// with a fraction are truncated, and then moved away from zero by one if
+// away tells so for the number.
+func roundingFunc(away func(n *big.Float) bool) function.Function {
+	return function.New(&function.Spec{
+		Params: []function.Parameter{
+			{Name: "num", Type: cty.Number},
+		},
+		Type: function.StaticReturnType(cty.Number),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			n := args[0].AsBigFloat()
+			if n.IsInf() {
+				return cty.UnknownVal(cty.Number), function.NewArgError(0, fmt.Errorf("cannot round an infinite number"))
+			}
+			if n.IsInt() {
+				return args[0], nil
+			}
+			whole, _ := n.Int(nil)
+			if away(n) {
+				whole.Add(whole, big.NewInt(int64(n.Sign())))
+			}
+			return cty.NumberVal(new(big.Float).SetInt(whole)), nil
+		},
+	})
+}
@@ -0,0 +1,87 @@
+package datcfg
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hcl/hclsyntax"
+)
+
+// 2^53 + 1 is the smallest positive integer a float64 can't represent.
+const beyondFloat = "9007199254740993"
+
+type idConfig struct {
+	ID int64 `hcl:"id"`
+}
+
+func init() {
+	MustRegisterComponent("test_id", &idConfig{})
+}
+
+func TestDecodeBigIntegers(t *testing.T) {
+	file, diags := hclsyntax.ParseConfig([]byte(`
+id  = 9007199254740993
+max = 18446744073709551615
+min = -9223372036854775808
+`), "ids.datcfg", hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		t.Fatal(diags)
+	}
+	var ids struct {
+		ID  int64  `hcl:"id"`
+		Max uint64 `hcl:"max"`
+		Min int64  `hcl:"min"`
+	}
+	if diags := DecodeBody(file.Body, nil, &ids); diags.HasErrors() {
+		t.Fatal(diags)
+	}
+	if ids.ID != 9007199254740993 || ids.Max != 18446744073709551615 || ids.Min != -9223372036854775808 {
+		t.Errorf("got %+v, want the exact integers", ids)
+	}
+}
+
+const idsConfig = `
+variable "id" {
+  default = ` + beyondFloat + `
+}
+
+cluster "a" {
+  controller_count = 1
+  worker_count     = 1
+}
+
+component "test_id" {
+  id = var.id
+}
+`
+
+func TestBigIntegersOutput(t *testing.T) {
+	result, diags := NewLoader(fstest.MapFS{"cluster.datcfg": {Data: []byte(idsConfig)}}).Load()
+	if diags.HasErrors() {
+		t.Fatal(diags)
+	}
+	if got := result.Components[0].Config.(*idConfig).ID; got != 9007199254740993 {
+		t.Errorf("id = %d, want %s", got, beyondFloat)
+	}
+
+	// Like `get`, which queries the config and formats the value.
+	for _, path := range []string{`var.id`, `components["test_id"].config.id`} {
+		val, diags := result.Query(path)
+		if diags.HasErrors() {
+			t.Fatal(diags)
+		}
+		if got := FormatJSONValue(val); got != beyondFloat {
+			t.Errorf("%s = %s, want %s", path, got, beyondFloat)
+		}
+	}
+
+	rendered, err := RenderJSON(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(rendered), `"id": `+beyondFloat) {
+		t.Errorf("the JSON output has no exact id:\n%s", rendered)
+	}
+}
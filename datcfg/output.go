@@ -0,0 +1,278 @@
+package datcfg
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Config is the fully evaluated configuration.
+type Config struct {
+	// Files are the names of the parsed config files.
+	Files []string
+	// Values are the values read from the values files.
+	Values map[string]cty.Value
+	// Variables are the final values of all declared variables.
+	Variables map[string]cty.Value
+	// Sensitive are the names of the variables whose values must not be
+	// shown, like those fetched from a variable source. Values derived from
+	// them are not tracked.
+	Sensitive map[string]bool
+	// Locals are the values of all local values.
+	Locals map[string]cty.Value
+	// EvalContext is the context the cluster and components were
+	// evaluated in.
+	EvalContext *hcl.EvalContext
+
+	Clusters   []Cluster
+	Components []Component
+	// Blocks are the decoded top-level blocks of the types registered with
+	// RegisterBlockType, by type, in declaration order. Each is a pointer to
+	// a new value of the registered type.
+	Blocks map[string][]interface{}
+	// Moved are the current addresses of the components moved with `moved`
+	// blocks, by their former address.
+	Moved map[string]string
+	// Overrides are the values set with WithOverride, by path, for the
+	// attributes where they replaced the value from the files.
+	Overrides map[string]string
+	// Redact are the glob patterns of `settings { redact }`, matching the
+	// names of the attributes whose values must not be shown, see Redacted.
+	Redact []string
+
+	// root is the raw decoded config, before evaluation.
+	root *configRoot
+	// bodies are the bodies of all config files, including those excluded
+	// by their applies_when condition, which declare variables too.
+	bodies []hcl.Body
+	// valueRanges are the ranges of the names of the values from the values
+	// files, by name.
+	valueRanges map[string]hcl.Range
+}
+
+// Component is a decoded and evaluated component block.
+type Component struct {
+	Type string
+	// Name is the value of the `name` meta-attribute, if set. It replaces
+	// the kind in the address of the component.
+	Name string
+	// Index is the `count.index` of the instance, 0 for components without
+	// `count`.
+	Index  int
+	Config ComponentConfig
+	// Outputs are the values exported by the component, if it implements
+	// Outputter.
+	Outputs map[string]cty.Value
+
+	// Retries is the number of times a failed apply is retried.
+	Retries int
+	// OnFailure is either "abort" or "continue".
+	OnFailure string
+	// Timeouts are the time limits from the `timeouts` block.
+	Timeouts ComponentTimeouts
+	// IgnoreChanges are the attributes excluded from change detection.
+	IgnoreChanges []string
+	// DependsOn are the component kinds listed in `depends_on`.
+	DependsOn []string
+	// Priority is the `priority` meta-attribute, 0 if not set. Components
+	// with a higher priority are applied and checked before those with a
+	// lower one, unless they depend on them. Components with the same
+	// priority keep their declaration order.
+	Priority int
+	// KnownAfterApply are the attributes whose values depend on outputs
+	// that are not known until apply. Their fields are left unset.
+	KnownAfterApply []string
+	// Metadata are the labels and annotations from the `metadata` block.
+	Metadata ComponentMetadata
+}
+
+// RenderJSON renders the config as JSON, using the attribute names from the
+// `hcl` struct tags of the decoded config structs. Redacted attributes are
+// masked.
+func RenderJSON(result *Config) ([]byte, error) {
+	clusters := map[string]interface{}{}
+	for _, cluster := range result.Clusters {
+		clusters[cluster.Name] = result.redactJSON(jsonValue(reflect.ValueOf(cluster.Config)))
+	}
+
+	components := []interface{}{}
+	for _, component := range result.Components {
+		components = append(components, result.renderComponent(component))
+	}
+
+	return marshalJSON(map[string]interface{}{
+		"clusters":   clusters,
+		"components": components,
+	})
+}
+
+// renderComponent returns the JSON rendering of a component, with the
+// redacted attributes masked.
+func (c *Config) renderComponent(component Component) map[string]interface{} {
+	rendered := map[string]interface{}{
+		"type":   component.Type,
+		"config": c.redactJSON(jsonValue(reflect.ValueOf(component.Config))),
+	}
+	if component.Name != "" {
+		rendered["name"] = component.Name
+	}
+	if len(component.DependsOn) > 0 {
+		rendered["depends_on"] = component.DependsOn
+	}
+	if component.Priority != 0 {
+		rendered["priority"] = component.Priority
+	}
+	if len(component.IgnoreChanges) > 0 {
+		rendered["ignore_changes"] = component.IgnoreChanges
+	}
+	if len(component.Metadata.Labels) > 0 {
+		rendered["labels"] = component.Metadata.Labels
+	}
+	if len(component.Metadata.Annotations) > 0 {
+		rendered["annotations"] = c.redactJSON(jsonValue(reflect.ValueOf(component.Metadata.Annotations)))
+	}
+	if component.Outputs != nil {
+		outputs := map[string]interface{}{}
+		for name, val := range component.Outputs {
+			value := ctyJSONValue(val)
+			if c.Redacted(name) {
+				value = redactedValue
+			}
+			outputs[name] = map[string]interface{}{
+				"value": c.redactJSON(value),
+				"type":  val.Type().FriendlyName(),
+			}
+		}
+		rendered["outputs"] = outputs
+	}
+	return rendered
+}
+
+// marshalJSON renders v as indented JSON, without escaping HTML characters
+// like the placeholders for capsule values.
+func marshalJSON(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+var (
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+func jsonValue(v reflect.Value) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+	if v.Type().Implements(textMarshalerType) || v.Type().Implements(jsonMarshalerType) {
+		return v.Interface()
+	}
+	if stringer, ok := v.Interface().(fmt.Stringer); ok && v.Kind() != reflect.Struct && v.Kind() != reflect.Ptr {
+		return stringer.String()
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		if kind, ok := variantKind(v.Type(), v); ok {
+			if obj, ok := jsonValue(v.Elem()).(map[string]interface{}); ok {
+				obj[variantKindAttr] = kind
+				return obj
+			}
+		}
+		return jsonValue(v.Elem())
+	case reflect.Struct:
+		obj := map[string]interface{}{}
+		for i := 0; i < v.NumField(); i++ {
+			tag := v.Type().Field(i).Tag.Get("hcl")
+			name := strings.Split(tag, ",")[0]
+			if name == "" || !isDataField(v.Field(i).Type()) {
+				continue
+			}
+			obj[name] = jsonValue(v.Field(i))
+		}
+		return obj
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		list := make([]interface{}, v.Len())
+		for i := range list {
+			list[i] = jsonValue(v.Index(i))
+		}
+		return list
+	case reflect.Map:
+		obj := map[string]interface{}{}
+		for _, key := range v.MapKeys() {
+			obj[fmt.Sprint(key.Interface())] = jsonValue(v.MapIndex(key))
+		}
+		return obj
+	default:
+		return v.Interface()
+	}
+}
+
+// isDataField reports whether a field holds decoded data, as opposed to the
+// raw hcl types used to defer decoding.
+func isDataField(ty reflect.Type) bool {
+	switch ty {
+	case bodyType, exprType, attrType, attrsType:
+		return false
+	}
+	return true
+}
+
+// ctyJSONValue converts a cty value into a value encoding/json can marshal.
+// Unknown values are rendered as null, capsule values as a placeholder.
+// Numbers keep all their digits, so that integers beyond 2^53, like IDs,
+// aren't rounded to the nearest float64.
+func ctyJSONValue(val cty.Value) interface{} {
+	ty := val.Type()
+	switch {
+	case ty.IsCapsuleType():
+		return capsulePlaceholder(ty)
+	case val.IsNull() || !val.IsKnown():
+		return nil
+	case ty == cty.String:
+		return val.AsString()
+	case ty == cty.Number:
+		bf := val.AsBigFloat()
+		if bf.IsInf() {
+			// Infinities have no JSON representation, encoding/json
+			// reports them.
+			f, _ := bf.Float64()
+			return f
+		}
+		return json.Number(bf.Text('f', -1))
+	case ty == cty.Bool:
+		return val.True()
+	case ty.IsObjectType() || ty.IsMapType():
+		obj := map[string]interface{}{}
+		for it := val.ElementIterator(); it.Next(); {
+			k, v := it.Element()
+			obj[k.AsString()] = ctyJSONValue(v)
+		}
+		return obj
+	default:
+		list := []interface{}{}
+		for it := val.ElementIterator(); it.Next(); {
+			_, v := it.Element()
+			list = append(list, ctyJSONValue(v))
+		}
+		return list
+	}
+}
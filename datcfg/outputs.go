@@ -0,0 +1,187 @@
+package datcfg
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl2/gohcl"
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Outputter is implemented by components that export values for use by the
+// components declared after them, as `component.<type>.<name>`. Outputs that
+// are only known after apply are returned as unknown values of their type,
+// like `cty.UnknownVal(cty.String)`, so that expressions using them are
+// still type-checked during plan.
+type Outputter interface {
+	Outputs() map[string]cty.Value
+}
+
+// OutputContract is implemented by Outputters that declare the types of
+// their outputs. The outputs must be exactly the declared ones, each
+// conforming to its type, otherwise loading fails with an error blaming the
+// component, before any other component is evaluated with them.
+// cty.DynamicPseudoType accepts values of any type.
+type OutputContract interface {
+	OutputTypes() map[string]cty.Type
+}
+
+// InputContract is implemented by components that require outputs of other
+// components, by their address without the `component.` prefix, like
+// "network.vpc_id" for `component.network.vpc_id`. The outputs must have
+// been exported by components declared before, with values conforming to
+// the required types, before the component is decoded.
+type InputContract interface {
+	InputTypes() map[string]cty.Type
+}
+
+// checkOutputTypes checks the outputs of the component at the given address
+// against the types it declares, if it implements OutputContract.
+func checkOutputTypes(address string, config ComponentConfig, outputs map[string]cty.Value, subject hcl.Range) hcl.Diagnostics {
+	contract, ok := config.(OutputContract)
+	if !ok {
+		return nil
+	}
+	types := contract.OutputTypes()
+
+	var diags hcl.Diagnostics
+	invalid := func(detail string) {
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid component output",
+			Detail:   fmt.Sprintf("The component %q %s. This is a bug in the component kind, not in the config.", address, detail),
+			Subject:  subject.Ptr(),
+		})
+	}
+	for _, name := range typeNames(types) {
+		val, ok := outputs[name]
+		if !ok {
+			invalid(fmt.Sprintf("declares the output %q, but doesn't export it", name))
+			continue
+		}
+		if errs := val.Type().TestConformance(types[name]); len(errs) > 0 {
+			invalid(fmt.Sprintf("declares the output %q as %s, but exports %s", name, types[name].FriendlyName(), val.Type().FriendlyName()))
+		}
+	}
+	var undeclared []string
+	for name := range outputs {
+		if _, ok := types[name]; !ok {
+			undeclared = append(undeclared, name)
+		}
+	}
+	sort.Strings(undeclared)
+	for _, name := range undeclared {
+		invalid(fmt.Sprintf("exports the undeclared output %q", name))
+	}
+	return diags
+}
+
+// checkInputTypes checks the outputs exported so far, by component kind,
+// against the inputs required by the component at the given address, if it
+// implements InputContract.
+func checkInputTypes(address string, config ComponentConfig, outputs map[string]cty.Value, subject hcl.Range) hcl.Diagnostics {
+	contract, ok := config.(InputContract)
+	if !ok {
+		return nil
+	}
+	types := contract.InputTypes()
+
+	var diags hcl.Diagnostics
+	for _, input := range typeNames(types) {
+		kind, name, _ := strings.Cut(input, ".")
+		kind = canonicalKindName(kind)
+		var val cty.Value
+		if exported, ok := outputs[kind]; ok && exported.Type().IsObjectType() && exported.Type().HasAttribute(name) {
+			val = exported.GetAttr(name)
+		}
+		switch {
+		case val == cty.NilVal:
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Missing component input",
+				Detail:   fmt.Sprintf("The component %q requires the output component.%s, which no component declared before it exports.", address, input),
+				Subject:  subject.Ptr(),
+			})
+		case len(val.Type().TestConformance(types[input])) > 0:
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid component input",
+				Detail:   fmt.Sprintf("The component %q requires the output component.%s to be %s, but it is %s.", address, input, types[input].FriendlyName(), val.Type().FriendlyName()),
+				Subject:  subject.Ptr(),
+			})
+		}
+	}
+	return diags
+}
+
+// typeNames returns the names of the given types in lexical order.
+func typeNames(types map[string]cty.Type) []string {
+	names := make([]string, 0, len(types))
+	for name := range types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// knownAfterApply returns the names of the attributes of the given component
+// body whose values are not known yet.
+func knownAfterApply(body hcl.Body, ctx *hcl.EvalContext, config ComponentConfig) []string {
+	schema, _ := gohcl.ImpliedBodySchema(config)
+	content, _, _ := body.PartialContent(schema)
+	if content == nil {
+		return nil
+	}
+
+	var names []string
+	for _, name := range sortedAttrNames(content.Attributes) {
+		val, diags := content.Attributes[name].Expr.Value(ctx)
+		if !diags.HasErrors() && !val.IsWhollyKnown() {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// checkOutputs validates the outputs exported by a component. Capsule-typed
+// values, like connection handles, are only allowed if the loader was created
+// with WithCapsuleTypes.
+func checkOutputs(componentType string, outputs map[string]cty.Value, allowCapsules bool) hcl.Diagnostics {
+	if allowCapsules {
+		return nil
+	}
+
+	var diags hcl.Diagnostics
+	for name, val := range outputs {
+		if containsCapsule(val) {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Unsupported output type",
+				Detail: fmt.Sprintf(
+					"The output %q of component %q contains a capsule value, which is only supported if capsule types are enabled.",
+					name, componentType,
+				),
+			})
+		}
+	}
+	return diags
+}
+
+func containsCapsule(val cty.Value) bool {
+	found := false
+	cty.Walk(val, func(path cty.Path, v cty.Value) (bool, error) {
+		if v.Type().IsCapsuleType() {
+			found = true
+		}
+		return !found, nil
+	})
+	return found
+}
+
+// capsulePlaceholder is rendered instead of capsule values in JSON output,
+// since their content is opaque.
+func capsulePlaceholder(ty cty.Type) string {
+	return fmt.Sprintf("<capsule %s>", ty.FriendlyName())
+}
@@ -0,0 +1,221 @@
+package datcfg
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hcl/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/gocty"
+)
+
+// WithOverride sets an attribute of the decoded config after evaluation,
+// replacing whatever the config files set it to. The path is either
+// `cluster.<attribute>`, which sets the attribute of every cluster, or
+// `component.<address>.<attribute>`, with the address of the component as
+// returned by ComponentAddress. Further names select the attributes of
+// nested blocks:
+//
+//	datcfg.WithOverride("cluster.worker_count", "5")
+//	datcfg.WithOverride("component.foo.settings.port", "8081")
+//
+// The value of strings, numbers and bools is given as is and converted to the
+// type of the attribute. Other values are given in the native syntax, like
+// `["a", "b"]`. The overridden attributes are recorded in Config.Overrides.
+func WithOverride(path, value string) LoaderOption {
+	return func(l *Loader) {
+		l.overrides = append(l.overrides, override{path: path, value: value})
+	}
+}
+
+type override struct {
+	path, value string
+}
+
+// applyOverrides sets the attributes given with WithOverride in the given
+// result, in the order they were given, either those of the cluster or all
+// others. The cluster is overridden as soon as it is decoded, so that the
+// `cluster` object the components refer to has the overridden attributes.
+func (l *Loader) applyOverrides(result *Config, cluster bool) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+	for _, o := range l.overrides {
+		if strings.HasPrefix(o.path, "cluster.") != cluster {
+			continue
+		}
+		targets, attrPath, targetDiags := overrideTargets(result, o.path)
+		diags = append(diags, targetDiags...)
+		if targetDiags.HasErrors() {
+			continue
+		}
+
+		applied := true
+		for _, target := range targets {
+			setDiags := l.setOverride(target.config, attrPath, o)
+			diags = append(diags, setDiags...)
+			if setDiags.HasErrors() {
+				applied = false
+				continue
+			}
+			if decoder, ok := target.config.Interface().(PostDecoder); ok {
+				// Derive the fields again from the overridden attribute. The
+				// variables registered again are ignored, the blocks after
+				// the config were already decoded with the former ones.
+				ctx := result.EvalContext.NewChild()
+				ctx.Variables = map[string]cty.Value{}
+				diags = append(diags, decoder.PostDecode(ctx)...)
+			}
+			if target.component != nil {
+				target.component.KnownAfterApply = withoutString(target.component.KnownAfterApply, strings.Join(attrPath, "."))
+			}
+		}
+		if applied {
+			if result.Overrides == nil {
+				result.Overrides = map[string]string{}
+			}
+			result.Overrides[o.path] = o.value
+		}
+	}
+	return diags
+}
+
+// overrideTarget is a decoded config an override applies to.
+type overrideTarget struct {
+	config reflect.Value
+	// component is the component the config belongs to, if any.
+	component *Component
+}
+
+// overrideTargets resolves the address part of an override path, returning
+// the configs it applies to and the names of the attribute within them.
+func overrideTargets(result *Config, path string) ([]overrideTarget, []string, hcl.Diagnostics) {
+	parts := strings.Split(path, ".")
+	var targets []overrideTarget
+	switch {
+	case parts[0] == "cluster" && len(parts) > 1:
+		for i := range result.Clusters {
+			targets = append(targets, overrideTarget{config: reflect.ValueOf(&result.Clusters[i].Config)})
+		}
+		parts = parts[1:]
+	case parts[0] == "component" && len(parts) > 2:
+		for i := range result.Components {
+			if ComponentAddress(result.Components, i) == result.CurrentAddress(parts[1]) {
+				component := &result.Components[i]
+				targets = append(targets, overrideTarget{config: reflect.ValueOf(component.Config), component: component})
+			}
+		}
+		if len(targets) == 0 {
+			return nil, nil, overrideError(path, fmt.Sprintf("There is no component with the address %q.", parts[1]))
+		}
+		parts = parts[2:]
+	default:
+		return nil, nil, overrideError(path, "The path must be like cluster.<attribute> or component.<address>.<attribute>.")
+	}
+	return targets, parts, nil
+}
+
+// setOverride sets the attribute at the given path within config, which is
+// a pointer to a struct.
+func (l *Loader) setOverride(config reflect.Value, attrPath []string, o override) hcl.Diagnostics {
+	val := config
+	for i, name := range attrPath {
+		for val.Kind() == reflect.Ptr || val.Kind() == reflect.Interface {
+			if val.IsNil() {
+				return overrideError(o.path, fmt.Sprintf("The block %q is not set, so it has no attributes to override.", strings.Join(attrPath[:i], ".")))
+			}
+			val = val.Elem()
+		}
+		if val.Kind() != reflect.Struct {
+			return overrideError(o.path, fmt.Sprintf("The value of %q has no attributes to override.", strings.Join(attrPath[:i], ".")))
+		}
+
+		tags := getDecodeFieldTags(val.Type())
+		if i < len(attrPath)-1 {
+			fieldIdx, ok := tags.Blocks[name]
+			if !ok {
+				return overrideError(o.path, fmt.Sprintf("There is no nested block named %q.", name))
+			}
+			val = val.Field(fieldIdx)
+			continue
+		}
+
+		fieldIdx, ok := tags.Attributes[name]
+		if !ok {
+			return overrideError(o.path, fmt.Sprintf("There is no attribute named %q.", name))
+		}
+		fieldV := val.Field(fieldIdx)
+		diags := decodeOverride(o, name, fieldV)
+		if !diags.HasErrors() {
+			// The attribute is no longer defined where the files set it.
+			delete(l.ranges[val.Addr().Interface()], name)
+		}
+		return diags
+	}
+	return nil
+}
+
+// decodeOverride decodes the value of an override into the given field,
+// like decoding an attribute with that value would.
+func decodeOverride(o override, name string, fieldV reflect.Value) hcl.Diagnostics {
+	if fieldV.Type() == attrType || exprType.AssignableTo(fieldV.Type()) {
+		return overrideError(o.path, fmt.Sprintf("The attribute %q is evaluated by the component itself.", name))
+	}
+
+	val, diags := overrideValue(o, fieldV)
+	if diags.HasErrors() {
+		return diags
+	}
+
+	attr := &hcl.Attribute{Name: name, Expr: hcl.StaticExpr(val, hcl.Range{})}
+	if hook, isPtr := decodeHookFor(fieldV.Type()); hook != nil {
+		diags = decodeAttrWithHook(attr, nil, fieldV, hook, isPtr)
+	} else {
+		diags = decodeExpression(attr.Expr, name, nil, fieldV)
+	}
+
+	// The value has no range in the files, point to the override instead.
+	for _, diag := range diags {
+		diag.Subject = nil
+		diag.Context = nil
+		diag.Detail = fmt.Sprintf("In the override of %s: %s", o.path, diag.Detail)
+	}
+	return diags
+}
+
+// overrideValue returns the value of an override, which is taken as is for
+// primitive attributes and parsed as an expression otherwise.
+func overrideValue(o override, fieldV reflect.Value) (cty.Value, hcl.Diagnostics) {
+	if hook, _ := decodeHookFor(fieldV.Type()); hook != nil {
+		return cty.StringVal(o.value), nil
+	}
+	if ty, err := gocty.ImpliedType(fieldV.Addr().Interface()); err == nil && ty.IsPrimitiveType() {
+		return cty.StringVal(o.value), nil
+	}
+
+	expr, diags := hclsyntax.ParseExpression([]byte(o.value), o.path, hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		return cty.NilVal, diags
+	}
+	return expr.Value(nil)
+}
+
+func overrideError(path, detail string) hcl.Diagnostics {
+	return hcl.Diagnostics{
+		{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid override",
+			Detail:   fmt.Sprintf("Can't override %s: %s", path, detail),
+		},
+	}
+}
+
+func withoutString(list []string, s string) []string {
+	var out []string
+	for _, item := range list {
+		if item != s {
+			out = append(out, item)
+		}
+	}
+	return out
+}
@@ -0,0 +1,142 @@
+package datcfg
+
+import (
+	"fmt"
+	"io/fs"
+	"runtime"
+	"sync"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hclparse"
+)
+
+// ParseConfigFiles parses all `.datcfg` files in the root of the given
+// filesystem, which can be the working directory, an `embed.FS` or an
+// in-memory filesystem like `fstest.MapFS`.
+//
+// All files are parsed even if some of them contain errors, so that the
+// diagnostics of all files are reported at once. Only the files that parsed
+// cleanly are returned.
+func ParseConfigFiles(fsys fs.FS) ([]*hcl.File, hcl.Diagnostics) {
+	return parseConfigFiles(fsys, nil)
+}
+
+// parseConfigFiles is ParseConfigFiles, reusing the files that didn't change
+// from the given cache, which may be nil.
+func parseConfigFiles(fsys fs.FS, cache *EvalCache) ([]*hcl.File, hcl.Diagnostics) {
+	return parseConfigGlob(fsys, "*.datcfg", cache)
+}
+
+// parseConfigGlob parses the config files matching pattern, like
+// parseConfigFiles.
+func parseConfigGlob(fsys fs.FS, pattern string, cache *EvalCache) ([]*hcl.File, hcl.Diagnostics) {
+	configFiles, err := fs.Glob(fsys, pattern)
+	if err != nil {
+		return nil, hcl.Diagnostics{
+			{
+				Severity: hcl.DiagError,
+				Summary:  "Failed to find config files",
+				Detail:   err.Error(),
+			},
+		}
+	}
+
+	// The files are read one after the other, since fsys need not be safe
+	// for concurrent use, and parsed concurrently.
+	parsed := make([]*hcl.File, len(configFiles))
+	var diags DiagnosticsCollector
+	var wg sync.WaitGroup
+	workers := make(chan struct{}, runtime.GOMAXPROCS(0))
+	for i, f := range configFiles {
+		src, err := fs.ReadFile(fsys, f)
+		if err != nil {
+			diags.Add(readFileDiags(f, err))
+			continue
+		}
+		wg.Add(1)
+		workers <- struct{}{}
+		go func(i int, path string, src []byte) {
+			defer func() {
+				<-workers
+				wg.Done()
+			}()
+			hclFile, fileDiags := cache.parseSource(src, path)
+			diags.Add(fileDiags)
+			if !fileDiags.HasErrors() {
+				parsed[i] = hclFile
+			}
+		}(i, f, src)
+	}
+	wg.Wait()
+
+	var hclFiles []*hcl.File
+	for _, hclFile := range parsed {
+		if hclFile != nil {
+			hclFiles = append(hclFiles, hclFile)
+		}
+	}
+	return hclFiles, diags.Diagnostics()
+}
+
+// parseHCLFile reads the file at the given path from fsys and parses it
+// using the given parser. The path is used as the filename in diagnostics.
+func parseHCLFile(hclParser *hclparse.Parser, fsys fs.FS, path string) (*hcl.File, hcl.Diagnostics) {
+	src, err := fs.ReadFile(fsys, path)
+	if err != nil {
+		return nil, readFileDiags(path, err)
+	}
+	if diags := checkEncoding(src, path); diags.HasErrors() {
+		return nil, diags
+	}
+
+	file, diags := hclParser.ParseHCL(rewriteNamespacedCalls(src, path), path)
+	diags = append(diags, separatorCalls(src, path)...)
+	wrapExpressions(file)
+	return file, diags
+}
+
+// ParseConfig parses the source of a single config file. The byte offsets of
+// all ranges in the result refer to src, so they can be used to edit it,
+// also if it starts with a byte order mark or has CRLF line endings.
+func ParseConfig(src []byte, filename string) (*hcl.File, hcl.Diagnostics) {
+	if diags := checkEncoding(src, filename); diags.HasErrors() {
+		return nil, diags
+	}
+	file, diags := hclparse.NewParser().ParseHCL(rewriteNamespacedCalls(src, filename), filename)
+	diags = append(diags, separatorCalls(src, filename)...)
+	wrapExpressions(file)
+	return file, diags
+}
+
+// parseJSONFile is like parseHCLFile, for files in the JSON syntax. A byte
+// order mark is stripped, so the byte offsets of the ranges in files that
+// start with one are off by its length. Line and column numbers are not.
+func parseJSONFile(hclParser *hclparse.Parser, fsys fs.FS, path string) (*hcl.File, hcl.Diagnostics) {
+	src, err := fs.ReadFile(fsys, path)
+	if err != nil {
+		return nil, readFileDiags(path, err)
+	}
+	if diags := checkEncoding(src, path); diags.HasErrors() {
+		return nil, diags
+	}
+
+	return hclParser.ParseJSON(stripBOM(src), path)
+}
+
+func readFileDiags(path string, err error) hcl.Diagnostics {
+	return hcl.Diagnostics{
+		{
+			Severity: hcl.DiagError,
+			Summary:  "Failed to read file",
+			Detail:   fmt.Sprintf("The file %q could not be read: %s.", path, err),
+		},
+	}
+}
+
+func fileNames(files []*hcl.File) []string {
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Body.MissingItemRange().Filename)
+	}
+	return names
+}
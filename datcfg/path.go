@@ -0,0 +1,104 @@
+package datcfg
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hcl/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// WithRootDir sets the directory the filesystem of the loader reads, as seen
+// from the working directory, for the `path` object. Loaders reading an
+// MmapFS know it, for other filesystems it defaults to ".".
+func WithRootDir(dir string) LoaderOption {
+	return func(l *Loader) {
+		l.rootDir = dir
+	}
+}
+
+// rootDirOf returns the directory a filesystem returned by MmapFS reads.
+func rootDirOf(fsys interface{}) string {
+	if m, ok := fsys.(mmapFS); ok {
+		return m.dir
+	}
+	return "."
+}
+
+// pathObject returns the `path` object available to expressions, which
+// holds the paths configs build the paths of other files from:
+//
+//	ca_file = "${path.module}/certs/ca.pem"
+//
+// `path.root` is the config directory and `path.module` the directory of
+// the file the expression is in, which are the same since config files are
+// only read from the root. Both are relative to the working directory, like
+// `path.file`, the path of the file itself. `path.cwd` is the absolute path
+// of the working directory, which is left out in restricted and hermetic
+// mode, see cwdReferences.
+func (l *Loader) pathObject() cty.Value {
+	root := filepath.ToSlash(filepath.Clean(l.rootDir))
+	attrs := map[string]cty.Value{
+		"root":   cty.StringVal(root),
+		"module": cty.StringVal(root),
+	}
+	if l.denyingMode() == "" {
+		if cwd, err := os.Getwd(); err == nil {
+			attrs["cwd"] = cty.StringVal(filepath.ToSlash(cwd))
+		}
+	}
+	return cty.ObjectVal(attrs)
+}
+
+// fileTraversalExpr is a reference to the `path` object, which is evaluated
+// with `path.file` set to the file it is in. It embeds a wrapper of the
+// reference, so that walks, like Variables, still find the reference as it
+// was parsed.
+type fileTraversalExpr struct {
+	*hclsyntax.TemplateWrapExpr
+}
+
+func (e *fileTraversalExpr) Value(ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
+	for scope := ctx; scope != nil; scope = scope.Parent() {
+		obj, ok := scope.Variables["path"]
+		if !ok {
+			continue
+		}
+		if !obj.Type().IsObjectType() || !obj.Type().HasAttribute("root") {
+			// A variable of a for expression or a block context shadows
+			// the path object.
+			break
+		}
+		attrs := obj.AsValueMap()
+		attrs["file"] = cty.StringVal(path.Join(attrs["root"].AsString(), e.SrcRange.Filename))
+		fileCtx := ctx.NewChild()
+		fileCtx.Variables = map[string]cty.Value{"path": cty.ObjectVal(attrs)}
+		return e.Wrapped.Value(fileCtx)
+	}
+	return e.Wrapped.Value(ctx)
+}
+
+// cwdReferences reports the references to `path.cwd` in the given files,
+// which is not set in restricted and hermetic mode since it tells where on
+// the host the config is.
+func cwdReferences(files []*hcl.File, mode string) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+	for _, traversal := range allTraversals(files) {
+		if traversal.RootName() != "path" || len(traversal) < 2 {
+			continue
+		}
+		if attr, ok := traversal[1].(hcl.TraverseAttr); !ok || attr.Name != "cwd" {
+			continue
+		}
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Working directory not available",
+			Detail:   fmt.Sprintf("The attribute path.cwd is not available in %s mode, since it tells where on the host the config is. Use path.module or path.root to build paths relative to the config.", mode),
+			Subject:  traversal.SourceRange().Ptr(),
+		})
+	}
+	return diags
+}
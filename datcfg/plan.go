@@ -0,0 +1,36 @@
+package datcfg
+
+import (
+	"context"
+
+	"github.com/hashicorp/hcl2/hcl"
+)
+
+// Planner is implemented by components that can tell which actions an apply
+// would perform, without performing them.
+type Planner interface {
+	Plan(ctx context.Context) (PlanSummary, hcl.Diagnostics)
+}
+
+// PlanActionType is the kind of change a plan action would make.
+type PlanActionType string
+
+const (
+	PlanCreate PlanActionType = "create"
+	PlanUpdate PlanActionType = "update"
+	PlanDelete PlanActionType = "delete"
+)
+
+// PlanAction is a single change a component intends to make.
+type PlanAction struct {
+	Type PlanActionType
+	// Address identifies the thing being changed within the component.
+	Address string
+	// Detail is an optional human readable description of the change.
+	Detail string
+}
+
+// PlanSummary is the list of actions a component intends to perform.
+type PlanSummary struct {
+	Actions []PlanAction
+}
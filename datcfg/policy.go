@@ -0,0 +1,242 @@
+package datcfg
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hcl/hclsyntax"
+	"github.com/hashicorp/hcl2/hclparse"
+)
+
+// PolicyFile is the file in the root of a config that restricts which files
+// may set which attributes, for configs maintained by several teams:
+//
+//	attribute "cluster.controller_count" {
+//	  allow_in = ["platform.datcfg"]
+//	}
+//
+//	attribute "var.controller_count" {
+//	  allow_in = ["environments/*/platform/"]
+//	}
+//
+// The label of an `attribute` block is the path of the attributes the rule
+// applies to, which are named by their blocks:
+//
+//   - cluster.<attribute>, for the `cluster` and `cluster_config` blocks;
+//   - component.<kind>.<attribute>, also for the meta-arguments;
+//   - <type>.<labels>.<attribute> for other blocks, like
+//     variable.region.default;
+//   - var.<name> for the values set in values files.
+//
+// Nested blocks add their type to the path, like
+// component.foo.timeouts.apply. A segment of a rule can be a glob pattern,
+// and a rule for a block, like `component.foo`, applies to all of its
+// attributes. The files that set an attribute a rule applies to must match
+// one of the glob patterns of its `allow_in`, patterns ending in a slash
+// match all files under a directory.
+const PolicyFile = "dat.policy"
+
+// policyRule is an `attribute` block of the policy file.
+type policyRule struct {
+	path    []string
+	allowIn []string
+	rng     hcl.Range
+}
+
+// loadPolicy reads the rules of the policy file of fsys, if it has one.
+func loadPolicy(fsys fs.FS) ([]policyRule, hcl.Diagnostics) {
+	if _, err := fs.Stat(fsys, PolicyFile); errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	file, diags := parseHCLFile(hclparse.NewParser(), fsys, PolicyFile)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	content, contentDiags := file.Body.Content(&hcl.BodySchema{
+		Blocks: []hcl.BlockHeaderSchema{{Type: "attribute", LabelNames: []string{"path"}}},
+	})
+	diags = append(diags, contentDiags...)
+	var rules []policyRule
+	for _, block := range content.Blocks {
+		attrs, attrDiags := block.Body.Content(&hcl.BodySchema{
+			Attributes: []hcl.AttributeSchema{{Name: "allow_in", Required: true}},
+		})
+		diags = append(diags, attrDiags...)
+		if attrDiags.HasErrors() {
+			continue
+		}
+		rule := policyRule{path: strings.Split(block.Labels[0], "."), rng: block.DefRange}
+		allowIn := attrs.Attributes["allow_in"]
+		allowDiags := decodeExpression(allowIn.Expr, allowIn.Name, nil, reflect.ValueOf(&rule.allowIn).Elem())
+		diags = append(diags, allowDiags...)
+		if allowDiags.HasErrors() {
+			continue
+		}
+		for _, pattern := range append(append([]string(nil), rule.path...), rule.allowIn...) {
+			if _, err := path.Match(strings.TrimSuffix(pattern, "/"), ""); err != nil {
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Invalid policy pattern",
+					Detail:   fmt.Sprintf("The pattern %q is not a valid glob pattern: %s.", pattern, err),
+					Subject:  block.DefRange.Ptr(),
+				})
+			}
+		}
+		rules = append(rules, rule)
+	}
+	return rules, diags
+}
+
+// checkPolicy reports the attributes of the given config files and of the
+// values files of the loader that are set in a file the policy of fsys
+// doesn't allow them in.
+func (l *Loader) checkPolicy(files []*hcl.File) hcl.Diagnostics {
+	rules, diags := loadPolicy(l.fsys)
+	if diags.HasErrors() || len(rules) == 0 {
+		return diags
+	}
+
+	check := func(attrPath []string, rng hcl.Range) {
+		for _, rule := range rules {
+			if !rule.appliesTo(attrPath) || rule.allows(rng.Filename) {
+				continue
+			}
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Attribute not allowed in this file",
+				Detail: fmt.Sprintf(
+					"The policy at %s only allows %s to be set in %s.",
+					rule.rng, strings.Join(attrPath, "."), strings.Join(rule.allowIn, ", "),
+				),
+				Subject: rng.Ptr(),
+			})
+			return
+		}
+	}
+
+	for _, file := range files {
+		if yaml, ok := file.Body.(*yamlBody); ok {
+			checkYAMLPolicy(yaml.node, check)
+			continue
+		}
+		body, ok := file.Body.(*hclsyntax.Body)
+		if !ok {
+			continue
+		}
+		for _, attr := range sortedAttributes(body) {
+			check([]string{attr.Name}, attr.NameRange)
+		}
+		for _, block := range body.Blocks {
+			var blockPath []string
+			switch block.Type {
+			case "cluster", "cluster_config":
+				blockPath = []string{"cluster"}
+			default:
+				blockPath = append([]string{block.Type}, block.Labels...)
+			}
+			checkBlockPolicy(block.Body, blockPath, check)
+		}
+	}
+
+	paths, pathDiags := valuesFiles(l.fsys, l.valuesFile)
+	diags = append(diags, pathDiags...)
+	if l.valuesEnvironment != "" {
+		envPaths, _ := environmentValuesFiles(l.fsys, l.valuesEnvironment)
+		paths = append(paths, envPaths...)
+	}
+	for _, valuesPath := range paths {
+		_, ranges, _ := loadValuesFile(l.fsys, valuesPath)
+		names := make([]string, 0, len(ranges))
+		for name := range ranges {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			check([]string{"var", name}, ranges[name])
+		}
+	}
+	return diags
+}
+
+// checkBlockPolicy checks the attributes of the given block body, and of
+// its nested blocks, at the given path.
+func checkBlockPolicy(body *hclsyntax.Body, blockPath []string, check func([]string, hcl.Range)) {
+	for _, attr := range sortedAttributes(body) {
+		check(append(append([]string(nil), blockPath...), attr.Name), attr.NameRange)
+	}
+	for _, block := range body.Blocks {
+		checkBlockPolicy(block.Body, append(append([]string(nil), blockPath...), block.Type), check)
+	}
+}
+
+// checkYAMLPolicy checks the values of a YAML config file, in which the
+// blocks can't be told from the attributes holding objects. All keys make up
+// the path of a value, so that `component.foo.tags.team` is covered by a
+// rule for component.foo.tags, like the attribute it would be in HCL.
+func checkYAMLPolicy(root *yamlNode, check func([]string, hcl.Range)) {
+	var walk func(node *yamlNode, valuePath []string, rng hcl.Range)
+	walk = func(node *yamlNode, valuePath []string, rng hcl.Range) {
+		switch {
+		case node.kind == yamlMapping && len(node.entries) > 0:
+			for _, entry := range node.entries {
+				walk(entry.value, append(append([]string(nil), valuePath...), entry.key), entry.keyRange)
+			}
+		case node.kind == yamlSequence && len(node.items) > 0 && node.items[0].kind == yamlMapping:
+			// Sequences of mappings may be several blocks of a type.
+			for _, item := range node.items {
+				walk(item, valuePath, rng)
+			}
+		default:
+			check(valuePath, rng)
+		}
+	}
+	for _, entry := range root.entries {
+		if entry.key != "cluster" && entry.key != "cluster_config" || entry.value.kind != yamlMapping {
+			walk(entry.value, []string{entry.key}, entry.keyRange)
+			continue
+		}
+		for _, labelled := range entry.value.entries {
+			walk(labelled.value, []string{"cluster"}, labelled.keyRange)
+		}
+	}
+}
+
+// appliesTo reports whether the rule applies to the attribute at the given
+// path, which it does if it matches the path or one of its blocks.
+func (r policyRule) appliesTo(attrPath []string) bool {
+	if len(r.path) > len(attrPath) {
+		return false
+	}
+	for i, pattern := range r.path {
+		if ok, _ := path.Match(pattern, attrPath[i]); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// allows reports whether the rule allows its attributes to be set in the
+// file with the given name.
+func (r policyRule) allows(name string) bool {
+	for _, pattern := range r.allowIn {
+		if dir := strings.TrimSuffix(pattern, "/"); dir != pattern {
+			for parent := path.Dir(name); parent != "."; parent = path.Dir(parent) {
+				if ok, _ := path.Match(dir, parent); ok {
+					return true
+				}
+			}
+			continue
+		}
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
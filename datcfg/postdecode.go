@@ -0,0 +1,63 @@
+package datcfg
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// PostDecoder is implemented by cluster and component configs that compute
+// derived fields once they are decoded, like ClusterConfig.NodeCount.
+//
+// The variables PostDecode sets in ctx.Variables are added to the root
+// context, so that the blocks decoded after it can refer to them. Variables
+// that are already defined, like `var` or those registered by another
+// config, can't be replaced. Configs an override applies to are derived
+// again, with the variables they set then being ignored.
+type PostDecoder interface {
+	PostDecode(ctx *hcl.EvalContext) hcl.Diagnostics
+}
+
+// postDecode runs the PostDecode method of the given decoded config, if it
+// has one, and adds the variables it registers to root. The address is that
+// of the block the config was decoded from.
+func postDecode(config interface{}, address string, root *hcl.EvalContext, subject hcl.Range) hcl.Diagnostics {
+	decoder, ok := config.(PostDecoder)
+	if !ok {
+		return nil
+	}
+
+	ctx := root.NewChild()
+	ctx.Variables = map[string]cty.Value{}
+	diags := decoder.PostDecode(ctx)
+	if diags.HasErrors() {
+		return diags
+	}
+
+	names := make([]string, 0, len(ctx.Variables))
+	for name := range ctx.Variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, exists := root.Variables[name]; exists {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Conflicting derived value",
+				Detail:   fmt.Sprintf("The config of %s registers the variable %q, which is already defined.", address, name),
+				Subject:  subject.Ptr(),
+			})
+			continue
+		}
+		root.Variables[name] = ctx.Variables[name]
+	}
+	return diags
+}
+
+// PostDecode computes the total number of nodes of the cluster.
+func (c *ClusterConfig) PostDecode(ctx *hcl.EvalContext) hcl.Diagnostics {
+	c.NodeCount = c.ControllerCount + c.WorkerCount
+	return nil
+}
@@ -0,0 +1,40 @@
+package datcfg
+
+import (
+	"sort"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hcl/hclsyntax"
+)
+
+// UnusedValues returns the names of the values and sections set in the given
+// values file for which none of the given config files declares a variable,
+// in lexical order. Those set under a former name of a variable, see
+// VariableRenames, are used. Values files in the JSON syntax have none.
+func UnusedValues(file *hcl.File, configFiles []*hcl.File) []string {
+	body, ok := file.Body.(*hclsyntax.Body)
+	if !ok {
+		return nil
+	}
+
+	declared := VariableRenames(configFiles)
+	for _, block := range topLevelBlocks(configFiles) {
+		if block.Type == "variable" && len(block.Labels) > 0 {
+			declared[block.Labels[0]] = block.Labels[0]
+		}
+	}
+
+	var unused []string
+	for _, attr := range sortedAttributes(body) {
+		if _, ok := declared[attr.Name]; !ok {
+			unused = append(unused, attr.Name)
+		}
+	}
+	for _, block := range body.Blocks {
+		if _, ok := declared[block.Type]; !ok && len(block.Labels) == 0 {
+			unused = append(unused, block.Type)
+		}
+	}
+	sort.Strings(unused)
+	return unused
+}
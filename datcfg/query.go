@@ -0,0 +1,89 @@
+package datcfg
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hcl/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// Query returns the value at the given path of the evaluated config, written
+// like a reference in an expression:
+//
+//	cluster.worker_count
+//	components["foo"].config.foo
+//	var.region
+//
+// `cluster` is the cluster as the blocks after it see it, `components` are
+// the components in the form of their JSON rendering, by address, and `var`
+// are the variables. Redacted attributes and sensitive variables are masked.
+func (c *Config) Query(path string) (cty.Value, hcl.Diagnostics) {
+	traversal, diags := hclsyntax.ParseTraversalAbs([]byte(path), "", hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		return cty.NilVal, queryDiags(path, diags)
+	}
+
+	components := map[string]interface{}{}
+	for i, component := range c.Components {
+		components[ComponentAddress(c.Components, i)] = c.renderComponent(component)
+	}
+	componentsVal, err := jsonToValue(components)
+	if err != nil {
+		return cty.NilVal, hcl.Diagnostics{
+			{
+				Severity: hcl.DiagError,
+				Summary:  "Failed to render the components",
+				Detail:   err.Error(),
+			},
+		}
+	}
+
+	if len(traversal) > 1 && traversal.RootName() == "components" {
+		// The address may be a former one, moved with a `moved` block.
+		if index, ok := traversal[1].(hcl.TraverseIndex); ok && index.Key.Type() == cty.String {
+			index.Key = cty.StringVal(c.CurrentAddress(index.Key.AsString()))
+			traversal[1] = index
+		}
+	}
+
+	ctx := &hcl.EvalContext{
+		Variables: map[string]cty.Value{
+			"components": componentsVal,
+			"var":        cty.ObjectVal(c.RedactValues(c.Variables)),
+		},
+	}
+	if c.EvalContext != nil {
+		if cluster, ok := c.EvalContext.Variables["cluster"]; ok {
+			ctx.Variables["cluster"] = c.RedactValue(cluster)
+		}
+	}
+	val, diags := traversal.TraverseAbs(ctx)
+	return val, queryDiags(path, diags)
+}
+
+// queryDiags points the given diagnostics to the query, which has no source
+// to point to with ranges.
+func queryDiags(path string, diags hcl.Diagnostics) hcl.Diagnostics {
+	for _, diag := range diags {
+		diag.Subject = nil
+		diag.Context = nil
+		diag.Detail = fmt.Sprintf("In the query %s: %s", path, diag.Detail)
+	}
+	return diags
+}
+
+// jsonToValue converts a value returned by jsonValue or ctyJSONValue into a
+// cty value, with the types implied by its JSON rendering.
+func jsonToValue(v interface{}) (cty.Value, error) {
+	src, err := marshalJSON(v)
+	if err != nil {
+		return cty.NilVal, err
+	}
+	ty, err := ctyjson.ImpliedType(src)
+	if err != nil {
+		return cty.NilVal, err
+	}
+	return ctyjson.Unmarshal(src, ty)
+}
@@ -0,0 +1,184 @@
+package datcfg
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hcl/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+	"github.com/zclconf/go-cty/cty/gocty"
+)
+
+// runFunctionParams are the parameters of the functions returning values of
+// the run, which runValueExpr wraps the calls of. Each call gets the site
+// it is made from as a hidden first argument.
+var runFunctionParams = map[string][]string{
+	"uuid":           nil,
+	"random_integer": {"min", "max"},
+	"timestamp":      nil,
+}
+
+// WithSeed makes the values of uuid, random_integer and timestamp depend on
+// the given seed only, instead of a random one per load, so that tests and
+// snapshots of configs using them are reproducible. timestamp then returns
+// the seed as seconds since the Unix epoch.
+func WithSeed(seed int64) LoaderOption {
+	return func(l *Loader) {
+		l.seed = &seed
+	}
+}
+
+// runValues holds what the values of uuid, random_integer and timestamp are
+// derived from during a load. A call returns the same value every time it
+// is evaluated in the same load, also if it is evaluated more than once,
+// while calls in different places, or in different instances of a block
+// with for_each or count, return different values.
+type runValues struct {
+	seed int64
+	time time.Time
+}
+
+func newRunValues(l *Loader) *runValues {
+	if l.seed != nil {
+		return &runValues{seed: *l.seed, time: time.Unix(*l.seed, 0).UTC()}
+	}
+	var seed [8]byte
+	rand.Read(seed[:])
+	return &runValues{seed: int64(binary.BigEndian.Uint64(seed[:])), time: time.Now().UTC()}
+}
+
+// sum returns the bytes the value of a call from the given site is derived
+// from.
+func (r *runValues) sum(site string) [sha256.Size]byte {
+	var seed [8]byte
+	binary.BigEndian.PutUint64(seed[:], uint64(r.seed))
+	return sha256.Sum256(append(seed[:], site...))
+}
+
+func (r *runValues) functions() map[string]function.Function {
+	site := function.Parameter{Name: "site", Type: cty.String}
+	return map[string]function.Function{
+		"uuid": function.New(&function.Spec{
+			Params: []function.Parameter{site},
+			Type:   function.StaticReturnType(cty.String),
+			Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+				b := r.sum(args[0].AsString())
+				b[6] = b[6]&0x0f | 0x40
+				b[8] = b[8]&0x3f | 0x80
+				return cty.StringVal(fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])), nil
+			},
+		}),
+		"random_integer": function.New(&function.Spec{
+			Params: []function.Parameter{
+				site,
+				{Name: "min", Type: cty.Number},
+				{Name: "max", Type: cty.Number},
+			},
+			Type: function.StaticReturnType(cty.Number),
+			Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+				var min, max int64
+				if err := gocty.FromCtyValue(args[1], &min); err != nil {
+					return cty.UnknownVal(cty.Number), function.NewArgError(1, err)
+				}
+				if err := gocty.FromCtyValue(args[2], &max); err != nil {
+					return cty.UnknownVal(cty.Number), function.NewArgError(2, err)
+				}
+				if min > max {
+					return cty.UnknownVal(cty.Number), function.NewArgErrorf(2, "must not be less than min, %d", min)
+				}
+				b := r.sum(args[0].AsString())
+				n := new(big.Int).SetBytes(b[:])
+				span := new(big.Int).Sub(big.NewInt(max), big.NewInt(min))
+				n.Mod(n, span.Add(span, big.NewInt(1)))
+				return cty.NumberVal(new(big.Float).SetInt(n.Add(n, big.NewInt(min)))), nil
+			},
+		}),
+		"timestamp": function.New(&function.Spec{
+			Params: []function.Parameter{site},
+			Type:   function.StaticReturnType(cty.String),
+			Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+				return cty.StringVal(r.time.Format(time.RFC3339)), nil
+			},
+		}),
+	}
+}
+
+// NondeterministicCalls returns the names of the functions called in the
+// given files whose values may differ between loads of the same files,
+// sorted: uuid, random_integer and timestamp, even with a seed, and the
+// namespaced functions of plugins, which may read anything.
+func NondeterministicCalls(files []*hcl.File) []string {
+	called := map[string]bool{}
+	for _, file := range files {
+		body, ok := file.Body.(*hclsyntax.Body)
+		if !ok {
+			continue
+		}
+		hclsyntax.VisitAll(body, func(node hclsyntax.Node) hcl.Diagnostics {
+			switch call := node.(type) {
+			case *runValueExpr:
+				called[call.Name] = true
+			case *hclsyntax.FunctionCallExpr:
+				if strings.Contains(call.Name, namespaceSeparator) {
+					called[strings.Replace(call.Name, namespaceSeparator, "::", 1)] = true
+				}
+			}
+			return nil
+		})
+	}
+
+	names := make([]string, 0, len(called))
+	for name := range called {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// runValueExpr is a call of uuid, random_integer or timestamp. It passes the
+// site of the call to the function: where it is in the source, and the
+// values of the variables of the scopes it is evaluated in, like `each` and
+// `count`, or those of for expressions.
+type runValueExpr struct {
+	*hclsyntax.FunctionCallExpr
+}
+
+func (e *runValueExpr) Value(ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
+	params := runFunctionParams[e.Name]
+	if len(e.Args) != len(params) || e.ExpandFinal {
+		return cty.DynamicVal, hcl.Diagnostics{
+			{
+				Severity: hcl.DiagError,
+				Summary:  "Wrong number of function arguments",
+				Detail:   fmt.Sprintf("Function %q expects %d argument(s).", e.Name, len(params)),
+				Subject:  e.Range().Ptr(),
+			},
+		}
+	}
+
+	site := fmt.Sprintf("%s:%d", e.NameRange.Filename, e.NameRange.Start.Byte)
+	for scope := ctx; scope != nil && scope.Parent() != nil; scope = scope.Parent() {
+		names := make([]string, 0, len(scope.Variables))
+		for name := range scope.Variables {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			site += fmt.Sprintf(" %s=%#v", name, scope.Variables[name])
+		}
+	}
+
+	call := *e.FunctionCallExpr
+	call.Args = append([]hclsyntax.Expression{
+		&hclsyntax.LiteralValueExpr{Val: cty.StringVal(site), SrcRange: e.NameRange},
+	}, e.Args...)
+	return call.Value(ctx)
+}
@@ -0,0 +1,144 @@
+package datcfg
+
+import (
+	"sort"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hcl/hclsyntax"
+)
+
+// Readiness tells which inputs the cluster or a component still needs before
+// it can be applied.
+type Readiness struct {
+	// Address is "cluster" or the address of a component, see
+	// ComponentAddress. Components with `count` are checked once, and
+	// addressed as if they had none.
+	Address string
+	// Missing are the names of the variables the block refers to that have
+	// no value, neither given nor a default. Undeclared variables are
+	// missing too.
+	Missing []string
+	// Unknown are the names of those whose value is not known yet.
+	Unknown []string
+}
+
+// Ready reports whether the block has all its inputs.
+func (r Readiness) Ready() bool {
+	return len(r.Missing) == 0 && len(r.Unknown) == 0
+}
+
+// Readiness reports for the cluster and every component which of the
+// variables they refer to are missing or unknown. The references are found in
+// the syntax, also through locals, the cluster, the components they extend
+// and the defaults of their kind, so configs that don't evaluate yet because
+// of the missing values are checked too. The components are in declaration
+// order.
+func (l *Loader) Readiness() ([]Readiness, hcl.Diagnostics) {
+	result, included, includedBodies, diags := l.loadFiles()
+	if result == nil {
+		return nil, diags
+	}
+
+	var root configRoot
+	rootDiags := DecodeBody(hcl.MergeBodies(includedBodies), nil, &root)
+	diags = append(diags, rootDiags...)
+	if rootDiags.HasErrors() {
+		return nil, diags
+	}
+	_, extendsDiags := resolveExtends(root.Components)
+	diags = append(diags, extendsDiags...)
+	if extendsDiags.HasErrors() {
+		return nil, diags
+	}
+
+	defaultsDiags := applyDefaults(&root)
+	diags = append(diags, defaultsDiags...)
+	if defaultsDiags.HasErrors() {
+		return nil, diags
+	}
+
+	clusterRefs := bodyReferences(root.Cluster.ClusterConfig)
+	if isSet(root.Cluster.ForEach) {
+		clusterRefs = append(clusterRefs, root.Cluster.ForEach.Variables()...)
+	}
+	for _, partial := range root.ClusterConfigs {
+		clusterRefs = append(clusterRefs, bodyReferences(partial.Config)...)
+	}
+
+	r := readinessCheck{config: result, refs: referenceTraversals(included), clusterRefs: clusterRefs}
+	readiness := []Readiness{r.readiness("cluster", clusterRefs)}
+	addressed := make([]Component, len(root.Components))
+	for i, component := range root.Components {
+		addressed[i].Type = canonicalKindName(component.Type)
+		addressed[i].Name, _ = staticComponentName(component)
+	}
+	for i, component := range root.Components {
+		readiness = append(readiness, r.readiness(ComponentAddress(addressed, i), bodyReferences(component.Config)))
+	}
+	return readiness, diags
+}
+
+// bodyReferences returns the variable traversals of the given body, with
+// those of the bodies it extends. The meta-arguments of a component, which
+// are left in the body after decoding it, are included. Bodies of static
+// configs, like those from YAML, have none.
+func bodyReferences(body hcl.Body) []hcl.Traversal {
+	switch body := body.(type) {
+	case *hclsyntax.Body:
+		return bodyTraversals(body)
+	case extendedBody:
+		return append(bodyReferences(body.body), bodyReferences(body.base)...)
+	}
+	return nil
+}
+
+// readinessCheck finds the variables blocks refer to.
+type readinessCheck struct {
+	config *Config
+	// refs are the traversals of the locals, by graph node, see
+	// referenceTraversals.
+	refs        map[string][]hcl.Traversal
+	clusterRefs []hcl.Traversal
+}
+
+// readiness returns the readiness of the block with the given traversals,
+// following those of the locals and the cluster.
+func (r readinessCheck) readiness(address string, traversals []hcl.Traversal) Readiness {
+	vars := map[string]bool{}
+	visited := map[string]bool{}
+	for len(traversals) > 0 {
+		traversal := traversals[0]
+		traversals = traversals[1:]
+		node, ok := traversalAddress(traversal)
+		if traversal.RootName() == "cluster" {
+			// All attributes of the cluster come from the same block.
+			node, ok = "cluster", true
+		}
+		if !ok || visited[node] {
+			continue
+		}
+		visited[node] = true
+		switch traversal.RootName() {
+		case "var":
+			vars[node[len("var."):]] = true
+		case "local":
+			traversals = append(traversals, r.refs[node]...)
+		case "cluster":
+			traversals = append(traversals, r.clusterRefs...)
+		}
+	}
+
+	readiness := Readiness{Address: address}
+	for name := range vars {
+		val, ok := r.config.Variables[name]
+		switch {
+		case !ok:
+			readiness.Missing = append(readiness.Missing, name)
+		case !val.IsWhollyKnown():
+			readiness.Unknown = append(readiness.Unknown, name)
+		}
+	}
+	sort.Strings(readiness.Missing)
+	sort.Strings(readiness.Unknown)
+	return readiness
+}
@@ -0,0 +1,97 @@
+package datcfg
+
+import (
+	"io/fs"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/hcl2/hcl"
+)
+
+// Recording collects the inputs a loader reads, so that its run can be
+// reproduced elsewhere: loading the recorded files with the recorded
+// environment, see WithEnvironment, evaluates the same configuration.
+// Values fetched from value sources, like vault, are not recorded.
+type Recording struct {
+	mu sync.Mutex
+	// Files are the contents of the files read from the filesystem, by
+	// their path in it.
+	Files map[string][]byte
+	// Env are the environment variables the config files refer to through
+	// the `env` object, by name. Variables referred to with a computed name,
+	// like `env[var.name]`, are not recorded.
+	Env map[string]string
+}
+
+// WithRecording makes the loader record the files it reads and the
+// environment variables the configuration refers to into rec. The same
+// recording can be shared by several loads of the same filesystem.
+func WithRecording(rec *Recording) LoaderOption {
+	return func(l *Loader) {
+		l.recording = rec
+	}
+}
+
+// WithEnvironment makes the `env` object hold the given variables instead of
+// those of the process, like when replaying a Recording.
+func WithEnvironment(env map[string]string) LoaderOption {
+	return func(l *Loader) {
+		l.env = env
+	}
+}
+
+func (r *Recording) recordFile(name string, src []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.Files == nil {
+		r.Files = map[string][]byte{}
+	}
+	r.Files[name] = append([]byte(nil), src...)
+}
+
+// recordEnv records the environment variables referred to by the given
+// files that are set in env.
+func (r *Recording) recordEnv(files []*hcl.File, env map[string]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	names, _ := EnvReferences(files)
+	for _, name := range names {
+		if val, ok := env[name]; ok {
+			if r.Env == nil {
+				r.Env = map[string]string{}
+			}
+			r.Env[name] = val
+		}
+	}
+}
+
+// recordingFS records the files read from the wrapped filesystem.
+type recordingFS struct {
+	fs.FS
+	rec *Recording
+}
+
+func (r recordingFS) ReadFile(name string) ([]byte, error) {
+	src, err := fs.ReadFile(r.FS, name)
+	if err == nil {
+		r.rec.recordFile(name, src)
+	}
+	return src, err
+}
+
+// environment returns the variables of the `env` object.
+func (l *Loader) environment() map[string]string {
+	if l.env != nil {
+		return l.env
+	}
+	env := map[string]string{}
+	for _, kv := range os.Environ() {
+		name, val, ok := strings.Cut(kv, "=")
+		if !ok || name == "" {
+			continue
+		}
+		env[name] = val
+	}
+	return env
+}
@@ -0,0 +1,156 @@
+package datcfg
+
+import (
+	"fmt"
+	"path"
+	"reflect"
+	"strings"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// redactedValue is shown instead of the values that must not be shown.
+const redactedValue = "(sensitive)"
+
+// checkRedactPatterns validates the glob patterns of `settings { redact }`.
+func checkRedactPatterns(patterns []string) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid redact pattern",
+				Detail:   fmt.Sprintf("The redact pattern %q is not a valid glob pattern: %s.", pattern, err),
+			})
+		}
+	}
+	return diags
+}
+
+// Redacted reports whether the values of attributes, variables or locals
+// with the given name must be masked in output, because the name matches
+// one of the glob patterns of `settings { redact }`:
+//
+//	settings {
+//	  redact = ["*password*", "*token*"]
+//	}
+//
+// Names are matched regardless of case.
+func (c *Config) Redacted(name string) bool {
+	for _, pattern := range c.Redact {
+		if ok, _ := path.Match(strings.ToLower(pattern), strings.ToLower(name)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactValue returns val with the attributes and map elements whose names
+// are redacted masked, at any depth. Collections with masked elements are
+// turned into objects and tuples, since the masked elements are strings.
+func (c *Config) RedactValue(val cty.Value) cty.Value {
+	if len(c.Redact) == 0 {
+		return val
+	}
+	redacted, _ := c.redactValue(val)
+	return redacted
+}
+
+func (c *Config) redactValue(val cty.Value) (cty.Value, bool) {
+	ty := val.Type()
+	if !val.IsKnown() || val.IsNull() || !(ty.IsObjectType() || ty.IsMapType() || ty.IsListType() || ty.IsSetType() || ty.IsTupleType()) {
+		return val, false
+	}
+
+	changed := false
+	if ty.IsObjectType() || ty.IsMapType() {
+		attrs := map[string]cty.Value{}
+		for it := val.ElementIterator(); it.Next(); {
+			k, v := it.Element()
+			if c.Redacted(k.AsString()) {
+				attrs[k.AsString()], changed = cty.StringVal(redactedValue), true
+				continue
+			}
+			var elemChanged bool
+			attrs[k.AsString()], elemChanged = c.redactValue(v)
+			changed = changed || elemChanged
+		}
+		if !changed {
+			return val, false
+		}
+		return cty.ObjectVal(attrs), true
+	}
+
+	var elems []cty.Value
+	for _, v := range sortedElements(val) {
+		elem, elemChanged := c.redactValue(v)
+		elems = append(elems, elem)
+		changed = changed || elemChanged
+	}
+	if !changed {
+		return val, false
+	}
+	return cty.TupleVal(elems), true
+}
+
+// RedactValues returns the given variables, locals or values with those that
+// are sensitive or redacted masked, and the redacted attributes of the
+// others.
+func (c *Config) RedactValues(vals map[string]cty.Value) map[string]cty.Value {
+	masked := map[string]cty.Value{}
+	for name, val := range vals {
+		if c.Sensitive[name] || c.Redacted(name) {
+			val = cty.StringVal(redactedValue)
+		}
+		masked[name] = c.RedactValue(val)
+	}
+	return masked
+}
+
+// RedactOverrides returns the given overrides, like Config.Overrides, with
+// the values masked whose path has a redacted name or the name of a
+// sensitive variable, like `component.db.password`.
+func (c *Config) RedactOverrides(overrides map[string]string) map[string]string {
+	masked := map[string]string{}
+	for path, value := range overrides {
+		for _, name := range strings.Split(path, ".") {
+			if c.Sensitive[name] || c.Redacted(name) {
+				value = redactedValue
+				break
+			}
+		}
+		masked[path] = value
+	}
+	return masked
+}
+
+// FormatConfig is like the FormatConfig function, with the redacted
+// attributes masked.
+func (c *Config) FormatConfig(config interface{}) string {
+	return formatJSON(c.redactJSON(jsonValue(reflect.ValueOf(config))))
+}
+
+// redactJSON masks the redacted attributes of a value returned by jsonValue
+// or ctyJSONValue.
+func (c *Config) redactJSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		masked := map[string]interface{}{}
+		for name, elem := range v {
+			if c.Redacted(name) {
+				masked[name] = redactedValue
+				continue
+			}
+			masked[name] = c.redactJSON(elem)
+		}
+		return masked
+	case []interface{}:
+		masked := make([]interface{}, len(v))
+		for i, elem := range v {
+			masked[i] = c.redactJSON(elem)
+		}
+		return masked
+	}
+	return v
+}
@@ -0,0 +1,68 @@
+package datcfg
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+)
+
+// registrations numbers the runs of TestConcurrentRegistration, whose
+// functions can't be registered twice when it runs with -count.
+var registrations int32
+
+type hookedValue string
+
+// TestConcurrentRegistration registers functions and decode hooks from
+// several goroutines, like plugins discovered concurrently, while configs
+// are loaded. Run with -race.
+func TestConcurrentRegistration(t *testing.T) {
+	run := atomic.AddInt32(&registrations, 1)
+	fsys := fstest.MapFS{"cluster.datcfg": {Data: []byte(workersConfig)}}
+	constant := function.New(&function.Spec{
+		Type: function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			return cty.StringVal("plugin"), nil
+		},
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			namespace := fmt.Sprintf("plugin%d_%d", run, i)
+			if err := RegisterFunction(namespace, "name", constant); err != nil {
+				t.Error(err)
+			}
+			if err := RegisterContextFunction(namespace, "context_name", &ContextFunctionSpec{Type: function.StaticReturnType(cty.String)}); err != nil {
+				t.Error(err)
+			}
+			RegisterDecodeHook(reflect.TypeOf(hookedValue("")), func(val cty.Value) (interface{}, error) {
+				return hookedValue(val.AsString()), nil
+			})
+			if _, diags := NewLoader(fsys).Load(); diags.HasErrors() {
+				t.Error(diags)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < 8; i++ {
+		key := fmt.Sprintf("plugin%d_%d%sname", run, i, namespaceSeparator)
+		if _, ok := pluginFunctions[key]; !ok {
+			t.Errorf("function %s was not registered", key)
+		}
+		if _, ok := contextFunctions[fmt.Sprintf("plugin%d_%d%scontext_name", run, i, namespaceSeparator)]; !ok {
+			t.Errorf("context function %d was not registered", i)
+		}
+	}
+	if err := RegisterFunction(fmt.Sprintf("plugin%d_0", run), "name", constant); err == nil {
+		t.Error("a function was registered twice")
+	}
+}
@@ -0,0 +1,124 @@
+package datcfg
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hcl/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// renamedFrom evaluates the `renamed_from` argument of a variable block,
+// which lists the former names of the variable:
+//
+//	variable "worker_count" {
+//	  renamed_from = ["workers"]
+//	}
+//
+// Values given under a former name are used for the variable, with a
+// deprecation warning.
+func renamedFrom(v variableBlock) ([]string, hcl.Diagnostics) {
+	attr, ok := v.Default["renamed_from"]
+	if !ok {
+		return nil, nil
+	}
+	var names []string
+	diags := decodeExpression(attr.Expr, attr.Name, nil, reflect.ValueOf(&names).Elem())
+	return names, diags
+}
+
+// renamedValue returns the value given for the variable under one of its
+// former names, if there is one and none under its current name.
+func renamedValue(v variableBlock, userVals map[string]cty.Value) (cty.Value, bool, hcl.Diagnostics) {
+	oldNames, diags := renamedFrom(v)
+	if diags.HasErrors() {
+		return cty.NilVal, false, diags
+	}
+
+	_, hasCurrent := userVals[v.Name]
+	var val cty.Value
+	found := false
+	for _, oldName := range oldNames {
+		oldVal, ok := userVals[oldName]
+		if !ok {
+			continue
+		}
+		detail := fmt.Sprintf("The variable %q was renamed to %q, its value is used under the new name. Run the fix command to rename it in the values files.", oldName, v.Name)
+		switch {
+		case hasCurrent:
+			detail = fmt.Sprintf("The variable %q was renamed to %q, which has a value too, so the value for %q is ignored.", oldName, v.Name, oldName)
+		case found:
+			detail = fmt.Sprintf("The variable %q was renamed to %q, which already has a value under another former name, so the value for %q is ignored.", oldName, v.Name, oldName)
+		default:
+			val, found = oldVal, true
+		}
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagWarning,
+			Summary:  "Deprecated variable name",
+			Detail:   detail,
+		})
+	}
+	return val, found, diags
+}
+
+// VariableRenames returns the new name of every former variable name listed
+// in a `renamed_from` argument of the given files, like "workers" for
+// "worker_count". Arguments that are not a static list of strings are
+// skipped, loading reports them.
+func VariableRenames(files []*hcl.File) map[string]string {
+	renames := map[string]string{}
+	for _, block := range topLevelBlocks(files) {
+		if block.Type != "variable" || len(block.Labels) == 0 {
+			continue
+		}
+		attr, ok := block.Body.Attributes["renamed_from"]
+		if !ok {
+			continue
+		}
+		var oldNames []string
+		if diags := decodeExpression(attr.Expr, attr.Name, nil, reflect.ValueOf(&oldNames).Elem()); diags.HasErrors() {
+			continue
+		}
+		for _, oldName := range oldNames {
+			renames[oldName] = block.Labels[0]
+		}
+	}
+	return renames
+}
+
+// ValuesRenameFixes returns the edits renaming the values and sections set
+// under a former variable name in the given values file. Values whose new
+// name is set too are left alone.
+func ValuesRenameFixes(file *hcl.File, renames map[string]string) []SourceEdit {
+	body, ok := file.Body.(*hclsyntax.Body)
+	if !ok {
+		return nil
+	}
+
+	defined := map[string]bool{}
+	for name := range body.Attributes {
+		defined[name] = true
+	}
+	for _, block := range body.Blocks {
+		defined[block.Type] = true
+	}
+
+	var edits []SourceEdit
+	rename := func(oldName string, rng hcl.Range) {
+		newName, ok := renames[oldName]
+		if !ok || defined[newName] {
+			// If both are set, a human has to decide which one wins.
+			return
+		}
+		edits = append(edits, SourceEdit{Range: rng, Replacement: []byte(newName)})
+		defined[newName] = true
+	}
+	for _, attr := range sortedAttributes(body) {
+		rename(attr.Name, attr.NameRange)
+	}
+	for _, block := range body.Blocks {
+		rename(block.Type, block.TypeRange)
+	}
+	return edits
+}
@@ -0,0 +1,113 @@
+package datcfg
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// WithRestrictedMode makes the loader safe to use on untrusted configs, like
+// those submitted to a multi-tenant service. Everything that can reach the
+// host is denied:
+//
+//   - functions registered with RegisterFunction, which may read files or
+//     the environment, are not available to expressions, and neither is the
+//     `env` object;
+//   - `variable_source` blocks fail, since sources talk to external stores
+//     using credentials of the host;
+//   - `post_decode` hooks are an error, since they run commands;
+//   - the `path.cwd` attribute is an error, since it tells where on the
+//     host the config is.
+//
+// The built-in functions, which are all pure, and those passed to the loader
+// with WithFunction remain available.
+func WithRestrictedMode() LoaderOption {
+	return func(l *Loader) {
+		l.restricted = true
+	}
+}
+
+// denyingMode returns the name of the mode denying access to the host the
+// loader is in, or "" if none.
+func (l *Loader) denyingMode() string {
+	switch {
+	case l.hermetic:
+		return "hermetic"
+	case l.restricted:
+		return "restricted"
+	}
+	return ""
+}
+
+// deniedSource replaces the value sources in restricted and hermetic mode.
+type deniedSource struct {
+	mode string
+}
+
+func (s deniedSource) Values(config map[string]cty.Value) (map[string]cty.Value, error) {
+	return nil, fmt.Errorf("variable sources are not available in %s mode", s.mode)
+}
+
+// restrictedSources returns the value sources of the given loader, with all
+// of them denied in restricted and hermetic mode.
+func restrictedSources(l *Loader) map[string]ValueSource {
+	mode := l.denyingMode()
+	if mode == "" {
+		return l.valueSources
+	}
+	sources := map[string]ValueSource{}
+	for name := range l.valueSources {
+		sources[name] = deniedSource{mode: mode}
+	}
+	return sources
+}
+
+// explainDenied rewrites the details of diagnostics about references to the
+// `env` object and calls to functions that only exist outside of restricted
+// and hermetic mode.
+func (l *Loader) explainDenied(diag *hcl.Diagnostic) {
+	mode := l.denyingMode()
+	if diag.Summary == "Unknown variable" && strings.HasPrefix(diag.Detail, `There is no variable named "env".`) {
+		diag.Summary = "Environment not available"
+		diag.Detail = fmt.Sprintf("The env object is not available in %s mode, since it exposes the environment of the host.", mode)
+		return
+	}
+	if diag.Summary != "Call to unknown function" {
+		return
+	}
+	var denied []string
+	functionsMu.RLock()
+	for key := range pluginFunctions {
+		denied = append(denied, key)
+	}
+	functionsMu.RUnlock()
+	if l.hermetic {
+		for key := range l.functions {
+			denied = append(denied, key)
+		}
+	}
+	for _, key := range denied {
+		if strings.HasPrefix(diag.Detail, fmt.Sprintf("There is no function named %q.", key)) {
+			diag.Summary = "Function not available"
+			name := strings.Replace(key, namespaceSeparator, "::", 1)
+			diag.Detail = fmt.Sprintf("The function %q is not available in %s mode, since it may access the host.", name, mode)
+			return
+		}
+	}
+}
+
+// deniedHooks reports the hooks configured in restricted and hermetic mode.
+func deniedHooks(hooks *HooksSettings, mode string) hcl.Diagnostics {
+	if len(hooks.PostDecode) == 0 {
+		return nil
+	}
+	return hcl.Diagnostics{
+		{
+			Severity: hcl.DiagError,
+			Summary:  "Hooks not available",
+			Detail:   fmt.Sprintf("The post_decode hooks can't be run in %s mode, since they run commands on the host.", mode),
+		},
+	}
+}
@@ -0,0 +1,33 @@
+package datcfg
+
+import (
+	"sort"
+
+	"github.com/hashicorp/hcl2/hcl"
+)
+
+// SourceEdit replaces the bytes of a range in a config file. Rewrites are
+// done on the original source so that comments and formatting outside the
+// edited ranges are preserved as-is.
+type SourceEdit struct {
+	Range       hcl.Range
+	Replacement []byte
+}
+
+// ApplyEdits applies the given non-overlapping edits to src.
+func ApplyEdits(src []byte, edits []SourceEdit) []byte {
+	sorted := make([]SourceEdit, len(edits))
+	copy(sorted, edits)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Range.Start.Byte < sorted[j].Range.Start.Byte
+	})
+
+	var out []byte
+	last := 0
+	for _, edit := range sorted {
+		out = append(out, src[last:edit.Range.Start.Byte]...)
+		out = append(out, edit.Replacement...)
+		last = edit.Range.End.Byte
+	}
+	return append(out, src[last:]...)
+}
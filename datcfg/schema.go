@@ -0,0 +1,15 @@
+package datcfg
+
+import (
+	"github.com/hashicorp/hcl2/hcl/hclsyntax"
+)
+
+// BlockAttributes returns the sorted names of the attributes the body of the
+// given top-level block accepts, or nil if its schema is not known.
+func BlockAttributes(block *hclsyntax.Block) []string {
+	ty := blockConfigType(block)
+	if ty == nil {
+		return nil
+	}
+	return sortedNames(getDecodeFieldTags(ty).Attributes)
+}
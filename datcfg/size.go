@@ -0,0 +1,64 @@
+package datcfg
+
+import (
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Estimated sizes in bytes of the parts of a cty value on a 64-bit platform:
+// every value is a type and a raw value, both interfaces, strings and slices
+// have a header, numbers are a big.Float and map entries have a share of the
+// buckets of their map.
+const (
+	valueSize        = 32
+	stringSize       = 16
+	sliceSize        = 24
+	numberSize       = 64
+	mapSize          = 48
+	mapEntryOverhead = 24
+)
+
+// ValueSize estimates the number of bytes the given value takes in memory,
+// including all of its elements and attributes. It is meant to find the
+// values that blow up the memory of an evaluation, like a list of a million
+// objects, not to account for the memory exactly.
+func ValueSize(val cty.Value) int64 {
+	var size int64
+	cty.Walk(val, func(path cty.Path, v cty.Value) (bool, error) {
+		size += valueSize
+		if v.IsNull() || !v.IsKnown() {
+			return true, nil
+		}
+		ty := v.Type()
+		switch {
+		case ty == cty.String:
+			size += stringSize + int64(len(v.AsString()))
+		case ty == cty.Number:
+			size += numberSize
+		case ty.IsListType() || ty.IsSetType() || ty.IsTupleType():
+			size += sliceSize
+		case ty.IsMapType():
+			size += mapSize
+			for it := v.ElementIterator(); it.Next(); {
+				key, _ := it.Element()
+				size += stringSize + int64(len(key.AsString())) + mapEntryOverhead
+			}
+		case ty.IsObjectType():
+			size += mapSize
+			for name := range ty.AttributeTypes() {
+				size += stringSize + int64(len(name)) + mapEntryOverhead
+			}
+		}
+		return true, nil
+	})
+	return size
+}
+
+// Size estimates the number of bytes the decoded config and the outputs of
+// the component take in memory, as values, see ValueSize.
+func (c Component) Size() int64 {
+	size := ValueSize(configValue(c.Config))
+	for name, val := range c.Outputs {
+		size += stringSize + int64(len(name)) + mapEntryOverhead + ValueSize(val)
+	}
+	return size
+}
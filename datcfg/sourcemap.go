@@ -0,0 +1,107 @@
+package datcfg
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/hashicorp/hcl2/gohcl"
+	"github.com/hashicorp/hcl2/hcl"
+)
+
+// sourceMap records where the attributes and nested blocks decoded into a
+// struct were defined, keyed by a pointer to the struct and then by the
+// attribute or block type name.
+type sourceMap map[interface{}]map[string]hcl.Range
+
+// RangeOf returns the range in the config of the attribute or nested block
+// decoded into the given field of a component config, as of the last Load.
+// The config can also be a pointer to a struct decoded from a nested block.
+// The field is given by its name in the config, like "worker_count", or by
+// the name of the Go struct field. It returns the zero range if there is no
+// such field or it was not set in the config.
+//
+// It lets components point their own errors at the config, like:
+//
+//	rng := loader.RangeOf(config, "port")
+//	diags = append(diags, &hcl.Diagnostic{..., Subject: &rng})
+func (l *Loader) RangeOf(config interface{}, field string) hcl.Range {
+	ranges := l.ranges[config]
+	if rng, ok := ranges[field]; ok {
+		return rng
+	}
+
+	ty := reflect.TypeOf(config)
+	if ty == nil || ty.Kind() != reflect.Ptr || ty.Elem().Kind() != reflect.Struct {
+		return hcl.Range{}
+	}
+	if f, ok := ty.Elem().FieldByName(field); ok {
+		name := f.Tag.Get("hcl")
+		if comma := strings.Index(name, ","); comma != -1 {
+			name = name[:comma]
+		}
+		return ranges[name]
+	}
+	return hcl.Range{}
+}
+
+// record adds the ranges of the attributes and nested blocks of the given
+// body, as decoded into val, which must be a pointer to a struct. Problems
+// with the body are ignored, since decoding reported them.
+func (m sourceMap) record(body hcl.Body, val interface{}) {
+	rv := reflect.ValueOf(val)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return
+	}
+	m.recordStruct(body, rv.Elem())
+}
+
+func (m sourceMap) recordStruct(body hcl.Body, val reflect.Value) {
+	schema, _ := gohcl.ImpliedBodySchema(val.Addr().Interface())
+	content, leftovers, _ := body.PartialContent(schema)
+	if content == nil {
+		return
+	}
+
+	ranges := map[string]hcl.Range{}
+	m[val.Addr().Interface()] = ranges
+	for name, attr := range content.Attributes {
+		ranges[name] = attr.Range
+	}
+
+	tags := getDecodeFieldTags(val.Type())
+	if tags.Remain != nil && val.Field(*tags.Remain).Kind() == reflect.Struct {
+		m.recordStruct(leftovers, val.Field(*tags.Remain))
+	}
+
+	blocksByType := content.Blocks.ByType()
+	for typeName, fieldIdx := range tags.Blocks {
+		blocks := blocksByType[typeName]
+		if len(blocks) == 0 {
+			continue
+		}
+		ranges[typeName] = blocks[0].DefRange
+
+		fieldV := val.Field(fieldIdx)
+		if fieldV.Kind() != reflect.Slice {
+			m.recordBlock(blocks[0], fieldV)
+			continue
+		}
+		for i, block := range blocks {
+			if i < fieldV.Len() {
+				m.recordBlock(block, fieldV.Index(i))
+			}
+		}
+	}
+}
+
+func (m sourceMap) recordBlock(block *hcl.Block, fieldV reflect.Value) {
+	if fieldV.Kind() == reflect.Ptr {
+		if fieldV.IsNil() {
+			return
+		}
+		fieldV = fieldV.Elem()
+	}
+	if fieldV.Kind() == reflect.Struct && fieldV.Type() != blockType {
+		m.recordStruct(block.Body, fieldV)
+	}
+}
@@ -0,0 +1,131 @@
+package datcfg
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// ValueSource fetches variable values from an external store, like Vault,
+// SSM or Consul. Sources are used by `variable_source` blocks:
+//
+//	variable_source "vault" {
+//	  path = "secret/data/cluster"
+//	}
+//
+// Values from a source take precedence over variable defaults, but not over
+// the values files. They are always treated as sensitive.
+type ValueSource interface {
+	// Values returns the values found with the given configuration, which
+	// holds the attributes of the variable_source block.
+	Values(config map[string]cty.Value) (map[string]cty.Value, error)
+}
+
+// WithValueSource makes the given source available to `variable_source`
+// blocks with the given label, in place of a registered one with that label.
+func WithValueSource(name string, source ValueSource) LoaderOption {
+	return func(l *Loader) {
+		l.valueSources[name] = source
+	}
+}
+
+var (
+	registeredSourcesMu sync.Mutex
+	registeredSources   = map[string]ValueSource{}
+)
+
+// RegisterValueSource makes the given source available to the
+// `variable_source` blocks of all loaders created afterwards, for sources
+// included by the files of an optional feature, like the Vault source of the
+// command line tool. Registering a name twice is an error.
+func RegisterValueSource(name string, source ValueSource) error {
+	registeredSourcesMu.Lock()
+	defer registeredSourcesMu.Unlock()
+	if _, exists := registeredSources[name]; exists {
+		return fmt.Errorf("value source %q is already registered", name)
+	}
+	registeredSources[name] = source
+	return nil
+}
+
+// MustRegisterValueSource is like RegisterValueSource, but panics if the
+// source can't be registered, for use in init functions.
+func MustRegisterValueSource(name string, source ValueSource) {
+	if err := RegisterValueSource(name, source); err != nil {
+		panic(err)
+	}
+}
+
+// registeredValueSources returns a copy of the registered value sources.
+func registeredValueSources() map[string]ValueSource {
+	registeredSourcesMu.Lock()
+	defer registeredSourcesMu.Unlock()
+	sources := make(map[string]ValueSource, len(registeredSources))
+	for name, source := range registeredSources {
+		sources[name] = source
+	}
+	return sources
+}
+
+type valueSourceBlock struct {
+	Name   string   `hcl:"name,label"`
+	Config hcl.Body `hcl:",remain"`
+}
+
+// HasValueSources reports whether the given files declare variable_source
+// blocks, whose values are fetched from outside the files on every load.
+func HasValueSources(files []*hcl.File) bool {
+	for _, block := range topLevelBlocks(files) {
+		if block.Type == "variable_source" {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchSourceValues returns the values of all variable_source blocks. When
+// several sources return the same value, the one declared last wins.
+func fetchSourceValues(blocks []valueSourceBlock, sources map[string]ValueSource) (map[string]cty.Value, hcl.Diagnostics) {
+	var diags hcl.Diagnostics
+	vals := map[string]cty.Value{}
+	for _, block := range blocks {
+		subject := block.Config.MissingItemRange().Ptr()
+		source, ok := sources[block.Name]
+		if !ok {
+			return nil, append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Unknown variable source",
+				Detail:   fmt.Sprintf("There is no variable source named %q.", block.Name),
+				Subject:  subject,
+			})
+		}
+
+		attrs, attrsDiags := block.Config.JustAttributes()
+		diags = append(diags, attrsDiags...)
+		config := map[string]cty.Value{}
+		for _, name := range sortedAttrNames(attrs) {
+			val, valDiags := attrs[name].Expr.Value(nil)
+			diags = append(diags, valDiags...)
+			config[name] = val
+		}
+		if diags.HasErrors() {
+			return nil, diags
+		}
+
+		sourceVals, err := source.Values(config)
+		if err != nil {
+			return nil, append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Failed to fetch variable values",
+				Detail:   fmt.Sprintf("The variable source %q failed: %s.", block.Name, err),
+				Subject:  subject,
+			})
+		}
+		for name, val := range sourceVals {
+			vals[name] = val
+		}
+	}
+	return vals, diags
+}
@@ -0,0 +1,132 @@
+package datcfg
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// WithStrictValues makes the loader check all variable values against the
+// declared variables in a single pass, before anything is evaluated with
+// them, and report every problem at once:
+//
+//   - values for variables that are not declared, like misspelled names;
+//   - values that don't convert to the type of their variable;
+//   - variables without a default that have no value.
+//
+// Without it, values for undeclared variables are ignored and the other
+// problems are reported one at a time, when evaluation gets to them.
+func WithStrictValues() LoaderOption {
+	return func(l *Loader) {
+		l.strictValues = true
+	}
+}
+
+// checkStrictValues checks the user values against the given variables, as
+// requested by WithStrictValues. Variables with a value from a source are
+// not missing. The ranges are those of the names the values are set with in
+// the values files.
+func checkStrictValues(body hcl.Body, variables []variableBlock, userVals, sourceVals map[string]cty.Value, ranges map[string]hcl.Range) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+
+	declared := map[string]bool{}
+	var names []string
+	formerNames := map[string][]string{}
+	for _, v := range variables {
+		declared[v.Name] = true
+		names = append(names, v.Name)
+		oldNames, renameDiags := renamedFrom(v)
+		diags = append(diags, renameDiags...)
+		for _, oldName := range oldNames {
+			declared[oldName] = true
+		}
+		formerNames[v.Name] = oldNames
+	}
+
+	var undeclared []string
+	for name := range userVals {
+		if !declared[name] {
+			undeclared = append(undeclared, name)
+		}
+	}
+	sort.Strings(undeclared)
+	for _, name := range undeclared {
+		detail := fmt.Sprintf("A value is set for %q, but no variable with that name is declared.", name)
+		if suggestion, ok := nearestName(name, names); ok {
+			detail += fmt.Sprintf(" Did you mean %q?", suggestion)
+		}
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Value for undeclared variable",
+			Detail:   detail,
+			Subject:  rangePtr(ranges, name),
+		})
+	}
+
+	declRanges := variableRanges(body)
+	for _, v := range variables {
+		name := v.Name
+		val, ok := userVals[name]
+		for _, oldName := range formerNames[v.Name] {
+			if ok {
+				break
+			}
+			val, ok = userVals[oldName]
+			name = oldName
+		}
+		if !ok {
+			_, fromSource := sourceVals[v.Name]
+			if _, hasDefault := v.Default["default"]; !fromSource && !hasDefault {
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Missing value for variable",
+					Detail:   fmt.Sprintf("The variable %q has no default, so the values files must set it.", v.Name),
+					Subject:  rangePtr(declRanges, v.Name),
+				})
+			}
+			continue
+		}
+
+		typeAttr, ok := v.Default["type"]
+		if !ok {
+			continue
+		}
+		ty, typeDiags := typeConstraint(typeAttr.Expr)
+		diags = append(diags, typeDiags...)
+		if typeDiags.HasErrors() {
+			continue
+		}
+		subject, ok := ranges[name]
+		if !ok {
+			subject = typeAttr.Expr.Range()
+		}
+		_, convDiags := convertVariable(v.Name, val, ty, subject)
+		diags = append(diags, convDiags...)
+	}
+	return diags
+}
+
+// variableRanges returns the ranges of the headers of the variable blocks in
+// the given body, by name.
+func variableRanges(body hcl.Body) map[string]hcl.Range {
+	content, _, _ := body.PartialContent(&hcl.BodySchema{
+		Blocks: []hcl.BlockHeaderSchema{{Type: "variable", LabelNames: []string{"name"}}},
+	})
+	ranges := map[string]hcl.Range{}
+	if content == nil {
+		return ranges
+	}
+	for _, block := range content.Blocks {
+		ranges[block.Labels[0]] = block.DefRange
+	}
+	return ranges
+}
+
+func rangePtr(ranges map[string]hcl.Range, name string) *hcl.Range {
+	if rng, ok := ranges[name]; ok {
+		return &rng
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package datcfg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+)
+
+// timeoutsBlock is the `timeouts` meta block accepted by every component,
+// limiting how long each phase of its lifecycle may take:
+//
+//	timeouts {
+//	  decode = "5s"
+//	  apply  = "10m"
+//	}
+type timeoutsBlock struct {
+	Decode hcl.Expression `hcl:"decode,optional"`
+	Apply  hcl.Expression `hcl:"apply,optional"`
+}
+
+// ComponentTimeouts are the time limits of the lifecycle phases of a
+// component. Zero means no limit.
+type ComponentTimeouts struct {
+	Decode time.Duration
+	// Apply limits every apply attempt separately, so that a retry gets
+	// the full time again.
+	Apply time.Duration
+}
+
+// decodeTimeouts evaluates the `timeouts` meta block of a component block.
+func decodeTimeouts(component componentBlock, ctx *hcl.EvalContext) (ComponentTimeouts, hcl.Diagnostics) {
+	var timeouts ComponentTimeouts
+	if component.Timeouts == nil {
+		return timeouts, nil
+	}
+
+	var diags hcl.Diagnostics
+	for _, phase := range []struct {
+		name string
+		expr hcl.Expression
+		dst  *time.Duration
+	}{
+		{"decode", component.Timeouts.Decode, &timeouts.Decode},
+		{"apply", component.Timeouts.Apply, &timeouts.Apply},
+	} {
+		if !isSet(phase.expr) {
+			continue
+		}
+		var s string
+		exprDiags := decodeExpression(phase.expr, phase.name, ctx, reflect.ValueOf(&s).Elem())
+		diags = append(diags, exprDiags...)
+		if exprDiags.HasErrors() {
+			continue
+		}
+
+		d, err := parseDuration(s)
+		if err == nil && d <= 0 {
+			err = fmt.Errorf("the timeout must be positive")
+		}
+		if err != nil {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid timeout",
+				Detail:   fmt.Sprintf("Invalid %s timeout: %s.", phase.name, err),
+				Subject:  phase.expr.Range().Ptr(),
+			})
+			continue
+		}
+		*phase.dst = d
+	}
+	return timeouts, diags
+}
+
+// decodeWithin runs the given decode function with a child of ctx, waiting
+// for it at most the given time, if it is not zero. Once the time is up, the
+// functions of the child fail, so that a decode running out of time stops at
+// its next function call instead of evaluating the rest of the body in the
+// background. Its result is dropped, and the returned bool is false.
+// Expressions without function calls, and calls in progress, are not
+// interrupted.
+func decodeWithin(timeout time.Duration, ctx *hcl.EvalContext, decode func(ctx *hcl.EvalContext) hcl.Diagnostics) (hcl.Diagnostics, bool) {
+	if timeout == 0 {
+		return decode(ctx), true
+	}
+
+	deadline, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	done := make(chan hcl.Diagnostics, 1)
+	go func() {
+		done <- decode(deadlineContext(deadline, ctx))
+	}()
+	select {
+	case diags := <-done:
+		// The calls failing at the deadline are reported as a timeout.
+		return diags, deadline.Err() == nil
+	case <-deadline.Done():
+		return nil, false
+	}
+}
+
+// deadlineContext returns a child of ctx with all functions available in it
+// failing once the deadline is done.
+func deadlineContext(deadline context.Context, ctx *hcl.EvalContext) *hcl.EvalContext {
+	if ctx == nil {
+		return nil
+	}
+	functions := map[string]function.Function{}
+	for scope := ctx; scope != nil; scope = scope.Parent() {
+		for name, fn := range scope.Functions {
+			if _, shadowed := functions[name]; !shadowed {
+				functions[name] = withDeadline(deadline, fn, errDecodeTimeout)
+			}
+		}
+	}
+	child := ctx.NewChild()
+	child.Functions = functions
+	return child
+}
+
+// withDeadline returns fn failing with err once the deadline is done, before
+// and after every call.
+func withDeadline(deadline context.Context, fn function.Function, err error) function.Function {
+	return function.New(&function.Spec{
+		Params:   fn.Params(),
+		VarParam: fn.VarParam(),
+		Type:     fn.ReturnTypeForValues,
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			if deadline.Err() != nil {
+				return cty.DynamicVal, err
+			}
+			val, callErr := fn.Call(args)
+			if deadline.Err() != nil {
+				return cty.DynamicVal, err
+			}
+			return val, callErr
+		},
+	})
+}
+
+// errDecodeTimeout is the error of the function calls of a decode that ran
+// out of time, which is reported as timeoutExceeded.
+var errDecodeTimeout = errors.New("the decode did not finish within its timeout")
+
+// applyWithin runs a single apply attempt with a context whose deadline is
+// the given timeout, if it is not zero. An attempt that returns after its
+// deadline failed, whatever the applier reported.
+func applyWithin(ctx context.Context, timeout time.Duration, address string, applier Applier) hcl.Diagnostics {
+	if timeout == 0 {
+		return applier.Apply(ctx)
+	}
+
+	attemptCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	diags := applier.Apply(attemptCtx)
+	if errors.Is(attemptCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+		diags = append(diags, timeoutExceeded(address, "apply", timeout, nil))
+	}
+	return diags
+}
+
+// timeoutExceeded returns the diagnostic of a component that didn't finish
+// the given phase in time.
+func timeoutExceeded(address, phase string, timeout time.Duration, subject *hcl.Range) *hcl.Diagnostic {
+	return &hcl.Diagnostic{
+		Severity: hcl.DiagError,
+		Summary:  "Timeout exceeded",
+		Detail:   fmt.Sprintf("The %s of component %q did not finish within its timeout of %s.", phase, address, timeout),
+		Subject:  subject,
+	}
+}
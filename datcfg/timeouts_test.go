@@ -0,0 +1,64 @@
+package datcfg
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+)
+
+// TestDecodeTimeoutStopsEvaluation checks that a decode running out of time
+// stops calling functions, instead of evaluating the rest of its body in the
+// background.
+func TestDecodeTimeoutStopsEvaluation(t *testing.T) {
+	var calls int32
+	slow := function.New(&function.Spec{
+		Params: []function.Parameter{{Name: "i", Type: cty.Number}},
+		Type:   function.StaticReturnType(cty.Number),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			atomic.AddInt32(&calls, 1)
+			time.Sleep(20 * time.Millisecond)
+			return args[0], nil
+		},
+	})
+	items := make([]string, 50)
+	for i := range items {
+		items[i] = "1"
+	}
+	fsys := fstest.MapFS{"cluster.datcfg": {Data: []byte(workersConfig + `
+component "slow" {
+  timeouts {
+    decode = "50ms"
+  }
+  values = [for i in [` + strings.Join(items, ", ") + `] : test::slow(i)]
+}
+`)}}
+
+	_, diags := NewLoader(fsys, WithUnknownComponents(), WithFunction("test", "slow", slow)).Load()
+	if !diags.HasErrors() || diags[len(diags)-1].Summary != "Timeout exceeded" {
+		t.Fatalf("got %v, want the decode to time out", diags)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if n := atomic.LoadInt32(&calls); n > 5 {
+		t.Errorf("the function was called %d times, want the calls to stop at the timeout", n)
+	}
+}
+
+func TestLoadContextStopsEvaluation(t *testing.T) {
+	fsys := fstest.MapFS{"cluster.datcfg": {Data: []byte(workersConfig + `
+locals {
+  zone = upper("a")
+}
+`)}}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, diags := NewLoader(fsys, WithContext(ctx)).Load()
+	if !diags.HasErrors() || !strings.Contains(diags.Error(), "the load was stopped") {
+		t.Errorf("got %v, want the function call to fail", diags)
+	}
+}
@@ -0,0 +1,77 @@
+package datcfg
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hcl/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// tryExpr is a call of the try or can function. Unlike other functions,
+// they need their arguments unevaluated, to catch the errors of evaluating
+// them:
+//
+//	port = try(var.settings.port, 8080)
+//	tls  = can(var.settings.certificate)
+//
+// try returns the value of the first argument that evaluates without
+// errors, can returns whether its single argument does. Both are unknown
+// while the argument they depend on is unknown. The embedded call keeps
+// the expression walkable like any other.
+type tryExpr struct {
+	*hclsyntax.FunctionCallExpr
+}
+
+func (e *tryExpr) Value(ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
+	if e.Name == "can" {
+		return e.canValue(ctx)
+	}
+
+	if len(e.Args) == 0 {
+		return cty.DynamicVal, e.callError("At least one argument is required.")
+	}
+	var failures []string
+	for _, arg := range e.Args {
+		val, diags := arg.Value(ctx)
+		if diags.HasErrors() {
+			for _, diag := range diags.Errs() {
+				failures = append(failures, "- "+diag.Error())
+			}
+			continue
+		}
+		if !val.IsWhollyKnown() {
+			// Whether a later argument is needed depends on the value, so
+			// the result is only known once it is.
+			return cty.DynamicVal, diags
+		}
+		return val, diags
+	}
+	return cty.DynamicVal, e.callError("No argument evaluated without errors:\n" + strings.Join(failures, "\n"))
+}
+
+func (e *tryExpr) canValue(ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
+	if len(e.Args) != 1 {
+		return cty.UnknownVal(cty.Bool), e.callError("Exactly one argument is required.")
+	}
+	val, diags := e.Args[0].Value(ctx)
+	if diags.HasErrors() {
+		return cty.False, nil
+	}
+	if !val.IsWhollyKnown() {
+		return cty.UnknownVal(cty.Bool), nil
+	}
+	return cty.True, nil
+}
+
+func (e *tryExpr) callError(detail string) hcl.Diagnostics {
+	return hcl.Diagnostics{
+		{
+			Severity: hcl.DiagError,
+			Summary:  "Error in function call",
+			Detail:   fmt.Sprintf("Call to function %q failed. %s", e.Name, detail),
+			Subject:  e.Range().Ptr(),
+		},
+	}
+}
@@ -0,0 +1,227 @@
+package datcfg
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+	"github.com/zclconf/go-cty/cty/function"
+)
+
+// typeConstraint evaluates the `type` argument of a variable block, which is
+// written like in Terraform:
+//
+//	type = set(string)
+//	type = tuple([string, number])
+//	type = object({ name = string, ports = list(number) })
+//
+// The primitive types are string, number and bool, and any accepts every
+// value. The older quoted names, like "string" or "list", are accepted too.
+// Values are converted to the constraint, so that lists in values files can
+// be given to set and tuple variables.
+func typeConstraint(expr hcl.Expression) (cty.Type, hcl.Diagnostics) {
+	invalid := func(detail string) (cty.Type, hcl.Diagnostics) {
+		return cty.DynamicPseudoType, hcl.Diagnostics{
+			{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid type specification",
+				Detail:   detail,
+				Subject:  expr.Range().Ptr(),
+			},
+		}
+	}
+
+	// Quoted type names are the older syntax, where "list" and "map" take
+	// elements of any type.
+	if val, valDiags := expr.Value(nil); !valDiags.HasErrors() && val.Type().Equals(cty.String) && val.IsKnown() && !val.IsNull() {
+		switch val.AsString() {
+		case "string":
+			return cty.String, nil
+		case "number":
+			return cty.Number, nil
+		case "bool":
+			return cty.Bool, nil
+		case "list":
+			return cty.List(cty.DynamicPseudoType), nil
+		case "map":
+			return cty.Map(cty.DynamicPseudoType), nil
+		}
+		return invalid(fmt.Sprintf("There is no type named %q. Types are written without quotes, like string or list(string).", val.AsString()))
+	}
+
+	switch hcl.ExprAsKeyword(expr) {
+	case "string":
+		return cty.String, nil
+	case "number":
+		return cty.Number, nil
+	case "bool":
+		return cty.Bool, nil
+	case "any":
+		return cty.DynamicPseudoType, nil
+	case "list", "set", "map", "tuple", "object":
+		return invalid(fmt.Sprintf("The %s type needs its element types, like %s.", hcl.ExprAsKeyword(expr), typeExample(hcl.ExprAsKeyword(expr))))
+	case "":
+	default:
+		return invalid(fmt.Sprintf("There is no type named %q. The primitive types are string, number and bool.", hcl.ExprAsKeyword(expr)))
+	}
+
+	call, diags := hcl.ExprCall(expr)
+	if diags.HasErrors() {
+		return invalid("A type is either a primitive type, like string, or a collection or structural type, like list(string).")
+	}
+	if len(call.Arguments) != 1 {
+		return invalid(fmt.Sprintf("The %s type takes exactly one argument, like %s.", call.Name, typeExample(call.Name)))
+	}
+	arg := call.Arguments[0]
+
+	switch call.Name {
+	case "list", "set", "map":
+		elemTy, diags := typeConstraint(arg)
+		if diags.HasErrors() {
+			return cty.DynamicPseudoType, diags
+		}
+		switch call.Name {
+		case "list":
+			return cty.List(elemTy), nil
+		case "set":
+			return cty.Set(elemTy), nil
+		default:
+			return cty.Map(elemTy), nil
+		}
+	case "tuple":
+		elemExprs, diags := hcl.ExprList(arg)
+		if diags.HasErrors() {
+			return invalid(fmt.Sprintf("The tuple type takes a list of element types, like %s.", typeExample("tuple")))
+		}
+		elemTys := make([]cty.Type, len(elemExprs))
+		for i, elemExpr := range elemExprs {
+			elemTy, elemDiags := typeConstraint(elemExpr)
+			if elemDiags.HasErrors() {
+				return cty.DynamicPseudoType, elemDiags
+			}
+			elemTys[i] = elemTy
+		}
+		return cty.Tuple(elemTys), nil
+	case "object":
+		pairs, diags := hcl.ExprMap(arg)
+		if diags.HasErrors() {
+			return invalid(fmt.Sprintf("The object type takes a map of attribute types, like %s.", typeExample("object")))
+		}
+		attrTys := map[string]cty.Type{}
+		for _, pair := range pairs {
+			name := hcl.ExprAsKeyword(pair.Key)
+			if name == "" {
+				return invalid("The attribute names of an object type must be identifiers.")
+			}
+			attrTy, attrDiags := typeConstraint(pair.Value)
+			if attrDiags.HasErrors() {
+				return cty.DynamicPseudoType, attrDiags
+			}
+			attrTys[name] = attrTy
+		}
+		return cty.Object(attrTys), nil
+	}
+	return invalid(fmt.Sprintf("There is no type named %q.", call.Name))
+}
+
+func typeExample(name string) string {
+	switch name {
+	case "tuple":
+		return "tuple([string, number])"
+	case "object":
+		return "object({ name = string })"
+	}
+	return name + "(string)"
+}
+
+// convertVariable converts the value of a variable to its type constraint.
+func convertVariable(name string, val cty.Value, ty cty.Type, subject hcl.Range) (cty.Value, hcl.Diagnostics) {
+	converted, err := convert.Convert(val, ty)
+	if err != nil {
+		reason := err.Error()
+		if pathErr, ok := err.(cty.PathError); ok && len(pathErr.Path) > 0 {
+			reason = fmt.Sprintf("at %s: %s", formatPath(pathErr.Path), reason)
+		}
+		return cty.DynamicVal, hcl.Diagnostics{
+			{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid value for variable",
+				Detail:   fmt.Sprintf("The variable %q must be of type %s, %s.", name, ty.FriendlyName(), reason),
+				Subject:  subject.Ptr(),
+			},
+		}
+	}
+	return converted, nil
+}
+
+// sortedElements returns the elements of a set, sorted, since cty iterates
+// sets in an order that depends on the hashes of the elements. Strings are
+// sorted lexicographically, numbers numerically and false comes before true.
+// Other elements are sorted by their rendering. The elements of other
+// collections are returned in their order.
+func sortedElements(val cty.Value) []cty.Value {
+	var elems []cty.Value
+	for it := val.ElementIterator(); it.Next(); {
+		_, elem := it.Element()
+		elems = append(elems, elem)
+	}
+	if !val.Type().IsSetType() {
+		return elems
+	}
+
+	elemTy := val.Type().ElementType()
+	sort.SliceStable(elems, func(i, j int) bool {
+		a, b := elems[i], elems[j]
+		if !a.IsKnown() || a.IsNull() || !b.IsKnown() || b.IsNull() {
+			return FormatValue(a) < FormatValue(b)
+		}
+		switch elemTy {
+		case cty.String:
+			return a.AsString() < b.AsString()
+		case cty.Number:
+			return a.LessThan(b).True()
+		case cty.Bool:
+			return b.True() && a.False()
+		}
+		return FormatValue(a) < FormatValue(b)
+	})
+	return elems
+}
+
+// sortedSet returns a set as a list of its sorted elements, so that it is
+// decoded into Go slices in a stable order. Other values are returned as is.
+func sortedSet(val cty.Value) cty.Value {
+	ty := val.Type()
+	if !ty.IsSetType() || !val.IsWhollyKnown() || val.IsNull() {
+		return val
+	}
+	if val.LengthInt() == 0 {
+		return cty.ListValEmpty(ty.ElementType())
+	}
+	return cty.ListVal(sortedElements(val))
+}
+
+// lengthFunc returns the number of elements of a list, set, map or tuple.
+// Unlike stdlib.LengthFunc, the length of a set with unknown elements is
+// unknown, since they may turn out to equal other elements, and the error
+// for other values names all the accepted types.
+var lengthFunc = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{Name: "collection", Type: cty.DynamicPseudoType, AllowDynamicType: true},
+	},
+	Type: func(args []cty.Value) (cty.Type, error) {
+		ty := args[0].Type()
+		if !ty.IsListType() && !ty.IsSetType() && !ty.IsMapType() && !ty.IsTupleType() && ty != cty.DynamicPseudoType {
+			return cty.NilType, function.NewArgErrorf(0, "collection must be a list, a set, a map or a tuple, not %s", ty.FriendlyName())
+		}
+		return cty.Number, nil
+	},
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		if args[0].Type().IsSetType() && !args[0].IsWhollyKnown() {
+			return cty.UnknownVal(cty.Number), nil
+		}
+		return args[0].Length(), nil
+	},
+})
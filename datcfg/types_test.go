@@ -0,0 +1,117 @@
+package datcfg
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+const zonesConfig = `
+variable "zones" {
+  type    = set(string)
+  default = ["b", "c", "a", "b"]
+}
+
+cluster "c" {
+  for_each         = var.zones
+  controller_count = length(var.zones)
+  worker_count     = each.value == "a" ? 1 : 2
+}
+`
+
+func clusterNames(clusters []Cluster) []string {
+	var names []string
+	for _, cluster := range clusters {
+		names = append(names, cluster.Name)
+	}
+	return names
+}
+
+func TestForEachOverSet(t *testing.T) {
+	fsys := fstest.MapFS{"cluster.datcfg": {Data: []byte(zonesConfig)}}
+	result, diags := NewLoader(fsys).Load()
+	if diags.HasErrors() {
+		t.Fatal(diags)
+	}
+
+	want := []string{`c["a"]`, `c["b"]`, `c["c"]`}
+	if got := clusterNames(result.Clusters); !reflect.DeepEqual(got, want) {
+		t.Errorf("clusters = %q, want them in sorted order %q", got, want)
+	}
+	for _, cluster := range result.Clusters {
+		if cluster.Config.ControllerCount != 3 {
+			t.Errorf("%s: controller_count = %d, want the length of the set without duplicates", cluster.Name, cluster.Config.ControllerCount)
+		}
+	}
+	if result.Clusters[0].Config.WorkerCount != 1 || result.Clusters[1].Config.WorkerCount != 2 {
+		t.Errorf("each.value is not the element of the set")
+	}
+}
+
+func TestForEachOverSetFromValuesFile(t *testing.T) {
+	fsys := fstest.MapFS{
+		"cluster.datcfg": {Data: []byte(zonesConfig)},
+		"dat.vars":       {Data: []byte(`zones = ["z", "y", "z"]`)},
+	}
+	result, diags := NewLoader(fsys).Load()
+	if diags.HasErrors() {
+		t.Fatal(diags)
+	}
+
+	if got := result.Variables["zones"].Type(); !got.Equals(cty.Set(cty.String)) {
+		t.Errorf("zones is a %s, want the list converted to a set", got.FriendlyName())
+	}
+	want := []string{`c["y"]`, `c["z"]`}
+	if got := clusterNames(result.Clusters); !reflect.DeepEqual(got, want) {
+		t.Errorf("clusters = %q, want %q", got, want)
+	}
+	if got := result.Clusters[0].Config.ControllerCount; got != 2 {
+		t.Errorf("controller_count = %d, want 2", got)
+	}
+}
+
+func TestForEachOverSetOfNumbers(t *testing.T) {
+	src := strings.Replace(zonesConfig, `set(string)`, `set(number)`, 1)
+	src = strings.Replace(src, `["b", "c", "a", "b"]`, `[2, 1]`, 1)
+	fsys := fstest.MapFS{"cluster.datcfg": {Data: []byte(src)}}
+	_, diags := NewLoader(fsys).Load()
+	if !diags.HasErrors() || diags[0].Summary != "Invalid for_each argument" {
+		t.Fatalf("got %v, want an invalid for_each argument", diags)
+	}
+}
+
+func TestLength(t *testing.T) {
+	tests := []struct {
+		arg  cty.Value
+		want cty.Value
+	}{
+		{cty.SetVal([]cty.Value{cty.StringVal("a"), cty.StringVal("b")}), cty.NumberIntVal(2)},
+		{cty.SetValEmpty(cty.String), cty.NumberIntVal(0)},
+		{cty.ListVal([]cty.Value{cty.StringVal("a"), cty.StringVal("a")}), cty.NumberIntVal(2)},
+		{cty.MapVal(map[string]cty.Value{"a": cty.True}), cty.NumberIntVal(1)},
+		{cty.TupleVal([]cty.Value{cty.True, cty.UnknownVal(cty.String)}), cty.NumberIntVal(2)},
+		// The unknown element may turn out to be "a".
+		{cty.SetVal([]cty.Value{cty.StringVal("a"), cty.UnknownVal(cty.String)}), cty.UnknownVal(cty.Number)},
+		{cty.UnknownVal(cty.Set(cty.String)), cty.UnknownVal(cty.Number)},
+	}
+	for _, test := range tests {
+		got, err := lengthFunc.Call([]cty.Value{test.arg})
+		if err != nil {
+			t.Errorf("length(%#v): %s", test.arg, err)
+			continue
+		}
+		if !got.RawEquals(test.want) {
+			t.Errorf("length(%#v) = %#v, want %#v", test.arg, got, test.want)
+		}
+	}
+}
+
+func TestLengthInvalid(t *testing.T) {
+	_, err := lengthFunc.Call([]cty.Value{cty.StringVal("abc")})
+	if err == nil || !strings.Contains(err.Error(), "a set") {
+		t.Fatalf("got %v, want an error naming sets", err)
+	}
+}
@@ -0,0 +1,87 @@
+package datcfg
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// ByteSize is a number of bytes. Fields of this type accept sizes like
+// `"512MiB"` or `"2GB"` as well as plain numbers of bytes.
+type ByteSize int64
+
+// byteSizeUnits are the multipliers of the units a ByteSize can be written
+// in, by their lower-case name. The IEC units are powers of 1024, the SI
+// units powers of 1000.
+var byteSizeUnits = map[string]int64{
+	"":    1,
+	"b":   1,
+	"kb":  1e3,
+	"mb":  1e6,
+	"gb":  1e9,
+	"tb":  1e12,
+	"kib": 1 << 10,
+	"mib": 1 << 20,
+	"gib": 1 << 30,
+	"tib": 1 << 40,
+}
+
+const byteSizeUnitNames = "B, KB, MB, GB, TB, KiB, MiB, GiB and TiB"
+
+// ParseByteSize parses a size like `"2GiB"` or `"1.5 GB"`. Unit names are
+// not case sensitive, and a number without a unit is a number of bytes.
+// Sizes are computed exactly, up to the largest int64.
+func ParseByteSize(s string) (ByteSize, error) {
+	trimmed := strings.TrimSpace(s)
+	end := strings.IndexFunc(trimmed, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if end == -1 {
+		end = len(trimmed)
+	}
+	num, unit := trimmed[:end], strings.TrimSpace(trimmed[end:])
+
+	if num == "" {
+		return 0, fmt.Errorf("%q is not a valid size, it must be a number followed by a unit like \"512MiB\"", s)
+	}
+	n, ok := new(big.Rat).SetString(num)
+	if !ok {
+		return 0, fmt.Errorf("%q is not a valid size, %q is not a number", s, num)
+	}
+	mult, ok := byteSizeUnits[strings.ToLower(unit)]
+	if !ok {
+		return 0, fmt.Errorf("%q is not a valid size, the unit %q is unknown; valid units are %s", s, unit, byteSizeUnitNames)
+	}
+
+	size := n.Mul(n, new(big.Rat).SetInt64(mult))
+	switch {
+	case !size.IsInt():
+		return 0, fmt.Errorf("%q is not a whole number of bytes", s)
+	case size.Num().Cmp(big.NewInt(math.MaxInt64)) > 0:
+		return 0, fmt.Errorf("%q is too large", s)
+	}
+	return ByteSize(size.Num().Int64()), nil
+}
+
+// String formats the size in the largest IEC unit it is a whole multiple of.
+func (s ByteSize) String() string {
+	for _, unit := range []string{"TiB", "GiB", "MiB", "KiB"} {
+		mult := ByteSize(byteSizeUnits[strings.ToLower(unit)])
+		if s != 0 && s%mult == 0 {
+			return fmt.Sprintf("%d%s", s/mult, unit)
+		}
+	}
+	return fmt.Sprintf("%dB", int64(s))
+}
+
+// parseDuration works like time.ParseDuration, with an error message that
+// tells config authors what a valid duration looks like.
+func parseDuration(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a valid duration, it must be a number followed by a unit like \"30s\", \"5m\" or \"1h30m\"; valid units are ns, us, ms, s, m and h", s)
+	}
+	return d, nil
+}
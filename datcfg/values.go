@@ -0,0 +1,211 @@
+package datcfg
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hcl/hclsyntax"
+	"github.com/hashicorp/hcl2/hclparse"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// LoadValuesFile reads the file at the given path from fsys and parses it as a
+// "values file" (key.value HCL config) for later use in the `EvalContext`.
+//
+// Blocks without labels group values into sections, such that
+//
+//	network {
+//	  cidr = "10.0.0.0/16"
+//	}
+//
+// is available as `var.network.cidr`. Files with a `.json` extension are
+// parsed as JSON, with nested objects in place of sections.
+//
+// Adapted from
+// https://github.com/hashicorp/terraform/blob/d4ac68423c4998279f33404db46809d27a5c2362/configs/parser_values.go#L8-L23
+func LoadValuesFile(fsys fs.FS, path string) (map[string]cty.Value, hcl.Diagnostics) {
+	vals, _, diags := loadValuesFile(fsys, path)
+	return vals, diags
+}
+
+// loadValuesFile is LoadValuesFile, also returning the ranges of the names
+// the values are set with.
+func loadValuesFile(fsys fs.FS, path string) (map[string]cty.Value, map[string]hcl.Range, hcl.Diagnostics) {
+	hclParser := hclparse.NewParser()
+	var varsFile *hcl.File
+	var diags hcl.Diagnostics
+	if strings.HasSuffix(path, ".json") {
+		varsFile, diags = parseJSONFile(hclParser, fsys, path)
+	} else {
+		varsFile, diags = parseHCLFile(hclParser, fsys, path)
+	}
+	if diags != nil {
+		return nil, nil, diags
+	}
+
+	body := varsFile.Body
+	if body == nil {
+		return nil, nil, diags
+	}
+
+	if syntaxBody, ok := body.(*hclsyntax.Body); ok {
+		return sectionValues(syntaxBody)
+	}
+
+	vars := make(map[string]cty.Value)
+	ranges := make(map[string]hcl.Range)
+	attrs, attrsDiags := body.JustAttributes()
+	diags = append(diags, attrsDiags...)
+	if attrs == nil {
+		return vars, ranges, diags
+	}
+
+	for name, attr := range attrs {
+		val, valDiags := attr.Expr.Value(nil)
+		diags = append(diags, valDiags...)
+		vars[name] = val
+		ranges[name] = attr.NameRange
+	}
+
+	return vars, ranges, diags
+}
+
+// WithValues sets variable values like a values file would, taking
+// precedence over all values files. It is meant for callers that get the
+// values from elsewhere, like the body of an API request.
+func WithValues(vals map[string]cty.Value) LoaderOption {
+	return func(l *Loader) {
+		if l.values == nil {
+			l.values = map[string]cty.Value{}
+		}
+		for name, val := range vals {
+			l.values[name] = val
+		}
+	}
+}
+
+// loadValuesFiles reads and merges all values files for the given base name
+// and environment, if any, in order of increasing precedence:
+//
+//	dat.vars
+//	dat.vars.json
+//	*.auto.dat.vars and *.auto.dat.vars.json, in lexical order
+//	the values files of the environment, see WithValuesEnvironment
+//
+// A value set in a later file replaces the one from an earlier file. Missing
+// files are skipped. The ranges of the names the values are set with are
+// returned too.
+func loadValuesFiles(fsys fs.FS, base, environment string) (map[string]cty.Value, map[string]hcl.Range, hcl.Diagnostics) {
+	paths, diags := valuesFiles(fsys, base)
+	if diags.HasErrors() {
+		return nil, nil, diags
+	}
+	if environment != "" {
+		envPaths, envDiags := environmentValuesFiles(fsys, environment)
+		diags = append(diags, envDiags...)
+		if envDiags.HasErrors() {
+			return nil, nil, diags
+		}
+		paths = append(paths, envPaths...)
+	}
+
+	vals := map[string]cty.Value{}
+	ranges := map[string]hcl.Range{}
+	for _, path := range paths {
+		fileVals, fileRanges, fileDiags := loadValuesFile(fsys, path)
+		diags = append(diags, fileDiags...)
+		if fileDiags.HasErrors() {
+			return nil, nil, diags
+		}
+		for name, val := range fileVals {
+			vals[name] = val
+			ranges[name] = fileRanges[name]
+		}
+	}
+
+	return vals, ranges, diags
+}
+
+// ValuesFiles returns the paths of the values files in the root of fsys that
+// a Loader reads, in order of increasing precedence.
+func ValuesFiles(fsys fs.FS) ([]string, hcl.Diagnostics) {
+	return valuesFiles(fsys, NewLoader(fsys).valuesFile)
+}
+
+func valuesFiles(fsys fs.FS, base string) ([]string, hcl.Diagnostics) {
+	candidates := []string{base, base + ".json"}
+
+	var autoPaths []string
+	for _, pattern := range []string{"*.auto." + base, "*.auto." + base + ".json"} {
+		matches, err := fs.Glob(fsys, pattern)
+		if err != nil {
+			return nil, hcl.Diagnostics{
+				{
+					Severity: hcl.DiagError,
+					Summary:  "Failed to find values files",
+					Detail:   err.Error(),
+				},
+			}
+		}
+		autoPaths = append(autoPaths, matches...)
+	}
+	sort.Strings(autoPaths)
+	candidates = append(candidates, autoPaths...)
+
+	var paths []string
+	for _, path := range candidates {
+		if _, err := fs.Stat(fsys, path); errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// sectionValues returns the values of all attributes in the given body, with
+// nested blocks turned into object values, and the ranges of their names.
+func sectionValues(body *hclsyntax.Body) (map[string]cty.Value, map[string]hcl.Range, hcl.Diagnostics) {
+	var diags hcl.Diagnostics
+	vals := make(map[string]cty.Value)
+	defined := make(map[string]hcl.Range)
+
+	for _, attr := range sortedAttributes(body) {
+		name := attr.Name
+		val, valDiags := attr.Expr.Value(nil)
+		diags = append(diags, valDiags...)
+		vals[name] = val
+		defined[name] = attr.NameRange
+	}
+
+	for _, block := range body.Blocks {
+		if len(block.Labels) > 0 {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Unexpected block labels",
+				Detail:   fmt.Sprintf("Sections in a values file have no labels, use %s { ... } instead.", block.Type),
+				Subject:  block.LabelRanges[0].Ptr(),
+			})
+			continue
+		}
+		if prev, ok := defined[block.Type]; ok {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Duplicate value",
+				Detail:   fmt.Sprintf("A value for %q was already defined at %s.", block.Type, prev),
+				Subject:  block.TypeRange.Ptr(),
+			})
+			continue
+		}
+
+		sectionVals, _, sectionDiags := sectionValues(block.Body)
+		diags = append(diags, sectionDiags...)
+		vals[block.Type] = cty.ObjectVal(sectionVals)
+		defined[block.Type] = block.TypeRange
+	}
+
+	return vals, defined, diags
+}
@@ -0,0 +1,142 @@
+package datcfg
+
+import (
+	"reflect"
+	"sort"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// VariableInfo describes a declared variable and where its value comes from,
+// for auditing the inputs of a config and for tools collecting them from
+// users.
+type VariableInfo struct {
+	Name string
+	// Type is the type constraint of the variable, like `set(string)`, or
+	// `any` if it declares none.
+	Type string
+	// Default is the default value, cty.NilVal if there is none. It is masked
+	// like the variable's value.
+	Default cty.Value
+	// Description is the `description` argument of the variable block.
+	Description string
+	// Sensitive tells whether the value must not be shown, because it came
+	// from a variable source or its name is redacted.
+	Sensitive bool
+	// Provided tells whether a value was given for the variable, rather than
+	// taken from its default.
+	Provided bool
+	// Origin is where the value comes from: "values file", "option" for
+	// WithValues, "variable source" or "default". It is empty if the variable
+	// has no value.
+	Origin string
+	// File is the values file of the value, for the origin "values file".
+	File string
+	// DeclRange is the range of the header of the variable block.
+	DeclRange hcl.Range
+}
+
+// settingsRoot decodes just the settings block of a config body.
+type settingsRoot struct {
+	Settings *Settings `hcl:"settings,block"`
+	Remain   hcl.Body  `hcl:",remain"`
+}
+
+// Variables returns the variables declared in the config files, sorted by
+// name, with the values given for them resolved as for Load. The components
+// are not evaluated, so the variables of configs that don't load yet, like
+// those missing values, are listed too.
+func (l *Loader) Variables() ([]VariableInfo, hcl.Diagnostics) {
+	result, _, includedBodies, diags := l.loadFiles()
+	if result == nil {
+		return nil, diags
+	}
+
+	var settings settingsRoot
+	settingsDiags := DecodeBody(hcl.MergeBodies(includedBodies), nil, &settings)
+	if settings.Settings != nil {
+		settingsDiags = append(settingsDiags, checkRedactPatterns(settings.Settings.Redact)...)
+		result.Redact = settings.Settings.Redact
+	}
+	diags = append(diags, settingsDiags...)
+	if settingsDiags.HasErrors() {
+		return nil, diags
+	}
+
+	body := hcl.MergeBodies(result.bodies)
+	var root variablesRoot
+	rootDiags := DecodeBody(body, nil, &root)
+	diags = append(diags, rootDiags...)
+	if rootDiags.HasErrors() {
+		return nil, diags
+	}
+
+	ranges := variableRanges(body)
+	var infos []VariableInfo
+	for _, v := range root.Variables {
+		info, infoDiags := result.variableInfo(v, ranges[v.Name])
+		diags = append(diags, infoDiags...)
+		if infoDiags.HasErrors() {
+			return nil, diags
+		}
+		infos = append(infos, info)
+	}
+	sort.SliceStable(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+	return infos, diags
+}
+
+// variableInfo describes the given variable of the config returned by
+// loadFiles.
+func (c *Config) variableInfo(v variableBlock, declRange hcl.Range) (VariableInfo, hcl.Diagnostics) {
+	info := VariableInfo{
+		Name:      v.Name,
+		DeclRange: declRange,
+		Type:      "any",
+		Sensitive: c.Sensitive[v.Name] || c.Redacted(v.Name),
+	}
+	var diags hcl.Diagnostics
+	if typeAttr, ok := v.Default["type"]; ok {
+		ty, typeDiags := typeConstraint(typeAttr.Expr)
+		diags = append(diags, typeDiags...)
+		info.Type = typeExpression(ty)
+	}
+	if attr, ok := v.Default["description"]; ok {
+		diags = append(diags, decodeExpression(attr.Expr, attr.Name, nil, reflect.ValueOf(&info.Description).Elem())...)
+	}
+	def, hasDefault := v.Default["default"]
+	if hasDefault {
+		val, defaultDiags := def.Expr.Value(nil)
+		diags = append(diags, defaultDiags...)
+		info.Default = c.RedactValue(val)
+		if info.Sensitive {
+			info.Default = cty.StringVal(redactedValue)
+		}
+	}
+	oldNames, renameDiags := renamedFrom(v)
+	diags = append(diags, renameDiags...)
+	if diags.HasErrors() {
+		return info, diags
+	}
+
+	// The value is looked up in the order of resolveVariables.
+	for _, name := range append([]string{v.Name}, oldNames...) {
+		if _, ok := c.Values[name]; !ok {
+			continue
+		}
+		info.Provided, info.Origin = true, "option"
+		if rng, ok := c.valueRanges[name]; ok {
+			info.Origin, info.File = "values file", rng.Filename
+		}
+		return info, diags
+	}
+	switch {
+	case c.Sensitive[v.Name]:
+		info.Provided, info.Origin = true, "variable source"
+	case hasDefault:
+		info.Origin = "default"
+	}
+	return info, diags
+}
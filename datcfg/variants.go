@@ -0,0 +1,208 @@
+package datcfg
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/hashicorp/hcl2/hcl"
+)
+
+// variantKindAttr is the attribute of a value that names its variant.
+const variantKindAttr = "kind"
+
+var (
+	variantsMu sync.RWMutex
+	// variants are the struct types registered with RegisterVariant, by
+	// interface type and kind.
+	variants = map[reflect.Type]map[string]reflect.Type{}
+)
+
+// RegisterVariant registers config as the variant of an interface type for
+// the given kind. Attributes of config fields of the interface type hold an
+// object whose `kind` names the variant it is decoded into, like the
+// storage backend of a component:
+//
+//	type Backend interface{ ... }
+//
+//	type FooComponentConfig struct {
+//		Storage Backend `hcl:"storage,attr"`
+//	}
+//
+//	datcfg.MustRegisterVariant((*Backend)(nil), "s3", &S3Backend{})
+//	datcfg.MustRegisterVariant((*Backend)(nil), "disk", &DiskBackend{})
+//
+//	storage = {
+//	  kind   = "s3"
+//	  bucket = "backups"
+//	}
+//
+// iface is a nil pointer to the interface type, and config a pointer to a
+// struct implementing it, only used as a prototype. The other attributes of
+// the object are decoded into a new value of the struct like the body of a
+// block, with its `hcl` tags and the decode hooks. The JSON rendering of the
+// field has the kind again.
+func RegisterVariant(iface interface{}, kind string, config interface{}) error {
+	ifaceTy := reflect.TypeOf(iface)
+	if ifaceTy == nil || ifaceTy.Kind() != reflect.Ptr || ifaceTy.Elem().Kind() != reflect.Interface {
+		return fmt.Errorf("variant %q must be registered for a nil pointer to an interface type, not %T", kind, iface)
+	}
+	ifaceTy = ifaceTy.Elem()
+	ty := reflect.TypeOf(config)
+	if ty == nil || ty.Kind() != reflect.Ptr || ty.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("config of variant %q must be a pointer to a struct, not %T", kind, config)
+	}
+	if !ty.Implements(ifaceTy) {
+		return fmt.Errorf("config of variant %q, %s, does not implement %s", kind, ty, ifaceTy)
+	}
+
+	variantsMu.Lock()
+	defer variantsMu.Unlock()
+	kinds := variants[ifaceTy]
+	if kinds == nil {
+		kinds = map[string]reflect.Type{}
+		variants[ifaceTy] = kinds
+	}
+	if prev, exists := kinds[kind]; exists {
+		return fmt.Errorf("variant %q of %s is already registered as %s", kind, ifaceTy, prev)
+	}
+	kinds[kind] = ty
+	return nil
+}
+
+// MustRegisterVariant is like RegisterVariant, but panics if the variant
+// can't be registered, for use in init functions.
+func MustRegisterVariant(iface interface{}, kind string, config interface{}) {
+	if err := RegisterVariant(iface, kind, config); err != nil {
+		panic(err)
+	}
+}
+
+// variantKinds returns the variants registered for the given type, by kind,
+// or false if it is not an interface type with variants.
+func variantKinds(ty reflect.Type) (map[string]reflect.Type, bool) {
+	if ty.Kind() != reflect.Interface {
+		return nil, false
+	}
+	variantsMu.RLock()
+	defer variantsMu.RUnlock()
+	kinds, ok := variants[ty]
+	return kinds, ok
+}
+
+// variantKind returns the kind of the given value of an interface type with
+// variants.
+func variantKind(ty reflect.Type, v reflect.Value) (string, bool) {
+	kinds, ok := variantKinds(ty)
+	if !ok || v.IsNil() {
+		return "", false
+	}
+	for kind, variantTy := range kinds {
+		if v.Elem().Type() == variantTy {
+			return kind, true
+		}
+	}
+	return "", false
+}
+
+// decodeVariant decodes the value of the given attribute into a field of an
+// interface type with the given variants. An object constructor is decoded
+// attribute by attribute, so that diagnostics point to them, other
+// expressions are evaluated first. A null value leaves the field nil.
+func decodeVariant(attr *hcl.Attribute, ctx *hcl.EvalContext, fieldV reflect.Value, kinds map[string]reflect.Type) hcl.Diagnostics {
+	attrs, diags := variantAttributes(attr.Expr, ctx)
+	if diags.HasErrors() || attrs == nil {
+		return diags
+	}
+
+	names := make([]string, 0, len(kinds))
+	for kind := range kinds {
+		names = append(names, kind)
+	}
+	sort.Strings(names)
+
+	kindAttr, ok := attrs[variantKindAttr]
+	if !ok {
+		return append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Missing variant kind",
+			Detail:   fmt.Sprintf("The value of %q must have a %q attribute, one of %q.", attr.Name, variantKindAttr, names),
+			Subject:  attr.Expr.Range().Ptr(),
+		})
+	}
+	delete(attrs, variantKindAttr)
+	var kind string
+	kindDiags := decodeExpression(kindAttr.Expr, variantKindAttr, ctx, reflect.ValueOf(&kind).Elem())
+	diags = append(diags, kindDiags...)
+	if kindDiags.HasErrors() {
+		return diags
+	}
+	variantTy, ok := kinds[kind]
+	if !ok {
+		detail := fmt.Sprintf("There is no %q variant of %q, it must be one of %q.", kind, attr.Name, names)
+		if suggestion, ok := nearestName(kind, names); ok {
+			detail = fmt.Sprintf("There is no %q variant of %q. Did you mean %q?", kind, attr.Name, suggestion)
+		}
+		return append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Unknown variant kind",
+			Detail:   detail,
+			Subject:  kindAttr.Expr.Range().Ptr(),
+		})
+	}
+
+	v := reflect.New(variantTy.Elem())
+	diags = append(diags, decodeBodyToStruct(attrsBody{attrs: attrs, missing: attr.Expr.Range()}, ctx, v.Elem())...)
+	fieldV.Set(v)
+	return diags
+}
+
+// variantAttributes returns the attributes of the object the given
+// expression evaluates to, nil if it is null or not known yet.
+func variantAttributes(expr hcl.Expression, ctx *hcl.EvalContext) (hcl.Attributes, hcl.Diagnostics) {
+	if pairs, pairDiags := hcl.ExprMap(expr); !pairDiags.HasErrors() {
+		attrs := hcl.Attributes{}
+		var diags hcl.Diagnostics
+		for _, pair := range pairs {
+			var name string
+			keyDiags := decodeExpression(pair.Key, "key", ctx, reflect.ValueOf(&name).Elem())
+			diags = append(diags, keyDiags...)
+			if keyDiags.HasErrors() {
+				continue
+			}
+			attrs[name] = &hcl.Attribute{
+				Name:      name,
+				Expr:      pair.Value,
+				Range:     hcl.RangeBetween(pair.Key.Range(), pair.Value.Range()),
+				NameRange: pair.Key.Range(),
+			}
+		}
+		return attrs, diags
+	}
+
+	val, diags := expr.Value(ctx)
+	if diags.HasErrors() || val.IsNull() || !val.IsWhollyKnown() {
+		return nil, diags
+	}
+	if !val.Type().IsObjectType() && !val.Type().IsMapType() {
+		return nil, append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Unsuitable value type",
+			Detail:   fmt.Sprintf("Unsuitable value: an object with a %q attribute is required, not %s.", variantKindAttr, val.Type().FriendlyName()),
+			Subject:  expr.StartRange().Ptr(),
+			Context:  expr.Range().Ptr(),
+		})
+	}
+	attrs := hcl.Attributes{}
+	for it := val.ElementIterator(); it.Next(); {
+		k, v := it.Element()
+		attrs[k.AsString()] = &hcl.Attribute{
+			Name:      k.AsString(),
+			Expr:      hcl.StaticExpr(v, expr.Range()),
+			Range:     expr.Range(),
+			NameRange: expr.StartRange(),
+		}
+	}
+	return attrs, diags
+}
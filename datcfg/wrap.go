@@ -0,0 +1,99 @@
+package datcfg
+
+import (
+	"reflect"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hcl/hclsyntax"
+)
+
+var (
+	syntaxExprType        = reflect.TypeOf((*hclsyntax.Expression)(nil)).Elem()
+	syntaxPkgPath         = reflect.TypeOf(hclsyntax.Body{}).PkgPath()
+	functionCallPtrType   = reflect.TypeOf(&hclsyntax.FunctionCallExpr{})
+	scopeTraversalPtrType = reflect.TypeOf(&hclsyntax.ScopeTraversalExpr{})
+	tryExprType           = reflect.TypeOf(tryExpr{})
+	runValueExprType      = reflect.TypeOf(runValueExpr{})
+	assertExprType        = reflect.TypeOf(assertExpr{})
+)
+
+// wrapExpressions replaces the expressions of the given file that evaluate
+// differently than the parser knows: the calls of try and can with tryExpr,
+// those of assert with assertExpr, those of uuid, random_integer and
+// timestamp with runValueExpr, and the references to `path` with
+// fileTraversalExpr. The expressions are found by walking the fields of the
+// syntax tree.
+func wrapExpressions(file *hcl.File) {
+	if file == nil {
+		return
+	}
+	if body, ok := file.Body.(*hclsyntax.Body); ok {
+		wrapExpressionsIn(reflect.ValueOf(body), map[uintptr]bool{})
+	}
+}
+
+func wrapExpressionsIn(v reflect.Value, visited map[uintptr]bool) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() || visited[v.Pointer()] {
+			return
+		}
+		visited[v.Pointer()] = true
+		wrapExpressionsIn(v.Elem(), visited)
+	case reflect.Interface:
+		if !v.IsNil() {
+			wrapExpressionsIn(v.Elem(), visited)
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			wrapExpression(v.Index(i))
+			wrapExpressionsIn(v.Index(i), visited)
+		}
+	case reflect.Map:
+		for _, key := range v.MapKeys() {
+			wrapExpressionsIn(v.MapIndex(key), visited)
+		}
+	case reflect.Struct:
+		// The wrapped arguments of calls may hold other calls, the wrapped
+		// references to path are done.
+		if v.Type().PkgPath() != syntaxPkgPath && v.Type() != tryExprType && v.Type() != runValueExprType && v.Type() != assertExprType {
+			return
+		}
+		// Names used as keys of object constructors, like `{ path = "/" }`,
+		// aren't references.
+		if key, ok := v.Interface().(hclsyntax.ObjectConsKeyExpr); ok && hcl.ExprAsKeyword(key.Wrapped) != "" {
+			return
+		}
+		for i := 0; i < v.NumField(); i++ {
+			if !v.Type().Field(i).IsExported() {
+				continue
+			}
+			wrapExpression(v.Field(i))
+			wrapExpressionsIn(v.Field(i), visited)
+		}
+	}
+}
+
+// wrapExpression replaces the value of an expression field holding a call of
+// try, can, assert, uuid, random_integer or timestamp or a reference to `path`.
+func wrapExpression(field reflect.Value) {
+	if field.Type() != syntaxExprType || field.IsNil() || !field.CanSet() {
+		return
+	}
+	switch field.Elem().Type() {
+	case functionCallPtrType:
+		call := field.Elem().Interface().(*hclsyntax.FunctionCallExpr)
+		if call.Name == "try" || call.Name == "can" {
+			field.Set(reflect.ValueOf(&tryExpr{call}))
+		} else if call.Name == "assert" {
+			field.Set(reflect.ValueOf(&assertExpr{call}))
+		} else if _, ok := runFunctionParams[call.Name]; ok {
+			field.Set(reflect.ValueOf(&runValueExpr{call}))
+		}
+	case scopeTraversalPtrType:
+		traversal := field.Elem().Interface().(*hclsyntax.ScopeTraversalExpr)
+		if traversal.Traversal.RootName() == "path" {
+			field.Set(reflect.ValueOf(&fileTraversalExpr{&hclsyntax.TemplateWrapExpr{Wrapped: traversal, SrcRange: traversal.SrcRange}}))
+		}
+	}
+}
@@ -0,0 +1,684 @@
+package datcfg
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/hashicorp/hcl2/hcl"
+)
+
+// yamlNode is a node of a parsed YAML document. This package reads the part
+// of YAML that config files use: block and flow mappings and sequences, plain,
+// quoted and block scalars, and comments. Anchors, aliases, tags and several
+// documents per file are not supported.
+type yamlNode struct {
+	kind yamlKind
+	// value is the content of a scalar, plain is set for scalars without
+	// quotes, which resolve to null, booleans and numbers like in YAML 1.2.
+	value   string
+	plain   bool
+	entries []yamlEntry
+	items   []*yamlNode
+	// rng covers the whole node, start its first token, like the key of the
+	// first entry of a mapping.
+	rng, start hcl.Range
+}
+
+type yamlKind int
+
+const (
+	yamlScalar yamlKind = iota
+	yamlMapping
+	yamlSequence
+)
+
+type yamlEntry struct {
+	key      string
+	keyRange hcl.Range
+	value    *yamlNode
+}
+
+// yamlLine is a line of a YAML source, by its byte offsets in the source.
+type yamlLine struct {
+	start, end int
+	// indent is the number of spaces the line starts with, text the rest
+	// without trailing white space, comments included.
+	indent int
+	text   string
+}
+
+func (l yamlLine) blank() bool {
+	return l.text == "" || l.text[0] == '#'
+}
+
+type yamlParser struct {
+	filename string
+	src      []byte
+	lines    []yamlLine
+	i        int
+	diags    hcl.Diagnostics
+}
+
+// parseYAML parses the YAML document in src. Parsing stops at the first
+// error, the ranges of the nodes are those of their source.
+func parseYAML(src []byte, filename string) (*yamlNode, hcl.Diagnostics) {
+	p := &yamlParser{filename: filename, src: src}
+	start := 0
+	if bytes.HasPrefix(src, utf8BOM) {
+		start = len(utf8BOM)
+	}
+	// The line break ending the last line doesn't start another one.
+	for start < len(src) {
+		end := bytes.IndexByte(src[start:], '\n')
+		next := start + end + 1
+		if end < 0 {
+			end, next = len(src)-start, len(src)+1
+		}
+		end += start
+		if end > start && src[end-1] == '\r' {
+			end--
+		}
+		line := yamlLine{start: start, end: end}
+		for line.indent < end-start && src[start+line.indent] == ' ' {
+			line.indent++
+		}
+		line.text = strings.TrimRight(string(src[start+line.indent:end]), " \t")
+		p.lines = append(p.lines, line)
+		start = next
+	}
+
+	content := false
+	for i := 0; i < len(p.lines); i++ {
+		line := p.lines[i]
+		if strings.HasPrefix(line.text, "\t") {
+			p.errorAt(line.start+line.indent, "Invalid YAML indentation", "YAML is indented with spaces, tabs are not allowed.")
+			return nil, p.diags
+		}
+		if line.blank() {
+			continue
+		}
+		switch {
+		case line.indent > 0:
+		case line.text == "---" && !content:
+			p.lines[i].text = ""
+			continue
+		case line.text == "...":
+			p.lines = p.lines[:i]
+		case line.text == "---" || strings.HasPrefix(line.text, "--- "):
+			p.errorAt(line.start, "Unsupported YAML feature", "A config file holds a single YAML document.")
+			return nil, p.diags
+		case strings.HasPrefix(line.text, "%"):
+			p.errorAt(line.start, "Unsupported YAML feature", "YAML directives are not supported.")
+			return nil, p.diags
+		}
+		content = true
+	}
+
+	node := p.parseNode(-1)
+	if node == nil && p.diags == nil {
+		rng := p.rangeOf(start, start)
+		node = &yamlNode{kind: yamlMapping, rng: rng, start: rng}
+	}
+	if p.skipBlank() && p.diags == nil {
+		line := p.lines[p.i]
+		p.errorAt(line.start+line.indent, "Invalid YAML indentation", "This line is not indented like the lines before it.")
+	}
+	if p.diags.HasErrors() {
+		return nil, p.diags
+	}
+	return node, nil
+}
+
+// skipBlank advances to the next line with content, it returns false at the
+// end of the source.
+func (p *yamlParser) skipBlank() bool {
+	for p.i < len(p.lines) && p.lines[p.i].blank() {
+		p.i++
+	}
+	return p.i < len(p.lines)
+}
+
+// parseNode parses the block node starting at the current line, which must
+// be indented more than its parent. It returns nil if there is none.
+func (p *yamlParser) parseNode(parentIndent int) *yamlNode {
+	if !p.skipBlank() || p.lines[p.i].indent <= parentIndent {
+		return nil
+	}
+	line := p.lines[p.i]
+	if isSequenceItem(line.text) {
+		return p.parseSequence(line.indent)
+	}
+	if _, _, _, ok := p.mappingKey(line); ok {
+		return p.parseMapping(line.indent)
+	}
+	return p.inlineValue(line.start+line.indent, parentIndent)
+}
+
+func isSequenceItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+func (p *yamlParser) parseMapping(indent int) *yamlNode {
+	node := &yamlNode{kind: yamlMapping}
+	seen := map[string]hcl.Range{}
+	for p.diags == nil && p.skipBlank() && p.lines[p.i].indent == indent {
+		line := p.lines[p.i]
+		key, keyRange, valueStart, ok := p.mappingKey(line)
+		if !ok {
+			p.errorAt(line.start+line.indent, "Invalid YAML mapping", "Expected a key, like `name: value`, since the lines before are a mapping.")
+			return nil
+		}
+		if prev, ok := seen[key]; ok {
+			p.diags = append(p.diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Duplicate YAML key",
+				Detail:   fmt.Sprintf("The key %q was already set at %s.", key, prev),
+				Subject:  keyRange.Ptr(),
+			})
+			return nil
+		}
+		seen[key] = keyRange
+
+		var value *yamlNode
+		rest := string(p.src[valueStart:line.end])
+		valueOff := valueStart + len(rest) - len(strings.TrimLeft(rest, " "))
+		if rest = strings.TrimSpace(rest); rest == "" || rest[0] == '#' {
+			p.i++
+			value = p.parseNode(indent)
+			// Sequences may be indented as much as the key they are the
+			// value of.
+			if value == nil && p.skipBlank() && p.lines[p.i].indent == indent && isSequenceItem(p.lines[p.i].text) {
+				value = p.parseSequence(indent)
+			}
+			if value == nil {
+				rng := p.rangeOf(keyRange.End.Byte+1, keyRange.End.Byte+1)
+				value = &yamlNode{kind: yamlScalar, plain: true, rng: rng, start: rng}
+			}
+		} else {
+			value = p.inlineValue(valueOff, indent)
+		}
+		if value == nil {
+			return nil
+		}
+		if node.entries == nil {
+			node.start = keyRange
+		}
+		node.entries = append(node.entries, yamlEntry{key: key, keyRange: keyRange, value: value})
+		node.rng = hcl.RangeBetween(node.start, value.rng)
+	}
+	if p.diags != nil {
+		return nil
+	}
+	return node
+}
+
+func (p *yamlParser) parseSequence(indent int) *yamlNode {
+	node := &yamlNode{kind: yamlSequence}
+	for p.diags == nil && p.skipBlank() && p.lines[p.i].indent == indent && isSequenceItem(p.lines[p.i].text) {
+		line := p.lines[p.i]
+		dash := p.rangeOf(line.start+indent, line.start+indent+1)
+		var item *yamlNode
+		if rest := strings.TrimSpace(line.text[1:]); rest == "" || rest[0] == '#' {
+			p.i++
+			item = p.parseNode(indent)
+			if item == nil {
+				item = &yamlNode{kind: yamlScalar, plain: true, rng: dash, start: dash}
+			}
+		} else {
+			// The item continues on the line of its dash, like in
+			// `- name: web`, which is parsed as if the dash was indentation.
+			offset := 1 + len(line.text[1:]) - len(strings.TrimLeft(line.text[1:], " "))
+			p.lines[p.i].indent += offset
+			p.lines[p.i].text = line.text[offset:]
+			item = p.parseNode(indent)
+		}
+		if item == nil {
+			return nil
+		}
+		if node.items == nil {
+			node.start = dash
+		}
+		node.items = append(node.items, item)
+		node.rng = hcl.RangeBetween(node.start, item.rng)
+	}
+	if p.diags != nil {
+		return nil
+	}
+	return node
+}
+
+// mappingKey returns the key of the mapping entry on the given line, the
+// range of the key and the offset of its value, if the line has one.
+func (p *yamlParser) mappingKey(line yamlLine) (string, hcl.Range, int, bool) {
+	start := line.start + line.indent
+	text := line.text
+	if text == "" || isSequenceItem(text) || strings.IndexByte("[{?&*!|>#@`", text[0]) >= 0 {
+		return "", hcl.Range{}, 0, false
+	}
+	if text[0] == '"' || text[0] == '\'' {
+		// Lines starting with a quoted string are checked for a key
+		// before parsing them as values, which reports their errors.
+		diags := p.diags
+		key, end, ok := p.quoted(start, false)
+		p.diags = diags
+		if !ok {
+			return "", hcl.Range{}, 0, false
+		}
+		colon := end
+		for colon < line.end && p.src[colon] == ' ' {
+			colon++
+		}
+		if colon == line.end || p.src[colon] != ':' || colon+1 < line.end && p.src[colon+1] != ' ' {
+			return "", hcl.Range{}, 0, false
+		}
+		return key, p.rangeOf(start, end), colon + 1, true
+	}
+	for i := 0; i < len(text); i++ {
+		if text[i] == '#' && i > 0 && text[i-1] == ' ' {
+			break
+		}
+		if text[i] == ':' && (i+1 == len(text) || text[i+1] == ' ') {
+			key := strings.TrimRight(text[:i], " ")
+			return key, p.rangeOf(start, start+len(key)), start + i + 1, true
+		}
+	}
+	return "", hcl.Range{}, 0, false
+}
+
+// inlineValue parses the value starting at the given offset of the current
+// line, and the lines it continues on, like those of a block scalar.
+func (p *yamlParser) inlineValue(off, parentIndent int) *yamlNode {
+	line := p.lines[p.i]
+	switch p.src[off] {
+	case '&', '*', '!':
+		p.errorAt(off, "Unsupported YAML feature", "Anchors, aliases and tags are not supported in config files.")
+		return nil
+	case '|', '>':
+		return p.blockScalar(off, parentIndent)
+	case '[', '{', '"', '\'':
+		node, end := p.flowValue(off)
+		if node == nil {
+			return nil
+		}
+		for p.lines[p.i].end < end {
+			p.i++
+		}
+		line = p.lines[p.i]
+		if rest := strings.TrimSpace(string(p.src[end:line.end])); rest != "" && rest[0] != '#' {
+			p.errorAt(end, "Invalid YAML value", "Expected the end of the line after the value.")
+			return nil
+		}
+		p.i++
+		return node
+	}
+
+	text := string(p.src[off:line.end])
+	end := len(text)
+	for i := 0; i < len(text); i++ {
+		if text[i] == '#' && i > 0 && (text[i-1] == ' ' || text[i-1] == '\t') {
+			end = i
+			break
+		}
+		if text[i] == ':' && (i+1 == len(text) || text[i+1] == ' ') {
+			p.errorAt(off+i, "Invalid YAML value", "A value can't be a mapping on the line of its key, the entries of a mapping go on their own lines.")
+			return nil
+		}
+	}
+	value := strings.TrimRight(text[:end], " \t")
+	rng := p.rangeOf(off, off+len(value))
+	p.i++
+
+	// Plain scalars continue on the following lines indented more than
+	// their parent, which are folded into one line.
+	for {
+		blanks := 0
+		for p.i+blanks < len(p.lines) && p.lines[p.i+blanks].text == "" {
+			blanks++
+		}
+		if p.i+blanks == len(p.lines) {
+			break
+		}
+		next := p.lines[p.i+blanks]
+		if next.indent <= parentIndent || next.text[0] == '#' || isSequenceItem(next.text) {
+			break
+		}
+		if _, _, _, ok := p.mappingKey(next); ok {
+			break
+		}
+		if blanks == 0 {
+			value += " "
+		} else {
+			value += strings.Repeat("\n", blanks)
+		}
+		cont := next.text
+		if i := strings.Index(cont, " #"); i >= 0 {
+			cont = strings.TrimRight(cont[:i], " ")
+		}
+		value += cont
+		rng = hcl.RangeBetween(rng, p.rangeOf(next.start+next.indent, next.start+next.indent+len(cont)))
+		p.i += blanks + 1
+	}
+	return &yamlNode{kind: yamlScalar, value: value, plain: true, rng: rng, start: rng}
+}
+
+// blockScalar parses a literal (`|`) or folded (`>`) block scalar, whose
+// header is at the given offset of the current line.
+func (p *yamlParser) blockScalar(off, parentIndent int) *yamlNode {
+	line := p.lines[p.i]
+	header := string(p.src[off:line.end])
+	if i := strings.Index(header, " #"); i >= 0 {
+		header = header[:i]
+	}
+	header = strings.TrimRight(header, " \t")
+	folded := header[0] == '>'
+	chomp := header[1:]
+	if chomp != "" && chomp != "-" && chomp != "+" {
+		p.errorAt(off, "Unsupported YAML feature", "Block scalars can only have a chomping indicator, `-` or `+`, after their `|` or `>`.")
+		return nil
+	}
+	rng := p.rangeOf(off, off+len(header))
+	start := rng
+	p.i++
+
+	indent := -1
+	var lines []string
+	for ; p.i < len(p.lines); p.i++ {
+		l := p.lines[p.i]
+		if l.text == "" {
+			lines = append(lines, "")
+			continue
+		}
+		if indent < 0 {
+			indent = l.indent
+		}
+		if l.indent <= parentIndent || l.indent < indent {
+			break
+		}
+		lines = append(lines, string(p.src[l.start+indent:l.end]))
+		rng = hcl.RangeBetween(rng, p.rangeOf(l.start, l.end))
+	}
+
+	// Trailing empty lines belong to the value only with the keep
+	// indicator, and are left to the following nodes otherwise.
+	trailing := 0
+	for trailing < len(lines) && lines[len(lines)-1-trailing] == "" {
+		trailing++
+	}
+	content := lines[:len(lines)-trailing]
+
+	// Folded scalars join their lines with spaces, except around empty
+	// lines, which are line breaks of their own, and more indented lines.
+	var b strings.Builder
+	for i, l := range content {
+		if i > 0 {
+			prev := content[i-1]
+			switch {
+			case !folded, l == "":
+				b.WriteByte('\n')
+			case prev == "":
+			case strings.HasPrefix(l, " ") || strings.HasPrefix(prev, " "):
+				b.WriteByte('\n')
+			default:
+				b.WriteByte(' ')
+			}
+		}
+		b.WriteString(l)
+	}
+	value := b.String()
+	switch {
+	case len(content) == 0:
+	case chomp == "-":
+	case chomp == "+":
+		value += strings.Repeat("\n", trailing+1)
+	default:
+		value += "\n"
+	}
+	return &yamlNode{kind: yamlScalar, value: value, rng: rng, start: start}
+}
+
+// flowValue parses the flow collection or quoted scalar starting at the
+// given offset of the source, which may span several lines. It returns the
+// offset of its end.
+func (p *yamlParser) flowValue(off int) (*yamlNode, int) {
+	open := p.rangeOf(off, off+1)
+	switch p.src[off] {
+	case '"', '\'':
+		value, end, ok := p.quoted(off, true)
+		if !ok {
+			return nil, 0
+		}
+		rng := p.rangeOf(off, end)
+		return &yamlNode{kind: yamlScalar, value: value, rng: rng, start: rng}, end
+	case '[':
+		node := &yamlNode{kind: yamlSequence, start: open}
+		off = p.skipFlowSpace(off + 1)
+		for off < len(p.src) && p.src[off] != ']' {
+			item, end := p.flowItem(off, "]")
+			if item == nil {
+				return nil, 0
+			}
+			node.items = append(node.items, item)
+			if off = p.flowSeparator(end, ']'); off < 0 {
+				return nil, 0
+			}
+		}
+		return p.closeFlow(node, off, ']')
+	case '{':
+		node := &yamlNode{kind: yamlMapping, start: open}
+		seen := map[string]bool{}
+		off = p.skipFlowSpace(off + 1)
+		for off < len(p.src) && p.src[off] != '}' {
+			keyNode, end := p.flowItem(off, ":}")
+			if keyNode == nil {
+				return nil, 0
+			}
+			if keyNode.kind != yamlScalar {
+				p.errorAt(off, "Unsupported YAML feature", "The keys of mappings must be scalars.")
+				return nil, 0
+			}
+			if seen[keyNode.value] {
+				p.errorAt(off, "Duplicate YAML key", fmt.Sprintf("The key %q is set more than once in this mapping.", keyNode.value))
+				return nil, 0
+			}
+			seen[keyNode.value] = true
+			end = p.skipFlowSpace(end)
+			value := &yamlNode{kind: yamlScalar, plain: true, rng: keyNode.rng, start: keyNode.rng}
+			if end < len(p.src) && p.src[end] == ':' {
+				valueStart := p.skipFlowSpace(end + 1)
+				if valueStart < len(p.src) && p.src[valueStart] != ',' && p.src[valueStart] != '}' {
+					if value, end = p.flowItem(valueStart, ",}"); value == nil {
+						return nil, 0
+					}
+				} else {
+					end = valueStart
+				}
+			}
+			node.entries = append(node.entries, yamlEntry{key: keyNode.value, keyRange: keyNode.rng, value: value})
+			if off = p.flowSeparator(end, '}'); off < 0 {
+				return nil, 0
+			}
+		}
+		return p.closeFlow(node, off, '}')
+	}
+	return nil, 0
+}
+
+// flowItem parses a value inside a flow collection, plain scalars end at
+// one of the given indicators.
+func (p *yamlParser) flowItem(off int, stops string) (*yamlNode, int) {
+	switch p.src[off] {
+	case '[', '{', '"', '\'':
+		return p.flowValue(off)
+	case '&', '*', '!':
+		p.errorAt(off, "Unsupported YAML feature", "Anchors, aliases and tags are not supported in config files.")
+		return nil, 0
+	}
+	end := off
+	for end < len(p.src) {
+		c := p.src[end]
+		if c == '\n' || c == '\r' || c == ',' || c == '[' || c == ']' || c == '{' || c == '}' ||
+			c == '#' && end > off && p.src[end-1] == ' ' ||
+			c == ':' && strings.IndexByte(stops, ':') >= 0 && (end+1 == len(p.src) || strings.IndexByte(" \r\n,]}", p.src[end+1]) >= 0) {
+			break
+		}
+		end++
+	}
+	value := strings.TrimRight(string(p.src[off:end]), " \t")
+	rng := p.rangeOf(off, off+len(value))
+	return &yamlNode{kind: yamlScalar, value: value, plain: true, rng: rng, start: rng}, off + len(value)
+}
+
+// flowSeparator skips the comma after an item of a flow collection, it
+// returns the offset of the next item or of the closing bracket.
+func (p *yamlParser) flowSeparator(off int, closing byte) int {
+	off = p.skipFlowSpace(off)
+	switch {
+	case off < len(p.src) && p.src[off] == ',':
+		return p.skipFlowSpace(off + 1)
+	case off < len(p.src) && p.src[off] == closing:
+		return off
+	}
+	p.errorAt(off, "Invalid YAML flow collection", fmt.Sprintf("Expected a comma or %q.", closing))
+	return -1
+}
+
+func (p *yamlParser) closeFlow(node *yamlNode, off int, closing byte) (*yamlNode, int) {
+	if off >= len(p.src) {
+		p.errorAt(node.start.Start.Byte, "Invalid YAML flow collection", fmt.Sprintf("The collection is never closed with %q.", closing))
+		return nil, 0
+	}
+	node.rng = hcl.RangeBetween(node.start, p.rangeOf(off, off+1))
+	return node, off + 1
+}
+
+// skipFlowSpace skips white space, line breaks and comments.
+func (p *yamlParser) skipFlowSpace(off int) int {
+	for off < len(p.src) {
+		switch p.src[off] {
+		case ' ', '\t', '\r', '\n':
+			off++
+		case '#':
+			for off < len(p.src) && p.src[off] != '\n' {
+				off++
+			}
+		default:
+			return off
+		}
+	}
+	return off
+}
+
+// quoted parses the single or double quoted scalar starting at the given
+// offset. Line breaks are folded into spaces if multiline is set, and are an
+// error otherwise.
+func (p *yamlParser) quoted(off int, multiline bool) (string, int, bool) {
+	quote := p.src[off]
+	var b strings.Builder
+	i := off + 1
+	for i < len(p.src) {
+		c := p.src[i]
+		switch {
+		case c == quote && quote == '\'' && i+1 < len(p.src) && p.src[i+1] == '\'':
+			b.WriteByte('\'')
+			i += 2
+		case c == quote:
+			return b.String(), i + 1, true
+		case c == '\n' || c == '\r':
+			if !multiline {
+				p.errorAt(off, "Invalid YAML string", "The string is not closed on its line.")
+				return "", 0, false
+			}
+			// A line break is folded into a space, empty lines
+			// into line breaks.
+			s := strings.TrimRight(b.String(), " \t")
+			b.Reset()
+			b.WriteString(s)
+			breaks := 0
+			for i < len(p.src) && strings.IndexByte(" \t\r\n", p.src[i]) >= 0 {
+				if p.src[i] == '\n' {
+					breaks++
+				}
+				i++
+			}
+			if breaks == 1 {
+				b.WriteByte(' ')
+			} else {
+				b.WriteString(strings.Repeat("\n", breaks-1))
+			}
+		case c == '\\' && quote == '"' && i+1 < len(p.src) && (p.src[i+1] == '\n' || p.src[i+1] == '\r'):
+			// An escaped line break continues the string without a
+			// space.
+			i++
+			for i < len(p.src) && strings.IndexByte(" \t\r\n", p.src[i]) >= 0 {
+				i++
+			}
+		case c == '\\' && quote == '"':
+			r, size, ok := p.escape(i)
+			if !ok {
+				return "", 0, false
+			}
+			b.WriteString(r)
+			i += size
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	p.errorAt(off, "Invalid YAML string", "The string is never closed.")
+	return "", 0, false
+}
+
+var yamlEscapes = map[byte]string{
+	'0': "\x00", 'a': "\a", 'b': "\b", 't': "\t", '\t': "\t", 'n': "\n", 'v': "\v",
+	'f': "\f", 'r': "\r", 'e': "\x1b", ' ': " ", '"': "\"", '/': "/", '\\': "\\",
+	'N': "\u0085", '_': "\u00a0", 'L': "\u2028", 'P': "\u2029",
+}
+
+// escape decodes the escape sequence of a double quoted scalar at the given
+// offset, returning its value and length.
+func (p *yamlParser) escape(off int) (string, int, bool) {
+	if off+1 < len(p.src) {
+		c := p.src[off+1]
+		if s, ok := yamlEscapes[c]; ok {
+			return s, 2, true
+		}
+		digits := map[byte]int{'x': 2, 'u': 4, 'U': 8}[c]
+		if digits > 0 && off+2+digits <= len(p.src) {
+			if r, err := strconv.ParseUint(string(p.src[off+2:off+2+digits]), 16, 32); err == nil && utf8.ValidRune(rune(r)) {
+				return string(rune(r)), 2 + digits, true
+			}
+		}
+	}
+	p.errorAt(off, "Invalid YAML string", "The string contains an invalid escape sequence.")
+	return "", 0, false
+}
+
+func (p *yamlParser) errorAt(off int, summary, detail string) {
+	p.diags = append(p.diags, &hcl.Diagnostic{
+		Severity: hcl.DiagError,
+		Summary:  summary,
+		Detail:   detail,
+		Subject:  p.rangeOf(off, off).Ptr(),
+	})
+}
+
+// rangeOf returns the range between the given byte offsets of the source.
+func (p *yamlParser) rangeOf(start, end int) hcl.Range {
+	return hcl.Range{Filename: p.filename, Start: p.pos(start), End: p.pos(end)}
+}
+
+func (p *yamlParser) pos(off int) hcl.Pos {
+	if off > len(p.src) {
+		off = len(p.src)
+	}
+	i := sort.Search(len(p.lines), func(i int) bool { return p.lines[i].start > off }) - 1
+	if i < 0 {
+		return hcl.Pos{Line: 1, Column: 1, Byte: off}
+	}
+	col := utf8.RuneCount(p.src[p.lines[i].start:off]) + 1
+	return hcl.Pos{Line: i + 1, Column: col, Byte: off}
+}
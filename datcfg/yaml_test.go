@@ -0,0 +1,234 @@
+package datcfg
+
+import (
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// plain is a plain scalar in the results of dumpYAML, quoted and block
+// scalars are strings.
+type plain string
+
+// dumpYAML returns the given node as Go values, to compare in tests.
+func dumpYAML(node *yamlNode) interface{} {
+	switch node.kind {
+	case yamlMapping:
+		entries := map[string]interface{}{}
+		for _, entry := range node.entries {
+			entries[entry.key] = dumpYAML(entry.value)
+		}
+		return entries
+	case yamlSequence:
+		items := []interface{}{}
+		for _, item := range node.items {
+			items = append(items, dumpYAML(item))
+		}
+		return items
+	}
+	if node.plain {
+		return plain(node.value)
+	}
+	return node.value
+}
+
+type m = map[string]interface{}
+type s = []interface{}
+
+func TestParseYAML(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want interface{}
+	}{
+		// Scalars.
+		{"plain", "a: hello world\n", m{"a": plain("hello world")}},
+		{"number", "a: 1.5e3\n", m{"a": plain("1.5e3")}},
+		{"null", "a: ~\nb:\n", m{"a": plain("~"), "b": plain("")}},
+		{"comment", "# head\na: b # c\nd: e#f\n", m{"a": plain("b"), "d": plain("e#f")}},
+		{"double quoted", `a: "tab\there \"q\" \u00e9"` + "\n", m{"a": "tab\there \"q\" é"}},
+		{"single quoted", "a: 'it''s # not a comment'\n", m{"a": "it's # not a comment"}},
+		{"multi-line plain", "a: one\n  two\n\n  three\n", m{"a": plain("one two\nthree")}},
+		{"document markers", "---\na: b\n...\nignored: true\n", m{"a": plain("b")}},
+		{"empty", "# only a comment\n", m{}},
+		{"empty file", "", m{}},
+		{"no final line break", "a: |+\n  one", m{"a": "one\n"}},
+		{"crlf", "a: b\r\nc: d\r\n", m{"a": plain("b"), "c": plain("d")}},
+
+		// Block collections.
+		{"nested mapping", "a:\n  b:\n    c: d\n  e: f\n", m{"a": m{"b": m{"c": plain("d")}, "e": plain("f")}}},
+		{"sequence", "a:\n  - x\n  - y\n", m{"a": s{plain("x"), plain("y")}}},
+		{"sequence at key indent", "a:\n- x\n- y\nb: z\n", m{"a": s{plain("x"), plain("y")}, "b": plain("z")}},
+		{"sequence of mappings", "a:\n  - name: web\n    size: 2\n  - name: db\n", m{"a": s{m{"name": plain("web"), "size": plain("2")}, m{"name": plain("db")}}}},
+		{"nested sequences", "- - a\n  - b\n- c\n", s{s{plain("a"), plain("b")}, plain("c")}},
+		{"empty item", "a:\n  -\n  - b\n", m{"a": s{plain(""), plain("b")}}},
+
+		// Flow collections.
+		{"flow sequence", `a: [1, "two", three]` + "\n", m{"a": s{plain("1"), "two", plain("three")}}},
+		{"flow mapping", "a: {x: 1, y: [2, 3], z: {}}\n", m{"a": m{"x": plain("1"), "y": s{plain("2"), plain("3")}, "z": m{}}}},
+		{"multi-line flow", "a: [\n  1, # one\n  2,\n]\nb: c\n", m{"a": s{plain("1"), plain("2")}, "b": plain("c")}},
+
+		// Block scalars.
+		{"literal", "a: |\n  one\n   two\n\nb: c\n", m{"a": "one\n two\n", "b": plain("c")}},
+		{"folded", "a: >\n  one\n  two\n\n  three\n", m{"a": "one two\nthree\n"}},
+		{"folded more indented", "a: >\n  one\n    code\n  two\n", m{"a": "one\n  code\ntwo\n"}},
+		{"strip", "a: |-\n  one\n\n", m{"a": "one"}},
+		{"keep", "a: |+\n  one\n\n", m{"a": "one\n\n"}},
+		{"block scalar comment", "a: | # the script\n  #!/bin/sh\n", m{"a": "#!/bin/sh\n"}},
+	}
+	for _, test := range tests {
+		node, diags := parseYAML([]byte(test.src), "config.yaml")
+		if diags.HasErrors() {
+			t.Errorf("%s: %s", test.name, diags.Error())
+			continue
+		}
+		if got := dumpYAML(node); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %#v, want %#v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestParseYAMLErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		summary string
+		line    int
+		column  int
+	}{
+		{"anchor", "a: &x 1\n", "Unsupported YAML feature", 1, 4},
+		{"alias", "a: 1\nb: *a\n", "Unsupported YAML feature", 2, 4},
+		{"tag", "a: !!str 1\n", "Unsupported YAML feature", 1, 4},
+		{"documents", "a: 1\n---\nb: 2\n", "Unsupported YAML feature", 2, 1},
+		{"directive", "%YAML 1.2\na: 1\n", "Unsupported YAML feature", 1, 1},
+		{"tab", "a:\n\tb: 1\n", "Invalid YAML indentation", 2, 1},
+		{"indentation", "a:\n    b: 1\n  c: 2\n", "Invalid YAML indentation", 3, 3},
+		{"duplicate key", "a: 1\na: 2\n", "Duplicate YAML key", 2, 1},
+		{"inline mapping", "a: b: c\n", "Invalid YAML value", 1, 5},
+		{"unterminated", "a: \"b\n", "", 1, 0},
+		{"chomping", "a: |2\n  b\n", "Unsupported YAML feature", 1, 4},
+	}
+	for _, test := range tests {
+		_, diags := parseYAML([]byte(test.src), "config.yaml")
+		if !diags.HasErrors() {
+			t.Errorf("%s: no error", test.name)
+			continue
+		}
+		diag := diags[0]
+		if test.summary != "" && diag.Summary != test.summary {
+			t.Errorf("%s: got %q, want %q", test.name, diag.Summary, test.summary)
+		}
+		if diag.Subject == nil || diag.Subject.Start.Line != test.line || test.column != 0 && diag.Subject.Start.Column != test.column {
+			t.Errorf("%s: error at %v, want %d:%d", test.name, diag.Subject, test.line, test.column)
+		}
+	}
+}
+
+func TestParseYAMLRanges(t *testing.T) {
+	src := "\ufeffa:\n  b: \"x\" # c\n  list:\n    - 1\n    - [2]\nscript: |\n  run\n"
+	node, diags := parseYAML([]byte(src), "config.yaml")
+	if diags.HasErrors() {
+		t.Fatal(diags)
+	}
+
+	at := func(startLine, startColumn, endLine, endColumn int) hcl.Range {
+		return hcl.Range{
+			Filename: "config.yaml",
+			Start:    hcl.Pos{Line: startLine, Column: startColumn},
+			End:      hcl.Pos{Line: endLine, Column: endColumn},
+		}
+	}
+	a := node.entries[0].value
+	b := a.entries[0]
+	list := a.entries[1].value
+	script := node.entries[1]
+	tests := []struct {
+		name string
+		got  hcl.Range
+		want hcl.Range
+	}{
+		{"key after the byte order mark", node.entries[0].keyRange, at(1, 1, 1, 2)},
+		{"nested key", b.keyRange, at(2, 3, 2, 4)},
+		{"quoted scalar", b.value.rng, at(2, 6, 2, 9)},
+		{"sequence", list.rng, at(4, 5, 5, 10)},
+		{"flow item", list.items[1].items[0].rng, at(5, 8, 5, 9)},
+		{"mapping", a.rng, at(2, 3, 5, 10)},
+		{"block scalar header", script.value.start, at(6, 9, 6, 10)},
+	}
+	for _, test := range tests {
+		got := test.got
+		got.Start.Byte, got.End.Byte = 0, 0
+		if got != test.want {
+			t.Errorf("%s: got %s, want %s", test.name, got, test.want)
+		}
+	}
+}
+
+func TestYAMLScalarValues(t *testing.T) {
+	tests := []struct {
+		src  string
+		want cty.Value
+	}{
+		{"~", cty.NullVal(cty.DynamicPseudoType)},
+		{"", cty.NullVal(cty.DynamicPseudoType)},
+		{"True", cty.True},
+		{"false", cty.False},
+		{"12", cty.NumberIntVal(12)},
+		{"+12", cty.NumberIntVal(12)},
+		{"0x1f", cty.NumberIntVal(31)},
+		{"0o17", cty.NumberIntVal(15)},
+		{"1.5e3", cty.NumberIntVal(1500)},
+		{"-.inf", cty.NegativeInfinity},
+		{"yes", cty.StringVal("yes")},
+		{"1.2.3", cty.StringVal("1.2.3")},
+		{`"12"`, cty.StringVal("12")},
+		{"'true'", cty.StringVal("true")},
+	}
+	for _, test := range tests {
+		node, diags := parseYAML([]byte("a: "+test.src+"\n"), "config.yaml")
+		if diags.HasErrors() {
+			t.Fatal(diags)
+		}
+		got, diags := node.entries[0].value.ctyValue()
+		if diags.HasErrors() {
+			t.Errorf("%s: %s", test.src, diags.Error())
+			continue
+		}
+		if !got.RawEquals(test.want) {
+			t.Errorf("%s: got %#v, want %#v", test.src, got, test.want)
+		}
+	}
+}
+
+func TestLoadYAMLConfig(t *testing.T) {
+	fsys := fstest.MapFS{
+		"cluster.datcfg.yaml": {Data: []byte(`
+cluster:
+  a:
+    controller_count: 1
+    worker_count: 3
+locals:
+  zones: [a, b]
+`)},
+		"invalid.datcfg.yaml": {Data: []byte("locals:\n  zone: &z a\n")},
+	}
+	_, diags := NewLoader(fsys, WithYAMLConfigs()).Load()
+	if !diags.HasErrors() || diags[0].Subject.Filename != "invalid.datcfg.yaml" || diags[0].Subject.Start.Line != 2 {
+		t.Errorf("got %v, want the anchor reported at line 2 of invalid.datcfg.yaml", diags)
+	}
+
+	delete(fsys, "invalid.datcfg.yaml")
+	result, diags := NewLoader(fsys, WithYAMLConfigs()).Load()
+	if diags.HasErrors() {
+		t.Fatal(diags)
+	}
+	if got := result.Clusters[0].Config.WorkerCount; got != 3 {
+		t.Errorf("worker_count = %d, want 3", got)
+	}
+	if got := result.Locals["zones"].LengthInt(); got != 2 {
+		t.Errorf("got %d zones, want 2", got)
+	}
+}
@@ -0,0 +1,385 @@
+package datcfg
+
+import (
+	"fmt"
+	"io/fs"
+	"math/big"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hcl/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// WithYAMLConfigs makes the loader also read the `.datcfg.yaml` files in the
+// root, for configs migrated from YAML-based tools. Their top-level keys are
+// the blocks and attributes of a config file, the labels of blocks are keys
+// nested in them, like in the JSON syntax of HCL:
+//
+//	component:
+//	  web:
+//	    replicas: 3
+//	    timeouts:
+//	      apply: 5m
+//
+// A key whose value is a sequence of mappings declares several blocks. The
+// values are static, strings are used as they are, without templates, and
+// the remaining scalars resolve like in YAML 1.2, to null, booleans and
+// numbers. Static references, like in `type: string` or `extends`, are
+// written as plain scalars.
+func WithYAMLConfigs() LoaderOption {
+	return func(l *Loader) {
+		l.yamlConfigs = true
+	}
+}
+
+// parseYAMLConfigFiles parses all `.datcfg.yaml` files in the root of fsys,
+// returning those that parsed cleanly.
+func parseYAMLConfigFiles(fsys fs.FS) ([]*hcl.File, hcl.Diagnostics) {
+	paths, err := fs.Glob(fsys, "*.datcfg.yaml")
+	if err != nil {
+		return nil, hcl.Diagnostics{
+			{
+				Severity: hcl.DiagError,
+				Summary:  "Failed to find config files",
+				Detail:   err.Error(),
+			},
+		}
+	}
+
+	var files []*hcl.File
+	var diags hcl.Diagnostics
+	for _, path := range paths {
+		src, err := fs.ReadFile(fsys, path)
+		if err != nil {
+			diags = append(diags, readFileDiags(path, err)...)
+			continue
+		}
+		if encDiags := checkEncoding(src, path); encDiags.HasErrors() {
+			diags = append(diags, encDiags...)
+			continue
+		}
+		node, parseDiags := parseYAML(src, path)
+		diags = append(diags, parseDiags...)
+		if parseDiags.HasErrors() {
+			continue
+		}
+		files = append(files, &hcl.File{Body: &yamlBody{node: node}, Bytes: src})
+	}
+	return files, diags
+}
+
+// yamlBody is the hcl.Body of a YAML mapping. Which of its keys are
+// attributes and which are blocks is decided by the schema it is decoded
+// with.
+type yamlBody struct {
+	node *yamlNode
+	// hidden are the keys already taken by PartialContent.
+	hidden map[string]bool
+}
+
+func (b *yamlBody) Content(schema *hcl.BodySchema) (*hcl.BodyContent, hcl.Diagnostics) {
+	content, rest, diags := b.PartialContent(schema)
+	if diags.HasErrors() {
+		return content, diags
+	}
+
+	var names []string
+	for _, attr := range schema.Attributes {
+		names = append(names, attr.Name)
+	}
+	for _, block := range schema.Blocks {
+		names = append(names, block.Type)
+	}
+	hidden := rest.(*yamlBody).hidden
+	for _, entry := range b.node.entries {
+		if hidden[entry.key] {
+			continue
+		}
+		detail := fmt.Sprintf("An argument or block named %q is not expected here.", entry.key)
+		if suggestion, ok := nearestName(entry.key, names); ok {
+			detail += fmt.Sprintf(" Did you mean %q?", suggestion)
+		}
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Unsupported argument",
+			Detail:   detail,
+			Subject:  entry.keyRange.Ptr(),
+		})
+	}
+	return content, diags
+}
+
+func (b *yamlBody) PartialContent(schema *hcl.BodySchema) (*hcl.BodyContent, hcl.Body, hcl.Diagnostics) {
+	content := &hcl.BodyContent{
+		Attributes:       hcl.Attributes{},
+		MissingItemRange: b.MissingItemRange(),
+	}
+	diags := b.checkMapping()
+	if diags.HasErrors() {
+		return content, b, diags
+	}
+
+	hidden := map[string]bool{}
+	for key := range b.hidden {
+		hidden[key] = true
+	}
+	attrSchemas := map[string]bool{}
+	for _, attr := range schema.Attributes {
+		attrSchemas[attr.Name] = true
+	}
+	blockSchemas := map[string]hcl.BlockHeaderSchema{}
+	for _, block := range schema.Blocks {
+		blockSchemas[block.Type] = block
+	}
+
+	for _, entry := range b.node.entries {
+		if b.hidden[entry.key] {
+			continue
+		}
+		if attrSchemas[entry.key] {
+			content.Attributes[entry.key] = entry.attribute()
+			hidden[entry.key] = true
+		} else if blockSchema, ok := blockSchemas[entry.key]; ok {
+			diags = append(diags, unpackYAMLBlock(entry.value, entry.key, entry.keyRange, blockSchema.LabelNames, nil, nil, &content.Blocks)...)
+			hidden[entry.key] = true
+		}
+	}
+
+	for _, attr := range schema.Attributes {
+		if _, ok := content.Attributes[attr.Name]; attr.Required && !ok {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Missing required argument",
+				Detail:   fmt.Sprintf("The argument %q is required, but no definition was found.", attr.Name),
+				Subject:  b.MissingItemRange().Ptr(),
+			})
+		}
+	}
+	return content, &yamlBody{node: b.node, hidden: hidden}, diags
+}
+
+func (b *yamlBody) JustAttributes() (hcl.Attributes, hcl.Diagnostics) {
+	attrs := hcl.Attributes{}
+	diags := b.checkMapping()
+	if diags.HasErrors() {
+		return attrs, diags
+	}
+	for _, entry := range b.node.entries {
+		if !b.hidden[entry.key] {
+			attrs[entry.key] = entry.attribute()
+		}
+	}
+	return attrs, nil
+}
+
+func (b *yamlBody) MissingItemRange() hcl.Range {
+	return hcl.Range{Filename: b.node.rng.Filename, Start: b.node.rng.End, End: b.node.rng.End}
+}
+
+// checkMapping reports if the body is not a mapping. Empty values, like
+// that of `locals:`, are empty bodies.
+func (b *yamlBody) checkMapping() hcl.Diagnostics {
+	if b.node.kind == yamlMapping || b.node.kind == yamlScalar && b.node.plain && b.node.value == "" {
+		return nil
+	}
+	return hcl.Diagnostics{
+		{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid YAML block",
+			Detail:   "A mapping is required here, setting the arguments and blocks of this block.",
+			Subject:  b.node.start.Ptr(),
+		},
+	}
+}
+
+func (e yamlEntry) attribute() *hcl.Attribute {
+	return &hcl.Attribute{
+		Name:      e.key,
+		Expr:      &yamlExpr{node: e.value},
+		Range:     hcl.RangeBetween(e.keyRange, e.value.rng),
+		NameRange: e.keyRange,
+	}
+}
+
+// unpackYAMLBlock appends the blocks of the given type declared by the
+// value of its key, taking the labels left from nested keys.
+func unpackYAMLBlock(node *yamlNode, blockType string, typeRange hcl.Range, labelsLeft, labels []string, labelRanges []hcl.Range, blocks *hcl.Blocks) hcl.Diagnostics {
+	if len(labelsLeft) > 0 {
+		if node.kind != yamlMapping || len(node.entries) == 0 {
+			return hcl.Diagnostics{
+				{
+					Severity: hcl.DiagError,
+					Summary:  "Missing block label",
+					Detail:   fmt.Sprintf("A mapping is required here, whose keys are the %s labels of the %s blocks.", labelsLeft[0], blockType),
+					Subject:  node.start.Ptr(),
+				},
+			}
+		}
+		var diags hcl.Diagnostics
+		for _, entry := range node.entries {
+			diags = append(diags, unpackYAMLBlock(
+				entry.value, blockType, typeRange, labelsLeft[1:],
+				append(append([]string(nil), labels...), entry.key),
+				append(append([]hcl.Range(nil), labelRanges...), entry.keyRange),
+				blocks,
+			)...)
+		}
+		return diags
+	}
+
+	defRange := typeRange
+	if len(labelRanges) > 0 {
+		defRange = hcl.RangeBetween(typeRange, labelRanges[len(labelRanges)-1])
+	}
+	bodies := []*yamlNode{node}
+	if node.kind == yamlSequence {
+		bodies = node.items
+	}
+	for _, body := range bodies {
+		*blocks = append(*blocks, &hcl.Block{
+			Type:        blockType,
+			Labels:      labels,
+			Body:        &yamlBody{node: body},
+			DefRange:    defRange,
+			TypeRange:   typeRange,
+			LabelRanges: labelRanges,
+		})
+	}
+	return nil
+}
+
+// yamlExpr is the hcl.Expression of a YAML value, which is static.
+type yamlExpr struct {
+	node *yamlNode
+}
+
+func (e *yamlExpr) Value(ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
+	return e.node.ctyValue()
+}
+
+func (e *yamlExpr) Variables() []hcl.Traversal {
+	return nil
+}
+
+func (e *yamlExpr) Range() hcl.Range {
+	return e.node.rng
+}
+
+func (e *yamlExpr) StartRange() hcl.Range {
+	return e.node.start
+}
+
+// ExprList makes sequences static lists for hcl.ExprList.
+func (e *yamlExpr) ExprList() []hcl.Expression {
+	if e.node.kind != yamlSequence {
+		return nil
+	}
+	exprs := make([]hcl.Expression, 0, len(e.node.items))
+	for _, item := range e.node.items {
+		exprs = append(exprs, &yamlExpr{node: item})
+	}
+	return exprs
+}
+
+// ExprMap makes mappings static maps for hcl.ExprMap.
+func (e *yamlExpr) ExprMap() []hcl.KeyValuePair {
+	if e.node.kind != yamlMapping {
+		return nil
+	}
+	pairs := make([]hcl.KeyValuePair, 0, len(e.node.entries))
+	for _, entry := range e.node.entries {
+		key := &yamlNode{kind: yamlScalar, value: entry.key, rng: entry.keyRange, start: entry.keyRange}
+		pairs = append(pairs, hcl.KeyValuePair{Key: &yamlExpr{node: key}, Value: &yamlExpr{node: entry.value}})
+	}
+	return pairs
+}
+
+// AsTraversal makes plain scalars like `component.network` static
+// references for hcl.AbsTraversalForExpr and hcl.ExprAsKeyword.
+func (e *yamlExpr) AsTraversal() hcl.Traversal {
+	if e.node.kind != yamlScalar || !e.node.plain {
+		return nil
+	}
+	traversal, diags := hclsyntax.ParseTraversalAbs([]byte(e.node.value), e.node.rng.Filename, e.node.rng.Start)
+	if diags.HasErrors() {
+		return nil
+	}
+	return traversal
+}
+
+var (
+	yamlInt   = regexp.MustCompile(`^[-+]?[0-9]+$`)
+	yamlFloat = regexp.MustCompile(`^[-+]?(\.[0-9]+|[0-9]+(\.[0-9]*)?)([eE][-+]?[0-9]+)?$`)
+	yamlRadix = regexp.MustCompile(`^0(x[0-9a-fA-F]+|o[0-7]+)$`)
+)
+
+// ctyValue returns the value of the node. Mappings are objects and
+// sequences are tuples, which convert to the maps and lists of a schema.
+func (n *yamlNode) ctyValue() (cty.Value, hcl.Diagnostics) {
+	var diags hcl.Diagnostics
+	switch n.kind {
+	case yamlMapping:
+		if len(n.entries) == 0 {
+			return cty.EmptyObjectVal, nil
+		}
+		attrs := make(map[string]cty.Value, len(n.entries))
+		for _, entry := range n.entries {
+			val, valDiags := entry.value.ctyValue()
+			diags = append(diags, valDiags...)
+			attrs[entry.key] = val
+		}
+		return cty.ObjectVal(attrs), diags
+	case yamlSequence:
+		if len(n.items) == 0 {
+			return cty.EmptyTupleVal, nil
+		}
+		vals := make([]cty.Value, 0, len(n.items))
+		for _, item := range n.items {
+			val, valDiags := item.ctyValue()
+			diags = append(diags, valDiags...)
+			vals = append(vals, val)
+		}
+		return cty.TupleVal(vals), diags
+	}
+
+	if !n.plain {
+		return cty.StringVal(n.value), nil
+	}
+	switch n.value {
+	case "", "~", "null", "Null", "NULL":
+		return cty.NullVal(cty.DynamicPseudoType), nil
+	case "true", "True", "TRUE":
+		return cty.True, nil
+	case "false", "False", "FALSE":
+		return cty.False, nil
+	case ".inf", ".Inf", ".INF", "+.inf", "+.Inf", "+.INF":
+		return cty.PositiveInfinity, nil
+	case "-.inf", "-.Inf", "-.INF":
+		return cty.NegativeInfinity, nil
+	case ".nan", ".NaN", ".NAN":
+		return cty.DynamicVal, hcl.Diagnostics{
+			{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid number",
+				Detail:   "Numbers can't be NaN.",
+				Subject:  n.rng.Ptr(),
+			},
+		}
+	}
+	if m := yamlRadix.FindStringSubmatch(n.value); m != nil {
+		base := 16
+		if m[1][0] == 'o' {
+			base = 8
+		}
+		i, _ := new(big.Int).SetString(m[1][1:], base)
+		return cty.NumberVal(new(big.Float).SetInt(i)), nil
+	}
+	if yamlInt.MatchString(n.value) || yamlFloat.MatchString(n.value) {
+		if val, err := cty.ParseNumberVal(strings.TrimPrefix(n.value, "+")); err == nil {
+			return val, nil
+		}
+	}
+	return cty.StringVal(n.value), nil
+}
@@ -0,0 +1,282 @@
+// Package diagfmt prints diagnostics as text, or in the formats CI systems
+// read: GitHub workflow commands, GitLab code quality reports and SARIF.
+package diagfmt
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/imranansari/hcl2-demo/datcfg"
+)
+
+// Format is the name of a format diagnostics are printed in. It is a
+// pflag.Value, to be set by a flag.
+type Format string
+
+// The supported formats. Text is the default, also for the empty Format.
+const (
+	Text   Format = "text"
+	GitHub Format = "github"
+	GitLab Format = "gitlab"
+	SARIF  Format = "sarif"
+)
+
+func (f *Format) String() string {
+	return string(*f)
+}
+
+func (f *Format) Set(s string) error {
+	switch Format(s) {
+	case Text, GitHub, GitLab, SARIF:
+		*f = Format(s)
+		return nil
+	}
+	return fmt.Errorf("unknown format %q", s)
+}
+
+func (f *Format) Type() string {
+	return "format"
+}
+
+// Printer prints diagnostics in its format. The formats that write a single
+// document collect the diagnostics until Flush.
+type Printer struct {
+	Format Format
+
+	pending hcl.Diagnostics
+}
+
+// Print prints the given diagnostics to w, or collects them for Flush.
+func (p *Printer) Print(w io.Writer, diags hcl.Diagnostics) {
+	switch p.Format {
+	case GitHub:
+		for _, diag := range diags {
+			fmt.Fprintln(w, githubCommand(diag))
+		}
+	case GitLab, SARIF:
+		p.pending = append(p.pending, diags...)
+	default:
+		for _, diag := range diags {
+			if diag.Subject == nil {
+				fmt.Fprintf(w, "%s; %s\n", diag.Summary, diag.Detail)
+				continue
+			}
+			fmt.Fprintf(w, "%v\n", diag)
+		}
+	}
+}
+
+// Flush writes the diagnostics collected for the formats that produce a
+// single document. Nothing is written if there are none.
+func (p *Printer) Flush(w io.Writer) {
+	if len(p.pending) == 0 {
+		return
+	}
+	var doc interface{}
+	switch p.Format {
+	case GitLab:
+		doc = gitlabReport(p.pending)
+	case SARIF:
+		doc = sarifReport(p.pending)
+	}
+	p.pending = nil
+
+	out, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	fmt.Fprintf(w, "%s\n", out)
+}
+
+// githubCommand renders a diagnostic as a GitHub Actions workflow command,
+// which the runner shows as an annotation.
+func githubCommand(diag *hcl.Diagnostic) string {
+	command := "error"
+	if diag.Severity == hcl.DiagWarning {
+		command = "warning"
+	}
+	props := []string{}
+	if diag.Subject != nil {
+		rng := diag.Subject
+		props = append(props,
+			"file="+githubEscapeProperty(annotationPath(rng.Filename)),
+			fmt.Sprintf("line=%d", rng.Start.Line),
+			fmt.Sprintf("col=%d", rng.Start.Column),
+			fmt.Sprintf("endLine=%d", rng.End.Line),
+			fmt.Sprintf("endColumn=%d", rng.End.Column),
+		)
+	}
+	props = append(props, "title="+githubEscapeProperty(diag.Summary))
+	return fmt.Sprintf("::%s %s::%s", command, strings.Join(props, ","), githubEscapeData(diag.Detail))
+}
+
+func githubEscapeData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+func githubEscapeProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
+
+// annotationPath returns the path of a config file relative to the root of
+// the repository checked out by the CI system, which annotations refer to.
+// Outside of CI, the path is relative to the working directory.
+func annotationPath(filename string) string {
+	for _, env := range []string{"GITHUB_WORKSPACE", "CI_PROJECT_DIR"} {
+		root := os.Getenv(env)
+		if root == "" {
+			continue
+		}
+		abs, err := filepath.Abs(filename)
+		if err != nil {
+			break
+		}
+		if rel, err := filepath.Rel(root, abs); err == nil && !strings.HasPrefix(rel, "..") {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return filepath.ToSlash(filename)
+}
+
+// gitlabIssue is an entry of a GitLab code quality report.
+type gitlabIssue struct {
+	Description string         `json:"description"`
+	CheckName   string         `json:"check_name"`
+	Fingerprint string         `json:"fingerprint"`
+	Severity    string         `json:"severity"`
+	Location    gitlabLocation `json:"location"`
+}
+
+type gitlabLocation struct {
+	Path  string      `json:"path"`
+	Lines gitlabLines `json:"lines"`
+}
+
+type gitlabLines struct {
+	Begin int `json:"begin"`
+}
+
+func gitlabReport(diags hcl.Diagnostics) []gitlabIssue {
+	issues := []gitlabIssue{}
+	for _, diag := range diags {
+		issue := gitlabIssue{
+			Description: diag.Summary + ": " + diag.Detail,
+			CheckName:   diag.Summary,
+			Severity:    "major",
+			// The code quality report requires a location, diagnostics
+			// without one are reported on the first line of the directory.
+			Location: gitlabLocation{Path: annotationPath("."), Lines: gitlabLines{Begin: 1}},
+		}
+		if diag.Severity == hcl.DiagWarning {
+			issue.Severity = "minor"
+		}
+		if diag.Subject != nil {
+			issue.Location = gitlabLocation{
+				Path:  annotationPath(diag.Subject.Filename),
+				Lines: gitlabLines{Begin: diag.Subject.Start.Line},
+			}
+		}
+		// The fingerprint leaves out the line, so that an issue is tracked
+		// across changes moving it.
+		sum := sha256.Sum256([]byte(issue.Location.Path + "\x00" + diag.Summary + "\x00" + diag.Detail))
+		issue.Fingerprint = hex.EncodeToString(sum[:])
+		issues = append(issues, issue)
+	}
+	return issues
+}
+
+// sarifLog is a SARIF 2.1.0 log with the diagnostics of a single run, as
+// uploaded to code scanning services.
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type sarifResult struct {
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+	EndLine     int `json:"endLine"`
+	EndColumn   int `json:"endColumn"`
+}
+
+func sarifReport(diags hcl.Diagnostics) sarifLog {
+	run := sarifRun{
+		Tool:    sarifTool{Driver: sarifDriver{Name: "datcfg", Version: datcfg.Version}},
+		Results: []sarifResult{},
+	}
+	for _, diag := range diags {
+		result := sarifResult{
+			Level:   "error",
+			Message: sarifMessage{Text: diag.Summary + ": " + diag.Detail},
+		}
+		if diag.Severity == hcl.DiagWarning {
+			result.Level = "warning"
+		}
+		if rng := diag.Subject; rng != nil {
+			result.Locations = []sarifLocation{
+				{
+					PhysicalLocation: sarifPhysicalLocation{
+						ArtifactLocation: sarifArtifactLocation{URI: annotationPath(rng.Filename)},
+						Region: sarifRegion{
+							StartLine:   rng.Start.Line,
+							StartColumn: rng.Start.Column,
+							EndLine:     rng.End.Line,
+							EndColumn:   rng.End.Column,
+						},
+					},
+				},
+			}
+		}
+		run.Results = append(run.Results, result)
+	}
+	return sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	}
+}
@@ -0,0 +1,580 @@
+// Package lsp serves the language server protocol for `.datcfg` files, for
+// the lsp subcommand of dat-config.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hcl/hclsyntax"
+	"github.com/imranansari/hcl2-demo/datcfg"
+	"github.com/imranansari/hcl2-demo/internal/memfs"
+)
+
+// NewServer returns a server reading messages from in and writing to out,
+// for the configuration in the directory root, which it loads with the
+// given options.
+func NewServer(in io.Reader, out io.Writer, root string, opts []datcfg.LoaderOption) *Server {
+	return &Server{
+		in:         bufio.NewReader(in),
+		out:        out,
+		root:       root,
+		docs:       map[string][]byte{},
+		loaderOpts: opts,
+	}
+}
+
+// Server is a minimal language server for `.datcfg` files. Documents are
+// synced in full, every change reloads the whole configuration with the open
+// documents overlaid on the files on disk.
+type Server struct {
+	in         *bufio.Reader
+	out        io.Writer
+	root       string
+	loaderOpts []datcfg.LoaderOption
+
+	// docs are the contents of the open documents, keyed by their path
+	// relative to root.
+	docs map[string][]byte
+	// files and config are the results of the last successful parse and
+	// load, used to answer requests while the documents contain errors.
+	files  []*hcl.File
+	config *datcfg.Config
+
+	shutdown bool
+}
+
+type request struct {
+	ID     *json.RawMessage `json:"id"`
+	Method string           `json:"method"`
+	Params json.RawMessage  `json:"params"`
+}
+
+type response struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id"`
+	Result  interface{}      `json:"result"`
+	Error   *responseError   `json:"error,omitempty"`
+}
+
+type notification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
+}
+
+type responseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type textRange struct {
+	Start position `json:"start"`
+	End   position `json:"end"`
+}
+
+type location struct {
+	URI   string    `json:"uri"`
+	Range textRange `json:"range"`
+}
+
+type diagnostic struct {
+	Range    textRange `json:"range"`
+	Severity int       `json:"severity"`
+	Source   string    `json:"source"`
+	Message  string    `json:"message"`
+}
+
+type completionItem struct {
+	Label      string `json:"label"`
+	Kind       int    `json:"kind"`
+	InsertText string `json:"insertText"`
+}
+
+type textDocumentPositionParams struct {
+	TextDocument struct {
+		URI string `json:"uri"`
+	} `json:"textDocument"`
+	Position position `json:"position"`
+}
+
+const (
+	severityError      = 1
+	severityWarning    = 2
+	completionProperty = 10
+	methodNotFound     = -32601
+	invalidParams      = -32602
+)
+
+// Serve answers the requests read from the input until the client exits.
+func (s *Server) Serve() error {
+	for {
+		body, err := s.readMessage()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var req request
+		if err := json.Unmarshal(body, &req); err != nil {
+			return fmt.Errorf("invalid message: %s", err)
+		}
+		if req.Method == "exit" {
+			if !s.shutdown {
+				return fmt.Errorf("exit before shutdown")
+			}
+			return nil
+		}
+
+		result, lspErr := s.handle(req)
+		if req.ID == nil {
+			continue
+		}
+		if err := s.writeMessage(response{JSONRPC: "2.0", ID: req.ID, Result: result, Error: lspErr}); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) readMessage() ([]byte, error) {
+	length := -1
+	for {
+		line, err := s.in.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		if value := strings.TrimPrefix(line, "Content-Length:"); value != line {
+			length, err = strconv.Atoi(strings.TrimSpace(value))
+			if err != nil {
+				return nil, fmt.Errorf("invalid Content-Length header: %s", err)
+			}
+		}
+	}
+	if length < 0 {
+		return nil, fmt.Errorf("missing Content-Length header")
+	}
+
+	body := make([]byte, length)
+	_, err := io.ReadFull(s.in, body)
+	return body, err
+}
+
+func (s *Server) writeMessage(msg interface{}) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	return err
+}
+
+func (s *Server) handle(req request) (interface{}, *responseError) {
+	switch req.Method {
+	case "initialize":
+		var params struct {
+			RootURI string `json:"rootUri"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, &responseError{Code: invalidParams, Message: err.Error()}
+		}
+		if root, ok := uriPath(params.RootURI); ok {
+			s.root = root
+		}
+		return map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"textDocumentSync":   1,
+				"hoverProvider":      true,
+				"definitionProvider": true,
+				"completionProvider": map[string]interface{}{},
+			},
+		}, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		var params struct {
+			TextDocument struct {
+				URI  string `json:"uri"`
+				Text string `json:"text"`
+			} `json:"textDocument"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err == nil {
+			s.update(params.TextDocument.URI, []byte(params.TextDocument.Text))
+		}
+		return nil, nil
+	case "textDocument/didChange":
+		var params struct {
+			TextDocument struct {
+				URI string `json:"uri"`
+			} `json:"textDocument"`
+			ContentChanges []struct {
+				Text string `json:"text"`
+			} `json:"contentChanges"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err == nil && len(params.ContentChanges) > 0 {
+			last := params.ContentChanges[len(params.ContentChanges)-1]
+			s.update(params.TextDocument.URI, []byte(last.Text))
+		}
+		return nil, nil
+	case "textDocument/didClose":
+		var params struct {
+			TextDocument struct {
+				URI string `json:"uri"`
+			} `json:"textDocument"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err == nil {
+			s.update(params.TextDocument.URI, nil)
+		}
+		return nil, nil
+	case "textDocument/hover", "textDocument/definition", "textDocument/completion":
+		var params textDocumentPositionParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, &responseError{Code: invalidParams, Message: err.Error()}
+		}
+		file, offset, ok := s.locate(params)
+		if !ok {
+			return nil, nil
+		}
+		switch req.Method {
+		case "textDocument/hover":
+			return s.hover(file, offset), nil
+		case "textDocument/definition":
+			return s.definition(file, offset), nil
+		default:
+			return s.completion(file, offset), nil
+		}
+	}
+
+	if req.ID == nil {
+		// Unknown notifications, like `initialized`, are ignored.
+		return nil, nil
+	}
+	return nil, &responseError{Code: methodNotFound, Message: fmt.Sprintf("method %q is not supported", req.Method)}
+}
+
+// update sets the content of an open document, or closes it if src is nil,
+// and publishes the diagnostics of all open documents.
+func (s *Server) update(uri string, src []byte) {
+	name, ok := s.relativeName(uri)
+	if !ok {
+		return
+	}
+	if src == nil {
+		delete(s.docs, name)
+		s.publishDiagnostics(name, nil)
+	} else {
+		s.docs[name] = src
+	}
+
+	fsys := overlayFS{base: os.DirFS(s.root), docs: s.docs}
+	files, diags := datcfg.ParseConfigFiles(fsys)
+	if !diags.HasErrors() {
+		s.files = files
+
+		var config *datcfg.Config
+		config, diags = datcfg.NewLoader(fsys, s.loaderOpts...).Load()
+		if config != nil {
+			s.config = config
+		}
+	}
+
+	byFile := map[string]hcl.Diagnostics{}
+	for _, diag := range diags {
+		if diag.Subject == nil {
+			// Diagnostics without a source range are shown at the top of
+			// every open document.
+			for name := range s.docs {
+				byFile[name] = append(byFile[name], diag)
+			}
+			continue
+		}
+		byFile[diag.Subject.Filename] = append(byFile[diag.Subject.Filename], diag)
+	}
+	for name := range s.docs {
+		s.publishDiagnostics(name, byFile[name])
+	}
+}
+
+func (s *Server) publishDiagnostics(name string, diags hcl.Diagnostics) {
+	src := s.docs[name]
+	lspDiags := []diagnostic{}
+	for _, diag := range diags {
+		lspDiag := diagnostic{
+			Severity: severityWarning,
+			Source:   "datcfg",
+			Message:  diag.Summary,
+		}
+		if diag.Severity == hcl.DiagError {
+			lspDiag.Severity = severityError
+		}
+		if diag.Detail != "" {
+			lspDiag.Message += ": " + diag.Detail
+		}
+		if diag.Subject != nil {
+			lspDiag.Range = toLSPRange(src, *diag.Subject)
+		}
+		lspDiags = append(lspDiags, lspDiag)
+	}
+
+	s.writeMessage(notification{
+		JSONRPC: "2.0",
+		Method:  "textDocument/publishDiagnostics",
+		Params: map[string]interface{}{
+			"uri":         s.uri(name),
+			"diagnostics": lspDiags,
+		},
+	})
+}
+
+// locate returns the last successfully parsed version of the document at the
+// given position, and the byte offset of the position in it.
+func (s *Server) locate(params textDocumentPositionParams) (*hcl.File, int, bool) {
+	name, ok := s.relativeName(params.TextDocument.URI)
+	if !ok {
+		return nil, 0, false
+	}
+	for _, file := range s.files {
+		if file.Body.MissingItemRange().Filename == name {
+			return file, toOffset(file.Bytes, params.Position), true
+		}
+	}
+	return nil, 0, false
+}
+
+func (s *Server) hover(file *hcl.File, offset int) interface{} {
+	traversal, name, ok := variableAt(file, offset)
+	if !ok || s.config == nil {
+		return nil
+	}
+	val, ok := s.config.Variables[name]
+	if !ok {
+		return nil
+	}
+	rendered := datcfg.FormatValue(s.config.RedactValue(val))
+	if s.config.Sensitive[name] || s.config.Redacted(name) {
+		rendered = "(sensitive)"
+	}
+
+	return map[string]interface{}{
+		"contents": map[string]interface{}{
+			"kind":  "markdown",
+			"value": fmt.Sprintf("`var.%s` (%s)\n\n```\n%s\n```", name, val.Type().FriendlyName(), rendered),
+		},
+		"range": toLSPRange(file.Bytes, traversal.SourceRange()),
+	}
+}
+
+func (s *Server) definition(file *hcl.File, offset int) interface{} {
+	_, name, ok := variableAt(file, offset)
+	if !ok {
+		return nil
+	}
+
+	for _, f := range s.files {
+		body, ok := f.Body.(*hclsyntax.Body)
+		if !ok {
+			continue
+		}
+		for _, block := range body.Blocks {
+			if block.Type == "variable" && len(block.Labels) > 0 && block.Labels[0] == name {
+				return location{
+					URI:   s.uri(block.DefRange().Filename),
+					Range: toLSPRange(f.Bytes, block.DefRange()),
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Server) completion(file *hcl.File, offset int) interface{} {
+	items := []completionItem{}
+	body, ok := file.Body.(*hclsyntax.Body)
+	if !ok {
+		return items
+	}
+
+	for _, block := range body.Blocks {
+		if offset <= block.OpenBraceRange.Start.Byte || offset > block.CloseBraceRange.Start.Byte {
+			continue
+		}
+		for _, name := range datcfg.BlockAttributes(block) {
+			if _, set := block.Body.Attributes[name]; set {
+				continue
+			}
+			items = append(items, completionItem{
+				Label:      name,
+				Kind:       completionProperty,
+				InsertText: name + " = ",
+			})
+		}
+	}
+	return items
+}
+
+// variableAt returns the `var.<name>` traversal at the given byte offset of
+// the file, if any.
+func variableAt(file *hcl.File, offset int) (hcl.Traversal, string, bool) {
+	body, ok := file.Body.(*hclsyntax.Body)
+	if !ok {
+		return nil, "", false
+	}
+
+	var found hcl.Traversal
+	hclsyntax.VisitAll(body, func(node hclsyntax.Node) hcl.Diagnostics {
+		expr, ok := node.(*hclsyntax.ScopeTraversalExpr)
+		if ok && expr.SrcRange.ContainsOffset(offset) {
+			found = expr.Traversal
+		}
+		return nil
+	})
+
+	if found.RootName() != "var" || len(found) < 2 {
+		return nil, "", false
+	}
+	attr, ok := found[1].(hcl.TraverseAttr)
+	if !ok {
+		return nil, "", false
+	}
+	return found, attr.Name, true
+}
+
+func (s *Server) relativeName(uri string) (string, bool) {
+	path, ok := uriPath(uri)
+	if !ok {
+		return "", false
+	}
+	rel, err := filepath.Rel(s.root, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+func (s *Server) uri(name string) string {
+	u := url.URL{Scheme: "file", Path: filepath.ToSlash(filepath.Join(s.root, name))}
+	return u.String()
+}
+
+func uriPath(uri string) (string, bool) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return "", false
+	}
+	return filepath.FromSlash(u.Path), true
+}
+
+// toOffset converts an LSP position, whose character is counted in UTF-16
+// code units, to a byte offset in src.
+func toOffset(src []byte, pos position) int {
+	offset := 0
+	for line := 0; line < pos.Line; line++ {
+		next := strings.IndexByte(string(src[offset:]), '\n')
+		if next < 0 {
+			return len(src)
+		}
+		offset += next + 1
+	}
+
+	for units := 0; units < pos.Character && offset < len(src) && src[offset] != '\n'; {
+		r, size := utf8.DecodeRune(src[offset:])
+		units += len(utf16.Encode([]rune{r}))
+		offset += size
+	}
+	return offset
+}
+
+func toLSPRange(src []byte, rng hcl.Range) textRange {
+	return textRange{
+		Start: toLSPPosition(src, rng.Start),
+		End:   toLSPPosition(src, rng.End),
+	}
+}
+
+func toLSPPosition(src []byte, pos hcl.Pos) position {
+	if pos.Byte > len(src) {
+		return position{Line: pos.Line - 1, Character: pos.Column - 1}
+	}
+	lineStart := strings.LastIndexByte(string(src[:pos.Byte]), '\n') + 1
+	units := len(utf16.Encode([]rune(string(src[lineStart:pos.Byte]))))
+	return position{Line: pos.Line - 1, Character: units}
+}
+
+// overlayFS reads the open documents from memory and everything else from
+// the base filesystem. The documents are listed in their directories too,
+// so that new documents that were never saved are loaded like the others.
+type overlayFS struct {
+	base fs.FS
+	docs map[string][]byte
+}
+
+func (o overlayFS) Open(name string) (fs.File, error) {
+	if _, ok := o.docs[name]; ok {
+		return memfs.FS(o.docs).Open(name)
+	}
+	f, err := o.base.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		// A directory only holding new documents.
+		if f, docErr := memfs.FS(o.docs).Open(name); docErr == nil {
+			return f, nil
+		}
+	}
+	return f, err
+}
+
+func (o overlayFS) ReadFile(name string) ([]byte, error) {
+	if src, ok := o.docs[name]; ok {
+		return src, nil
+	}
+	return fs.ReadFile(o.base, name)
+}
+
+// ReadDir lists the named directory of the base filesystem together with
+// the open documents in it.
+func (o overlayFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entries, err := fs.ReadDir(o.base, name)
+	docEntries, docErr := memfs.FS(o.docs).ReadDir(name)
+	if err != nil && (docErr != nil || !errors.Is(err, fs.ErrNotExist)) {
+		return nil, err
+	}
+
+	byName := map[string]fs.DirEntry{}
+	for _, entry := range entries {
+		byName[entry.Name()] = entry
+	}
+	for _, entry := range docEntries {
+		if _, ok := byName[entry.Name()]; !ok || !entry.IsDir() {
+			byName[entry.Name()] = entry
+		}
+	}
+	merged := make([]fs.DirEntry, 0, len(byName))
+	for _, entry := range byName {
+		merged = append(merged, entry)
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].Name() < merged[j].Name() })
+	return merged, nil
+}
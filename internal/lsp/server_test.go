@@ -0,0 +1,57 @@
+package lsp
+
+import (
+	"io/fs"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestOverlayFS(t *testing.T) {
+	fsys := overlayFS{
+		base: fstest.MapFS{
+			"cluster.datcfg":    {Data: []byte("saved")},
+			"components.datcfg": {Data: []byte("saved")},
+		},
+		docs: map[string][]byte{
+			"cluster.datcfg":             []byte("edited"),
+			"new.datcfg":                 []byte("unsaved"),
+			"environments/prod/dat.vars": []byte("unsaved"),
+		},
+	}
+
+	names, err := fs.Glob(fsys, "*.datcfg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"cluster.datcfg", "components.datcfg", "new.datcfg"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got %v, want the unsaved document listed too, %v", names, want)
+	}
+
+	for name, want := range map[string]string{
+		"cluster.datcfg":             "edited",
+		"components.datcfg":          "saved",
+		"new.datcfg":                 "unsaved",
+		"environments/prod/dat.vars": "unsaved",
+	} {
+		if src, err := fs.ReadFile(fsys, name); err != nil || string(src) != want {
+			t.Errorf("%s: got %q, %v, want %q", name, src, err, want)
+		}
+		f, err := fsys.Open(name)
+		if err != nil {
+			t.Errorf("%s: %s", name, err)
+			continue
+		}
+		if info, err := f.Stat(); err != nil || info.Size() != int64(len(want)) {
+			t.Errorf("%s: got size %v, %v, want %d", name, info.Size(), err, len(want))
+		}
+		f.Close()
+	}
+
+	if _, err := fs.ReadDir(fsys, "environments/prod"); err != nil {
+		t.Errorf("the directory of an unsaved document: %s", err)
+	}
+	if _, err := fs.ReadDir(fsys, "missing"); err == nil {
+		t.Errorf("listed a missing directory")
+	}
+}
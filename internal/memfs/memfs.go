@@ -0,0 +1,156 @@
+// Package memfs is a read-only in-memory filesystem, for loading configs
+// that don't come from the disk, like the bundles posted to serve or the
+// files of a recorded run.
+package memfs
+
+import (
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FS holds the content of files by their slash-separated path. Directories
+// are implied by the paths of the files in them.
+type FS map[string][]byte
+
+// Open opens the named file or directory.
+func (m FS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if data, ok := m[name]; ok {
+		return &file{info: info{name: path.Base(name), size: int64(len(data))}, data: data}, nil
+	}
+	entries, ok := m.entries(name)
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return &dir{info: info{name: path.Base(name), dir: true}, entries: entries}, nil
+}
+
+// ReadFile returns the content of the named file.
+func (m FS) ReadFile(name string) ([]byte, error) {
+	data, ok := m[name]
+	if !ok {
+		if _, isDir := m.entries(name); isDir {
+			return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrInvalid}
+		}
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrNotExist}
+	}
+	return append([]byte(nil), data...), nil
+}
+
+// ReadDir returns the entries of the named directory, sorted by name.
+func (m FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	entries, ok := m.entries(name)
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	return entries, nil
+}
+
+// entries returns the entries of the directory name, and whether it exists.
+// The root always does.
+func (m FS) entries(name string) ([]fs.DirEntry, bool) {
+	prefix := ""
+	if name != "." {
+		prefix = name + "/"
+	}
+	children := map[string]fs.DirEntry{}
+	for p, data := range m {
+		rest := strings.TrimPrefix(p, prefix)
+		if rest == p && prefix != "" {
+			continue
+		}
+		if child, _, nested := strings.Cut(rest, "/"); nested {
+			children[child] = info{name: child, dir: true}
+		} else if _, ok := children[rest]; !ok {
+			children[rest] = info{name: rest, size: int64(len(data))}
+		}
+	}
+	if len(children) == 0 && name != "." {
+		return nil, false
+	}
+	entries := make([]fs.DirEntry, 0, len(children))
+	for _, entry := range children {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, true
+}
+
+// info describes a file or directory, as both fs.FileInfo and fs.DirEntry.
+type info struct {
+	name string
+	size int64
+	dir  bool
+}
+
+func (i info) Name() string               { return i.name }
+func (i info) Size() int64                { return i.size }
+func (i info) ModTime() time.Time         { return time.Time{} }
+func (i info) IsDir() bool                { return i.dir }
+func (i info) Sys() interface{}           { return nil }
+func (i info) Info() (fs.FileInfo, error) { return i, nil }
+func (i info) Type() fs.FileMode          { return i.Mode().Type() }
+
+func (i info) Mode() fs.FileMode {
+	if i.dir {
+		return fs.ModeDir | 0555
+	}
+	return 0444
+}
+
+type file struct {
+	info
+	data   []byte
+	offset int
+}
+
+func (f *file) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *file) Close() error               { return nil }
+
+func (f *file) Read(b []byte) (int, error) {
+	if f.offset >= len(f.data) {
+		return 0, io.EOF
+	}
+	n := copy(b, f.data[f.offset:])
+	f.offset += n
+	return n, nil
+}
+
+type dir struct {
+	info
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *dir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *dir) Close() error               { return nil }
+
+func (d *dir) Read(b []byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: fs.ErrInvalid}
+}
+
+// ReadDir implements fs.ReadDirFile.
+func (d *dir) ReadDir(n int) ([]fs.DirEntry, error) {
+	rest := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return rest, nil
+	}
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(rest) {
+		n = len(rest)
+	}
+	d.offset += n
+	return rest[:n], nil
+}
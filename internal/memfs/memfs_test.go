@@ -0,0 +1,25 @@
+package memfs
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestFS(t *testing.T) {
+	fsys := FS{
+		"cluster.datcfg":                         []byte(`cluster "a" {}`),
+		"dat.vars":                               []byte(`foo = 1`),
+		"environments/prod/cluster.vars":         []byte(`foo = 2`),
+		"environments/staging/cluster.vars":      []byte(`foo = 3`),
+		"environments/staging/cluster.vars.json": []byte(`{"foo": 4}`),
+	}
+	if err := fstest.TestFS(fsys, "cluster.datcfg", "dat.vars", "environments/prod/cluster.vars", "environments/staging/cluster.vars.json"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFSEmpty(t *testing.T) {
+	if err := fstest.TestFS(FS{}); err != nil {
+		t.Fatal(err)
+	}
+}
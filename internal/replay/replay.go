@@ -0,0 +1,199 @@
+// Package replay records the inputs of a run, the files and environment
+// variables its loaders read, into an archive, and reads the archive back to
+// reproduce the run elsewhere.
+package replay
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/imranansari/hcl2-demo/datcfg"
+	"github.com/imranansari/hcl2-demo/internal/memfs"
+)
+
+// Manifest is the manifest.json of a recorded run. The files read
+// from the directory Dirs[i] are stored under dirs/<i>/ in the archive.
+type Manifest struct {
+	// Version is the datcfg.Version that recorded the run.
+	Version string            `json:"version"`
+	Args    []string          `json:"args"`
+	Env     map[string]string `json:"env,omitempty"`
+	Dirs    []string          `json:"dirs"`
+}
+
+// Recorder records the inputs of the loaders of a run started with
+// --record.
+type Recorder struct {
+	Path string
+	// recordings are the recordings of the loaded directories, by the
+	// directory as given on the command line.
+	recordings map[string]*datcfg.Recording
+}
+
+// NewRecorder returns a recorder of a run, writing its archive to path.
+func NewRecorder(path string) *Recorder {
+	return &Recorder{Path: path, recordings: map[string]*datcfg.Recording{}}
+}
+
+// Recording returns the recording of the loaders of the directory dir, as
+// given on the command line.
+func (r *Recorder) Recording(dir string) *datcfg.Recording {
+	rec, ok := r.recordings[dir]
+	if !ok {
+		rec = &datcfg.Recording{}
+		r.recordings[dir] = rec
+	}
+	return rec
+}
+
+// Write writes the archive of the run with the given command line
+// arguments, those of the --record flag left out.
+func (r *Recorder) Write(args []string) error {
+	manifest := Manifest{
+		Version: datcfg.Version,
+		Args:    WithoutRecordFlag(args),
+		Env:     map[string]string{},
+	}
+	for dir := range r.recordings {
+		manifest.Dirs = append(manifest.Dirs, dir)
+	}
+	sort.Strings(manifest.Dirs)
+	for _, dir := range manifest.Dirs {
+		for name, val := range r.recordings[dir].Env {
+			manifest.Env[name] = val
+		}
+	}
+
+	f, err := os.Create(r.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+
+	now := time.Now()
+	add := func(name string, content []byte) error {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), ModTime: now}); err != nil {
+			return err
+		}
+		_, err := tw.Write(content)
+		return err
+	}
+	src, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := add("manifest.json", append(src, '\n')); err != nil {
+		return err
+	}
+	for i, dir := range manifest.Dirs {
+		files := r.recordings[dir].Files
+		names := make([]string, 0, len(files))
+		for name := range files {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if err := add(path.Join("dirs", strconv.Itoa(i), name), files[name]); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// WithoutRecordFlag returns the arguments without the --record flag, so that
+// replaying them doesn't record again.
+func WithoutRecordFlag(args []string) []string {
+	var kept []string
+	for i := 0; i < len(args); i++ {
+		name := strings.TrimLeft(args[i], "-")
+		switch {
+		case !strings.HasPrefix(args[i], "-"):
+			kept = append(kept, args[i])
+		case name == "record":
+			i++
+		case !strings.HasPrefix(name, "record="):
+			kept = append(kept, args[i])
+		}
+	}
+	return kept
+}
+
+// Archive is the content of an archive written with --record.
+type Archive struct {
+	Manifest Manifest
+	// Dirs are the recorded files, by the directory they were read from.
+	Dirs map[string]memfs.FS
+}
+
+// ReadArchive reads the archive written with --record to the file name.
+func ReadArchive(name string) (*Archive, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest []byte
+	files := map[string][]byte{}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Name == "manifest.json" {
+			manifest = content
+		} else {
+			files[hdr.Name] = content
+		}
+	}
+	if manifest == nil {
+		return nil, fmt.Errorf("%s has no manifest.json, it was not written with --record", name)
+	}
+
+	archive := &Archive{Dirs: map[string]memfs.FS{}}
+	if err := json.Unmarshal(manifest, &archive.Manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest.json: %v", err)
+	}
+	for i, dir := range archive.Manifest.Dirs {
+		archive.Dirs[dir] = memfs.FS{}
+		prefix := path.Join("dirs", strconv.Itoa(i)) + "/"
+		for name, content := range files {
+			if rest := strings.TrimPrefix(name, prefix); rest != name && fs.ValidPath(rest) {
+				archive.Dirs[dir][rest] = content
+			}
+		}
+	}
+	return archive, nil
+}
@@ -0,0 +1,266 @@
+// Package report writes the machine-readable reports of validate, plan,
+// apply and status runs, to a file and to the destinations given with
+// --output-to.
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/imranansari/hcl2-demo/datcfg"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// ExitInterrupted is the exit status of a run cancelled by an interrupt or
+// a termination signal, like shells use for SIGINT.
+const ExitInterrupted = 130
+
+// Report is the machine-readable result of a validate, plan or apply run,
+// written with --report so that CI systems can archive and display it without
+// parsing the console output.
+type Report struct {
+	Command     string           `json:"command"`
+	Status      string           `json:"status"`
+	StartedAt   time.Time        `json:"started_at"`
+	DurationMS  float64          `json:"duration_ms"`
+	Provenance  Provenance       `json:"provenance"`
+	Inputs      map[string]Input `json:"inputs"`
+	Components  []Component      `json:"components"`
+	Diagnostics []Diagnostic     `json:"diagnostics"`
+	// Matrix are the reports of the runs of a --var-file-matrix, by values
+	// file.
+	Matrix map[string]*Report `json:"matrix,omitempty"`
+
+	path  string
+	sinks Sinks
+	// metadata are the metadata of the components of the loaded config,
+	// by address, added to their entries when the report is complete.
+	metadata map[string]datcfg.ComponentMetadata
+	// sizes are the estimated sizes of the components, by address.
+	sizes map[string]int64
+}
+
+// Provenance records what produced the result.
+type Provenance struct {
+	Version    string   `json:"version"`
+	Args       []string `json:"args"`
+	WorkingDir string   `json:"working_dir"`
+	Files      []string `json:"files"`
+	// Overrides are the attributes set with --set, by path.
+	Overrides map[string]string `json:"overrides,omitempty"`
+}
+
+// Input is the resolved value of a variable. The values of sensitive
+// and redacted variables are left out, and so are their sizes.
+type Input struct {
+	Value     json.RawMessage `json:"value,omitempty"`
+	Origin    string          `json:"origin"`
+	Sensitive bool            `json:"sensitive,omitempty"`
+	// SizeBytes is the estimated memory taken by the value, see
+	// datcfg.ValueSize.
+	SizeBytes int64 `json:"size_bytes,omitempty"`
+}
+
+type Component struct {
+	Address    string   `json:"address"`
+	Status     string   `json:"status"`
+	DurationMS float64  `json:"duration_ms"`
+	Attempts   int      `json:"attempts,omitempty"`
+	Reason     string   `json:"reason,omitempty"`
+	Actions    []Action `json:"actions,omitempty"`
+	// SizeBytes is the estimated memory taken by the config and outputs of
+	// the component, see datcfg.Component.Size.
+	SizeBytes int64 `json:"size_bytes,omitempty"`
+	// KnownAfterApply are the planned attributes whose values are unknown.
+	KnownAfterApply []string          `json:"known_after_apply,omitempty"`
+	Labels          map[string]string `json:"labels,omitempty"`
+	Annotations     map[string]string `json:"annotations,omitempty"`
+	Diagnostics     []Diagnostic      `json:"diagnostics,omitempty"`
+}
+
+type Action struct {
+	Type    datcfg.PlanActionType `json:"type"`
+	Address string                `json:"address"`
+	Detail  string                `json:"detail,omitempty"`
+}
+
+type Diagnostic struct {
+	Severity string `json:"severity"`
+	Summary  string `json:"summary"`
+	Detail   string `json:"detail,omitempty"`
+	Range    *Range `json:"range,omitempty"`
+}
+
+type Range struct {
+	Filename string `json:"filename"`
+	Start    Pos    `json:"start"`
+	End      Pos    `json:"end"`
+}
+
+type Pos struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// New starts the report of a run, which is only written if path is not
+// empty or there are sinks to write it to.
+func New(path, command string, args []string, sinks Sinks) *Report {
+	wd, _ := os.Getwd()
+	return &Report{
+		Command:   command,
+		StartedAt: time.Now(),
+		Provenance: Provenance{
+			Version:    datcfg.Version,
+			Args:       args,
+			WorkingDir: wd,
+			Files:      []string{},
+		},
+		Inputs:      map[string]Input{},
+		Components:  []Component{},
+		Diagnostics: []Diagnostic{},
+		path:        path,
+		sinks:       sinks,
+	}
+}
+
+// AddConfig records the files and resolved variables of the loaded config.
+func (r *Report) AddConfig(result *datcfg.Config) {
+	r.Provenance.Files = result.Files
+	r.metadata = map[string]datcfg.ComponentMetadata{}
+	r.sizes = map[string]int64{}
+	for i, component := range result.Components {
+		r.sizes[datcfg.ComponentAddress(result.Components, i)] = component.Size()
+		metadata := datcfg.ComponentMetadata{Labels: component.Metadata.Labels}
+		for key, val := range component.Metadata.Annotations {
+			if metadata.Annotations == nil {
+				metadata.Annotations = map[string]string{}
+			}
+			if result.Redacted(key) {
+				val = "(sensitive)"
+			}
+			metadata.Annotations[key] = val
+		}
+		r.metadata[datcfg.ComponentAddress(result.Components, i)] = metadata
+	}
+	r.Provenance.Overrides = result.RedactOverrides(result.Overrides)
+	r.Provenance.Args = redactOverrideArgs(r.Provenance.Args, result.Overrides, r.Provenance.Overrides)
+	for name, val := range result.Variables {
+		input := Input{Origin: "default", Sensitive: result.Sensitive[name]}
+		switch _, fromValues := result.Values[name]; {
+		case fromValues:
+			input.Origin = "values file"
+		case input.Sensitive:
+			input.Origin = "variable source"
+		}
+		input.Sensitive = input.Sensitive || result.Redacted(name)
+		if !input.Sensitive {
+			input.Value, _ = ctyjson.SimpleJSONValue{Value: result.RedactValue(val)}.MarshalJSON()
+			input.SizeBytes = datcfg.ValueSize(val)
+		}
+		r.Inputs[name] = input
+	}
+}
+
+// redactOverrideArgs returns args with the masked values of the --set flags
+// masked too.
+func redactOverrideArgs(args []string, overrides, masked map[string]string) []string {
+	redacted := make([]string, len(args))
+	for i, arg := range args {
+		if strings.HasPrefix(arg, "--set=") {
+			for path, value := range overrides {
+				if masked[path] != value {
+					arg = strings.Replace(arg, path+"="+value, path+"="+masked[path], -1)
+				}
+			}
+		}
+		redacted[i] = arg
+	}
+	return redacted
+}
+
+func (r *Report) AddDiags(diags hcl.Diagnostics) {
+	r.Diagnostics = append(r.Diagnostics, Diagnostics(diags)...)
+}
+
+// Actions converts the actions of a plan summary.
+func Actions(summary datcfg.PlanSummary) []Action {
+	var out []Action
+	for _, action := range summary.Actions {
+		out = append(out, Action{Type: action.Type, Address: action.Address, Detail: action.Detail})
+	}
+	return out
+}
+
+func Diagnostics(diags hcl.Diagnostics) []Diagnostic {
+	var out []Diagnostic
+	for _, diag := range diags {
+		severity := "error"
+		if diag.Severity == hcl.DiagWarning {
+			severity = "warning"
+		}
+		rd := Diagnostic{
+			Severity: severity,
+			Summary:  diag.Summary,
+			Detail:   diag.Detail,
+		}
+		if rng := diag.Subject; rng != nil {
+			rd.Range = &Range{
+				Filename: rng.Filename,
+				Start:    Pos{Line: rng.Start.Line, Column: rng.Start.Column},
+				End:      Pos{Line: rng.End.Line, Column: rng.End.Column},
+			}
+		}
+		out = append(out, rd)
+	}
+	return out
+}
+
+// Complete records the given exit status of the run and its duration.
+func (r *Report) Complete(status int) {
+	switch status {
+	case 0:
+		r.Status = "succeeded"
+	case ExitInterrupted:
+		r.Status = "interrupted"
+	default:
+		r.Status = "failed"
+	}
+	r.DurationMS = Milliseconds(time.Since(r.StartedAt))
+	for i := range r.Components {
+		metadata := r.metadata[r.Components[i].Address]
+		r.Components[i].Labels, r.Components[i].Annotations = metadata.Labels, metadata.Annotations
+		r.Components[i].SizeBytes = r.sizes[r.Components[i].Address]
+	}
+}
+
+// Finish writes the report, if requested, and returns the given exit status
+// of the run.
+func (r *Report) Finish(status int) int {
+	if r.path == "" && len(r.sinks) == 0 {
+		return status
+	}
+	r.Complete(status)
+
+	src, err := json.MarshalIndent(r, "", "  ")
+	if err == nil && r.path != "" {
+		err = os.WriteFile(r.path, append(src, '\n'), 0644)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write the run report: %s\n", err)
+		return 1
+	}
+	if !r.sinks.write(append(src, '\n')) {
+		return 1
+	}
+	return status
+}
+
+// Milliseconds returns d in fractional milliseconds, as durations are
+// reported.
+func Milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,280 @@
+package report
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Sinks are the destinations the report is written to besides its file,
+// as given with --output-to. It is a pflag.Value parsing the URIs.
+type Sinks []Sink
+
+// Sink is a destination of the run report. Writes that fail with a
+// temporary error are retried.
+type Sink interface {
+	// write stores the report, with checksum being its hex-encoded SHA-256.
+	write(content []byte, checksum string) (retry bool, err error)
+	String() string
+}
+
+const (
+	sinkAttempts = 3
+	sinkTimeout  = 30 * time.Second
+	// sinkRetryDelay is the delay before the second attempt of a write,
+	// which doubles with every attempt.
+	sinkRetryDelay = time.Second
+)
+
+var sinkClient = &http.Client{Timeout: sinkTimeout}
+
+// Set parses a --output-to URI and adds its sink.
+func (s *Sinks) Set(uri string) error {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return err
+	}
+	switch u.Scheme {
+	case "file":
+		name := u.Path
+		if u.Opaque != "" {
+			name = u.Opaque
+		}
+		if name == "" {
+			return fmt.Errorf("%s has no path", uri)
+		}
+		*s = append(*s, fileSink{path: filepath.FromSlash(name)})
+	case "http", "https":
+		*s = append(*s, httpSink{url: u.String()})
+	case "s3":
+		key := strings.TrimPrefix(u.Path, "/")
+		if u.Host == "" || key == "" {
+			return fmt.Errorf("%s must be like s3://bucket/key", uri)
+		}
+		*s = append(*s, s3Sink{bucket: u.Host, key: key})
+	default:
+		return fmt.Errorf("unsupported destination %q, expected a file://, s3:// or https:// URI", uri)
+	}
+	return nil
+}
+
+func (s *Sinks) String() string {
+	uris := make([]string, len(*s))
+	for i, sink := range *s {
+		uris[i] = sink.String()
+	}
+	return strings.Join(uris, ",")
+}
+
+func (s *Sinks) Type() string {
+	return "uri"
+}
+
+// write writes the report to all sinks, reporting the ones that failed on
+// stderr. It returns false if any did.
+func (s Sinks) write(content []byte) bool {
+	sum := sha256.Sum256(content)
+	checksum := hex.EncodeToString(sum[:])
+
+	ok := true
+	for _, sink := range s {
+		delay := sinkRetryDelay
+		for attempt := 1; ; attempt++ {
+			retry, err := sink.write(content, checksum)
+			if err == nil {
+				break
+			}
+			if !retry || attempt == sinkAttempts {
+				fmt.Fprintf(os.Stderr, "Failed to write the run report to %s: %s\n", sink, err)
+				ok = false
+				break
+			}
+			time.Sleep(delay)
+			delay *= 2
+		}
+	}
+	return ok
+}
+
+// fileSink writes the report to a local file, with its checksum in a
+// `.sha256` file next to it, in the format of sha256sum.
+type fileSink struct {
+	path string
+}
+
+func (s fileSink) write(content []byte, checksum string) (bool, error) {
+	if err := os.WriteFile(s.path, content, 0644); err != nil {
+		return false, err
+	}
+	line := fmt.Sprintf("%s  %s\n", checksum, filepath.Base(s.path))
+	return false, os.WriteFile(s.path+".sha256", []byte(line), 0644)
+}
+
+func (s fileSink) String() string {
+	return "file://" + filepath.ToSlash(s.path)
+}
+
+// httpSink posts the report to a URL, with its checksum in the
+// X-Checksum-Sha256 header.
+type httpSink struct {
+	url string
+}
+
+func (s httpSink) write(content []byte, checksum string) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(content))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Checksum-Sha256", checksum)
+	return sendSinkRequest(req)
+}
+
+func (s httpSink) String() string {
+	return s.url
+}
+
+// s3Sink uploads the report to an S3 bucket, with its checksum as the
+// SHA-256 checksum of the object, which S3 verifies. The credentials and the
+// region are taken from the usual AWS_* environment variables, and
+// AWS_ENDPOINT_URL_S3 points it to an S3-compatible store instead.
+type s3Sink struct {
+	bucket, key string
+}
+
+func (s s3Sink) write(content []byte, checksum string) (bool, error) {
+	accessKey, secretKey := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY")
+	if accessKey == "" || secretKey == "" {
+		return false, fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set")
+	}
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	// Buckets are addressed by their virtual host on AWS, and by path on
+	// other endpoints, which most S3-compatible stores expect.
+	endpoint := fmt.Sprintf("https://%s.s3.%s.amazonaws.com", s.bucket, region)
+	objectPath := "/" + s.key
+	if custom := os.Getenv("AWS_ENDPOINT_URL_S3"); custom != "" {
+		endpoint = strings.TrimSuffix(custom, "/")
+		objectPath = "/" + s.bucket + "/" + s.key
+	}
+	req, err := http.NewRequest(http.MethodPut, endpoint+awsURIEncode(objectPath), bytes.NewReader(content))
+	if err != nil {
+		return false, err
+	}
+	sum, _ := hex.DecodeString(checksum)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Amz-Checksum-Sha256", base64.StdEncoding.EncodeToString(sum))
+	req.Header.Set("X-Amz-Content-Sha256", checksum)
+	if token := os.Getenv("AWS_SESSION_TOKEN"); token != "" {
+		req.Header.Set("X-Amz-Security-Token", token)
+	}
+	signV4(req, accessKey, secretKey, region, "s3", checksum, time.Now().UTC())
+	return sendSinkRequest(req)
+}
+
+func (s s3Sink) String() string {
+	return "s3://" + s.bucket + "/" + s.key
+}
+
+// sendSinkRequest sends a request writing the report. Failures to connect,
+// throttling and server errors are retried.
+func sendSinkRequest(req *http.Request) (bool, error) {
+	resp, err := sinkClient.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		return false, nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	err = fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500, err
+}
+
+// signV4 signs the request with AWS Signature Version 4, over its host and
+// its X-Amz-* headers. payloadHash is the hex-encoded SHA-256 of the body.
+func signV4(req *http.Request, accessKey, secretKey, region, service, payloadHash string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, vals := range req.Header {
+		if lower := strings.ToLower(name); strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.TrimSpace(strings.Join(vals, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&canonicalHeaders, "%s:%s\n", name, headers[name])
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	scope := strings.Join([]string{date, region, service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, hex.EncodeToString(requestHash[:])}, "\n")
+
+	key := []byte("AWS4" + secretKey)
+	for _, part := range []string{date, region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signedHeaders, signature,
+	))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// awsURIEncode escapes a path as AWS signatures expect, which leaves only
+// the unreserved characters and slashes as they are.
+func awsURIEncode(p string) string {
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
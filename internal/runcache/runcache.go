@@ -0,0 +1,179 @@
+// Package runcache caches the output of commands whose result only depends
+// on the config files and the command line, so that running them again on
+// unchanged inputs replays the output instead of evaluating again.
+package runcache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/imranansari/hcl2-demo/datcfg"
+)
+
+// Dir is the directory, relative to the config directory, the entries are
+// stored in.
+const Dir = ".datcache"
+
+// ErrNotCacheable is returned by Key for configs whose results may differ
+// between runs on the same files and command line.
+var ErrNotCacheable = errors.New("the result of the configuration cannot be cached")
+
+// version is bumped whenever the content of entries or the way results are
+// produced changes incompatibly, invalidating all entries.
+const version = 1
+
+// entry is the recorded output of a command run.
+type entry struct {
+	Key string `json:"key"`
+	// Version is the datcfg.Version that recorded the entry.
+	Version string `json:"version"`
+	Stdout  string `json:"stdout"`
+	Stderr  string `json:"stderr"`
+	Status  int    `json:"status"`
+}
+
+// Run runs the command, recording its output and exit status in the
+// working directory under the given key, which is computed with Key. If the
+// entry of the command already has the key, the recorded output is replayed
+// instead.
+func Run(command, key string, run func(stdout, stderr io.Writer) int) int {
+	path := filepath.Join(Dir, command+".json")
+	if e, ok := readEntry(path); ok && e.Key == key {
+		fmt.Fprint(os.Stdout, e.Stdout)
+		fmt.Fprint(os.Stderr, e.Stderr)
+		return e.Status
+	}
+
+	var stdout, stderr bytes.Buffer
+	status := run(io.MultiWriter(os.Stdout, &stdout), io.MultiWriter(os.Stderr, &stderr))
+	writeEntry(path, entry{
+		Key:     key,
+		Version: datcfg.Version,
+		Stdout:  stdout.String(),
+		Stderr:  stderr.String(),
+		Status:  status,
+	})
+	return status
+}
+
+// Key hashes everything the result of a command can depend on: the
+// versions of the cache and the package, the command, the given inputs,
+// like its arguments, all files in the root of fsys and in its environments
+// and modules directories, and the environment variables the config files
+// refer to. If the result depends on more than that, the error is
+// ErrNotCacheable: when the config files refer to the env object as a
+// whole, call functions whose values differ between runs, like uuid, fetch
+// values from variable sources or run post_decode hooks, whose side effects
+// would be skipped on cache hits.
+func Key(fsys fs.FS, command string, inputs []string) (string, error) {
+	h := sha256.New()
+	fmt.Fprintf(h, "%d\x00%s\x00%s\x00", version, datcfg.Version, command)
+	for _, input := range inputs {
+		fmt.Fprintf(h, "%s\x00", input)
+	}
+
+	files, err := configFiles(fsys)
+	if err != nil {
+		return "", err
+	}
+	envNames, ok := datcfg.EnvReferences(files)
+	if !ok || len(datcfg.NondeterministicCalls(files)) > 0 || datcfg.HasValueSources(files) || datcfg.HasPostDecodeHooks(files) {
+		return "", ErrNotCacheable
+	}
+	for _, name := range envNames {
+		// Unset variables are told apart from empty ones.
+		val, set := os.LookupEnv(name)
+		fmt.Fprintf(h, "env\x00%s\x00%t\x00%s\x00", name, set, val)
+	}
+
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return "", err
+	}
+	var names []string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() {
+			names = append(names, entry.Name())
+		}
+	}
+	for _, dir := range []string{datcfg.EnvironmentsDir, datcfg.ModulesDir} {
+		err = fs.WalkDir(fsys, dir, func(name string, entry fs.DirEntry, err error) error {
+			if errors.Is(err, fs.ErrNotExist) && name == dir {
+				return fs.SkipDir
+			}
+			if err == nil && entry.Type().IsRegular() {
+				names = append(names, name)
+			}
+			return err
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+	for _, name := range names {
+		src, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00", name, len(src))
+		h.Write(src)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// configFiles parses the config files in the root of fsys and those of the
+// vendored modules.
+func configFiles(fsys fs.FS) ([]*hcl.File, error) {
+	files, diags := datcfg.ParseConfigFiles(fsys)
+	modules, moduleDiags := datcfg.DeclaredModules(files)
+	diags = append(diags, moduleDiags...)
+	for _, module := range modules {
+		moduleFS, err := fs.Sub(fsys, path.Join(datcfg.ModulesDir, module.Name))
+		if err != nil {
+			return nil, err
+		}
+		moduleFiles, moduleDiags := datcfg.ParseConfigFiles(moduleFS)
+		diags = append(diags, moduleDiags...)
+		files = append(files, moduleFiles...)
+	}
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	return files, nil
+}
+
+func readEntry(path string) (entry, bool) {
+	var e entry
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return e, false
+	}
+	if err := json.Unmarshal(src, &e); err != nil {
+		return e, false
+	}
+	return e, true
+}
+
+// writeEntry records the entry, ignoring errors, since a failure to write
+// the cache must not fail the command.
+func writeEntry(path string, e entry) {
+	src, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return
+	}
+	os.WriteFile(path, src, 0644)
+}
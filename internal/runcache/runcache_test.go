@@ -0,0 +1,115 @@
+package runcache
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"testing/fstest"
+)
+
+func configFS(src string) fstest.MapFS {
+	return fstest.MapFS{"cluster.datcfg": {Data: []byte(src)}}
+}
+
+func mustKey(t *testing.T, fsys fstest.MapFS) string {
+	t.Helper()
+	key, err := Key(fsys, "plan", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestKeyEnv(t *testing.T) {
+	fsys := configFS(`
+cluster "a" {
+  controller_count = 1
+  worker_count     = length(env.DAT_TEST_REGION) + length(env["DAT_TEST_ZONE"])
+}
+`)
+	t.Setenv("DAT_TEST_REGION", "eu-west-1")
+	t.Setenv("DAT_TEST_ZONE", "a")
+	key := mustKey(t, fsys)
+
+	t.Setenv("DAT_TEST_UNRELATED", "x")
+	if mustKey(t, fsys) != key {
+		t.Error("the key changed with a variable the config doesn't refer to")
+	}
+	t.Setenv("DAT_TEST_REGION", "us-east-1")
+	if mustKey(t, fsys) == key {
+		t.Error("the key didn't change with a variable the config refers to")
+	}
+	t.Setenv("DAT_TEST_REGION", "eu-west-1")
+	t.Setenv("DAT_TEST_ZONE", "b")
+	if mustKey(t, fsys) == key {
+		t.Error("the key didn't change with a variable the config indexes env with")
+	}
+}
+
+func TestKeyEnvUnset(t *testing.T) {
+	fsys := configFS(`cluster "a" { worker_count = env.DAT_TEST_UNSET }`)
+	t.Setenv("DAT_TEST_UNSET", "")
+	empty := mustKey(t, fsys)
+	// The variable is restored by the cleanup of Setenv.
+	os.Unsetenv("DAT_TEST_UNSET")
+	if mustKey(t, fsys) == empty {
+		t.Fatal("an unset variable has the key of an empty one")
+	}
+}
+
+func TestKeyWholeEnv(t *testing.T) {
+	fsys := configFS(`
+variable "name" {}
+
+cluster "a" { worker_count = env[var.name] }
+`)
+	if _, err := Key(fsys, "plan", nil); !errors.Is(err, ErrNotCacheable) {
+		t.Fatalf("got %v, want ErrNotCacheable", err)
+	}
+}
+
+func TestKeyNondeterministic(t *testing.T) {
+	for _, call := range []string{`uuid()`, `timestamp()`, `random_integer(1, 3)`, `acme::lookup("a")`} {
+		fsys := configFS(`cluster "a" { name = ` + call + ` }`)
+		if _, err := Key(fsys, "plan", nil); !errors.Is(err, ErrNotCacheable) {
+			t.Errorf("%s: got %v, want ErrNotCacheable", call, err)
+		}
+	}
+
+	fsys := configFS(`cluster "a" { name = upper("a") }`)
+	if _, err := Key(fsys, "plan", nil); err != nil {
+		t.Errorf("a config calling upper is not cacheable: %v", err)
+	}
+}
+
+func TestKeyExternalInputs(t *testing.T) {
+	for _, src := range []string{
+		`settings {
+  hooks {
+    post_decode = ["./notify"]
+  }
+}`,
+		`variable_source "vault" {
+  path = "secret/data/cluster"
+}`,
+	} {
+		if _, err := Key(configFS(src), "plan", nil); !errors.Is(err, ErrNotCacheable) {
+			t.Errorf("%s: got %v, want ErrNotCacheable", src, err)
+		}
+	}
+}
+
+func TestKeyInputs(t *testing.T) {
+	fsys := configFS(`cluster "a" {}`)
+	one, err := Key(fsys, "plan", []string{`set="cluster.name"="a,b=c"`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	two, err := Key(fsys, "plan", []string{`set="cluster.name"="a"`, `set="b"="c"`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if one == two {
+		t.Error("different overrides have the same key")
+	}
+}